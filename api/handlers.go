@@ -0,0 +1,471 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"gorm.io/gorm"
+)
+
+func (s *Server) listChains(w http.ResponseWriter, r *http.Request) {
+	var chains []models.Chain
+	if err := s.db.WithContext(r.Context()).Order("chain_id").Find(&chains).Error; err != nil {
+		writeInternalError(w, "Error querying chains", err)
+		return
+	}
+
+	resp := make([]chainResponse, 0, len(chains))
+	for _, chain := range chains {
+		resp = append(resp, chainResponse{ChainID: chain.ChainID, Name: chain.Name})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) listBlocks(w http.ResponseWriter, r *http.Request) {
+	chain, ok := s.chain(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := s.pagination(w, r)
+	if !ok {
+		return
+	}
+
+	var blocks []models.Block
+	query := s.db.WithContext(r.Context()).Preload("ProposerConsAddress").Where("chain_id = ?", chain.ID).Order("height DESC")
+	if err := paginate(query, page).Find(&blocks).Error; err != nil {
+		writeInternalError(w, "Error querying blocks", err)
+		return
+	}
+
+	resp := make([]blockResponse, 0, len(blocks))
+	for _, block := range blocks {
+		resp = append(resp, newBlockResponse(block))
+	}
+
+	writePage(w, resp, page)
+}
+
+func (s *Server) getBlock(w http.ResponseWriter, r *http.Request) {
+	chain, ok := s.chain(w, r)
+	if !ok {
+		return
+	}
+
+	height, err := strconv.ParseInt(r.PathValue("height"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "height must be an integer")
+		return
+	}
+
+	var block models.Block
+	err = s.db.WithContext(r.Context()).Preload("ProposerConsAddress").Where("chain_id = ? AND height = ?", chain.ID, height).First(&block).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, http.StatusNotFound, "block not found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "Error querying block", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newBlockResponse(block))
+}
+
+// txQuery selects the txes of the chain with everything needed for a txResponse
+func (s *Server) txQuery(ctx context.Context, chain models.Chain) *gorm.DB {
+	return s.db.WithContext(ctx).
+		Model(&models.Tx{}).
+		Joins("JOIN blocks ON blocks.id = txes.block_id").
+		Where("blocks.chain_id = ?", chain.ID).
+		Preload("Block").
+		Preload("SignerAddresses").
+		Preload("Fees.Denomination").
+		Preload("FeePayerAddress").
+		Preload("FeeGranterAddress")
+}
+
+func (s *Server) getTx(w http.ResponseWriter, r *http.Request) {
+	chain, ok := s.chain(w, r)
+	if !ok {
+		return
+	}
+
+	// Hashes are indexed as upper case hex
+	hash := strings.ToUpper(r.PathValue("hash"))
+
+	var tx models.Tx
+	err := s.txQuery(r.Context(), chain).Where("txes.hash = ?", hash).First(&tx).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, http.StatusNotFound, "tx not found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "Error querying tx", err)
+		return
+	}
+
+	resp := newTxResponse(tx)
+
+	var messages []models.Message
+	if err := s.db.WithContext(r.Context()).Preload("MessageType").Where("tx_id = ?", tx.ID).Order("message_index").Find(&messages).Error; err != nil {
+		writeInternalError(w, "Error querying tx messages", err)
+		return
+	}
+
+	messageIDs := make([]uint, 0, len(messages))
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+	}
+
+	messageEvents, err := s.messageEvents(r.Context(), messageIDs)
+	if err != nil {
+		writeInternalError(w, "Error querying message events", err)
+		return
+	}
+
+	for _, message := range messages {
+		message.Tx = tx
+		messageResp := newMessageResponse(message)
+		messageResp.Events = messageEvents[message.ID]
+		resp.Messages = append(resp.Messages, messageResp)
+	}
+
+	resp.Events, err = s.txEvents(r.Context(), tx.ID)
+	if err != nil {
+		writeInternalError(w, "Error querying tx events", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) listAddressTxs(w http.ResponseWriter, r *http.Request) {
+	chain, ok := s.chain(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := s.pagination(w, r)
+	if !ok {
+		return
+	}
+
+	var txs []models.Tx
+	query := s.txQuery(r.Context(), chain).
+		Joins("JOIN tx_signer_addresses ON tx_signer_addresses.tx_id = txes.id").
+		Joins("JOIN addresses ON addresses.id = tx_signer_addresses.address_id").
+		Where("addresses.address = ?", r.PathValue("address")).
+		Order("blocks.height DESC, txes.tx_index DESC")
+	if err := paginate(query, page).Find(&txs).Error; err != nil {
+		writeInternalError(w, "Error querying address txes", err)
+		return
+	}
+
+	resp := make([]txResponse, 0, len(txs))
+	for _, tx := range txs {
+		resp = append(resp, newTxResponse(tx))
+	}
+
+	writePage(w, resp, page)
+}
+
+func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
+	chain, ok := s.chain(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := s.pagination(w, r)
+	if !ok {
+		return
+	}
+
+	query := s.db.WithContext(r.Context()).
+		Model(&models.Message{}).
+		Joins("JOIN txes ON txes.id = messages.tx_id").
+		Joins("JOIN blocks ON blocks.id = txes.block_id").
+		Where("blocks.chain_id = ?", chain.ID).
+		Preload("Tx.Block").
+		Preload("MessageType").
+		Order("blocks.height DESC, txes.tx_index DESC, messages.message_index DESC")
+
+	if messageType := r.URL.Query().Get("type"); messageType != "" {
+		query = query.
+			Joins("JOIN message_types ON message_types.id = messages.message_type_id").
+			Where("message_types.message_type = ?", messageType)
+	}
+
+	var messages []models.Message
+	if err := paginate(query, page).Find(&messages).Error; err != nil {
+		writeInternalError(w, "Error querying messages", err)
+		return
+	}
+
+	resp := make([]messageResponse, 0, len(messages))
+	for _, message := range messages {
+		resp = append(resp, newMessageResponse(message))
+	}
+
+	writePage(w, resp, page)
+}
+
+func (s *Server) listBlockEvents(w http.ResponseWriter, r *http.Request) {
+	chain, ok := s.chain(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := s.pagination(w, r)
+	if !ok {
+		return
+	}
+
+	query := s.db.WithContext(r.Context()).
+		Model(&models.BlockEvent{}).
+		Joins("JOIN blocks ON blocks.id = block_events.block_id").
+		Where("blocks.chain_id = ?", chain.ID).
+		Preload("Block").
+		Preload("BlockEventType").
+		Order("blocks.height DESC, block_events.lifecycle_position, block_events.index")
+
+	if eventType := r.URL.Query().Get("type"); eventType != "" {
+		query = query.
+			Joins("JOIN block_event_types ON block_event_types.id = block_events.block_event_type_id").
+			Where("block_event_types.type = ?", eventType)
+	}
+
+	attributeKey := r.URL.Query().Get("attribute_key")
+	attributeValue := r.URL.Query().Get("attribute_value")
+	switch {
+	case attributeKey == "" && attributeValue != "":
+		writeError(w, http.StatusBadRequest, "attribute_value can only be used with attribute_key")
+		return
+	case attributeKey != "":
+		attributes := s.db.WithContext(r.Context()).
+			Table("block_event_attributes").
+			Select("1").
+			Joins("JOIN block_event_attribute_keys ON block_event_attribute_keys.id = block_event_attributes.block_event_attribute_key_id").
+			Where("block_event_attributes.block_event_id = block_events.id AND block_event_attribute_keys.key = ?", attributeKey)
+		if attributeValue != "" {
+			attributes = attributes.Where("block_event_attributes.value = ?", attributeValue)
+		}
+		query = query.Where("EXISTS (?)", attributes)
+	}
+
+	var blockEvents []models.BlockEvent
+	if err := paginate(query, page).Find(&blockEvents).Error; err != nil {
+		writeInternalError(w, "Error querying block events", err)
+		return
+	}
+
+	eventIDs := make([]uint, 0, len(blockEvents))
+	for _, blockEvent := range blockEvents {
+		eventIDs = append(eventIDs, blockEvent.ID)
+	}
+
+	var attributes []models.BlockEventAttribute
+	if len(eventIDs) != 0 {
+		if err := s.db.WithContext(r.Context()).Preload("BlockEventAttributeKey").Where("block_event_id IN ?", eventIDs).Order("block_event_id, index").Find(&attributes).Error; err != nil {
+			writeInternalError(w, "Error querying block event attributes", err)
+			return
+		}
+	}
+
+	eventAttributes := make(map[uint][]attributeResponse)
+	for _, attribute := range attributes {
+		eventAttributes[attribute.BlockEventID] = append(eventAttributes[attribute.BlockEventID], attributeResponse{Key: attribute.BlockEventAttributeKey.Key, Value: attribute.Value})
+	}
+
+	resp := make([]blockEventResponse, 0, len(blockEvents))
+	for _, blockEvent := range blockEvents {
+		resp = append(resp, blockEventResponse{
+			Height:            blockEvent.Block.Height,
+			LifecyclePosition: lifecyclePositionName(blockEvent.LifecyclePosition),
+			Index:             blockEvent.Index,
+			eventResponse:     newEventResponse(blockEvent.BlockEventType.Type, eventAttributes[blockEvent.ID]),
+		})
+	}
+
+	writePage(w, resp, page)
+}
+
+func (s *Server) listMessageParserErrors(w http.ResponseWriter, r *http.Request) {
+	chain, ok := s.chain(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := s.pagination(w, r)
+	if !ok {
+		return
+	}
+
+	query := s.db.WithContext(r.Context()).
+		Model(&models.MessageParserError{}).
+		Joins("JOIN messages ON messages.id = message_parser_errors.message_id").
+		Joins("JOIN txes ON txes.id = messages.tx_id").
+		Joins("JOIN blocks ON blocks.id = txes.block_id").
+		Where("blocks.chain_id = ?", chain.ID).
+		Preload("MessageParser").
+		Preload("Message.Tx.Block").
+		Preload("Message.MessageType").
+		Order("blocks.height DESC, message_parser_errors.id DESC")
+
+	if parser := r.URL.Query().Get("parser"); parser != "" {
+		query = query.
+			Joins("JOIN message_parsers ON message_parsers.id = message_parser_errors.message_parser_id").
+			Where("message_parsers.identifier = ?", parser)
+	}
+
+	var parserErrors []models.MessageParserError
+	if err := paginate(query, page).Find(&parserErrors).Error; err != nil {
+		writeInternalError(w, "Error querying message parser errors", err)
+		return
+	}
+
+	resp := make([]messageParserErrorResponse, 0, len(parserErrors))
+	for _, parserError := range parserErrors {
+		resp = append(resp, messageParserErrorResponse{
+			Parser:       parserError.MessageParser.Identifier,
+			TxHash:       parserError.Message.Tx.Hash,
+			Height:       parserError.Message.Tx.Block.Height,
+			MessageIndex: parserError.Message.MessageIndex,
+			MessageType:  parserError.Message.MessageType.MessageType,
+			Error:        parserError.Error,
+		})
+	}
+
+	writePage(w, resp, page)
+}
+
+func (s *Server) listBlockEventParserErrors(w http.ResponseWriter, r *http.Request) {
+	chain, ok := s.chain(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := s.pagination(w, r)
+	if !ok {
+		return
+	}
+
+	query := s.db.WithContext(r.Context()).
+		Model(&models.BlockEventParserError{}).
+		Joins("JOIN block_events ON block_events.id = block_event_parser_errors.block_event_id").
+		Joins("JOIN blocks ON blocks.id = block_events.block_id").
+		Where("blocks.chain_id = ?", chain.ID).
+		Preload("BlockEventParser").
+		Preload("BlockEvent.Block").
+		Preload("BlockEvent.BlockEventType").
+		Order("blocks.height DESC, block_event_parser_errors.id DESC")
+
+	if parser := r.URL.Query().Get("parser"); parser != "" {
+		query = query.
+			Joins("JOIN block_event_parsers ON block_event_parsers.id = block_event_parser_errors.block_event_parser_id").
+			Where("block_event_parsers.identifier = ?", parser)
+	}
+
+	var parserErrors []models.BlockEventParserError
+	if err := paginate(query, page).Find(&parserErrors).Error; err != nil {
+		writeInternalError(w, "Error querying block event parser errors", err)
+		return
+	}
+
+	resp := make([]blockEventParserErrorResponse, 0, len(parserErrors))
+	for _, parserError := range parserErrors {
+		resp = append(resp, blockEventParserErrorResponse{
+			Parser:            parserError.BlockEventParser.Identifier,
+			Height:            parserError.BlockEvent.Block.Height,
+			LifecyclePosition: lifecyclePositionName(parserError.BlockEvent.LifecyclePosition),
+			EventIndex:        parserError.BlockEvent.Index,
+			EventType:         parserError.BlockEvent.BlockEventType.Type,
+			Error:             parserError.Error,
+		})
+	}
+
+	writePage(w, resp, page)
+}
+
+// messageEvents loads the events of the messages with their attributes, keyed by message ID
+func (s *Server) messageEvents(ctx context.Context, messageIDs []uint) (map[uint][]eventResponse, error) {
+	events := make(map[uint][]eventResponse)
+	if len(messageIDs) == 0 {
+		return events, nil
+	}
+
+	var messageEvents []models.MessageEvent
+	if err := s.db.WithContext(ctx).Preload("MessageEventType").Where("message_id IN ?", messageIDs).Order("message_id, index").Find(&messageEvents).Error; err != nil {
+		return nil, err
+	}
+
+	eventIDs := make([]uint, 0, len(messageEvents))
+	for _, messageEvent := range messageEvents {
+		eventIDs = append(eventIDs, messageEvent.ID)
+	}
+
+	var attributes []models.MessageEventAttribute
+	if len(eventIDs) != 0 {
+		if err := s.db.WithContext(ctx).Preload("MessageEventAttributeKey").Where("message_event_id IN ?", eventIDs).Order("message_event_id, index").Find(&attributes).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	eventAttributes := make(map[uint][]attributeResponse)
+	for _, attribute := range attributes {
+		eventAttributes[attribute.MessageEventID] = append(eventAttributes[attribute.MessageEventID], attributeResponse{Key: attribute.MessageEventAttributeKey.Key, Value: attribute.Value})
+	}
+
+	for _, messageEvent := range messageEvents {
+		events[messageEvent.MessageID] = append(events[messageEvent.MessageID], newEventResponse(messageEvent.MessageEventType.Type, eventAttributes[messageEvent.ID]))
+	}
+
+	return events, nil
+}
+
+// txEvents loads the events of the tx that belong to no message with their attributes
+func (s *Server) txEvents(ctx context.Context, txID uint) ([]eventResponse, error) {
+	var txEvents []models.TxEvent
+	if err := s.db.WithContext(ctx).Preload("MessageEventType").Where("tx_id = ?", txID).Order("index").Find(&txEvents).Error; err != nil {
+		return nil, err
+	}
+
+	if len(txEvents) == 0 {
+		return nil, nil
+	}
+
+	eventIDs := make([]uint, 0, len(txEvents))
+	for _, txEvent := range txEvents {
+		eventIDs = append(eventIDs, txEvent.ID)
+	}
+
+	var attributes []models.TxEventAttribute
+	if err := s.db.WithContext(ctx).Preload("MessageEventAttributeKey").Where("tx_event_id IN ?", eventIDs).Order("tx_event_id, index").Find(&attributes).Error; err != nil {
+		return nil, err
+	}
+
+	eventAttributes := make(map[uint][]attributeResponse)
+	for _, attribute := range attributes {
+		eventAttributes[attribute.TxEventID] = append(eventAttributes[attribute.TxEventID], attributeResponse{Key: attribute.MessageEventAttributeKey.Key, Value: attribute.Value})
+	}
+
+	events := make([]eventResponse, 0, len(txEvents))
+	for _, txEvent := range txEvents {
+		events = append(events, newEventResponse(txEvent.MessageEventType.Type, eventAttributes[txEvent.ID]))
+	}
+
+	return events, nil
+}
+
+func newEventResponse(eventType string, attributes []attributeResponse) eventResponse {
+	if attributes == nil {
+		attributes = []attributeResponse{}
+	}
+	return eventResponse{Type: eventType, Attributes: attributes}
+}
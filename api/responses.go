@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+)
+
+type chainResponse struct {
+	ChainID string `json:"chain_id"`
+	Name    string `json:"name"`
+}
+
+type blockResponse struct {
+	Height             int64     `json:"height"`
+	Hash               string    `json:"hash"`
+	TimeStamp          time.Time `json:"time_stamp"`
+	ProposerAddress    string    `json:"proposer_address"`
+	AppHash            string    `json:"app_hash"`
+	LastBlockHash      string    `json:"last_block_hash"`
+	TxCount            int       `json:"tx_count"`
+	TxIndexed          bool      `json:"tx_indexed"`
+	BlockEventsIndexed bool      `json:"block_events_indexed"`
+}
+
+type coinResponse struct {
+	Denom  string `json:"denom"`
+	Amount string `json:"amount"`
+}
+
+type txResponse struct {
+	Hash          string         `json:"hash"`
+	Height        int64          `json:"height"`
+	TimeStamp     time.Time      `json:"time_stamp"`
+	TxIndex       int            `json:"tx_index"`
+	Code          uint32         `json:"code"`
+	Codespace     string         `json:"codespace,omitempty"`
+	RawLog        string         `json:"raw_log,omitempty"`
+	GasWanted     int64          `json:"gas_wanted"`
+	GasUsed       int64          `json:"gas_used"`
+	TimeoutHeight uint64         `json:"timeout_height"`
+	Memo          string         `json:"memo"`
+	Signers       []string       `json:"signers"`
+	Fees          []coinResponse `json:"fees"`
+	FeePayer      string         `json:"fee_payer,omitempty"`
+	FeeGranter    string         `json:"fee_granter,omitempty"`
+	// Messages and tx events are only included for single tx lookups
+	Messages []messageResponse `json:"messages,omitempty"`
+	Events   []eventResponse   `json:"events,omitempty"`
+}
+
+type messageResponse struct {
+	TxHash string `json:"tx_hash"`
+	Height int64  `json:"height"`
+	Index  int    `json:"index"`
+	Type   string `json:"type"`
+	// JSON is only set if the messages are indexed with flags.index-tx-message-json
+	JSON   json.RawMessage `json:"json,omitempty"`
+	Events []eventResponse `json:"events,omitempty"`
+}
+
+type eventResponse struct {
+	Type       string              `json:"type"`
+	Attributes []attributeResponse `json:"attributes"`
+}
+
+type attributeResponse struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type blockEventResponse struct {
+	Height            int64  `json:"height"`
+	LifecyclePosition string `json:"lifecycle_position"`
+	Index             uint64 `json:"index"`
+	eventResponse
+}
+
+type messageParserErrorResponse struct {
+	Parser       string `json:"parser"`
+	TxHash       string `json:"tx_hash"`
+	Height       int64  `json:"height"`
+	MessageIndex int    `json:"message_index"`
+	MessageType  string `json:"message_type"`
+	Error        string `json:"error"`
+}
+
+type blockEventParserErrorResponse struct {
+	Parser            string `json:"parser"`
+	Height            int64  `json:"height"`
+	LifecyclePosition string `json:"lifecycle_position"`
+	EventIndex        uint64 `json:"event_index"`
+	EventType         string `json:"event_type"`
+	Error             string `json:"error"`
+}
+
+func newBlockResponse(block models.Block) blockResponse {
+	return blockResponse{
+		Height:             block.Height,
+		Hash:               block.Hash,
+		TimeStamp:          block.TimeStamp,
+		ProposerAddress:    block.ProposerConsAddress.Address,
+		AppHash:            block.AppHash,
+		LastBlockHash:      block.LastBlockHash,
+		TxCount:            block.TxCount,
+		TxIndexed:          block.TxIndexed,
+		BlockEventsIndexed: block.BlockEventsIndexed,
+	}
+}
+
+func newTxResponse(tx models.Tx) txResponse {
+	resp := txResponse{
+		Hash:          tx.Hash,
+		Height:        tx.Block.Height,
+		TimeStamp:     tx.Block.TimeStamp,
+		TxIndex:       tx.TxIndex,
+		Code:          tx.Code,
+		Codespace:     tx.Codespace,
+		RawLog:        tx.RawLog,
+		GasWanted:     tx.GasWanted,
+		GasUsed:       tx.GasUsed,
+		TimeoutHeight: tx.TimeoutHeight,
+		Memo:          tx.Memo,
+		Signers:       []string{},
+		Fees:          []coinResponse{},
+	}
+
+	for _, signer := range tx.SignerAddresses {
+		resp.Signers = append(resp.Signers, signer.Address)
+	}
+
+	for _, fee := range tx.Fees {
+		resp.Fees = append(resp.Fees, coinResponse{Denom: fee.Denomination.Base, Amount: fee.Amount.String()})
+	}
+
+	if tx.FeePayerAddress != nil {
+		resp.FeePayer = tx.FeePayerAddress.Address
+	}
+
+	if tx.FeeGranterAddress != nil {
+		resp.FeeGranter = tx.FeeGranterAddress.Address
+	}
+
+	return resp
+}
+
+func newMessageResponse(message models.Message) messageResponse {
+	return messageResponse{
+		TxHash: message.Tx.Hash,
+		Height: message.Tx.Block.Height,
+		Index:  message.MessageIndex,
+		Type:   message.MessageType.MessageType,
+		JSON:   message.MessageJSON,
+	}
+}
+
+func lifecyclePositionName(position models.BlockLifecyclePosition) string {
+	if position == models.EndBlockEvent {
+		return "end_block"
+	}
+	return "begin_block"
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"gorm.io/gorm"
+)
+
+// Server serves read-only REST endpoints over the indexed data, every chain scoped endpoint is prefixed with the chain ID of the chain
+type Server struct {
+	db              *gorm.DB
+	defaultPageSize int
+	maxPageSize     int
+}
+
+func NewServer(db *gorm.DB, defaultPageSize int, maxPageSize int) *Server {
+	return &Server{
+		db:              db,
+		defaultPageSize: defaultPageSize,
+		maxPageSize:     maxPageSize,
+	}
+}
+
+// Handler routes the API endpoints, only GET requests are accepted
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /chains", s.listChains)
+	mux.HandleFunc("GET /chains/{chain_id}/blocks", s.listBlocks)
+	mux.HandleFunc("GET /chains/{chain_id}/blocks/{height}", s.getBlock)
+	mux.HandleFunc("GET /chains/{chain_id}/txs/{hash}", s.getTx)
+	mux.HandleFunc("GET /chains/{chain_id}/addresses/{address}/txs", s.listAddressTxs)
+	mux.HandleFunc("GET /chains/{chain_id}/messages", s.listMessages)
+	mux.HandleFunc("GET /chains/{chain_id}/block-events", s.listBlockEvents)
+	mux.HandleFunc("GET /chains/{chain_id}/parser-errors/messages", s.listMessageParserErrors)
+	mux.HandleFunc("GET /chains/{chain_id}/parser-errors/block-events", s.listBlockEventParserErrors)
+	return mux
+}
+
+// StartServer serves the API at the passed in address until the context is cancelled
+func StartServer(ctx context.Context, address string, server *Server) error {
+	httpServer := &http.Server{
+		Addr:              address,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			config.Log.Error("Error shutting down API server", err)
+		}
+	}()
+
+	config.Log.Infof("Serving the REST API on %s", address)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+type pagination struct {
+	Page    int  `json:"page"`
+	Limit   int  `json:"limit"`
+	HasMore bool `json:"has_more"`
+}
+
+type pageResponse[T any] struct {
+	Data       []T        `json:"data"`
+	Pagination pagination `json:"pagination"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// pagination reads the 1-based page and the page size limit query parameters, writing a bad request response if they are invalid
+func (s *Server) pagination(w http.ResponseWriter, r *http.Request) (pagination, bool) {
+	page := pagination{Page: 1, Limit: s.defaultPageSize}
+
+	if value := r.URL.Query().Get("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			writeError(w, http.StatusBadRequest, "page must be a positive integer")
+			return page, false
+		}
+		page.Page = parsed
+	}
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > s.maxPageSize {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(s.maxPageSize))
+			return page, false
+		}
+		page.Limit = parsed
+	}
+
+	return page, true
+}
+
+// paginate applies the page to the query, fetching one extra row to know if there are more pages
+func paginate(query *gorm.DB, page pagination) *gorm.DB {
+	return query.Offset((page.Page - 1) * page.Limit).Limit(page.Limit + 1)
+}
+
+// writePage writes the rows of a page queried with paginate, dropping the extra row
+func writePage[T any](w http.ResponseWriter, rows []T, page pagination) {
+	if len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+		page.HasMore = true
+	}
+
+	if rows == nil {
+		rows = []T{}
+	}
+
+	writeJSON(w, http.StatusOK, pageResponse[T]{Data: rows, Pagination: page})
+}
+
+// chain looks up the chain of the chain_id path value, writing a not found response if the chain has not been indexed
+func (s *Server) chain(w http.ResponseWriter, r *http.Request) (models.Chain, bool) {
+	var chain models.Chain
+	err := s.db.WithContext(r.Context()).Where("chain_id = ?", r.PathValue("chain_id")).First(&chain).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, http.StatusNotFound, "chain not found")
+		return chain, false
+	}
+	if err != nil {
+		writeInternalError(w, "Error querying chain", err)
+		return chain, false
+	}
+
+	return chain, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		config.Log.Error("Error writing API response", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+// writeInternalError logs the error and hides it from the client
+func writeInternalError(w http.ResponseWriter, message string, err error) {
+	config.Log.Error(message, err)
+	writeError(w, http.StatusInternalServerError, "internal server error")
+}
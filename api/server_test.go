@@ -0,0 +1,294 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	dbTypes "github.com/DefiantLabs/cosmos-indexer/db"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"github.com/ory/dockertest/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type APITestSuite struct {
+	suite.Suite
+	db      *gorm.DB
+	clean   func()
+	handler http.Handler
+}
+
+func (suite *APITestSuite) SetupTest() {
+	clean, db, err := setupTestDatabase()
+	suite.Require().NoError(err)
+
+	suite.db = db
+	suite.clean = clean
+
+	suite.Require().NoError(dbTypes.MigrateModels(suite.db))
+	suite.seed()
+
+	suite.handler = NewServer(suite.db, 2, 3).Handler()
+}
+
+func (suite *APITestSuite) TearDownTest() {
+	if suite.clean != nil {
+		suite.clean()
+	}
+
+	suite.db = nil
+	suite.clean = nil
+}
+
+func setupTestDatabase() (func(), *gorm.DB, error) {
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = pool.Client.Ping()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resource, err := pool.Run("postgres", "15-alpine", []string{"POSTGRES_USER=test", "POSTGRES_PASSWORD=test", "POSTGRES_DB=test"})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var db *gorm.DB
+	if err := pool.Retry(func() error {
+		var err error
+		db, err = dbTypes.PostgresDbConnect(resource.GetBoundIP("5432/tcp"), resource.GetPort("5432/tcp"), "test", "test", "test", "debug")
+		return err
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	clean := func() {
+		if err := pool.Purge(resource); err != nil {
+			log.Fatalf("Could not purge resource: %s", err)
+		}
+	}
+
+	return clean, db, nil
+}
+
+// seed indexes 5 blocks on chain-a and 2 blocks on chain-b, each block has a transfer block event and chain-a block 1 has a tx
+func (suite *APITestSuite) seed() {
+	transfer := models.BlockEventType{Type: "transfer"}
+	recipient := models.BlockEventAttributeKey{Key: "recipient"}
+
+	for chainID, heights := range map[string]int64{"chain-a": 5, "chain-b": 2} {
+		chain := models.Chain{ChainID: chainID, Name: chainID}
+		suite.Require().NoError(suite.db.Create(&chain).Error)
+
+		for height := int64(1); height <= heights; height++ {
+			// Odd heights transfer to alice, even heights to bob
+			recipientValue := "alice"
+			if height%2 == 0 {
+				recipientValue = "bob"
+			}
+
+			block := &models.Block{Height: height, ChainID: chain.ID, TimeStamp: time.Unix(height, 0).UTC(), ProposerConsAddress: models.Address{Address: chainID + "valcons"}}
+			_, err := dbTypes.IndexBlockEvents(suite.db, false, &dbTypes.BlockDBWrapper{
+				Block: block,
+				BeginBlockEvents: []dbTypes.BlockEventDBWrapper{{
+					BlockEvent: models.BlockEvent{Index: 0, LifecyclePosition: models.BeginBlockEvent, BlockEventType: transfer},
+					Attributes: []models.BlockEventAttribute{{Index: 0, Value: recipientValue, BlockEventAttributeKey: recipient}},
+				}},
+				UniqueBlockEventTypes:         map[string]models.BlockEventType{transfer.Type: transfer},
+				UniqueBlockEventAttributeKeys: map[string]models.BlockEventAttributeKey{recipient.Key: recipient},
+			}, "")
+			suite.Require().NoError(err)
+		}
+
+		if chainID == "chain-a" {
+			indexerConfig := config.IndexConfig{}
+			indexerConfig.Flags.IndexEmptyTransactions = true
+
+			block := models.Block{Height: 1, ChainID: chain.ID, TimeStamp: time.Unix(1, 0).UTC(), ProposerConsAddress: models.Address{Address: chainID + "valcons"}}
+			_, _, err := dbTypes.IndexNewBlock(suite.db, block, []dbTypes.TxDBWrapper{{Tx: models.Tx{Hash: "ABCDEF"}}}, nil, indexerConfig)
+			suite.Require().NoError(err)
+		}
+	}
+}
+
+// get serves the request and decodes the JSON response body into the response value
+func (suite *APITestSuite) get(path string, response any) int {
+	recorder := httptest.NewRecorder()
+	suite.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	suite.Require().Equal("application/json", recorder.Header().Get("Content-Type"))
+	suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), response))
+	return recorder.Code
+}
+
+func (suite *APITestSuite) TestListChains() {
+	var chains []chainResponse
+	suite.Require().Equal(http.StatusOK, suite.get("/chains", &chains))
+	suite.Require().Equal([]chainResponse{{ChainID: "chain-a", Name: "chain-a"}, {ChainID: "chain-b", Name: "chain-b"}}, chains)
+}
+
+func (suite *APITestSuite) TestPagination() {
+	tests := []struct {
+		path    string
+		status  int
+		heights []int64
+		hasMore bool
+	}{
+		{path: "/chains/chain-a/blocks", status: http.StatusOK, heights: []int64{5, 4}, hasMore: true},
+		{path: "/chains/chain-a/blocks?page=2", status: http.StatusOK, heights: []int64{3, 2}, hasMore: true},
+		{path: "/chains/chain-a/blocks?page=3", status: http.StatusOK, heights: []int64{1}, hasMore: false},
+		{path: "/chains/chain-a/blocks?page=4", status: http.StatusOK, heights: []int64{}, hasMore: false},
+		{path: "/chains/chain-a/blocks?limit=3", status: http.StatusOK, heights: []int64{5, 4, 3}, hasMore: true},
+		{path: "/chains/chain-a/blocks?limit=3&page=2", status: http.StatusOK, heights: []int64{2, 1}, hasMore: false},
+		{path: "/chains/chain-a/blocks?limit=4", status: http.StatusBadRequest},
+		{path: "/chains/chain-a/blocks?limit=0", status: http.StatusBadRequest},
+		{path: "/chains/chain-a/blocks?page=0", status: http.StatusBadRequest},
+		{path: "/chains/chain-a/blocks?page=-1", status: http.StatusBadRequest},
+		{path: "/chains/chain-a/blocks?page=first", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.path, func() {
+			var resp struct {
+				pageResponse[blockResponse]
+				errorResponse
+			}
+			suite.Require().Equal(tt.status, suite.get(tt.path, &resp))
+			if tt.status != http.StatusOK {
+				suite.Require().NotEmpty(resp.Error)
+				return
+			}
+
+			heights := []int64{}
+			for _, block := range resp.Data {
+				heights = append(heights, block.Height)
+			}
+			suite.Require().Equal(tt.heights, heights)
+			suite.Require().Equal(tt.hasMore, resp.Pagination.HasMore)
+		})
+	}
+}
+
+func (suite *APITestSuite) TestNotFound() {
+	tests := []struct {
+		path  string
+		error string
+	}{
+		{path: "/chains/chain-c/blocks", error: "chain not found"},
+		{path: "/chains/chain-c/block-events", error: "chain not found"},
+		{path: "/chains/chain-a/blocks/6", error: "block not found"},
+		{path: "/chains/chain-b/blocks/3", error: "block not found"},
+		{path: "/chains/chain-a/txs/012345", error: "tx not found"},
+		// Txes are scoped to the chain of the path
+		{path: "/chains/chain-b/txs/ABCDEF", error: "tx not found"},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.path, func() {
+			var resp errorResponse
+			suite.Require().Equal(http.StatusNotFound, suite.get(tt.path, &resp))
+			suite.Require().Equal(tt.error, resp.Error)
+		})
+	}
+}
+
+func (suite *APITestSuite) TestGetBlockAndTx() {
+	var block blockResponse
+	suite.Require().Equal(http.StatusOK, suite.get("/chains/chain-b/blocks/2", &block))
+	suite.Require().Equal(int64(2), block.Height)
+	suite.Require().Equal("chain-bvalcons", block.ProposerAddress)
+
+	var errResp errorResponse
+	suite.Require().Equal(http.StatusBadRequest, suite.get("/chains/chain-a/blocks/latest", &errResp))
+
+	// Hashes are matched case insensitively
+	var tx txResponse
+	suite.Require().Equal(http.StatusOK, suite.get("/chains/chain-a/txs/abcdef", &tx))
+	suite.Require().Equal("ABCDEF", tx.Hash)
+	suite.Require().Equal(int64(1), tx.Height)
+}
+
+func (suite *APITestSuite) TestListBlockEvents() {
+	tests := []struct {
+		path    string
+		status  int
+		heights []int64
+	}{
+		{path: "/chains/chain-a/block-events?limit=3", status: http.StatusOK, heights: []int64{5, 4, 3}},
+		{path: "/chains/chain-a/block-events?type=transfer&limit=3", status: http.StatusOK, heights: []int64{5, 4, 3}},
+		{path: "/chains/chain-a/block-events?type=burn", status: http.StatusOK, heights: []int64{}},
+		{path: "/chains/chain-a/block-events?attribute_key=recipient&attribute_value=bob", status: http.StatusOK, heights: []int64{4, 2}},
+		{path: "/chains/chain-a/block-events?attribute_key=recipient&attribute_value=alice&limit=3", status: http.StatusOK, heights: []int64{5, 3, 1}},
+		{path: "/chains/chain-a/block-events?attribute_key=sender", status: http.StatusOK, heights: []int64{}},
+		// Events are scoped to the chain of the path
+		{path: "/chains/chain-b/block-events?attribute_key=recipient&attribute_value=alice", status: http.StatusOK, heights: []int64{1}},
+		{path: "/chains/chain-a/block-events?attribute_value=bob", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.path, func() {
+			var resp struct {
+				pageResponse[blockEventResponse]
+				errorResponse
+			}
+			suite.Require().Equal(tt.status, suite.get(tt.path, &resp))
+			if tt.status != http.StatusOK {
+				suite.Require().Equal("attribute_value can only be used with attribute_key", resp.Error)
+				return
+			}
+
+			heights := []int64{}
+			for _, event := range resp.Data {
+				heights = append(heights, event.Height)
+				suite.Require().Equal("transfer", event.Type)
+				suite.Require().Equal("begin_block", event.LifecyclePosition)
+				suite.Require().Len(event.Attributes, 1)
+			}
+			suite.Require().Equal(tt.heights, heights)
+		})
+	}
+}
+
+func TestAPISuite(t *testing.T) {
+	suite.Run(t, new(APITestSuite))
+}
+
+func TestPaginationParameters(t *testing.T) {
+	server := NewServer(nil, 10, 100)
+
+	tests := []struct {
+		query    string
+		ok       bool
+		expected pagination
+	}{
+		{query: "", ok: true, expected: pagination{Page: 1, Limit: 10}},
+		{query: "page=3&limit=100", ok: true, expected: pagination{Page: 3, Limit: 100}},
+		{query: "limit=1", ok: true, expected: pagination{Page: 1, Limit: 1}},
+		{query: "limit=101", ok: false},
+		{query: "limit=0", ok: false},
+		{query: "limit=ten", ok: false},
+		{query: "page=0", ok: false},
+		{query: "page=1.5", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			page, ok := server.pagination(recorder, httptest.NewRequest(http.MethodGet, "/chains/chain-a/blocks?"+tt.query, nil))
+			require.Equal(t, tt.ok, ok)
+			if !tt.ok {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				return
+			}
+			require.Equal(t, tt.expected, page)
+		})
+	}
+}
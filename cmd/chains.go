@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	indexerPackage "github.com/DefiantLabs/cosmos-indexer/indexer"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// loadChainConfigs builds an index config for every [[chains]] entry in the config file, returning nil if no chains are listed.
+// Values in a chain entry take precedence over command line flags, which take precedence over the top level config file values.
+func loadChainConfigs(cmd *cobra.Command, v *viper.Viper) ([]*config.IndexConfig, error) {
+	if !v.IsSet("chains") {
+		return nil, nil
+	}
+
+	var chains []map[string]any
+	if err := v.UnmarshalKey("chains", &chains); err != nil {
+		return nil, fmt.Errorf("failed to parse chains config: %w", err)
+	}
+
+	var chainConfigs []*config.IndexConfig
+	for index, chain := range chains {
+		chainViper := viper.New()
+		if err := chainViper.MergeConfigMap(chain); err != nil {
+			return nil, fmt.Errorf("failed to parse config of chain %d: %w", index, err)
+		}
+
+		if ignoredKeys := config.CheckSuperfluousChainKeys(chainViper.AllKeys()); len(ignoredKeys) > 0 {
+			return nil, fmt.Errorf("chain %d has keys that can not be set per chain: %v", index, ignoredKeys)
+		}
+
+		// Bind the chain config to its own flag set so the chain values are parsed and defaulted the same way as the command line flags
+		chainConfig := &config.IndexConfig{}
+		chainCmd := &cobra.Command{}
+		setupIndexFlags(chainConfig, chainCmd)
+		if err := chainCmd.ParseFlags(nil); err != nil {
+			return nil, err
+		}
+
+		BindFlags(chainCmd, chainViper)
+
+		var err error
+		cmd.Flags().Visit(func(f *pflag.Flag) {
+			chainFlag := chainCmd.Flags().Lookup(f.Name)
+			if err != nil || chainFlag == nil || chainFlag.Changed {
+				return
+			}
+
+			value := f.Value.String()
+			if sliceValue, ok := f.Value.(pflag.SliceValue); ok {
+				value = strings.Join(sliceValue.GetSlice(), ",")
+			}
+			err = chainCmd.Flags().Set(f.Name, value)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply command line flags to chain %d: %w", index, err)
+		}
+
+		BindFlags(chainCmd, v)
+
+		chainConfigs = append(chainConfigs, chainConfig)
+	}
+
+	return chainConfigs, nil
+}
+
+// validateChainConfigs validates the config of every chain, and checks that the chain IDs are unique and the account prefixes are shared
+func validateChainConfigs(chainConfigs []*config.IndexConfig) error {
+	chainIDs := make(map[string]struct{})
+	for _, chainConfig := range chainConfigs {
+		err := chainConfig.Validate()
+		if err != nil {
+			if len(chainConfigs) > 1 {
+				return fmt.Errorf("chain %s: %w", chainConfig.Probe.ChainID, err)
+			}
+			return err
+		}
+
+		if _, ok := chainIDs[chainConfig.Probe.ChainID]; ok {
+			return fmt.Errorf("chain %s is configured more than once", chainConfig.Probe.ChainID)
+		}
+		chainIDs[chainConfig.Probe.ChainID] = struct{}{}
+
+		// The Cosmos SDK address prefixes are process wide, addresses of a chain with another prefix would be encoded with the wrong one
+		if prefix := chainConfigs[0].Probe.AccountPrefix; chainConfig.Probe.AccountPrefix != prefix {
+			return fmt.Errorf("chain %s: account prefix %s differs from the prefix %s of chain %s, all chains indexed by a process must share the account prefix", chainConfig.Probe.ChainID, chainConfig.Probe.AccountPrefix, prefix, chainConfigs[0].Probe.ChainID)
+		}
+
+		// 0 is an invalid starting block, set it to 1
+		if chainConfig.Base.StartBlock == 0 {
+			chainConfig.Base.StartBlock = 1
+		}
+	}
+
+	return nil
+}
+
+// runChains runs the pipeline of every chain concurrently until they all return, joining the errors of the chains.
+// Each chain runs under its own context, the stopChain function passed to a chain only cancels that chain so a chain
+// finishing early or failing does not stop the others. The chains are only stopped together by cancelling the parent context.
+func runChains(ctx context.Context, indexers []*indexerPackage.Indexer, run func(ctx context.Context, idxr *indexerPackage.Indexer, stopChain context.CancelCauseFunc) error) error {
+	var chainsWg sync.WaitGroup
+	chainErrs := make([]error, len(indexers))
+	for i, idxr := range indexers {
+		chainsWg.Add(1)
+		go func(i int, idxr *indexerPackage.Indexer) {
+			defer chainsWg.Done()
+
+			chainCtx, stopChain := context.WithCancelCause(ctx)
+			defer stopChain(nil)
+
+			if err := run(chainCtx, idxr, stopChain); err != nil {
+				chainErrs[i] = fmt.Errorf("chain %s: %w", idxr.Config.Probe.ChainID, err)
+			}
+		}(i, idxr)
+	}
+	chainsWg.Wait()
+
+	return errors.Join(chainErrs...)
+}
@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	indexerPackage "github.com/DefiantLabs/cosmos-indexer/indexer"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+// Top level sections shared by the chains of the test configs
+const baseTestConfig = `
+[database]
+host = "localhost"
+port = "5432"
+database = "indexer"
+user = "indexer"
+password = "indexer"
+log-level = "info"
+
+[log]
+level = "info"
+
+[base]
+start-block = 5
+end-block = 100
+index-transactions = true
+rpc-workers = 2
+rpc-requests-per-second = 10
+
+[probe]
+rpc = "http://base.rpc:26657"
+account-prefix = "cosmos"
+chain-id = "base-1"
+chain-name = "Base"
+`
+
+// loadTestChainConfigs loads the chain configs of the config file the way the index command does, with the command line args applied
+func loadTestChainConfigs(t *testing.T, configFile string, args ...string) ([]*config.IndexConfig, error) {
+	conf := &config.IndexConfig{}
+	cmd := &cobra.Command{}
+	setupIndexFlags(conf, cmd)
+	require.NoError(t, cmd.ParseFlags(args))
+
+	v := viper.New()
+	v.SetConfigType("toml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(configFile)))
+	BindFlags(cmd, v)
+
+	chainConfigs, err := loadChainConfigs(cmd, v)
+	if err != nil {
+		return nil, err
+	}
+	return chainConfigs, validateChainConfigs(chainConfigs)
+}
+
+func TestLoadChainConfigs(t *testing.T) {
+	tests := []struct {
+		name   string
+		chains string
+		args   []string
+		err    string
+		check  func(t *testing.T, chainConfigs []*config.IndexConfig)
+	}{
+		{
+			name: "no chains",
+			check: func(t *testing.T, chainConfigs []*config.IndexConfig) {
+				require.Nil(t, chainConfigs)
+			},
+		},
+		{
+			name: "multiple chains",
+			chains: `
+[[chains]]
+[chains.probe]
+rpc = "http://hub.rpc:26657"
+chain-id = "cosmoshub-4"
+chain-name = "CosmosHub"
+[chains.base]
+rpc-workers = 4
+
+[[chains]]
+[chains.probe]
+rpc = "http://testnet.rpc:26657"
+chain-id = "theta-testnet-001"
+chain-name = "Testnet"
+[chains.base]
+index-block-events = true
+`,
+			check: func(t *testing.T, chainConfigs []*config.IndexConfig) {
+				require.Len(t, chainConfigs, 2)
+
+				require.Equal(t, "cosmoshub-4", chainConfigs[0].Probe.ChainID)
+				require.Equal(t, "http://hub.rpc:26657", chainConfigs[0].Probe.RPC)
+				require.Equal(t, int64(4), chainConfigs[0].Base.RPCWorkers)
+				require.False(t, chainConfigs[0].Base.BlockEventIndexingEnabled)
+
+				require.Equal(t, "theta-testnet-001", chainConfigs[1].Probe.ChainID)
+				require.Equal(t, "Testnet", chainConfigs[1].Probe.ChainName)
+				require.Equal(t, int64(2), chainConfigs[1].Base.RPCWorkers)
+				require.True(t, chainConfigs[1].Base.BlockEventIndexingEnabled)
+			},
+		},
+		{
+			name: "defaults inherited from base",
+			chains: `
+[[chains]]
+[chains.probe]
+chain-id = "cosmoshub-4"
+`,
+			check: func(t *testing.T, chainConfigs []*config.IndexConfig) {
+				require.Len(t, chainConfigs, 1)
+				chainConfig := chainConfigs[0]
+
+				// Unset chain values fall back to the top level sections
+				require.Equal(t, "cosmoshub-4", chainConfig.Probe.ChainID)
+				require.Equal(t, "http://base.rpc:26657", chainConfig.Probe.RPC)
+				require.Equal(t, "cosmos", chainConfig.Probe.AccountPrefix)
+				require.Equal(t, int64(5), chainConfig.Base.StartBlock)
+				require.Equal(t, int64(100), chainConfig.Base.EndBlock)
+				require.True(t, chainConfig.Base.TransactionIndexingEnabled)
+				require.Equal(t, int64(2), chainConfig.Base.RPCWorkers)
+				require.Equal(t, 10.0, chainConfig.Base.RPCRequestsPerSecond)
+				require.Equal(t, "localhost", chainConfig.Database.Host)
+
+				// Values unset everywhere get the flag defaults
+				require.Equal(t, int64(1), chainConfig.Base.ProcessingWorkers)
+				require.Equal(t, int64(10000), chainConfig.Base.BlockTimer)
+			},
+		},
+		{
+			name: "command line flags override base but not the chain",
+			chains: `
+[[chains]]
+[chains.probe]
+chain-id = "cosmoshub-4"
+[chains.base]
+rpc-workers = 4
+
+[[chains]]
+[chains.probe]
+chain-id = "theta-testnet-001"
+`,
+			args: []string{"--base.rpc-workers=8", "--base.end-block=50"},
+			check: func(t *testing.T, chainConfigs []*config.IndexConfig) {
+				require.Len(t, chainConfigs, 2)
+				require.Equal(t, int64(4), chainConfigs[0].Base.RPCWorkers)
+				require.Equal(t, int64(8), chainConfigs[1].Base.RPCWorkers)
+				require.Equal(t, int64(50), chainConfigs[0].Base.EndBlock)
+				require.Equal(t, int64(50), chainConfigs[1].Base.EndBlock)
+			},
+		},
+		{
+			name: "differing account prefix rejected",
+			chains: `
+[[chains]]
+[chains.probe]
+chain-id = "cosmoshub-4"
+
+[[chains]]
+[chains.probe]
+chain-id = "osmosis-1"
+account-prefix = "osmo"
+`,
+			err: "chain osmosis-1: account prefix osmo differs from the prefix cosmos of chain cosmoshub-4",
+		},
+		{
+			name: "duplicate chain id rejected",
+			chains: `
+[[chains]]
+[chains.probe]
+chain-id = "cosmoshub-4"
+
+[[chains]]
+[chains.probe]
+chain-id = "cosmoshub-4"
+rpc = "http://other.rpc:26657"
+`,
+			err: "chain cosmoshub-4 is configured more than once",
+		},
+		{
+			name: "chain keys that can not be set per chain rejected",
+			chains: `
+[[chains]]
+[chains.probe]
+chain-id = "cosmoshub-4"
+[chains.database]
+host = "other"
+`,
+			err: "chain 0 has keys that can not be set per chain: [database.host]",
+		},
+		{
+			name: "invalid chain config rejected with its chain id",
+			chains: `
+[[chains]]
+[chains.probe]
+chain-id = "cosmoshub-4"
+
+[[chains]]
+[chains.probe]
+chain-id = "theta-testnet-001"
+[chains.base]
+end-block = 1
+`,
+			err: "chain theta-testnet-001:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chainConfigs, err := loadTestChainConfigs(t, baseTestConfig+tt.chains, tt.args...)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			tt.check(t, chainConfigs)
+		})
+	}
+}
+
+func TestRunChainsStopChainIsolation(t *testing.T) {
+	indexers := []*indexerPackage.Indexer{
+		{Config: &config.IndexConfig{Probe: config.Probe{ChainID: "failing-1"}}},
+		{Config: &config.IndexConfig{Probe: config.Probe{ChainID: "healthy-1"}}},
+	}
+
+	chainErr := errors.New("db write failed")
+	failed := make(chan struct{})
+
+	err := runChains(context.Background(), indexers, func(ctx context.Context, idxr *indexerPackage.Indexer, stopChain context.CancelCauseFunc) error {
+		if idxr.Config.Probe.ChainID == "failing-1" {
+			stopChain(chainErr)
+			close(failed)
+			return context.Cause(ctx)
+		}
+
+		// The other chain keeps running after the failing chain was stopped
+		<-failed
+		select {
+		case <-ctx.Done():
+			return errors.New("chain was stopped by another chain")
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		}
+	})
+
+	require.ErrorIs(t, err, chainErr)
+	require.EqualError(t, err, "chain failing-1: db write failed")
+}
+
+func TestRunChainsParentCancel(t *testing.T) {
+	indexers := []*indexerPackage.Indexer{
+		{Config: &config.IndexConfig{Probe: config.Probe{ChainID: "chain-1"}}},
+		{Config: &config.IndexConfig{Probe: config.Probe{ChainID: "chain-2"}}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A shutdown stops every chain
+	err := runChains(ctx, indexers, func(ctx context.Context, idxr *indexerPackage.Indexer, stopChain context.CancelCauseFunc) error {
+		if idxr.Config.Probe.ChainID == "chain-1" {
+			cancel()
+		}
+		<-ctx.Done()
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/core"
+	dbTypes "github.com/DefiantLabs/cosmos-indexer/db"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	indexerPackage "github.com/DefiantLabs/cosmos-indexer/indexer"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	// The gaps command shares the index configuration so the gap blocks can be indexed with the same settings
+	setupIndexFlags(indexer.Config, gapsCmd)
+	config.SetupGapsFlags(&indexer.Config.Gaps, gapsCmd)
+
+	rootCmd.AddCommand(gapsCmd)
+}
+
+var gapsCmd = &cobra.Command{
+	Use:   "gaps",
+	Short: "Finds block heights that are missing or not fully indexed.",
+	Long: `Finds the block heights between the start and end block that are missing from the database, are not indexed
+	for the enabled datasets, or are recorded as failed. The gaps can be written to a JSON block input file
+	or indexed directly.`,
+	PreRunE: setupIndex,
+	Run:     findGaps,
+}
+
+func findGaps(cmd *cobra.Command, args []string) {
+	if indexer.Config.Gaps.Output != "" && len(indexers) > 1 {
+		safeCleanupSetupExit(&indexer)
+		config.Log.Fatal("gaps.output can only be used with a single chain, a block input file only holds the heights of one chain")
+	}
+
+	// Chains without gaps have nothing to index
+	var chainsWithGaps []*indexerPackage.Indexer
+	for _, idxr := range indexers {
+		blockGaps := findIndexerGaps(idxr)
+		if len(blockGaps) == 0 {
+			continue
+		}
+
+		var err error
+		idxr.BlockEnqueueFunction, err = core.GenerateBlockGapsEnqueueFunction(blockGaps)
+		if err != nil {
+			config.Log.Fatal("Failed to generate block enqueue function", err)
+		}
+		chainsWithGaps = append(chainsWithGaps, idxr)
+	}
+
+	if !indexer.Config.Gaps.Enqueue || len(chainsWithGaps) == 0 {
+		safeCleanupSetupExit(&indexer)
+		return
+	}
+
+	indexers = chainsWithGaps
+
+	index(cmd, args)
+}
+
+// findIndexerGaps prints the gaps of the indexer chain, writing the gap ranges to the gaps output file if one is configured
+func findIndexerGaps(idxr *indexerPackage.Indexer) []dbTypes.BlockGap {
+	chain := models.Chain{
+		ChainID: idxr.Config.Probe.ChainID,
+		Name:    idxr.Config.Probe.ChainName,
+	}
+
+	dbChainID, err := dbTypes.GetDBChainID(idxr.DB, chain)
+	if err != nil {
+		config.Log.Fatal("Failed to add/create chain in DB", err)
+	}
+
+	blockGaps, err := dbTypes.FindBlockGaps(idxr.DB, dbChainID, idxr.Config.Base.StartBlock, idxr.Config.Base.EndBlock, idxr.Config.Base.TransactionIndexingEnabled, idxr.Config.Base.BlockEventIndexingEnabled)
+	if err != nil {
+		config.Log.Fatalf("Failed to find block gaps for chain %s. Err: %v", chain.ChainID, err)
+	}
+
+	var blocks int64
+	for _, gap := range blockGaps {
+		blocks += gap.Blocks()
+		fmt.Printf("%s\t%d-%d\t%s\ttransactions=%t\tblock-events=%t\n", chain.ChainID, gap.StartHeight, gap.EndHeight, gap.Reason, gap.Transactions, gap.BlockEvents)
+	}
+	fmt.Printf("Found %d gaps covering %d blocks for chain %s\n", len(blockGaps), blocks, chain.ChainID)
+
+	if indexer.Config.Gaps.Output != "" {
+		if blockGaps == nil {
+			blockGaps = []dbTypes.BlockGap{}
+		}
+
+		output, err := json.Marshal(blockGaps)
+		if err != nil {
+			config.Log.Fatal("Failed to encode gap block ranges", err)
+		}
+
+		err = os.WriteFile(indexer.Config.Gaps.Output, output, 0o600)
+		if err != nil {
+			config.Log.Fatalf("Failed to write gap block ranges to %s. Err: %v", indexer.Config.Gaps.Output, err)
+		}
+
+		config.Log.Infof("Wrote %d gap block ranges to %s", len(blockGaps), indexer.Config.Gaps.Output)
+	}
+
+	return blockGaps
+}
@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/DefiantLabs/cosmos-indexer/config"
@@ -12,24 +17,29 @@ import (
 	dbTypes "github.com/DefiantLabs/cosmos-indexer/db"
 	"github.com/DefiantLabs/cosmos-indexer/db/models"
 	"github.com/DefiantLabs/cosmos-indexer/filter"
+	"github.com/DefiantLabs/cosmos-indexer/health"
 	indexerPackage "github.com/DefiantLabs/cosmos-indexer/indexer"
+	"github.com/DefiantLabs/cosmos-indexer/metrics"
 	"github.com/DefiantLabs/cosmos-indexer/probe"
 	"github.com/DefiantLabs/cosmos-indexer/rpc"
+	transferTypes "github.com/cosmos/ibc-go/v7/modules/apps/transfer/types"
 	"github.com/spf13/cobra"
 )
 
 var (
-	indexer        indexerPackage.Indexer
+	// The config is allocated here rather than in init so every command's init can bind flags to it
+	indexer        = indexerPackage.Indexer{Config: &config.IndexConfig{}}
 	oldHelpCommand func(cmd *cobra.Command, args []string)
+	// Indexers registered for specific chains of a multi-chain config, keyed by chain ID
+	chainIndexers = map[string]*indexerPackage.Indexer{}
+	// The indexers run by the index command, one per chain
+	indexers []*indexerPackage.Indexer
+	// The Cosmos SDK address prefixes are process wide and can only be set once
+	chainConfigOnce sync.Once
 )
 
 func init() {
-	indexer.Config = &config.IndexConfig{}
-	config.SetupLogFlags(&indexer.Config.Log, indexCmd)
-	config.SetupDatabaseFlags(&indexer.Config.Database, indexCmd)
-	config.SetupProbeFlags(&indexer.Config.Probe, indexCmd)
-	config.SetupThrottlingFlag(&indexer.Config.Base.Throttling, indexCmd)
-	config.SetupIndexSpecificFlags(indexer.Config, indexCmd)
+	setupIndexFlags(indexer.Config, indexCmd)
 
 	oldHelpCommand = indexCmd.HelpFunc()
 	indexCmd.SetHelpFunc(HelpOverride)
@@ -51,7 +61,17 @@ func HelpOverride(cmd *cobra.Command, args []string) {
 	safeCleanupSetupExit(&indexer)
 }
 
+// setupIndexFlags registers every flag of the index configuration on the command
+func setupIndexFlags(conf *config.IndexConfig, cmd *cobra.Command) {
+	config.SetupLogFlags(&conf.Log, cmd)
+	config.SetupDatabaseFlags(&conf.Database, cmd)
+	config.SetupProbeFlags(&conf.Probe, cmd)
+	config.SetupThrottlingFlag(&conf.Base.Throttling, cmd)
+	config.SetupIndexSpecificFlags(conf, cmd)
+}
+
 // GetBuiltinIndexer returns the indexer instance for the index command. Usable for customizing pre-run setup.
+// When multiple chains are configured, every chain without its own indexer from GetChainIndexer uses a copy of the builtin indexer customizations.
 func GetBuiltinIndexer() *indexerPackage.Indexer {
 	if indexer.PostSetupDatasetChannel == nil {
 		indexer.PostSetupDatasetChannel = make(chan *indexerPackage.PostSetupDataset, 1)
@@ -60,6 +80,37 @@ func GetBuiltinIndexer() *indexerPackage.Indexer {
 	return &indexer
 }
 
+// GetChainIndexer returns the indexer instance used for the chain ID when multiple chains are configured.
+// Usable for registering custom parsers, filters and functions for a single chain instead of all chains.
+func GetChainIndexer(chainID string) *indexerPackage.Indexer {
+	idxr, ok := chainIndexers[chainID]
+	if !ok {
+		idxr = &indexerPackage.Indexer{
+			PostSetupDatasetChannel: make(chan *indexerPackage.PostSetupDataset, 1),
+		}
+		chainIndexers[chainID] = idxr
+	}
+
+	return idxr
+}
+
+// newChainIndexer returns the indexer for a chain of a multi-chain config, using a copy of the builtin indexer customizations if
+// no indexer was registered for the chain
+func newChainIndexer(conf *config.IndexConfig) *indexerPackage.Indexer {
+	idxr, ok := chainIndexers[conf.Probe.ChainID]
+	if !ok {
+		builtinCopy := indexer
+		builtinCopy.MessageTypeFilters = append([]filter.MessageTypeFilter{}, indexer.MessageTypeFilters...)
+		builtinCopy.PostSetupDatasetChannel = make(chan *indexerPackage.PostSetupDataset, 1)
+		idxr = &builtinCopy
+	}
+
+	idxr.Config = conf
+	idxr.DB = indexer.DB
+
+	return idxr
+}
+
 func safeCleanupSetupExit(indexer *indexerPackage.Indexer) {
 	close(indexer.PostSetupDatasetChannel)
 
@@ -76,6 +127,7 @@ func safeCleanupSetupExit(indexer *indexerPackage.Indexer) {
 }
 
 // setupIndex loads the configuration from file and command line flags, validates the configuration, and sets up the logger and database connection.
+// If the config file lists chains, an indexer is set up for every chain.
 func setupIndex(cmd *cobra.Command, args []string) error {
 	if indexer.PostSetupDatasetChannel == nil {
 		indexer.PostSetupDatasetChannel = make(chan *indexerPackage.PostSetupDataset, 1)
@@ -83,7 +135,17 @@ func setupIndex(cmd *cobra.Command, args []string) error {
 
 	BindFlags(cmd, viperConf)
 
-	err := indexer.Config.Validate()
+	chainConfigs, err := loadChainConfigs(cmd, viperConf)
+	if err != nil {
+		safeCleanupSetupExit(&indexer)
+		return err
+	}
+
+	if len(chainConfigs) == 0 {
+		chainConfigs = []*config.IndexConfig{indexer.Config}
+	}
+
+	err = validateChainConfigs(chainConfigs)
 	if err != nil {
 		safeCleanupSetupExit(&indexer)
 		return err
@@ -97,11 +159,6 @@ func setupIndex(cmd *cobra.Command, args []string) error {
 
 	setupLogger(indexer.Config.Log.Level, indexer.Config.Log.Path, indexer.Config.Log.Pretty)
 
-	// 0 is an invalid starting block, set it to 1
-	if indexer.Config.Base.StartBlock == 0 {
-		indexer.Config.Base.StartBlock = 1
-	}
-
 	// If DB has not been preset, connect to the database and migrate using the default configuration settings
 	if indexer.DB == nil {
 		db, err := ConnectToDBAndMigrate(indexer.Config.Database)
@@ -119,18 +176,36 @@ func setupIndex(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	indexer.DryRun = indexer.Config.Base.Dry
+	indexers = nil
+	for _, chainConfig := range chainConfigs {
+		idxr := &indexer
+		if chainConfig != indexer.Config {
+			idxr = newChainIndexer(chainConfig)
+		}
+
+		setupIndexerCustomizations(idxr)
+		indexers = append(indexers, idxr)
+	}
+
+	return nil
+}
+
+// setupIndexerCustomizations loads the block event and message type filters of the indexer and migrates its custom models and parsers
+func setupIndexerCustomizations(idxr *indexerPackage.Indexer) {
+	var err error
 
-	indexer.BlockEventFilterRegistries = indexerPackage.BlockEventFilterRegistries{
+	idxr.DryRun = idxr.Config.Base.Dry
+
+	idxr.BlockEventFilterRegistries = indexerPackage.BlockEventFilterRegistries{
 		BeginBlockEventFilterRegistry: &filter.StaticBlockEventFilterRegistry{},
 		EndBlockEventFilterRegistry:   &filter.StaticBlockEventFilterRegistry{},
 	}
 
-	if indexer.Config.Base.FilterFile != "" {
-		f, err := os.Open(indexer.Config.Base.FilterFile)
+	if idxr.Config.Base.FilterFile != "" {
+		f, err := os.Open(idxr.Config.Base.FilterFile)
 		if err != nil {
 			safeCleanupSetupExit(&indexer)
-			config.Log.Fatalf("Failed to open block event filter file %s: %s", indexer.Config.Base.FilterFile, err)
+			config.Log.Fatalf("Failed to open block event filter file %s: %s", idxr.Config.Base.FilterFile, err)
 		}
 
 		b, err := io.ReadAll(f)
@@ -141,10 +216,10 @@ func setupIndex(cmd *cobra.Command, args []string) error {
 
 		var fileMessageTypeFilters []filter.MessageTypeFilter
 
-		indexer.BlockEventFilterRegistries.BeginBlockEventFilterRegistry.BlockEventFilters,
-			indexer.BlockEventFilterRegistries.BeginBlockEventFilterRegistry.RollingWindowEventFilters,
-			indexer.BlockEventFilterRegistries.EndBlockEventFilterRegistry.BlockEventFilters,
-			indexer.BlockEventFilterRegistries.EndBlockEventFilterRegistry.RollingWindowEventFilters,
+		idxr.BlockEventFilterRegistries.BeginBlockEventFilterRegistry.BlockEventFilters,
+			idxr.BlockEventFilterRegistries.BeginBlockEventFilterRegistry.RollingWindowEventFilters,
+			idxr.BlockEventFilterRegistries.EndBlockEventFilterRegistry.BlockEventFilters,
+			idxr.BlockEventFilterRegistries.EndBlockEventFilterRegistry.RollingWindowEventFilters,
 			fileMessageTypeFilters,
 			err = config.ParseJSONFilterConfig(b)
 		if err != nil {
@@ -152,35 +227,35 @@ func setupIndex(cmd *cobra.Command, args []string) error {
 			config.Log.Fatal("Failed to parse block event filter config", err)
 		}
 
-		indexer.MessageTypeFilters = append(indexer.MessageTypeFilters, fileMessageTypeFilters...)
+		idxr.MessageTypeFilters = append(idxr.MessageTypeFilters, fileMessageTypeFilters...)
 	}
 
-	if len(indexer.CustomModels) != 0 {
-		err = dbTypes.MigrateInterfaces(indexer.DB, indexer.CustomModels)
+	if len(idxr.CustomModels) != 0 {
+		err = dbTypes.MigrateInterfaces(idxr.DB, idxr.CustomModels)
 		if err != nil {
 			safeCleanupSetupExit(&indexer)
 			config.Log.Fatal("Failed to migrate custom models", err)
 		}
 	}
 
-	if len(indexer.CustomBeginBlockParserTrackers) != 0 {
-		err = dbTypes.FindOrCreateCustomBlockEventParsers(indexer.DB, indexer.CustomBeginBlockParserTrackers)
+	if len(idxr.CustomBeginBlockParserTrackers) != 0 {
+		err = dbTypes.FindOrCreateCustomBlockEventParsers(idxr.DB, idxr.CustomBeginBlockParserTrackers)
 		if err != nil {
 			safeCleanupSetupExit(&indexer)
 			config.Log.Fatal("Failed to migrate custom block event parsers", err)
 		}
 	}
 
-	if len(indexer.CustomEndBlockParserTrackers) != 0 {
-		err = dbTypes.FindOrCreateCustomBlockEventParsers(indexer.DB, indexer.CustomEndBlockParserTrackers)
+	if len(idxr.CustomEndBlockParserTrackers) != 0 {
+		err = dbTypes.FindOrCreateCustomBlockEventParsers(idxr.DB, idxr.CustomEndBlockParserTrackers)
 		if err != nil {
 			safeCleanupSetupExit(&indexer)
 			config.Log.Fatal("Failed to migrate custom block event parsers", err)
 		}
 	}
 
-	if len(indexer.CustomMessageParserTrackers) != 0 {
-		err = dbTypes.FindOrCreateCustomMessageParsers(indexer.DB, indexer.CustomMessageParserTrackers)
+	if len(idxr.CustomMessageParserTrackers) != 0 {
+		err = dbTypes.FindOrCreateCustomMessageParsers(idxr.DB, idxr.CustomMessageParserTrackers)
 		if err != nil {
 			safeCleanupSetupExit(&indexer)
 			config.Log.Fatal("Failed to migrate custom message parsers", err)
@@ -188,75 +263,221 @@ func setupIndex(cmd *cobra.Command, args []string) error {
 
 	}
 
-	return nil
 }
 
-// SetupIndexer sets up the "indexer" package Indexer instance with the configuration, database, and chain client
-func setupIndexer() *indexerPackage.Indexer {
+// SetupIndexer sets up the "indexer" package Indexer instance with the configuration, database, and chain client.
+// Returns the context error if shutdown is requested before the setup completes.
+func setupIndexer(ctx context.Context, idxr *indexerPackage.Indexer) error {
 	var err error
 
-	config.SetChainConfig(indexer.Config.Probe.AccountPrefix)
+	// Custom setup functions may be waiting on the setup dataset channel, close it on every return
+	postSetupChannelClosed := false
+	defer func() {
+		if !postSetupChannelClosed {
+			close(idxr.PostSetupDatasetChannel)
+		}
+	}()
+
+	// Every chain shares the account prefix, setupIndex rejects configs where they differ
+	chainConfigOnce.Do(func() {
+		config.SetChainConfig(idxr.Config.Probe.AccountPrefix)
+	})
+
+	var descriptorRegistry *probe.DescriptorRegistry
+	if len(idxr.Config.Probe.ProtoDescriptorSets) != 0 {
+		descriptorRegistry, err = probe.LoadDescriptorSets(idxr.Config.Probe.ProtoDescriptorSets)
+		if err != nil {
+			return fmt.Errorf("failed to load proto descriptor sets: %w", err)
+		}
+		config.LogCtx(ctx).Infof("Loaded %d message types from proto descriptor sets", len(descriptorRegistry.TypeURLs()))
+	}
+
+	var endpoints []*rpc.Endpoint
+	for _, rpcAddress := range idxr.Config.Probe.RPCEndpoints() {
+		endpointConf := idxr.Config.Probe
+		endpointConf.RPC = rpcAddress
+
+		chainClient, err := probe.GetProbeClient(endpointConf, idxr.CustomModuleBasics, idxr.CustomMsgTypeRegistry)
+		if err != nil {
+			return fmt.Errorf("failed to create probe client for RPC endpoint %s: %w", rpcAddress, err)
+		}
+
+		if descriptorRegistry != nil {
+			probe.IncludeDescriptorSets(chainClient, descriptorRegistry)
+		}
 
-	indexer.ChainClient, err = probe.GetProbeClient(indexer.Config.Probe, indexer.CustomModuleBasics, indexer.CustomMsgTypeRegistry)
+		limiter := rpc.NewRateLimiter(idxr.Config.Base.RPCRequestsPerSecond, idxr.Config.Base.RPCBurst)
+		endpoint, err := rpc.NewEndpoint(chainClient, limiter)
+		if err != nil {
+			return fmt.Errorf("failed to create RPC client for RPC endpoint %s: %w", rpcAddress, err)
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
 
+	idxr.RPCPool, err = rpc.NewPool(endpoints, rpc.PoolOptions{
+		Selection:           idxr.Config.Probe.RPCSelection,
+		MaxLag:              idxr.Config.Probe.RPCMaxLag,
+		FailureThreshold:    idxr.Config.Probe.RPCFailureThreshold,
+		UnhealthyCooldown:   time.Second * time.Duration(idxr.Config.Probe.RPCUnhealthyCooldown),
+		EndpointRetryBudget: idxr.Config.Probe.RPCEndpointRetryBudget,
+		RetryAttempts:       idxr.Config.Base.RequestRetryAttempts,
+		RetryMaxWait:        time.Second * time.Duration(idxr.Config.Base.RequestRetryMaxWait),
+	})
 	if err != nil {
-		config.Log.Fatal("Failed to create probe client", err)
+		return fmt.Errorf("failed to create RPC endpoint pool: %w", err)
+	}
+
+	idxr.ChainClient = idxr.RPCPool.Primary().ChainClient
+
+	isCatchingUp := func() (bool, error) {
+		var catchingUp bool
+		err := idxr.RPCPool.Do(ctx, func(endpoint *rpc.Endpoint) error {
+			var err error
+			catchingUp, err = rpc.IsCatchingUp(endpoint.ChainClient)
+			return err
+		})
+		return catchingUp, err
+	}
+
+	// Wait between status checks, don't spam the node with requests. Returns the context error if shutdown is requested while waiting.
+	waitForChainDelay := func() error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second * time.Duration(idxr.Config.Base.WaitForChainDelay)):
+			return nil
+		}
 	}
 
 	// Depending on the app configuration, wait for the chain to catch up
-	chainCatchingUp, err := rpc.IsCatchingUp(indexer.ChainClient)
-	for indexer.Config.Base.WaitForChain && chainCatchingUp && err == nil {
-		// Wait between status checks, don't spam the node with requests
-		config.Log.Debug("Chain is still catching up, please wait or disable check in config.")
-		time.Sleep(time.Second * time.Duration(indexer.Config.Base.WaitForChainDelay))
-		chainCatchingUp, err = rpc.IsCatchingUp(indexer.ChainClient)
+	chainCatchingUp, err := isCatchingUp()
+	for idxr.Config.Base.WaitForChain && chainCatchingUp && err == nil {
+		config.LogCtx(ctx).Debug("Chain is still catching up, please wait or disable check in config.")
+		if err = waitForChainDelay(); err != nil {
+			break
+		}
+		chainCatchingUp, err = isCatchingUp()
 
 		// This EOF error pops up from time to time and is unpredictable
 		// It is most likely an error on the node, we would need to see any error logs on the node side
 		// Try one more time
 		if err != nil && strings.HasSuffix(err.Error(), "EOF") {
-			time.Sleep(time.Second * time.Duration(indexer.Config.Base.WaitForChainDelay))
-			chainCatchingUp, err = rpc.IsCatchingUp(indexer.ChainClient)
+			if err = waitForChainDelay(); err != nil {
+				break
+			}
+			chainCatchingUp, err = isCatchingUp()
 		}
 	}
 	if err != nil {
-		close(indexer.PostSetupDatasetChannel)
-		config.Log.Fatal("Error querying chain status.", err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("error querying chain status: %w", err)
 	}
 
-	if indexer.PostSetupDatasetChannel != nil {
-		indexer.PostSetupDatasetChannel <- &indexerPackage.PostSetupDataset{
-			Config:      indexer.Config,
-			DryRun:      indexer.DryRun,
-			ChainClient: indexer.ChainClient,
+	if idxr.PostSetupDatasetChannel != nil {
+		idxr.PostSetupDatasetChannel <- &indexerPackage.PostSetupDataset{
+			Config:      idxr.Config,
+			DryRun:      idxr.DryRun,
+			ChainClient: idxr.ChainClient,
 		}
 	}
 
-	close(indexer.PostSetupDatasetChannel)
+	close(idxr.PostSetupDatasetChannel)
+	postSetupChannelClosed = true
 
-	if indexer.PostSetupCustomFunction != nil {
-		err = indexer.PostSetupCustomFunction(indexerPackage.PostSetupCustomDataset{
-			ChainClient: indexer.ChainClient,
-			Config:      *indexer.Config,
-			DB:          indexer.DB,
+	if idxr.PostSetupCustomFunction != nil {
+		err = idxr.PostSetupCustomFunction(indexerPackage.PostSetupCustomDataset{
+			ChainClient: idxr.ChainClient,
+			Config:      *idxr.Config,
+			DB:          idxr.DB,
 		})
 		if err != nil {
-			config.Log.Fatal("Failed to run post setup custom function", err)
+			return fmt.Errorf("failed to run post setup custom function: %w", err)
 		}
 	}
 
-	return &indexer
+	return nil
 }
 
 func index(cmd *cobra.Command, args []string) {
-	// Setup the indexer with config, db, and cl
-	idxr := setupIndexer()
-	dbConn, err := idxr.DB.DB()
+	// Cancel the pipeline context on SIGINT/SIGTERM so in-flight blocks can be drained to the DB before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		// Restore default signal behavior so a second signal forces an immediate exit
+		stop()
+		config.Log.Info("Shutdown signal received, finishing in-flight blocks. Send the signal again to force exit.")
+	}()
+
+	dbConn, err := indexer.DB.DB()
 	if err != nil {
 		config.Log.Fatal("Failed to connect to DB", err)
 	}
 	defer dbConn.Close()
 
+	// The metrics and health servers are shared by all chains, they are configured by the top level config
+	if indexer.Config.Metrics.Enabled {
+		go metrics.StartServer(ctx, indexer.Config.Metrics.ListenAddress)
+	}
+
+	var checker *health.Checker
+	if indexer.Config.Health.Enabled {
+		checker = health.NewChecker(indexer.DB, time.Second*time.Duration(indexer.Config.Health.MaxCommitStaleness))
+		go health.StartServer(ctx, indexer.Config.Health.ListenAddress, checker)
+	}
+
+	// The builtin indexer only runs when a single chain is configured, chain indexers have their own setup dataset channels
+	if len(indexers) != 1 || indexers[0] != &indexer {
+		close(indexer.PostSetupDatasetChannel)
+	}
+
+	chainsErr := runChains(ctx, indexers, func(ctx context.Context, idxr *indexerPackage.Indexer, stopChain context.CancelCauseFunc) error {
+		return runIndexer(ctx, idxr, checker, stopChain)
+	})
+
+	if ctx.Err() != nil {
+		config.Log.Info("All in-flight blocks have been indexed, shutting down")
+	}
+
+	if indexer.PreExitCustomFunction != nil {
+		err = indexer.PreExitCustomFunction(&indexerPackage.PreExitCustomDataset{
+			Config: *indexer.Config,
+			DB:     indexer.DB,
+			DryRun: indexer.DryRun,
+		})
+		if err != nil {
+			config.Log.Fatal("Failed to run pre-exit custom function", err)
+		}
+	}
+
+	// Exit with a non-zero code when any chain failed, after the other chains finished and the pre-exit function ran
+	if chainsErr != nil {
+		config.Log.Fatal("Indexing failed", chainsErr)
+	}
+}
+
+// runIndexer runs the indexing pipeline of a single chain until the block enqueue function returns and every enqueued block has been written.
+// Logs of the pipeline are tagged with the chain ID.
+// Every stage stops the chain through stopChain when it fails, which only cancels the chain context. The blocks already in flight
+// are drained and the cause is returned.
+func runIndexer(ctx context.Context, idxr *indexerPackage.Indexer, checker *health.Checker, stopChain context.CancelCauseFunc) error {
+	ctx = config.ContextWithLogger(ctx, config.Log.With("chain_id", idxr.Config.Probe.ChainID))
+	// The DB session carries the chain logger to the db package, without the cancellation so in-flight blocks can still be written on shutdown
+	idxr.DB = idxr.DB.WithContext(context.WithoutCancel(ctx))
+
+	// Setup the indexer with config, db, and cl
+	if err := setupIndexer(ctx, idxr); err != nil {
+		if ctx.Err() != nil {
+			config.LogCtx(ctx).Info("Shutdown requested during setup, exiting")
+			return nil
+		}
+		return err
+	}
+
 	// blockChans are just the block heights; limit max jobs in the queue, otherwise this queue would contain one
 	// item (block height) for every block on the entire blockchain we're indexing. Furthermore, once the queue
 	// is close to empty, we will spin up a new thread to fill it up with new jobs.
@@ -283,7 +504,39 @@ func index(cmd *cobra.Command, args []string) {
 
 	dbChainID, err := dbTypes.GetDBChainID(idxr.DB, chain)
 	if err != nil {
-		config.Log.Fatal("Failed to add/create chain in DB", err)
+		return fmt.Errorf("failed to add/create chain in DB: %w", err)
+	}
+
+	switch {
+	// If block enqueue function has been explicitly set, use that
+	case idxr.BlockEnqueueFunction != nil:
+	// Default block enqueue functions based on config values
+	case idxr.Config.Base.ReindexMessageType != "":
+		idxr.BlockEnqueueFunction, err = core.GenerateMsgTypeEnqueueFunction(idxr.DB, *idxr.Config, dbChainID, idxr.Config.Base.ReindexMessageType)
+	case idxr.Config.Base.ReprocessFailedTxs:
+		idxr.BlockEnqueueFunction, err = core.GenerateFailedTxsEnqueueFunction(idxr.DB, *idxr.Config, dbChainID)
+	case idxr.Config.Base.BlockInputFile != "":
+		idxr.BlockEnqueueFunction, err = core.GenerateBlockFileEnqueueFunction(idxr.DB, *idxr.Config, idxr.RPCPool, dbChainID, idxr.Config.Base.BlockInputFile)
+	case idxr.Config.Base.DistributedEnqueue:
+		idxr.BlockEnqueueFunction, err = core.GenerateLeaseEnqueueFunction(idxr.DB, *idxr.Config, idxr.RPCPool, dbChainID)
+	default:
+		idxr.BlockEnqueueFunction, err = core.GenerateDefaultEnqueueFunction(idxr.DB, *idxr.Config, idxr.RPCPool, dbChainID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate block enqueue function: %w", err)
+	}
+
+	traces := make(map[string]transferTypes.DenomTrace)
+	if idxr.Config.Base.EnrichDenoms && idxr.Config.Base.DenomTraceFile != "" {
+		traces, err = core.LoadDenomTraceFile(idxr.Config.Base.DenomTraceFile)
+		if err != nil {
+			return fmt.Errorf("failed to load denom trace file: %w", err)
+		}
+	}
+
+	// Track endpoint heights so lagging RPC endpoints are skipped, only needed when there is more than one endpoint to choose from
+	if len(idxr.RPCPool.Endpoints()) > 1 {
+		go idxr.RPCPool.MonitorHeights(ctx, time.Second*time.Duration(idxr.Config.Probe.RPCHealthCheckInterval))
 	}
 
 	// This block consolidates all base RPC requests into one worker.
@@ -292,7 +545,7 @@ func index(cmd *cobra.Command, args []string) {
 	blockRPCWorkerDataChan := make(chan core.IndexerBlockEventData, 10)
 	for i := 0; i < rpcQueryThreads; i++ {
 		blockRPCWaitGroup.Add(1)
-		go core.BlockRPCWorker(&blockRPCWaitGroup, blockEnqueueChan, dbChainID, idxr.Config.Probe.ChainID, idxr.Config, idxr.ChainClient, idxr.DB, blockRPCWorkerDataChan)
+		go core.BlockRPCWorker(ctx, &blockRPCWaitGroup, blockEnqueueChan, dbChainID, idxr.Config.Probe.ChainID, idxr.Config, idxr.RPCPool, idxr.DB, blockRPCWorkerDataChan, stopChain)
 	}
 
 	go func() {
@@ -304,50 +557,78 @@ func index(cmd *cobra.Command, args []string) {
 	blockEventsDataChan := make(chan *indexerPackage.BlockEventsDBData, 4*rpcQueryThreads)
 	txDataChan := make(chan *indexerPackage.DBData, 4*rpcQueryThreads)
 
+	if indexer.Config.Metrics.Enabled {
+		chainID := idxr.Config.Probe.ChainID
+		queues := map[string]func() int{
+			"blockEnqueueChan":       func() int { return len(blockEnqueueChan) },
+			"blockRPCWorkerDataChan": func() int { return len(blockRPCWorkerDataChan) },
+			"txDataChan":             func() int { return len(txDataChan) },
+			"blockEventsDataChan":    func() int { return len(blockEventsDataChan) },
+		}
+		for channel, depth := range queues {
+			if err := metrics.RegisterQueueDepth(chainID, channel, depth); err != nil {
+				config.LogCtx(ctx).Warnf("Failed to register the queue depth metric of %s. Err: %v", channel, err)
+			}
+		}
+
+		go metrics.PollChainHead(ctx, chainID, time.Second*time.Duration(indexer.Config.Metrics.ChainHeadPollInterval), func() (int64, error) {
+			return idxr.RPCPool.LatestBlockHeight(ctx)
+		})
+	}
+
+	if checker != nil {
+		checker.AddChain(idxr.Config.Probe.ChainID, idxr.RPCPool)
+	}
+
 	wg.Add(1)
-	go idxr.ProcessBlocks(&wg, core.HandleFailedBlock, blockRPCWorkerDataChan, blockEventsDataChan, txDataChan, dbChainID, indexer.BlockEventFilterRegistries)
+	go idxr.ProcessBlocks(ctx, &wg, core.HandleFailedBlock, blockRPCWorkerDataChan, blockEventsDataChan, txDataChan, dbChainID, idxr.BlockEventFilterRegistries, stopChain)
 
 	wg.Add(1)
-	go idxr.DoDBUpdates(&wg, txDataChan, blockEventsDataChan, dbChainID)
+	go idxr.DoDBUpdates(ctx, &wg, txDataChan, blockEventsDataChan, dbChainID, stopChain)
+
+	// The failed block retrier shares the enqueue channel, so it is stopped before the channel is closed
+	retryCtx, stopRetries := context.WithCancel(ctx)
+	var retryWaitGroup sync.WaitGroup
+	if idxr.Config.Base.FailedBlockRetryInterval > 0 && !idxr.Config.Base.Dry {
+		retryWaitGroup.Add(1)
+		go func() {
+			defer retryWaitGroup.Done()
+			core.RetryFailedBlocks(retryCtx, idxr.DB, *idxr.Config, dbChainID, blockEnqueueChan)
+		}()
+	}
 
-	switch {
-	// If block enqueue function has been explicitly set, use that
-	case idxr.BlockEnqueueFunction != nil:
-	// Default block enqueue functions based on config values
-	case idxr.Config.Base.ReindexMessageType != "":
-		idxr.BlockEnqueueFunction, err = core.GenerateMsgTypeEnqueueFunction(idxr.DB, *idxr.Config, dbChainID, idxr.Config.Base.ReindexMessageType)
-		if err != nil {
-			config.Log.Fatal("Failed to generate block enqueue function", err)
-		}
-	case idxr.Config.Base.BlockInputFile != "":
-		idxr.BlockEnqueueFunction, err = core.GenerateBlockFileEnqueueFunction(idxr.DB, *idxr.Config, idxr.ChainClient, dbChainID, idxr.Config.Base.BlockInputFile)
-		if err != nil {
-			config.Log.Fatal("Failed to generate block enqueue function", err)
-		}
-	default:
-		idxr.BlockEnqueueFunction, err = core.GenerateDefaultEnqueueFunction(idxr.DB, *idxr.Config, idxr.ChainClient, dbChainID)
-		if err != nil {
-			config.Log.Fatal("Failed to generate block enqueue function", err)
-		}
+	// The denom enricher resolves denoms in the background for as long as the chain is being indexed
+	enrichCtx, stopEnrichment := context.WithCancel(ctx)
+	var enrichWaitGroup sync.WaitGroup
+	if idxr.Config.Base.EnrichDenoms && !idxr.Config.Base.Dry {
+		enrichWaitGroup.Add(1)
+		go func() {
+			defer enrichWaitGroup.Done()
+			core.EnrichDenoms(enrichCtx, idxr.DB, idxr.RPCPool, traces, time.Duration(idxr.Config.Base.DenomEnrichmentInterval)*time.Second)
+		}()
 	}
 
-	err = idxr.BlockEnqueueFunction(blockEnqueueChan)
-	if err != nil {
-		config.Log.Fatal("Block enqueue failed", err)
+	err = idxr.BlockEnqueueFunction(ctx, blockEnqueueChan)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		stopChain(fmt.Errorf("block enqueue failed: %w", err))
 	}
 
+	stopRetries()
+	retryWaitGroup.Wait()
+
 	close(blockEnqueueChan)
 
 	wg.Wait()
 
-	if indexer.PreExitCustomFunction != nil {
-		err = indexer.PreExitCustomFunction(&indexerPackage.PreExitCustomDataset{
-			Config: *idxr.Config,
-			DB:     indexer.DB,
-			DryRun: indexer.DryRun,
-		})
-		if err != nil {
-			config.Log.Fatal("Failed to run pre-exit custom function", err)
-		}
+	stopEnrichment()
+	enrichWaitGroup.Wait()
+
+	// A shutdown request cancels the chain without an error cause
+	if err := context.Cause(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		config.LogCtx(ctx).Error("Indexing stopped", err)
+		return err
 	}
+
+	config.LogCtx(ctx).Info("Indexing complete")
+	return nil
 }
@@ -101,7 +101,12 @@ func BindFlags(cmd *cobra.Command, v *viper.Viper) {
 		// Apply the viper config value to the flag when the flag is not set and viper has a value
 		if !f.Changed && v.IsSet(configName) {
 			val := v.Get(configName)
-			err := cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val))
+			flagValue := fmt.Sprintf("%v", val)
+			// TOML arrays must be passed to slice flags as comma separated values
+			if _, ok := f.Value.(pflag.SliceValue); ok {
+				flagValue = strings.Join(v.GetStringSlice(configName), ",")
+			}
+			err := cmd.Flags().Set(f.Name, flagValue)
 			if err != nil {
 				log.Fatalf("Failed to bind config file value %v. Err: %v", configName, err)
 			}
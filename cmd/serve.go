@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/DefiantLabs/cosmos-indexer/api"
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/db"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+var (
+	serveConfig config.ServeConfig
+	// The serve command only reads, it does not run migrations so it can connect as a read-only DB user
+	serveDB *gorm.DB
+)
+
+func init() {
+	config.SetupServeFlags(&serveConfig, serveCmd)
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serves a read-only REST API over the indexed data.",
+	Long: `Serves paginated REST endpoints for the blocks, transactions, messages, block events and parser errors
+	indexed into the database, for every chain in the database. The database must already have been migrated
+	by the index command.`,
+	PreRunE: setupServe,
+	Run:     serve,
+}
+
+func setupServe(cmd *cobra.Command, args []string) error {
+	BindFlags(cmd, viperConf)
+
+	err := serveConfig.Validate()
+	if err != nil {
+		return err
+	}
+
+	// The serve command shares the config file of the index command, so only keys unknown to both are reported
+	ignoredKeys := config.CheckSuperfluousIndexKeys(viperConf.AllKeys())
+
+	if len(ignoredKeys) > 0 {
+		config.Log.Warnf("Warning, the following invalid keys will be ignored: %v", ignoredKeys)
+	}
+
+	setupLogger(serveConfig.Log.Level, serveConfig.Log.Path, serveConfig.Log.Pretty)
+
+	serveDB, err = db.PostgresDbConnect(serveConfig.Database.Host, serveConfig.Database.Port, serveConfig.Database.Database, serveConfig.Database.User, serveConfig.Database.Password, strings.ToLower(serveConfig.Database.LogLevel))
+	if err != nil {
+		config.Log.Fatal("Could not establish connection to the database", err)
+	}
+
+	return nil
+}
+
+func serve(cmd *cobra.Command, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := api.NewServer(serveDB, int(serveConfig.Server.DefaultPageSize), int(serveConfig.Server.MaxPageSize))
+	if err := api.StartServer(ctx, serveConfig.Server.ListenAddress, server); err != nil {
+		config.Log.Fatal("API server failed", err)
+	}
+}
@@ -27,14 +27,37 @@ type Database struct {
 }
 
 type Probe struct {
-	RPC           string
-	AccountPrefix string `mapstructure:"account-prefix"`
-	ChainID       string `mapstructure:"chain-id"`
-	ChainName     string `mapstructure:"chain-name"`
+	RPC                    string
+	RPCs                   []string `mapstructure:"rpcs"`
+	RPCSelection           string   `mapstructure:"rpc-selection"`
+	RPCMaxLag              int64    `mapstructure:"rpc-max-lag"`
+	RPCFailureThreshold    int64    `mapstructure:"rpc-failure-threshold"`
+	RPCUnhealthyCooldown   int64    `mapstructure:"rpc-unhealthy-cooldown"`
+	RPCEndpointRetryBudget int64    `mapstructure:"rpc-endpoint-retry-budget"`
+	RPCHealthCheckInterval int64    `mapstructure:"rpc-health-check-interval"`
+	AccountPrefix          string   `mapstructure:"account-prefix"`
+	ChainID                string   `mapstructure:"chain-id"`
+	ChainName              string   `mapstructure:"chain-name"`
+	ProtoDescriptorSets    []string `mapstructure:"proto-descriptor-sets"`
+}
+
+// RPCEndpoints returns the unique RPC endpoints configured in probe.rpc and probe.rpcs, with probe.rpc first
+func (probeConf Probe) RPCEndpoints() []string {
+	seen := make(map[string]bool)
+	var endpoints []string
+	for _, endpoint := range append([]string{probeConf.RPC}, probeConf.RPCs...) {
+		if endpoint != "" && !seen[endpoint] {
+			seen[endpoint] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
 }
 
 type throttlingBase struct {
-	Throttling float64 `mapstructure:"throttling"`
+	Throttling           float64 `mapstructure:"throttling"` // Deprecated: use RPCRequestsPerSecond
+	RPCRequestsPerSecond float64 `mapstructure:"rpc-requests-per-second"`
+	RPCBurst             int64   `mapstructure:"rpc-burst"`
 }
 
 type retryBase struct {
@@ -59,13 +82,21 @@ func SetupDatabaseFlags(databaseConf *Database, cmd *cobra.Command) {
 
 func SetupProbeFlags(probeConf *Probe, cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVar(&probeConf.RPC, "probe.rpc", "", "node rpc endpoint")
+	cmd.PersistentFlags().StringSliceVar(&probeConf.RPCs, "probe.rpcs", []string{}, "additional node rpc endpoints, requests are load balanced and failed over between probe.rpc and these endpoints")
+	cmd.PersistentFlags().StringVar(&probeConf.RPCSelection, "probe.rpc-selection", "round-robin", "how to pick the rpc endpoint for each request (round-robin or least-latency)")
+	cmd.PersistentFlags().Int64Var(&probeConf.RPCMaxLag, "probe.rpc-max-lag", 10, "blocks an rpc endpoint can be behind the highest endpoint before it is skipped (use 0 to disable)")
+	cmd.PersistentFlags().Int64Var(&probeConf.RPCFailureThreshold, "probe.rpc-failure-threshold", 3, "consecutive failed requests before an rpc endpoint is marked unhealthy")
+	cmd.PersistentFlags().Int64Var(&probeConf.RPCUnhealthyCooldown, "probe.rpc-unhealthy-cooldown", 30, "seconds an unhealthy rpc endpoint is skipped for")
+	cmd.PersistentFlags().Int64Var(&probeConf.RPCEndpointRetryBudget, "probe.rpc-endpoint-retry-budget", 1, "attempts made against a single rpc endpoint per request before failing over to the next endpoint")
+	cmd.PersistentFlags().Int64Var(&probeConf.RPCHealthCheckInterval, "probe.rpc-health-check-interval", 15, "seconds between latest block height checks on every rpc endpoint, used to detect lagging endpoints")
 	cmd.PersistentFlags().StringVar(&probeConf.AccountPrefix, "probe.account-prefix", "", "probe account prefix")
 	cmd.PersistentFlags().StringVar(&probeConf.ChainID, "probe.chain-id", "", "probe chain ID")
 	cmd.PersistentFlags().StringVar(&probeConf.ChainName, "probe.chain-name", "", "probe chain name")
+	cmd.PersistentFlags().StringSliceVar(&probeConf.ProtoDescriptorSets, "probe.proto-descriptor-sets", []string{}, "binary FileDescriptorSet files whose message types are registered in the codec at runtime, used to decode messages the indexer has no compiled types for")
 }
 
 func SetupThrottlingFlag(throttlingValue *float64, cmd *cobra.Command) {
-	cmd.PersistentFlags().Float64Var(throttlingValue, "base.throttling", 0.5, "block enqueue throttle delay")
+	cmd.PersistentFlags().Float64Var(throttlingValue, "base.throttling", 0, "no longer supported, use base.rpc-requests-per-second. Setting it fails the config validation")
 }
 
 func validateDatabaseConf(dbConf Database) error {
@@ -89,16 +120,46 @@ func validateDatabaseConf(dbConf Database) error {
 }
 
 func validateProbeConf(probeConf Probe) (Probe, error) {
-	if util.StrNotSet(probeConf.RPC) {
+	if util.StrNotSet(probeConf.RPC) && len(probeConf.RPCs) == 0 {
 		return probeConf, errors.New("probe rpc must be set")
 	}
-	// add port if not set
-	if strings.Count(probeConf.RPC, ":") != 2 {
-		if strings.HasPrefix(probeConf.RPC, "https:") {
-			probeConf.RPC = fmt.Sprintf("%s:443", probeConf.RPC)
-		} else if strings.HasPrefix(probeConf.RPC, "http:") {
-			probeConf.RPC = fmt.Sprintf("%s:80", probeConf.RPC)
-		}
+
+	probeConf.RPC = addDefaultRPCPort(probeConf.RPC)
+	for i := range probeConf.RPCs {
+		probeConf.RPCs[i] = addDefaultRPCPort(probeConf.RPCs[i])
+	}
+
+	// The first endpoint is used as the primary client
+	if util.StrNotSet(probeConf.RPC) {
+		probeConf.RPC = probeConf.RPCs[0]
+	}
+
+	switch probeConf.RPCSelection {
+	case "":
+		probeConf.RPCSelection = "round-robin"
+	case "round-robin", "least-latency":
+	default:
+		return probeConf, errors.New("probe rpc-selection must be one of round-robin or least-latency")
+	}
+
+	if probeConf.RPCMaxLag < 0 {
+		return probeConf, errors.New("probe rpc-max-lag must be a positive number or 0")
+	}
+
+	if probeConf.RPCUnhealthyCooldown < 0 {
+		return probeConf, errors.New("probe rpc-unhealthy-cooldown must be a positive number or 0")
+	}
+
+	if probeConf.RPCFailureThreshold <= 0 {
+		probeConf.RPCFailureThreshold = 1
+	}
+
+	if probeConf.RPCEndpointRetryBudget <= 0 {
+		probeConf.RPCEndpointRetryBudget = 1
+	}
+
+	if probeConf.RPCHealthCheckInterval <= 0 {
+		probeConf.RPCHealthCheckInterval = 15
 	}
 
 	if util.StrNotSet(probeConf.AccountPrefix) {
@@ -113,11 +174,34 @@ func validateProbeConf(probeConf Probe) (Probe, error) {
 	return probeConf, nil
 }
 
-func validateThrottlingConf(throttlingConf throttlingBase) error {
-	if throttlingConf.Throttling < 0 {
-		return errors.New("throttling must be a positive number or 0")
+// add port if not set
+func addDefaultRPCPort(rpc string) string {
+	if strings.Count(rpc, ":") != 2 {
+		if strings.HasPrefix(rpc, "https:") {
+			return fmt.Sprintf("%s:443", rpc)
+		} else if strings.HasPrefix(rpc, "http:") {
+			return fmt.Sprintf("%s:80", rpc)
+		}
 	}
-	return nil
+	return rpc
+}
+
+func validateThrottlingConf(throttlingConf throttlingBase) (throttlingBase, error) {
+	// The deprecated throttling was a delay between enqueued blocks, which has no equivalent request rate since the number of
+	// requests per block depends on the datasets indexed and the background pollers share the rate limit. Fail instead of guessing one.
+	if throttlingConf.Throttling != 0 {
+		return throttlingConf, errors.New("base.throttling is no longer supported, remove it and set base.rpc-requests-per-second to limit the RPC request rate")
+	}
+
+	if throttlingConf.RPCRequestsPerSecond < 0 {
+		return throttlingConf, errors.New("rpc-requests-per-second must be a positive number or 0")
+	}
+
+	if throttlingConf.RPCBurst <= 0 {
+		throttlingConf.RPCBurst = 1
+	}
+
+	return throttlingConf, nil
 }
 
 // Reads the Viper mapstructure tag to get the valid keys for a given config struct
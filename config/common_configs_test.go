@@ -69,19 +69,45 @@ func (suite *ConfigTestSuite) TestValidateProbeConf() {
 	conf.ChainName = "fake-chain-name"
 	_, err = validateProbeConf(conf)
 	suite.Require().NoError(err)
+
+	conf.RPC = ""
+	conf.RPCs = []string{"http://fake-rpc-1", "https://fake-rpc-2"}
+	conf, err = validateProbeConf(conf)
+	suite.Require().NoError(err)
+	suite.Require().Equal("http://fake-rpc-1:80", conf.RPC)
+	suite.Require().Equal([]string{"http://fake-rpc-1:80", "https://fake-rpc-2:443"}, conf.RPCEndpoints())
+
+	conf.RPCSelection = "fake-selection"
+	_, err = validateProbeConf(conf)
+	suite.Require().Error(err)
 }
 
 func (suite *ConfigTestSuite) TestValidateThrottlingConf() {
-	conf := throttlingBase{
-		Throttling: -1,
+	// The removed throttling fails instead of being converted to a request rate
+	for _, throttling := range []float64{-1, 0.5, 2} {
+		_, err := validateThrottlingConf(throttlingBase{Throttling: throttling, RPCRequestsPerSecond: 5})
+		suite.Require().ErrorContains(err, "base.throttling is no longer supported")
 	}
 
-	err := validateThrottlingConf(conf)
-	suite.Require().Error(err)
+	// No limit by default
+	conf, err := validateThrottlingConf(throttlingBase{})
+	suite.Require().NoError(err)
+	suite.Require().Zero(conf.RPCRequestsPerSecond)
+
+	conf.RPCRequestsPerSecond = 5
+	conf, err = validateThrottlingConf(conf)
+	suite.Require().NoError(err)
+	suite.Require().Equal(5.0, conf.RPCRequestsPerSecond)
+	suite.Require().Equal(int64(1), conf.RPCBurst)
 
-	conf.Throttling = 0.5
-	err = validateThrottlingConf(conf)
+	conf.RPCBurst = 10
+	conf, err = validateThrottlingConf(conf)
 	suite.Require().NoError(err)
+	suite.Require().Equal(int64(10), conf.RPCBurst)
+
+	conf.RPCRequestsPerSecond = -1
+	_, err = validateThrottlingConf(conf)
+	suite.Require().Error(err)
 }
 
 func TestConfigSuite(t *testing.T) {
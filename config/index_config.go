@@ -8,51 +8,111 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// DB write failure policies, selectable with base.db-failure-policy
+const (
+	// Exit the application after a single reattempt of the failed DB write
+	FailurePolicyFailFast = "fail-fast"
+	// Record the block in the failed blocks tables and continue indexing
+	FailurePolicyRecordAndContinue = "record-and-continue"
+	// Retry the DB write with incremental backoff, recording the block in the failed blocks tables if all attempts fail
+	FailurePolicyRetry = "retry"
+)
+
 type IndexConfig struct {
 	Database Database
 	Base     indexBase
 	Log      log
 	Probe    Probe
 	Flags    flags
+	Metrics  metrics
+	Health   health
+	Gaps     gaps
 }
 
 type indexBase struct {
 	throttlingBase
 	retryBase
-	ReindexMessageType          string `mapstructure:"reindex-message-type"`
-	ReattemptFailedBlocks       bool   `mapstructure:"reattempt-failed-blocks"`
-	StartBlock                  int64  `mapstructure:"start-block"`
-	EndBlock                    int64  `mapstructure:"end-block"`
-	BlockInputFile              string `mapstructure:"block-input-file"`
-	ReIndex                     bool   `mapstructure:"reindex"`
-	RPCWorkers                  int64  `mapstructure:"rpc-workers"`
-	SkipBlockByHeightRPCRequest bool   `mapstructure:"skip-block-by-height-rpc-request"`
-	BlockTimer                  int64  `mapstructure:"block-timer"`
-	WaitForChain                bool   `mapstructure:"wait-for-chain"`
-	WaitForChainDelay           int64  `mapstructure:"wait-for-chain-delay"`
-	TransactionIndexingEnabled  bool   `mapstructure:"index-transactions"`
-	ExitWhenCaughtUp            bool   `mapstructure:"exit-when-caught-up"`
-	BlockEventIndexingEnabled   bool   `mapstructure:"index-block-events"`
-	FilterFile                  string `mapstructure:"filter-file"`
-	Dry                         bool   `mapstructure:"dry"`
+	ReindexMessageType          string  `mapstructure:"reindex-message-type"`
+	ReprocessFailedTxs          bool    `mapstructure:"reprocess-failed-txs"`
+	ReattemptFailedBlocks       bool    `mapstructure:"reattempt-failed-blocks"`
+	FailedBlockMaxAttempts      int64   `mapstructure:"failed-block-max-attempts"`
+	FailedBlockRetryInterval    int64   `mapstructure:"failed-block-retry-interval"`
+	FailedBlockRetryBackoff     int64   `mapstructure:"failed-block-retry-backoff"`
+	FailedBlockRetryMaxBackoff  int64   `mapstructure:"failed-block-retry-max-backoff"`
+	StartBlock                  int64   `mapstructure:"start-block"`
+	EndBlock                    int64   `mapstructure:"end-block"`
+	BlockInputFile              string  `mapstructure:"block-input-file"`
+	ReIndex                     bool    `mapstructure:"reindex"`
+	RPCWorkers                  int64   `mapstructure:"rpc-workers"`
+	ProcessingWorkers           int64   `mapstructure:"processing-workers"`
+	SkipBlockByHeightRPCRequest bool    `mapstructure:"skip-block-by-height-rpc-request"`
+	BlockTimer                  int64   `mapstructure:"block-timer"`
+	WaitForChain                bool    `mapstructure:"wait-for-chain"`
+	WaitForChainDelay           int64   `mapstructure:"wait-for-chain-delay"`
+	TransactionIndexingEnabled  bool    `mapstructure:"index-transactions"`
+	ExitWhenCaughtUp            bool    `mapstructure:"exit-when-caught-up"`
+	BlockEventIndexingEnabled   bool    `mapstructure:"index-block-events"`
+	FilterFile                  string  `mapstructure:"filter-file"`
+	Dry                         bool    `mapstructure:"dry"`
+	DBFailurePolicy             string  `mapstructure:"db-failure-policy"`
+	DBFailureRetryAttempts      int64   `mapstructure:"db-failure-retry-attempts"`
+	DBFailureRetryMaxWait       uint64  `mapstructure:"db-failure-retry-max-wait"`
+	DBBatchSize                 int64   `mapstructure:"db-batch-size"`
+	DBBatchWindow               float64 `mapstructure:"db-batch-window"`
+	DistributedEnqueue          bool    `mapstructure:"distributed-enqueue"`
+	LeaseRangeSize              int64   `mapstructure:"lease-range-size"`
+	LeaseDuration               int64   `mapstructure:"lease-duration"`
+	LeaseOwner                  string  `mapstructure:"lease-owner"`
+	EnrichDenoms                bool    `mapstructure:"enrich-denoms"`
+	DenomTraceFile              string  `mapstructure:"denom-trace-file"`
+	DenomEnrichmentInterval     int64   `mapstructure:"denom-enrichment-interval"`
 }
 
 // Flags for specific, deeper indexing behavior
 type flags struct {
 	IndexTxMessageRaw        bool `mapstructure:"index-tx-message-raw"`
+	IndexTxMessageJSON       bool `mapstructure:"index-tx-message-json"`
 	IndexEmptyTransactions   bool `mapstructure:"index-empty-transactions"`
 	BlockEventsBase64Encoded bool `mapstructure:"block-events-base64-encoded"`
 	IndexMessageEvents       bool `mapstructure:"index-message-events"`
+	IndexTxEvents            bool `mapstructure:"index-tx-events"`
+	IndexBlockSignatures     bool `mapstructure:"index-block-signatures"`
+	IndexBalanceChanges      bool `mapstructure:"index-balance-changes"`
+}
+
+// Optional Prometheus metrics server
+type metrics struct {
+	Enabled               bool   `mapstructure:"enabled"`
+	ListenAddress         string `mapstructure:"listen-address"`
+	ChainHeadPollInterval int64  `mapstructure:"chain-head-poll-interval"`
+}
+
+// Optional health and readiness HTTP endpoints
+type health struct {
+	Enabled            bool   `mapstructure:"enabled"`
+	ListenAddress      string `mapstructure:"listen-address"`
+	MaxCommitStaleness int64  `mapstructure:"max-commit-staleness"`
+}
+
+// Options for the gaps command
+type gaps struct {
+	Output  string `mapstructure:"output"`
+	Enqueue bool   `mapstructure:"enqueue"`
 }
 
 func SetupIndexSpecificFlags(conf *IndexConfig, cmd *cobra.Command) {
 	// chain indexing
 	cmd.PersistentFlags().Int64Var(&conf.Base.StartBlock, "base.start-block", 0, "block to start indexing at (use -1 to resume from highest block indexed)")
 	cmd.PersistentFlags().Int64Var(&conf.Base.EndBlock, "base.end-block", -1, "block to stop indexing at (use -1 to index indefinitely")
-	cmd.PersistentFlags().StringVar(&conf.Base.BlockInputFile, "base.block-input-file", "", "A file location containing a JSON list of block heights to index. Will override start and end block flags.")
+	cmd.PersistentFlags().StringVar(&conf.Base.BlockInputFile, "base.block-input-file", "", "A file location containing a JSON list of block heights to index, or the JSON list of gap ranges written by the gaps command. Will override start and end block flags.")
 	cmd.PersistentFlags().BoolVar(&conf.Base.ReIndex, "base.reindex", false, "if true, this will re-attempt to index blocks we have already indexed (defaults to false)")
 	cmd.PersistentFlags().BoolVar(&conf.Base.ReattemptFailedBlocks, "base.reattempt-failed-blocks", false, "re-enqueue failed blocks for reattempts at startup.")
+	cmd.PersistentFlags().Int64Var(&conf.Base.FailedBlockMaxAttempts, "base.failed-block-max-attempts", 0, "failed blocks that have failed this many times are no longer reattempted (use 0 to always reattempt)")
+	cmd.PersistentFlags().Int64Var(&conf.Base.FailedBlockRetryInterval, "base.failed-block-retry-interval", 0, "seconds between checks for failed blocks to retry while indexing (use 0 to disable background retries)")
+	cmd.PersistentFlags().Int64Var(&conf.Base.FailedBlockRetryBackoff, "base.failed-block-retry-backoff", 60, "seconds to wait after a block fails before retrying it in the background, doubled with every failed attempt")
+	cmd.PersistentFlags().Int64Var(&conf.Base.FailedBlockRetryMaxBackoff, "base.failed-block-retry-max-backoff", 3600, "max seconds to wait between background retries of a failed block")
 	cmd.PersistentFlags().StringVar(&conf.Base.ReindexMessageType, "base.reindex-message-type", "", "a Cosmos message type URL. When set, the block enqueue method will reindex all blocks between start and end block that contain this message type.")
+	cmd.PersistentFlags().BoolVar(&conf.Base.ReprocessFailedTxs, "base.reprocess-failed-txs", false, "if true, the block enqueue method will reindex the transactions of all blocks between start and end block that have failed txes or failed messages")
 	// block event indexing
 	cmd.PersistentFlags().BoolVar(&conf.Base.TransactionIndexingEnabled, "base.index-transactions", false, "enable transaction indexing?")
 	cmd.PersistentFlags().BoolVar(&conf.Base.BlockEventIndexingEnabled, "base.index-block-events", false, "enable block beginblocker and endblocker event indexing?")
@@ -61,6 +121,9 @@ func SetupIndexSpecificFlags(conf *IndexConfig, cmd *cobra.Command) {
 	// other base setting
 	cmd.PersistentFlags().BoolVar(&conf.Base.Dry, "base.dry", false, "index the chain but don't insert data in the DB.")
 	cmd.PersistentFlags().Int64Var(&conf.Base.RPCWorkers, "base.rpc-workers", 1, "the number of concurrent RPC request workers to spin up.")
+	cmd.PersistentFlags().Int64Var(&conf.Base.ProcessingWorkers, "base.processing-workers", 1, "the number of concurrent workers parsing RPC data into DB types. Parsed blocks are handed off to the DB in the order they were received.")
+	cmd.PersistentFlags().Float64Var(&conf.Base.RPCRequestsPerSecond, "base.rpc-requests-per-second", 0, "max requests per second made to each RPC endpoint, shared across all RPC workers (use 0 for no limit)")
+	cmd.PersistentFlags().Int64Var(&conf.Base.RPCBurst, "base.rpc-burst", 0, "max requests made to each RPC endpoint in a burst above the requests per second limit (defaults to 1 when the requests per second are limited)")
 	cmd.PersistentFlags().BoolVar(&conf.Base.SkipBlockByHeightRPCRequest, "base.skip-block-by-height-rpc-request", false, "skip the /block?height=<height> RPC request and only attempt the /block_results RPC request. Sometimes pruned nodes will not have return results for the block RPC request, but still return results for the block_result request.")
 	cmd.PersistentFlags().BoolVar(&conf.Base.WaitForChain, "base.wait-for-chain", false, "wait for chain to be in sync?")
 	cmd.PersistentFlags().Int64Var(&conf.Base.WaitForChainDelay, "base.wait-for-chain-delay", 10, "seconds to wait between each check for node to catch up to the chain")
@@ -68,12 +131,46 @@ func SetupIndexSpecificFlags(conf *IndexConfig, cmd *cobra.Command) {
 	cmd.PersistentFlags().BoolVar(&conf.Base.ExitWhenCaughtUp, "base.exit-when-caught-up", false, "Gets the latest block at runtime and exits when this block has been reached.")
 	cmd.PersistentFlags().Int64Var(&conf.Base.RequestRetryAttempts, "base.request-retry-attempts", 0, "number of RPC query retries to make")
 	cmd.PersistentFlags().Uint64Var(&conf.Base.RequestRetryMaxWait, "base.request-retry-max-wait", 30, "max retry incremental backoff wait time in seconds")
+	// db write failure handling
+	cmd.PersistentFlags().StringVar(&conf.Base.DBFailurePolicy, "base.db-failure-policy", FailurePolicyFailFast, "how to handle a failed DB write for a block: fail-fast stops indexing the chain, record-and-continue adds the block to the failed blocks tables and continues, retry reattempts the write with backoff before recording the block as failed")
+	cmd.PersistentFlags().Int64Var(&conf.Base.DBFailureRetryAttempts, "base.db-failure-retry-attempts", 3, "number of DB write reattempts to make when using the retry failure policy")
+	cmd.PersistentFlags().Uint64Var(&conf.Base.DBFailureRetryMaxWait, "base.db-failure-retry-max-wait", 30, "max DB write retry incremental backoff wait time in seconds when using the retry failure policy")
+	cmd.PersistentFlags().Int64Var(&conf.Base.DBBatchSize, "base.db-batch-size", 1, "number of blocks to write to the DB in a single transaction. Failed batches fall back to single block writes")
+	cmd.PersistentFlags().Float64Var(&conf.Base.DBBatchWindow, "base.db-batch-window", 5, "max seconds to wait for a DB batch to fill before writing the blocks collected so far")
+	// distributed indexing
+	cmd.PersistentFlags().BoolVar(&conf.Base.DistributedEnqueue, "base.distributed-enqueue", false, "if true, claim height ranges from a lease table in the DB so several indexer replicas can index the same chain without overlapping")
+	cmd.PersistentFlags().Int64Var(&conf.Base.LeaseRangeSize, "base.lease-range-size", 1000, "number of block heights claimed in a single lease when using distributed enqueue")
+	cmd.PersistentFlags().Int64Var(&conf.Base.LeaseDuration, "base.lease-duration", 300, "seconds a lease is held without a heartbeat before another replica can claim it when using distributed enqueue")
+	cmd.PersistentFlags().StringVar(&conf.Base.LeaseOwner, "base.lease-owner", "", "the name this replica holds leases under when using distributed enqueue (defaults to the hostname and process ID)")
+	// denom enrichment
+	cmd.PersistentFlags().BoolVar(&conf.Base.EnrichDenoms, "base.enrich-denoms", false, "if true, resolve the IBC denom traces and bank denom metadata of indexed denoms in the background, starting with a backfill of the existing denoms")
+	cmd.PersistentFlags().StringVar(&conf.Base.DenomTraceFile, "base.denom-trace-file", "", "a file location containing IBC denom traces in the JSON format of the denom_traces REST endpoint, used before querying traces from the chain")
+	cmd.PersistentFlags().Int64Var(&conf.Base.DenomEnrichmentInterval, "base.denom-enrichment-interval", 300, "seconds between checks for new denoms to resolve while indexing (use 0 to only resolve denoms at startup)")
 
 	// flags
 	cmd.PersistentFlags().BoolVar(&conf.Flags.IndexTxMessageRaw, "flags.index-tx-message-raw", false, "if true, this will index the raw message bytes. This will significantly increase the size of the database.")
+	cmd.PersistentFlags().BoolVar(&conf.Flags.IndexTxMessageJSON, "flags.index-tx-message-json", false, "if true, this will index the proto-JSON rendering of every message in a JSONB column so message fields can be queried in SQL. This will significantly increase the size of the database.")
 	cmd.PersistentFlags().BoolVar(&conf.Flags.IndexEmptyTransactions, "flags.index-empty-transactions", true, "if true, this will index transactions that have no messages. Setting this to false when filtering TX message types will result in no transactions being indexed if all message types are filtered out.")
 	cmd.PersistentFlags().BoolVar(&conf.Flags.BlockEventsBase64Encoded, "flags.block-events-base64-encoded", false, "if true, decode the block event attributes and keys as base64. Some versions of CometBFT encode the block event attributes and keys as base64 in the response from RPC.")
 	cmd.PersistentFlags().BoolVar(&conf.Flags.IndexMessageEvents, "flags.index-message-events", true, "if true, skip indexing message events if they are uneeded. This will save space in the database.")
+	cmd.PersistentFlags().BoolVar(&conf.Flags.IndexTxEvents, "flags.index-tx-events", true, "if true, index the events of a transaction that belong to no message, such as the fee deduction and ante handler events. Only Cosmos SDK v0.50 and later mark these events in successful transactions, for earlier versions they are only indexed for failed transactions.")
+	cmd.PersistentFlags().BoolVar(&conf.Flags.IndexBlockSignatures, "flags.index-block-signatures", false, "if true, index the validator signatures of the last commit of every block, used for validator uptime and missed block analytics. This will significantly increase the size of the database.")
+	cmd.PersistentFlags().BoolVar(&conf.Flags.IndexBalanceChanges, "flags.index-balance-changes", false, "if true, index a ledger of per-address, per-denom balance changes derived from the coin_spent and coin_received events of txes and, if block events are indexed, of BeginBlock and EndBlock events.")
+
+	// metrics
+	cmd.PersistentFlags().BoolVar(&conf.Metrics.Enabled, "metrics.enabled", false, "if true, serve Prometheus metrics over HTTP")
+	cmd.PersistentFlags().StringVar(&conf.Metrics.ListenAddress, "metrics.listen-address", "", "the address the metrics server listens on, metrics are served on /metrics. Required when metrics are enabled")
+	cmd.PersistentFlags().Int64Var(&conf.Metrics.ChainHeadPollInterval, "metrics.chain-head-poll-interval", 30, "seconds between latest block height requests used for the chain head lag metric")
+
+	// health
+	cmd.PersistentFlags().BoolVar(&conf.Health.Enabled, "health.enabled", false, "if true, serve /healthz and /readyz endpoints over HTTP")
+	cmd.PersistentFlags().StringVar(&conf.Health.ListenAddress, "health.listen-address", ":8080", "the address the health server listens on")
+	cmd.PersistentFlags().Int64Var(&conf.Health.MaxCommitStaleness, "health.max-commit-staleness", 600, "seconds since the last block was committed to the DB before /readyz reports the indexer as not ready (use 0 to disable)")
+}
+
+func SetupGapsFlags(gapsConf *gaps, cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&gapsConf.Output, "gaps.output", "", "a file location to write the gap block ranges to as a JSON list usable as a base.block-input-file")
+	cmd.PersistentFlags().BoolVar(&gapsConf.Enqueue, "gaps.enqueue", false, "if true, index the gap blocks after they are found")
 }
 
 func (conf *IndexConfig) Validate() error {
@@ -91,7 +188,7 @@ func (conf *IndexConfig) Validate() error {
 
 	conf.Probe = probeConf
 
-	err = validateThrottlingConf(conf.Base.throttlingBase)
+	conf.Base.throttlingBase, err = validateThrottlingConf(conf.Base.throttlingBase)
 	if err != nil {
 		return err
 	}
@@ -102,6 +199,59 @@ func (conf *IndexConfig) Validate() error {
 		return err
 	}
 
+	err = conf.validateFailurePolicyValues()
+	if err != nil {
+		return err
+	}
+
+	err = conf.validateDBBatchValues()
+	if err != nil {
+		return err
+	}
+
+	err = conf.validateLeaseValues()
+	if err != nil {
+		return err
+	}
+
+	err = conf.validateFailedBlockRetryValues()
+	if err != nil {
+		return err
+	}
+
+	err = conf.validateDenomEnrichmentValues()
+	if err != nil {
+		return err
+	}
+
+	if conf.Base.ProcessingWorkers <= 0 {
+		conf.Base.ProcessingWorkers = 1
+	}
+
+	if conf.Metrics.Enabled {
+		if conf.Metrics.ListenAddress == "" {
+			return errors.New("metrics.listen-address must be set when metrics are enabled")
+		}
+
+		if conf.Metrics.ChainHeadPollInterval <= 0 {
+			return errors.New("metrics.chain-head-poll-interval must be greater than 0")
+		}
+	}
+
+	if conf.Health.Enabled {
+		if conf.Health.ListenAddress == "" {
+			return errors.New("health.listen-address must be set when health endpoints are enabled")
+		}
+
+		if conf.Health.MaxCommitStaleness < 0 {
+			return errors.New("health.max-commit-staleness must be a positive number or 0")
+		}
+
+		if conf.Metrics.Enabled && conf.Metrics.ListenAddress == conf.Health.ListenAddress {
+			return errors.New("health.listen-address and metrics.listen-address must be different")
+		}
+	}
+
 	if conf.Base.BlockInputFile != "" {
 		if _, err := os.Stat(conf.Base.BlockInputFile); os.IsNotExist(err) {
 			return fmt.Errorf("base.block-input-file %s does not exist", conf.Base.BlockInputFile)
@@ -123,6 +273,10 @@ func (conf *IndexConfig) validateBlockInputValues() error {
 		return errors.New("must enable at least one of base.index-transactions or base.index-block-events")
 	}
 
+	if conf.Base.ReprocessFailedTxs && !conf.Base.TransactionIndexingEnabled {
+		return errors.New("base.reprocess-failed-txs requires base.index-transactions")
+	}
+
 	if conf.Base.BlockInputFile != "" {
 		return nil
 	}
@@ -146,6 +300,102 @@ func (conf *IndexConfig) validateBlockInputValues() error {
 	return nil
 }
 
+func (conf *IndexConfig) validateFailurePolicyValues() error {
+	// Preserve the previous behavior when unset
+	if conf.Base.DBFailurePolicy == "" {
+		conf.Base.DBFailurePolicy = FailurePolicyFailFast
+	}
+
+	switch conf.Base.DBFailurePolicy {
+	case FailurePolicyFailFast, FailurePolicyRecordAndContinue:
+	case FailurePolicyRetry:
+		if conf.Base.DBFailureRetryAttempts <= 0 {
+			return errors.New("base.db-failure-retry-attempts must be greater than 0 when using the retry failure policy")
+		}
+	default:
+		return fmt.Errorf("base.db-failure-policy must be one of %s, %s or %s", FailurePolicyFailFast, FailurePolicyRecordAndContinue, FailurePolicyRetry)
+	}
+
+	return nil
+}
+
+func (conf *IndexConfig) validateDBBatchValues() error {
+	// Preserve the previous single block writes when unset
+	if conf.Base.DBBatchSize <= 0 {
+		conf.Base.DBBatchSize = 1
+	}
+
+	if conf.Base.DBBatchWindow < 0 {
+		return errors.New("base.db-batch-window must be a positive number or 0")
+	}
+
+	return nil
+}
+
+func (conf *IndexConfig) validateFailedBlockRetryValues() error {
+	if conf.Base.FailedBlockMaxAttempts < 0 {
+		return errors.New("base.failed-block-max-attempts must be a positive number or 0")
+	}
+
+	if conf.Base.FailedBlockRetryInterval < 0 {
+		return errors.New("base.failed-block-retry-interval must be a positive number or 0")
+	}
+
+	if conf.Base.FailedBlockRetryInterval > 0 {
+		if conf.Base.FailedBlockRetryBackoff <= 0 {
+			return errors.New("base.failed-block-retry-backoff must be greater than 0")
+		}
+
+		if conf.Base.FailedBlockRetryMaxBackoff < conf.Base.FailedBlockRetryBackoff {
+			return errors.New("base.failed-block-retry-max-backoff must be greater than or equal to base.failed-block-retry-backoff")
+		}
+	}
+
+	return nil
+}
+
+func (conf *IndexConfig) validateDenomEnrichmentValues() error {
+	if conf.Base.DenomEnrichmentInterval < 0 {
+		return errors.New("base.denom-enrichment-interval must be a positive number or 0")
+	}
+
+	if conf.Base.DenomTraceFile != "" {
+		if _, err := os.Stat(conf.Base.DenomTraceFile); os.IsNotExist(err) {
+			return fmt.Errorf("base.denom-trace-file %s does not exist", conf.Base.DenomTraceFile)
+		}
+	}
+
+	return nil
+}
+
+func (conf *IndexConfig) validateLeaseValues() error {
+	if !conf.Base.DistributedEnqueue {
+		return nil
+	}
+
+	if conf.Base.BlockInputFile != "" || conf.Base.ReindexMessageType != "" || conf.Base.ReprocessFailedTxs {
+		return errors.New("base.distributed-enqueue can not be used with base.block-input-file, base.reindex-message-type or base.reprocess-failed-txs")
+	}
+
+	if conf.Base.LeaseRangeSize <= 0 {
+		return errors.New("base.lease-range-size must be greater than 0")
+	}
+
+	if conf.Base.LeaseDuration <= 0 {
+		return errors.New("base.lease-duration must be greater than 0")
+	}
+
+	if conf.Base.LeaseOwner == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("failed to get hostname for base.lease-owner: %w", err)
+		}
+		conf.Base.LeaseOwner = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	return nil
+}
+
 func CheckSuperfluousIndexKeys(keys []string) []string {
 	validKeys := make(map[string]struct{})
 
@@ -170,6 +420,26 @@ func CheckSuperfluousIndexKeys(keys []string) []string {
 		validKeys[key] = struct{}{}
 	}
 
+	for _, key := range getValidConfigKeys(metrics{}, "metrics") {
+		validKeys[key] = struct{}{}
+	}
+
+	for _, key := range getValidConfigKeys(health{}, "health") {
+		validKeys[key] = struct{}{}
+	}
+
+	for _, key := range getValidConfigKeys(gaps{}, "gaps") {
+		validKeys[key] = struct{}{}
+	}
+
+	// The server section is read by the serve command from the same config file
+	for _, key := range getValidConfigKeys(server{}, "server") {
+		validKeys[key] = struct{}{}
+	}
+
+	// Chain entries are checked separately by CheckSuperfluousChainKeys
+	validKeys["chains"] = struct{}{}
+
 	// Check keys
 	ignoredKeys := make([]string, 0)
 	for _, key := range keys {
@@ -180,3 +450,30 @@ func CheckSuperfluousIndexKeys(keys []string) []string {
 
 	return ignoredKeys
 }
+
+// CheckSuperfluousChainKeys returns the keys of a chains entry that can not be set per chain.
+// Only the probe, base and flags sections apply to a single chain, the other sections are shared by all chains.
+func CheckSuperfluousChainKeys(keys []string) []string {
+	validKeys := make(map[string]struct{})
+
+	addProbeConfigKeys(validKeys)
+
+	for _, section := range []any{indexBase{}, throttlingBase{}, retryBase{}} {
+		for _, key := range getValidConfigKeys(section, "base") {
+			validKeys[key] = struct{}{}
+		}
+	}
+
+	for _, key := range getValidConfigKeys(flags{}, "flags") {
+		validKeys[key] = struct{}{}
+	}
+
+	ignoredKeys := make([]string, 0)
+	for _, key := range keys {
+		if _, ok := validKeys[key]; !ok {
+			ignoredKeys = append(ignoredKeys, key)
+		}
+	}
+
+	return ignoredKeys
+}
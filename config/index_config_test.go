@@ -52,6 +52,45 @@ func (suite *IndexConfigTestSuite) TestIndexConfig() {
 	conf.Base.EndBlock = 2
 	err = conf.Validate()
 	suite.Require().NoError(err)
+
+	// The metrics listen address has no default
+	conf.Metrics.Enabled = true
+	conf.Metrics.ChainHeadPollInterval = 30
+	err = conf.Validate()
+	suite.Require().ErrorContains(err, "metrics.listen-address must be set")
+
+	conf.Metrics.ListenAddress = ":9464"
+	err = conf.Validate()
+	suite.Require().NoError(err)
+
+	conf.Health.Enabled = true
+	conf.Health.ListenAddress = ":9464"
+	err = conf.Validate()
+	suite.Require().ErrorContains(err, "must be different")
+}
+
+func (suite *IndexConfigTestSuite) TestValidateFailurePolicyValues() {
+	conf := IndexConfig{}
+
+	err := conf.validateFailurePolicyValues()
+	suite.Require().NoError(err)
+	suite.Require().Equal(FailurePolicyFailFast, conf.Base.DBFailurePolicy)
+
+	conf.Base.DBFailurePolicy = "fake-policy"
+	err = conf.validateFailurePolicyValues()
+	suite.Require().Error(err)
+
+	conf.Base.DBFailurePolicy = FailurePolicyRecordAndContinue
+	err = conf.validateFailurePolicyValues()
+	suite.Require().NoError(err)
+
+	conf.Base.DBFailurePolicy = FailurePolicyRetry
+	err = conf.validateFailurePolicyValues()
+	suite.Require().Error(err)
+
+	conf.Base.DBFailureRetryAttempts = 3
+	err = conf.validateFailurePolicyValues()
+	suite.Require().NoError(err)
 }
 
 func (suite *IndexConfigTestSuite) TestCheckSuperfluousIndexKeys() {
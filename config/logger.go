@@ -1,6 +1,7 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -10,70 +11,111 @@ import (
 	zlog "github.com/rs/zerolog/log"
 )
 
-type Logger struct{}
+type Logger struct {
+	fields map[string]string
+}
 
 // Log is exposed on the config as a drop-in replacement for our old logger
 var Log *Logger
 
+type loggerContextKey struct{}
+
+// With returns a logger that adds the key and value to every log line, used to tell apart the logs of concurrently indexed chains
+func (l *Logger) With(key string, value string) *Logger {
+	fields := map[string]string{key: value}
+	if l != nil {
+		for k, v := range l.fields {
+			if _, ok := fields[k]; !ok {
+				fields[k] = v
+			}
+		}
+	}
+	return &Logger{fields: fields}
+}
+
+// ContextWithLogger returns a copy of the context carrying the logger
+func ContextWithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LogCtx returns the logger carried by the context, falling back to the global Log
+func LogCtx(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*Logger); ok {
+		return logger
+	}
+	return Log
+}
+
+// withFields adds the logger fields to the event. Fields are read from the logger on every call so they apply to the global logger as it is reconfigured.
+func (l *Logger) withFields(event *zerolog.Event) *zerolog.Event {
+	if l == nil {
+		return event
+	}
+	for key, value := range l.fields {
+		event = event.Str(key, value)
+	}
+	return event
+}
+
 // These functions are provided to reduce refactoring.
 func (l *Logger) Debug(msg string, err ...error) {
 	if len(err) == 1 {
-		zlog.Debug().Err(err[0]).Msg(msg)
+		l.withFields(zlog.Debug()).Err(err[0]).Msg(msg)
 		return
 	}
-	zlog.Debug().Msg(msg)
+	l.withFields(zlog.Debug()).Msg(msg)
 }
 
 func (l *Logger) Debugf(msg string, args ...interface{}) {
-	zlog.Debug().Msg(fmt.Sprintf(msg, args...))
+	l.withFields(zlog.Debug()).Msg(fmt.Sprintf(msg, args...))
 }
 
 func (l *Logger) Info(msg string, err ...error) {
 	if len(err) == 1 {
-		zlog.Info().Err(err[0]).Msg(msg)
+		l.withFields(zlog.Info()).Err(err[0]).Msg(msg)
 		return
 	}
-	zlog.Info().Msg(msg)
+	l.withFields(zlog.Info()).Msg(msg)
 }
 
 func (l *Logger) Infof(msg string, args ...interface{}) {
-	zlog.Info().Msg(fmt.Sprintf(msg, args...))
+	l.withFields(zlog.Info()).Msg(fmt.Sprintf(msg, args...))
 }
 
 func (l *Logger) Warn(msg string, err ...error) {
 	if len(err) == 1 {
-		zlog.Warn().Err(err[0]).Msg(msg)
+		l.withFields(zlog.Warn()).Err(err[0]).Msg(msg)
 		return
 	}
-	zlog.Warn().Msg(msg)
+	l.withFields(zlog.Warn()).Msg(msg)
 }
 
 func (l *Logger) Warnf(msg string, args ...interface{}) {
-	zlog.Warn().Msg(fmt.Sprintf(msg, args...))
+	l.withFields(zlog.Warn()).Msg(fmt.Sprintf(msg, args...))
 }
 
 func (l *Logger) Error(msg string, err ...error) {
 	if len(err) == 1 {
-		zlog.Error().Err(err[0]).Msg(msg)
+		l.withFields(zlog.Error()).Err(err[0]).Msg(msg)
 		return
 	}
-	zlog.Error().Msg(msg)
+	l.withFields(zlog.Error()).Msg(msg)
 }
 
 func (l *Logger) Errorf(msg string, args ...interface{}) {
-	zlog.Error().Msg(fmt.Sprintf(msg, args...))
+	l.withFields(zlog.Error()).Msg(fmt.Sprintf(msg, args...))
 }
 
 func (l *Logger) Fatal(msg string, err ...error) {
 	if len(err) == 1 {
-		zlog.Fatal().Err(err[0]).Msg(msg)
+		l.withFields(zlog.Fatal()).Err(err[0]).Msg(msg)
 		return
 	}
-	zlog.Fatal().Msg(msg)
+	l.withFields(zlog.Fatal()).Msg(msg)
 }
 
 func (l *Logger) Fatalf(msg string, args ...interface{}) {
-	zlog.Fatal().Msg(fmt.Sprintf(msg, args...))
+	l.withFields(zlog.Fatal()).Msg(fmt.Sprintf(msg, args...))
 }
 
 func DoConfigureLogger(logPath string, logLevel string, prettyLogging bool) {
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+type ServeConfig struct {
+	Database Database
+	Log      log
+	Server   server
+}
+
+// Options for the read-only REST API of the serve command
+type server struct {
+	ListenAddress   string `mapstructure:"listen-address"`
+	DefaultPageSize int64  `mapstructure:"default-page-size"`
+	MaxPageSize     int64  `mapstructure:"max-page-size"`
+}
+
+func SetupServeFlags(serveConf *ServeConfig, cmd *cobra.Command) {
+	SetupLogFlags(&serveConf.Log, cmd)
+	SetupDatabaseFlags(&serveConf.Database, cmd)
+
+	cmd.PersistentFlags().StringVar(&serveConf.Server.ListenAddress, "server.listen-address", ":8081", "the address the REST API server listens on")
+	cmd.PersistentFlags().Int64Var(&serveConf.Server.DefaultPageSize, "server.default-page-size", 50, "the number of items returned per page when a request sets no limit")
+	cmd.PersistentFlags().Int64Var(&serveConf.Server.MaxPageSize, "server.max-page-size", 500, "the highest limit a request can set on the number of items returned per page")
+}
+
+func (conf *ServeConfig) Validate() error {
+	err := validateDatabaseConf(conf.Database)
+	if err != nil {
+		return err
+	}
+
+	if conf.Server.ListenAddress == "" {
+		return errors.New("server.listen-address must be set")
+	}
+
+	if conf.Server.DefaultPageSize <= 0 {
+		return errors.New("server.default-page-size must be greater than 0")
+	}
+
+	if conf.Server.MaxPageSize < conf.Server.DefaultPageSize {
+		return errors.New("server.max-page-size must be greater than or equal to server.default-page-size")
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ServeConfigTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ServeConfigTestSuite) TestServeConfig() {
+	conf := ServeConfig{
+		Database: Database{
+			Host:     "fake-host",
+			Port:     "5432",
+			Database: "fake-database",
+			User:     "fake-user",
+			Password: "fake-password",
+		},
+	}
+
+	err := conf.Validate()
+	suite.Require().Error(err)
+
+	conf.Server.ListenAddress = ":8081"
+	err = conf.Validate()
+	suite.Require().Error(err)
+
+	conf.Server.DefaultPageSize = 50
+	err = conf.Validate()
+	suite.Require().Error(err)
+
+	conf.Server.MaxPageSize = 500
+	err = conf.Validate()
+	suite.Require().NoError(err)
+
+	// Server keys are valid in the shared config file
+	suite.Require().Empty(CheckSuperfluousIndexKeys([]string{"server.listen-address"}))
+}
+
+func TestServeConfig(t *testing.T) {
+	suite.Run(t, new(ServeConfigTestSuite))
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	txtypes "github.com/DefiantLabs/cosmos-indexer/cosmos/modules/tx"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"github.com/DefiantLabs/cosmos-indexer/util"
+	"github.com/cosmos/cosmos-sdk/types"
+	authTypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+)
+
+// ProcessBalanceChanges derives the balance changes of a coin_spent or coin_received event, any other event changes no balance.
+// Coins that cannot be parsed are skipped.
+func ProcessBalanceChanges(event txtypes.LogMessageEvent) []models.BalanceChange {
+	if event.Type != txtypes.EventTypeCoinSpent && event.Type != txtypes.EventTypeCoinReceived {
+		return nil
+	}
+
+	balanceEvents, err := txtypes.ParseCoinBalanceEvent(event)
+	if err != nil {
+		config.Log.Warnf("Error parsing %s event, skipping its balance changes: %v", event.Type, err)
+		return nil
+	}
+
+	var balanceChanges []models.BalanceChange
+	for _, balanceEvent := range balanceEvents {
+		for _, coinString := range strings.Split(balanceEvent.Amount, ",") {
+			if coinString == "" {
+				continue
+			}
+
+			coin, err := types.ParseCoinNormalized(coinString)
+			if err != nil {
+				config.Log.Warnf("Error parsing coin %s of %s event, skipping its balance change: %v", coinString, event.Type, err)
+				continue
+			}
+
+			if coin.Amount.IsZero() {
+				continue
+			}
+
+			amount := util.ToNumeric(coin.Amount.BigInt())
+			if balanceEvent.Spent {
+				amount = amount.Neg()
+			}
+
+			balanceChanges = append(balanceChanges, models.BalanceChange{
+				Address: models.Address{Address: balanceEvent.Address},
+				Denom:   models.Denom{Base: coin.Denom},
+				Amount:  amount,
+			})
+		}
+	}
+
+	return balanceChanges
+}
+
+// ProcessFeeBalanceChanges derives the fee deduction of a tx from its fees, for txes whose fee events are not known.
+// The fee is paid by the fee granter if there is one, otherwise by the fee payer, and is received by the fee collector module account.
+func ProcessFeeBalanceChanges(tx models.Tx) []models.BalanceChange {
+	payer := tx.FeePayerAddress
+	if tx.FeeGranterAddress != nil {
+		payer = tx.FeeGranterAddress
+	}
+
+	if payer == nil || len(tx.Fees) == 0 {
+		return nil
+	}
+
+	prefix, _ := models.ClassifyAddress(payer.Address)
+	if prefix == "" {
+		return nil
+	}
+
+	feeCollector, err := types.Bech32ifyAddressBytes(prefix, authTypes.NewModuleAddress(authTypes.FeeCollectorName))
+	if err != nil {
+		config.Log.Warnf("Error encoding the fee collector address with prefix %s, skipping the fee balance changes: %v", prefix, err)
+		return nil
+	}
+
+	var balanceChanges []models.BalanceChange
+	for _, fee := range tx.Fees {
+		balanceChanges = append(balanceChanges,
+			models.BalanceChange{
+				Address: models.Address{Address: payer.Address},
+				Denom:   models.Denom{Base: fee.Denomination.Base},
+				Amount:  fee.Amount.Neg(),
+			},
+			models.BalanceChange{
+				Address: models.Address{Address: feeCollector},
+				Denom:   models.Denom{Base: fee.Denomination.Base},
+				Amount:  fee.Amount,
+			},
+		)
+	}
+
+	return balanceChanges
+}
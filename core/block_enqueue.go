@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"math"
 	"os"
@@ -13,7 +14,6 @@ import (
 	"github.com/DefiantLabs/cosmos-indexer/db/models"
 	"github.com/DefiantLabs/cosmos-indexer/rpc"
 	"github.com/DefiantLabs/cosmos-indexer/util"
-	"github.com/DefiantLabs/probe/client"
 	"gorm.io/gorm"
 )
 
@@ -25,13 +25,79 @@ type EnqueueData struct {
 	IndexTransactions bool
 }
 
-func GenerateBlockFileEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, client *client.ChainClient, chainID uint, blockInputFile string) (func(chan *EnqueueData) error, error) {
-	return func(blockChan chan *EnqueueData) error {
+// enqueueBlock sends the block to the enqueue channel, returning the context error if the context is cancelled before the send completes
+func enqueueBlock(ctx context.Context, blockChan chan *EnqueueData, block *EnqueueData) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case blockChan <- block:
+		return nil
+	}
+}
+
+// Number of heights looked up at a time when checking which blocks after the checkpoint have already been indexed
+const indexedBlockWindowSize = 1000
+
+// indexedBlockWindow looks up the blocks already in the DB one window of heights at a time,
+// so the enqueue function does not need to hold every indexed block in memory
+type indexedBlockWindow struct {
+	db      *gorm.DB
+	chainID uint
+	start   int64
+	end     int64
+	blocks  map[int64]models.Block
+}
+
+// get returns the block at height if it is in the DB, loading the window starting at height if it is outside the current one
+func (w *indexedBlockWindow) get(height int64) (models.Block, bool, error) {
+	if w.blocks == nil || height < w.start || height > w.end {
+		blocks, err := dbTypes.GetBlocksFromStart(w.db, w.chainID, height, height+indexedBlockWindowSize-1)
+		if err != nil {
+			return models.Block{}, false, err
+		}
+
+		w.start, w.end = height, height+indexedBlockWindowSize-1
+		w.blocks = make(map[int64]models.Block, len(blocks))
+		for _, block := range blocks {
+			w.blocks[block.Height] = block
+		}
+	}
+
+	block, ok := w.blocks[height]
+	return block, ok, nil
+}
+
+// Delay between checks for new blocks once the enqueue function has caught up to the chain or filled the queue
+const enqueuePollInterval = time.Second
+
+// waitForNextPoll waits for the enqueue poll interval, returning the context error if the context is cancelled while waiting
+func waitForNextPoll(ctx context.Context) error {
+	timer := time.NewTimer(enqueuePollInterval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// GenerateBlockFileEnqueueFunction enqueues the heights listed in the block input file. The file is either a JSON list of heights
+// or the JSON list of gap ranges written by the gaps command.
+func GenerateBlockFileEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, rpcPool *rpc.Pool, chainID uint, blockInputFile string) (func(context.Context, chan *EnqueueData) error, error) {
+	return func(ctx context.Context, blockChan chan *EnqueueData) error {
 		plan, err := os.ReadFile(blockInputFile)
 		if err != nil {
-			config.Log.Errorf("Error reading block input file. Err: %v", err)
+			config.LogCtx(ctx).Errorf("Error reading block input file. Err: %v", err)
 			return err
 		}
+
+		var gaps []dbTypes.BlockGap
+		if err := json.Unmarshal(plan, &gaps); err == nil && len(gaps) != 0 {
+			return enqueueBlockFileGaps(ctx, blockChan, cfg, rpcPool, gaps)
+		}
+
 		var blocksToIndex []uint64
 		err = json.Unmarshal(plan, &blocksToIndex)
 		if err != nil {
@@ -39,16 +105,16 @@ func GenerateBlockFileEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, clien
 
 			switch {
 			case errString == "json: cannot unmarshal string into Go value of type int":
-				config.Log.Errorf("Error parsing block input file. Err: Found non-integer value in block array")
+				config.LogCtx(ctx).Errorf("Error parsing block input file. Err: Found non-integer value in block array")
 				return err
 			case errString == "cannot unmarshal object into Go value of type []uint64":
-				config.Log.Errorf("Error parsing block input file. Err: Found object that could not be parsed into an array of integers")
+				config.LogCtx(ctx).Errorf("Error parsing block input file. Err: Found object that could not be parsed into an array of integers")
 				return err
 			case strings.Contains(errString, "cannot unmarshal number"):
-				config.Log.Errorf("Error parsing block input file. Err: Found number that could not be parsed into Go unsigned integer")
+				config.LogCtx(ctx).Errorf("Error parsing block input file. Err: Found number that could not be parsed into Go unsigned integer")
 				return err
 			default:
-				config.Log.Errorf("Error parsing block input file. Err: %v", err)
+				config.LogCtx(ctx).Errorf("Error parsing block input file. Err: %v", err)
 				return err
 			}
 		}
@@ -58,9 +124,15 @@ func GenerateBlockFileEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, clien
 		sort.Slice(blocksToIndex, func(i, j int) bool { return blocksToIndex[i] < blocksToIndex[j] })
 
 		// Get latest block height and check to see if we are trying to index blocks outside range
-		earliestBlock, latestBlock, err := rpc.GetEarliestAndLatestBlockHeights(client)
+		var earliestBlock, latestBlock int64
+		err = rpcPool.Do(ctx, func(endpoint *rpc.Endpoint) error {
+			var err error
+			earliestBlock, latestBlock, err = rpc.GetEarliestAndLatestBlockHeights(endpoint.ChainClient)
+			return err
+		})
 		if err != nil {
-			config.Log.Fatal("Error getting blockchain latest height. Err: %v", err)
+			config.LogCtx(ctx).Errorf("Error getting blockchain latest height. Err: %v", err)
+			return err
 		}
 
 		unindexableBlockHeights := []uint64{}
@@ -74,32 +146,117 @@ func GenerateBlockFileEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, clien
 		}
 
 		if len(unindexableBlockHeights) != 0 {
-			config.Log.Warnf("The following blocks are past the blockchain earliest height (%d) and latest height (%d) and will be skipped: %v", earliestBlock, latestBlock, unindexableBlockHeights)
+			config.LogCtx(ctx).Warnf("The following blocks are past the blockchain earliest height (%d) and latest height (%d) and will be skipped: %v", earliestBlock, latestBlock, unindexableBlockHeights)
 		}
 
 		if len(blockInRange) == 0 {
-			config.Log.Infof("No blocks to index within blockchain earliest height (%d) and latest height (%d), exiting", earliestBlock, latestBlock)
+			config.LogCtx(ctx).Infof("No blocks to index within blockchain earliest height (%d) and latest height (%d), exiting", earliestBlock, latestBlock)
 			return nil
 		}
 
 		// Add jobs to the queue to be processed
 		for _, height := range blockInRange {
-			if cfg.Base.Throttling != 0 {
-				time.Sleep(time.Second * time.Duration(cfg.Base.Throttling))
-			}
-			config.Log.Debugf("Sending block %v to be indexed.", height)
+			config.LogCtx(ctx).Debugf("Sending block %v to be indexed.", height)
 			// Add the new block to the queue
-			blockChan <- &EnqueueData{
+			err := enqueueBlock(ctx, blockChan, &EnqueueData{
 				IndexBlockEvents:  cfg.Base.BlockEventIndexingEnabled,
 				IndexTransactions: cfg.Base.TransactionIndexingEnabled,
 				Height:            int64(height),
+			})
+			if err != nil {
+				return err
 			}
 		}
 		return nil
 	}, nil
 }
 
-func GenerateMsgTypeEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, chainID uint, msgType string) (func(chan *EnqueueData) error, error) {
+// enqueueBlockFileGaps enqueues the gap ranges of a block input file that are within the earliest and latest height of the chain.
+// Each gap only indexes the datasets it is missing out of the enabled datasets.
+func enqueueBlockFileGaps(ctx context.Context, blockChan chan *EnqueueData, cfg config.IndexConfig, rpcPool *rpc.Pool, gaps []dbTypes.BlockGap) error {
+	var earliestBlock, latestBlock int64
+	err := rpcPool.Do(ctx, func(endpoint *rpc.Endpoint) error {
+		var err error
+		earliestBlock, latestBlock, err = rpc.GetEarliestAndLatestBlockHeights(endpoint.ChainClient)
+		return err
+	})
+	if err != nil {
+		config.LogCtx(ctx).Errorf("Error getting blockchain latest height. Err: %v", err)
+		return err
+	}
+
+	var inRange []dbTypes.BlockGap
+	for _, gap := range gaps {
+		if gap.StartHeight < earliestBlock || gap.EndHeight > latestBlock {
+			config.LogCtx(ctx).Warnf("Blocks %d to %d are partly past the blockchain earliest height (%d) and latest height (%d), skipping the blocks out of range", gap.StartHeight, gap.EndHeight, earliestBlock, latestBlock)
+		}
+
+		gap.StartHeight = max(gap.StartHeight, earliestBlock)
+		gap.EndHeight = min(gap.EndHeight, latestBlock)
+		gap.Transactions = gap.Transactions && cfg.Base.TransactionIndexingEnabled
+		gap.BlockEvents = gap.BlockEvents && cfg.Base.BlockEventIndexingEnabled
+		if gap.StartHeight <= gap.EndHeight && (gap.Transactions || gap.BlockEvents) {
+			inRange = append(inRange, gap)
+		}
+	}
+
+	enqueue, err := GenerateBlockGapsEnqueueFunction(inRange)
+	if err != nil {
+		return err
+	}
+	return enqueue(ctx, blockChan)
+}
+
+// GenerateBlockGapsEnqueueFunction enqueues every height in the gaps, only indexing the datasets each gap is missing.
+// Heights are enqueued as the gap ranges are walked, so large gaps are not held in memory.
+func GenerateBlockGapsEnqueueFunction(gaps []dbTypes.BlockGap) (func(context.Context, chan *EnqueueData) error, error) {
+	return func(ctx context.Context, blockChan chan *EnqueueData) error {
+		for _, gap := range gaps {
+			config.LogCtx(ctx).Infof("Enqueuing %s blocks %d to %d", gap.Reason, gap.StartHeight, gap.EndHeight)
+			for height := gap.StartHeight; height <= gap.EndHeight; height++ {
+				err := enqueueBlock(ctx, blockChan, &EnqueueData{
+					Height:            height,
+					IndexBlockEvents:  gap.BlockEvents,
+					IndexTransactions: gap.Transactions,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}, nil
+}
+
+// GenerateFailedTxsEnqueueFunction enqueues the blocks between the start and end block that have failed transactions or failed messages,
+// so they can be reprocessed after the missing proto definitions have been registered. Only transactions are indexed.
+func GenerateFailedTxsEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, chainID uint) (func(context.Context, chan *EnqueueData) error, error) {
+	heights, err := dbTypes.GetFailedTxHeights(db, chainID, cfg.Base.StartBlock, cfg.Base.EndBlock)
+	if err != nil {
+		config.LogCtx(db.Statement.Context).Errorf("Error checking DB for blocks with failed txes. Err: %v", err)
+		return nil, err
+	}
+
+	config.LogCtx(db.Statement.Context).Infof("Found %d blocks with failed txes or messages to reprocess", len(heights))
+
+	return func(ctx context.Context, blockChan chan *EnqueueData) error {
+		for _, height := range heights {
+			config.LogCtx(ctx).Debugf("Sending block %v to be reprocessed.", height)
+			err := enqueueBlock(ctx, blockChan, &EnqueueData{
+				Height:            height,
+				IndexTransactions: true,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, nil
+}
+
+func GenerateMsgTypeEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, chainID uint, msgType string) (func(context.Context, chan *EnqueueData) error, error) {
 	// get the block range
 	startBlock := cfg.Base.StartBlock
 	endBlock := cfg.Base.EndBlock
@@ -116,42 +273,51 @@ func GenerateMsgTypeEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, chainID
 							WHERE height >= ? AND height <= ? AND chain_id = ?::int;
 							`, msgType, startBlock, endBlock, chainID).Rows()
 	if err != nil {
-		config.Log.Errorf("Error checking DB for blocks to reindex. Err: %v", err)
+		config.LogCtx(db.Statement.Context).Errorf("Error checking DB for blocks to reindex. Err: %v", err)
 		return nil, err
 	}
 
-	return func(blockChan chan *EnqueueData) error {
+	return func(ctx context.Context, blockChan chan *EnqueueData) error {
 		defer rows.Close()
 		for rows.Next() {
 			var block int64
-			err = db.ScanRows(rows, &block)
+			err := db.ScanRows(rows, &block)
 			if err != nil {
-				config.Log.Fatal("Error getting block height. Err: %v", err)
-			}
-			config.Log.Debugf("Sending block %v to be re-indexed.", block)
-
-			if cfg.Base.Throttling != 0 {
-				time.Sleep(time.Second * time.Duration(cfg.Base.Throttling))
+				config.LogCtx(ctx).Errorf("Error getting block height. Err: %v", err)
+				return err
 			}
+			config.LogCtx(ctx).Debugf("Sending block %v to be re-indexed.", block)
 
 			// Add the new block to the queue
-			blockChan <- &EnqueueData{
+			err = enqueueBlock(ctx, blockChan, &EnqueueData{
 				IndexBlockEvents:  cfg.Base.BlockEventIndexingEnabled,
 				IndexTransactions: cfg.Base.TransactionIndexingEnabled,
 				Height:            block,
+			})
+			if err != nil {
+				return err
 			}
 		}
 
-		return nil
+		return rows.Err()
 	}, nil
 }
 
+// failedBlocksToReattempt filters a failed blocks table query to the chain blocks that have not reached the max failed block attempts
+func failedBlocksToReattempt(query *gorm.DB, cfg config.IndexConfig, chainID uint) *gorm.DB {
+	query = query.Where("blockchain_id = ?::int", chainID)
+	if cfg.Base.FailedBlockMaxAttempts > 0 {
+		query = query.Where("attempts < ?", cfg.Base.FailedBlockMaxAttempts)
+	}
+	return query.Order("height asc")
+}
+
 // The default enqueue function will enqueue blocks according to the configuration passed in. It has a few default cases detailed here:
 // Based on whether transaction indexing or block event indexing are enabled, it will choose a start block based on passed in config values.
 // If reindexing is disabled, it will not reindex blocks that have already been indexed. This means it may skip around finding blocks that have not been
 // indexed according to the current configuration.
 // If failed block reattempts are enabled, it will enqueue those according to the passed in configuration as well.
-func GenerateDefaultEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, client *client.ChainClient, chainID uint) (func(chan *EnqueueData) error, error) {
+func GenerateDefaultEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, rpcPool *rpc.Pool, chainID uint) (func(context.Context, chan *EnqueueData) error, error) {
 	var failedBlockEnqueueData []*EnqueueData
 	if cfg.Base.ReattemptFailedBlocks {
 		var failedEventBlocks []models.FailedEventBlock
@@ -159,17 +325,17 @@ func GenerateDefaultEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, client
 
 		uniqueBlockFailures := make(map[int64]*EnqueueData)
 		if cfg.Base.BlockEventIndexingEnabled {
-			err := db.Table("failed_event_blocks").Where("blockchain_id = ?::int", chainID).Order("height asc").Scan(&failedEventBlocks).Error
+			err := failedBlocksToReattempt(db.Table("failed_event_blocks"), cfg, chainID).Scan(&failedEventBlocks).Error
 			if err != nil {
-				config.Log.Error("Error retrieving failed event blocks for reenqueue", err)
+				config.LogCtx(db.Statement.Context).Error("Error retrieving failed event blocks for reenqueue", err)
 				return nil, err
 			}
 		}
 
 		if cfg.Base.TransactionIndexingEnabled {
-			err := db.Table("failed_blocks").Where("blockchain_id = ?::int", chainID).Order("height asc").Scan(&failedBlocks).Error
+			err := failedBlocksToReattempt(db.Table("failed_blocks"), cfg, chainID).Scan(&failedBlocks).Error
 			if err != nil {
-				config.Log.Error("Error retrieving failed blocks for reenqueue", err)
+				config.LogCtx(db.Statement.Context).Error("Error retrieving failed blocks for reenqueue", err)
 				return nil, err
 			}
 		}
@@ -212,62 +378,66 @@ func GenerateDefaultEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, client
 		startBlock = 1
 	}
 
-	var blocksFromStart []models.Block
-
 	if !reindexing {
-		var err error
-		config.Log.Info("Reindexing is disabled, skipping blocks that have already been indexed")
-		// We need to pick up where we last left off, find blocks after start and skip already indexed blocks
-		blocksFromStart, err = dbTypes.GetBlocksFromStart(db, chainID, startBlock, endBlock)
+		config.LogCtx(db.Statement.Context).Info("Reindexing is disabled, skipping blocks that have already been indexed")
+		// We need to pick up where we last left off, the checkpoint covers the contiguous indexed heights so only blocks after it need to be checked
+		checkpoint, err := dbTypes.InitCheckpoint(db, chainID)
 		if err != nil {
 			return nil, err
 		}
 
+		resumeBlock := checkpoint.ResumeHeight(startBlock, cfg.Base.TransactionIndexingEnabled, cfg.Base.BlockEventIndexingEnabled)
+		if resumeBlock > startBlock {
+			config.LogCtx(db.Statement.Context).Infof("Blocks %d to %d have already been indexed, resuming from block %d", startBlock, resumeBlock-1, resumeBlock)
+			startBlock = resumeBlock
+		}
 	} else {
-		config.Log.Info("Reindexing is enabled starting from initial start height")
+		config.LogCtx(db.Statement.Context).Info("Reindexing is enabled starting from initial start height")
 	}
 
-	return func(blockChan chan *EnqueueData) error {
-		blocksInDB := make(map[int64]models.Block)
-		for _, block := range blocksFromStart {
-			blocksInDB[block.Height] = block
-		}
+	return func(ctx context.Context, blockChan chan *EnqueueData) error {
+		blocksInDB := &indexedBlockWindow{db: db, chainID: chainID}
 
 		if len(failedBlockEnqueueData) > 0 && cfg.Base.ReattemptFailedBlocks {
-			config.Log.Info("Re-enqueuing failed blocks")
+			config.LogCtx(ctx).Info("Re-enqueuing failed blocks")
 			for _, block := range failedBlockEnqueueData {
 
 				switch {
 				case block.IndexBlockEvents && block.IndexTransactions:
-					config.Log.Infof("Re-attempting failed block %v for both block events and transactions", block.Height)
+					config.LogCtx(ctx).Infof("Re-attempting failed block %v for both block events and transactions", block.Height)
 				case block.IndexBlockEvents:
-					config.Log.Infof("Re-attempting failed block: %v for block events", block.Height)
+					config.LogCtx(ctx).Infof("Re-attempting failed block: %v for block events", block.Height)
 				case block.IndexTransactions:
-					config.Log.Infof("Re-attempting failed block: %v for transactions", block.Height)
+					config.LogCtx(ctx).Infof("Re-attempting failed block: %v for transactions", block.Height)
 				}
 
 				if block.IndexBlockEvents || block.IndexTransactions {
-					blockChan <- block
-					if cfg.Base.Throttling != 0 {
-						time.Sleep(time.Second * time.Duration(cfg.Base.Throttling))
+					if err := enqueueBlock(ctx, blockChan, block); err != nil {
+						return err
 					}
 				}
 			}
-			config.Log.Info("All failed blocks have been re-enqueued for processing")
+			config.LogCtx(ctx).Info("All failed blocks have been re-enqueued for processing")
 		} else if cfg.Base.ReattemptFailedBlocks {
-			config.Log.Info("No failed blocks to re-enqueue")
+			config.LogCtx(ctx).Info("No failed blocks to re-enqueue")
 		}
 
 		currBlock := startBlock
 
 		for {
+			// Stop enqueuing new blocks once shutdown has been requested
+			if ctx.Err() != nil {
+				config.LogCtx(ctx).Info("Shutdown requested, exiting enqueue func.")
+				return ctx.Err()
+			}
+
 			// The program is configured to stop running after a set block height.
 			// Generally this will only be done while debugging or if a particular block was incorrectly processed.
 			if endBlock != -1 && currBlock > endBlock {
-				config.Log.Info("Hit the last block we're allowed to index, exiting enqueue func.")
+				config.LogCtx(ctx).Info("Hit the last block we're allowed to index, exiting enqueue func.")
 				return nil
 			} else if cfg.Base.ExitWhenCaughtUp && currBlock > latestBlock {
-				config.Log.Info("Hit the last block we're allowed to index, exiting enqueue func.")
+				config.LogCtx(ctx).Info("Hit the last block we're allowed to index, exiting enqueue func.")
 				return nil
 			}
 
@@ -276,25 +446,28 @@ func GenerateDefaultEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, client
 				// This is the latest block height available on the Node.
 
 				var err error
-				latestBlock, err = rpc.GetLatestBlockHeightWithRetry(client, cfg.Base.RequestRetryAttempts, cfg.Base.RequestRetryMaxWait)
+				latestBlock, err = rpcPool.LatestBlockHeight(ctx)
 				if err != nil {
-					config.Log.Error("Error getting blockchain latest height. Err: %v", err)
+					config.LogCtx(ctx).Error("Error getting blockchain latest height. Err: %v", err)
 					return err
 				}
 
-				// Throttling in case of hitting public APIs
-				if cfg.Base.Throttling != 0 {
-					time.Sleep(time.Second * time.Duration(cfg.Base.Throttling))
-				}
-
 				// Already at the latest block, wait for the next block to be available.
 				for currBlock < latestBlock && (currBlock <= endBlock || endBlock == -1) && len(blockChan) != cap(blockChan) {
 					// if we are not re-indexing, skip curr block if already indexed
-					block, blockExists := blocksInDB[currBlock]
+					var block models.Block
+					var blockExists bool
+					if !reindexing {
+						block, blockExists, err = blocksInDB.get(currBlock)
+						if err != nil {
+							config.LogCtx(ctx).Error("Error retrieving indexed blocks", err)
+							return err
+						}
+					}
 
 					// Skip blocks already in DB that do not need indexing according to the config
 					if !reindexing && blockExists {
-						config.Log.Debugf("Block %d already in DB, checking if it needs indexing", currBlock)
+						config.LogCtx(ctx).Debugf("Block %d already in DB, checking if it needs indexing", currBlock)
 
 						needsIndex := false
 
@@ -305,39 +478,42 @@ func GenerateDefaultEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, client
 						}
 
 						if !needsIndex {
-							config.Log.Debugf("Block %d already indexed, skipping", currBlock)
+							config.LogCtx(ctx).Debugf("Block %d already indexed, skipping", currBlock)
 							currBlock++
 							continue
 						}
-						config.Log.Debugf("Block %d needs indexing, adding to queue", currBlock)
-						blockChan <- &EnqueueData{
+						config.LogCtx(ctx).Debugf("Block %d needs indexing, adding to queue", currBlock)
+						err := enqueueBlock(ctx, blockChan, &EnqueueData{
 							Height:            currBlock,
 							IndexBlockEvents:  cfg.Base.BlockEventIndexingEnabled && !block.BlockEventsIndexed,
 							IndexTransactions: cfg.Base.TransactionIndexingEnabled && !block.TxIndexed,
+						})
+						if err != nil {
+							return err
 						}
 
-						delete(blocksInDB, currBlock)
-
 						currBlock++
 
-						if cfg.Base.Throttling != 0 {
-							time.Sleep(time.Second * time.Duration(cfg.Base.Throttling))
-						}
-
 						continue
 					}
 
 					// Add the new block to the queue
-					blockChan <- &EnqueueData{
+					err := enqueueBlock(ctx, blockChan, &EnqueueData{
 						Height:            currBlock,
 						IndexBlockEvents:  cfg.Base.BlockEventIndexingEnabled,
 						IndexTransactions: cfg.Base.TransactionIndexingEnabled,
+					})
+					if err != nil {
+						return err
 					}
 					currBlock++
+				}
+			}
 
-					if cfg.Base.Throttling != 0 {
-						time.Sleep(time.Second * time.Duration(cfg.Base.Throttling))
-					}
+			// Caught up to the chain or the queue is full, wait before polling again instead of spinning on RPC requests
+			if currBlock >= latestBlock || len(blockChan) > cap(blockChan)/4 {
+				if err := waitForNextPoll(ctx); err != nil {
+					return err
 				}
 			}
 		}
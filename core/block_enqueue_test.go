@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	dbTypes "github.com/DefiantLabs/cosmos-indexer/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockGapsEnqueueFunction(t *testing.T) {
+	enqueue, err := GenerateBlockGapsEnqueueFunction([]dbTypes.BlockGap{
+		{StartHeight: 2, EndHeight: 4, Reason: dbTypes.GapMissing, Transactions: true, BlockEvents: true},
+		{StartHeight: 7, EndHeight: 7, Reason: dbTypes.GapPartial, BlockEvents: true},
+		{StartHeight: 9, EndHeight: 10, Reason: dbTypes.GapFailed, Transactions: true},
+	})
+	require.NoError(t, err)
+
+	// The channel is smaller than the gaps, heights are enqueued while the ranges are walked
+	blockChan := make(chan *EnqueueData, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- enqueue(context.Background(), blockChan)
+		close(blockChan)
+	}()
+
+	var enqueued []EnqueueData
+	for block := range blockChan {
+		enqueued = append(enqueued, *block)
+	}
+	require.NoError(t, <-done)
+
+	require.Equal(t, []EnqueueData{
+		{Height: 2, IndexTransactions: true, IndexBlockEvents: true},
+		{Height: 3, IndexTransactions: true, IndexBlockEvents: true},
+		{Height: 4, IndexTransactions: true, IndexBlockEvents: true},
+		{Height: 7, IndexBlockEvents: true},
+		{Height: 9, IndexTransactions: true},
+		{Height: 10, IndexTransactions: true},
+	}, enqueued)
+}
+
+func TestBlockGapsEnqueueFunctionCancel(t *testing.T) {
+	enqueue, err := GenerateBlockGapsEnqueueFunction([]dbTypes.BlockGap{{StartHeight: 1, EndHeight: 1 << 40, Transactions: true}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	blockChan := make(chan *EnqueueData, 2)
+
+	// A huge gap blocks on the full channel instead of materializing its heights, and stops once the context is cancelled
+	done := make(chan error, 1)
+	go func() { done <- enqueue(ctx, blockChan) }()
+
+	require.Equal(t, int64(1), (<-blockChan).Height)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
@@ -2,10 +2,16 @@ package core
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
 
 	abci "github.com/cometbft/cometbft/abci/types"
+	cryptoenc "github.com/cometbft/cometbft/crypto/encoding"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	sdkTypes "github.com/cosmos/cosmos-sdk/types"
 
 	"github.com/DefiantLabs/cosmos-indexer/config"
+	txtypes "github.com/DefiantLabs/cosmos-indexer/cosmos/modules/tx"
 	"github.com/DefiantLabs/cosmos-indexer/db"
 	"github.com/DefiantLabs/cosmos-indexer/db/models"
 	"github.com/DefiantLabs/cosmos-indexer/filter"
@@ -17,6 +23,7 @@ func ProcessRPCBlockResults(conf config.IndexConfig, block models.Block, blockRe
 	var blockDBWrapper db.BlockDBWrapper
 
 	blockDBWrapper.Block = &block
+	blockDBWrapper.IndexBalanceChanges = conf.Flags.IndexBalanceChanges
 
 	blockDBWrapper.UniqueBlockEventAttributeKeys = make(map[string]models.BlockEventAttributeKey)
 	blockDBWrapper.UniqueBlockEventTypes = make(map[string]models.BlockEventType)
@@ -32,9 +39,54 @@ func ProcessRPCBlockResults(conf config.IndexConfig, block models.Block, blockRe
 		return nil, err
 	}
 
+	blockDBWrapper.ValidatorUpdates, err = ProcessRPCValidatorUpdates(blockResults.ValidatorUpdates)
+	if err != nil {
+		return nil, err
+	}
+
+	blockDBWrapper.ConsensusParamUpdate, err = ProcessRPCConsensusParamUpdates(blockResults.ConsensusParamUpdates)
+	if err != nil {
+		return nil, err
+	}
+
 	return &blockDBWrapper, nil
 }
 
+// ProcessRPCValidatorUpdates processes the validator power changes of a block, keyed by the consensus address of the validator public key
+func ProcessRPCValidatorUpdates(validatorUpdates []abci.ValidatorUpdate) ([]models.ValidatorUpdate, error) {
+	var updates []models.ValidatorUpdate
+	for index, validatorUpdate := range validatorUpdates {
+		pubKey, err := cryptoenc.PubKeyFromProto(validatorUpdate.PubKey)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding public key of validator update %d: %w", index, err)
+		}
+
+		updates = append(updates, models.ValidatorUpdate{
+			Index:                index,
+			ValidatorConsAddress: models.Address{Address: sdkTypes.ConsAddress(pubKey.Address()).String()},
+			PubKeyType:           pubKey.Type(),
+			PubKey:               base64.StdEncoding.EncodeToString(pubKey.Bytes()),
+			Power:                validatorUpdate.Power,
+		})
+	}
+
+	return updates, nil
+}
+
+// ProcessRPCConsensusParamUpdates processes the consensus param changes of a block, returning nil if the params did not change
+func ProcessRPCConsensusParamUpdates(consensusParams *cmtproto.ConsensusParams) (*models.ConsensusParamUpdate, error) {
+	if consensusParams == nil {
+		return nil, nil
+	}
+
+	params, err := json.Marshal(consensusParams)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding consensus param updates: %w", err)
+	}
+
+	return &models.ConsensusParamUpdate{Params: params}, nil
+}
+
 func ProcessRPCBlockEvents(block *models.Block, blockEvents []abci.Event, blockLifecyclePosition models.BlockLifecyclePosition, uniqueEventTypes map[string]models.BlockEventType, uniqueAttributeKeys map[string]models.BlockEventAttributeKey, customParsers map[string][]parsers.BlockEventParser, conf config.IndexConfig) ([]db.BlockEventDBWrapper, error) {
 	beginBlockEvents := make([]db.BlockEventDBWrapper, len(blockEvents))
 
@@ -90,6 +142,14 @@ func ProcessRPCBlockEvents(block *models.Block, blockEvents []abci.Event, blockL
 
 		}
 
+		if conf.Flags.IndexBalanceChanges {
+			balanceEvent := txtypes.LogMessageEvent{Type: event.Type}
+			for _, attribute := range beginBlockEvents[index].Attributes {
+				balanceEvent.Attributes = append(balanceEvent.Attributes, txtypes.Attribute{Key: attribute.BlockEventAttributeKey.Key, Value: attribute.Value})
+			}
+			beginBlockEvents[index].BalanceChanges = ProcessBalanceChanges(balanceEvent)
+		}
+
 		if customParsers != nil {
 			if customBlockEventParsers, ok := customParsers[event.Type]; ok {
 				for index, customParser := range customBlockEventParsers {
@@ -0,0 +1,218 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/cosmos/modules/denoms"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"github.com/DefiantLabs/cosmos-indexer/rpc"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	transferTypes "github.com/cosmos/ibc-go/v7/modules/apps/transfer/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// Max denoms read from the denoms table per enrichment scan
+const denomEnrichmentScanSize = 1000
+
+// LoadDenomTraceFile reads a JSON file of IBC denom traces, as returned by the /ibc/apps/transfer/v1/denom_traces REST endpoint
+// or the `query ibc-transfer denom-traces --output json` command of a chain binary. The traces are keyed by their IBC denom.
+func LoadDenomTraceFile(path string) (map[string]transferTypes.DenomTrace, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading denom trace file %s: %w", path, err)
+	}
+
+	var resp denoms.GetDenomTracesResponse
+	err = json.Unmarshal(raw, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing denom trace file %s: %w", path, err)
+	}
+
+	traces := make(map[string]transferTypes.DenomTrace, len(resp.DenomTraces))
+	for _, trace := range resp.DenomTraces {
+		traces[trace.IBCDenom()] = trace
+	}
+
+	return traces, nil
+}
+
+// EnrichDenoms resolves the IBC traces and bank metadata of the denoms that have not been resolved yet, the first pass backfills all existing denoms.
+// Denoms created while indexing are resolved by a new pass every interval until the context is cancelled, an interval of 0 only runs the first pass.
+// IBC traces are looked up in the traces loaded from the trace file before they are queried from the chain.
+// The denoms table is shared by every chain indexed into the DB, so a denom is only marked resolved once a trace or metadata was found for it.
+func EnrichDenoms(ctx context.Context, db *gorm.DB, pool *rpc.Pool, traces map[string]transferTypes.DenomTrace, interval time.Duration) {
+	querier := poolDenomQuerier{pool: pool}
+
+	// Denoms the chain has no trace or metadata for are skipped until restart, they may have been indexed from another chain sharing the DB
+	unknown := make(map[string]bool)
+
+	for {
+		resolved, err := enrichDenomsPass(ctx, db, querier, traces, unknown)
+		if err != nil {
+			config.LogCtx(ctx).Errorf("Error enriching denoms. Err: %v", err)
+		}
+		if resolved > 0 {
+			config.LogCtx(ctx).Infof("Resolved the metadata of %d denoms", resolved)
+		}
+
+		if interval <= 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// enrichDenomsPass resolves every unresolved denom once, returning the number of denoms resolved
+func enrichDenomsPass(ctx context.Context, db *gorm.DB, querier denomQuerier, traces map[string]transferTypes.DenomTrace, unknown map[string]bool) (int, error) {
+	var resolved int
+	var lastID uint
+
+	for {
+		var unresolved []models.Denom
+		if err := db.Where("metadata_resolved = ? AND id > ?", false, lastID).Order("id").Limit(denomEnrichmentScanSize).Find(&unresolved).Error; err != nil {
+			return resolved, err
+		}
+
+		if len(unresolved) == 0 {
+			return resolved, nil
+		}
+
+		for _, denom := range unresolved {
+			lastID = denom.ID
+			if ctx.Err() != nil {
+				return resolved, nil
+			}
+
+			if unknown[denom.Base] {
+				continue
+			}
+
+			found, err := resolveDenom(ctx, querier, traces, &denom)
+			if errors.Is(err, rpc.ErrRequestCancelled) {
+				return resolved, nil
+			}
+			if err != nil {
+				config.LogCtx(ctx).Errorf("Error resolving denom %s. Err: %v", denom.Base, err)
+				continue
+			}
+
+			if !found {
+				config.LogCtx(ctx).Debugf("No IBC denom trace or bank metadata found for denom %s", denom.Base)
+				unknown[denom.Base] = true
+				continue
+			}
+
+			if err := db.Model(&denom).Select("ibc_path", "ibc_base_denom", "display", "symbol", "exponent", "metadata_resolved").Updates(&denom).Error; err != nil {
+				return resolved, err
+			}
+			resolved++
+		}
+	}
+}
+
+// denomQuerier looks up denoms on the chain, returning nil without an error if the chain has no trace or metadata for the denom
+type denomQuerier interface {
+	DenomTrace(ctx context.Context, hash string) (*transferTypes.DenomTrace, error)
+	DenomMetadata(ctx context.Context, denom string) (*bankTypes.Metadata, error)
+}
+
+// poolDenomQuerier queries denoms from the RPC endpoint pool
+type poolDenomQuerier struct {
+	pool *rpc.Pool
+}
+
+func (querier poolDenomQuerier) DenomTrace(ctx context.Context, hash string) (*transferTypes.DenomTrace, error) {
+	var trace *transferTypes.DenomTrace
+	err := querier.pool.Do(ctx, func(endpoint *rpc.Endpoint) error {
+		// A missing trace is an answer, not an endpoint failure
+		resp, err := rpc.GetDenomTrace(endpoint.ChainClient, hash)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		trace = resp
+		return nil
+	})
+	return trace, err
+}
+
+func (querier poolDenomQuerier) DenomMetadata(ctx context.Context, denom string) (*bankTypes.Metadata, error) {
+	var metadata *bankTypes.Metadata
+	err := querier.pool.Do(ctx, func(endpoint *rpc.Endpoint) error {
+		// Most chains only register metadata for a few denoms
+		resp, err := rpc.GetDenomMetadata(endpoint.ChainClient, denom)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		metadata = resp
+		return nil
+	})
+	return metadata, err
+}
+
+// resolveDenom fills in the IBC trace and bank metadata of the denom. It returns false if the chain has neither for the denom,
+// or if it is an IBC denom the chain has no trace for, the denom may then belong to another chain sharing the DB.
+func resolveDenom(ctx context.Context, querier denomQuerier, traces map[string]transferTypes.DenomTrace, denom *models.Denom) (bool, error) {
+	traceFound := false
+	if hash, ok := strings.CutPrefix(denom.Base, transferTypes.DenomPrefix+"/"); ok {
+		trace, found := traces[denom.Base]
+		if !found {
+			resp, err := querier.DenomTrace(ctx, hash)
+			if err != nil {
+				return false, err
+			}
+			if resp != nil {
+				trace, found = *resp, true
+			}
+		}
+
+		if !found {
+			return false, nil
+		}
+
+		denom.IBCPath = trace.Path
+		denom.IBCBaseDenom = trace.BaseDenom
+		traceFound = true
+	}
+
+	metadata, err := querier.DenomMetadata(ctx, denom.Base)
+	if err != nil {
+		return false, err
+	}
+
+	if metadata == nil && !traceFound {
+		return false, nil
+	}
+
+	if metadata != nil {
+		denom.Display = metadata.Display
+		denom.Symbol = metadata.Symbol
+		for _, unit := range metadata.DenomUnits {
+			if unit.Denom == metadata.Display {
+				denom.Exponent = unit.Exponent
+			}
+		}
+	}
+
+	denom.MetadataResolved = true
+	return true, nil
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	transferTypes "github.com/cosmos/ibc-go/v7/modules/apps/transfer/types"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDenomQuerier answers denom lookups from maps, lookups of denoms not in the maps are not found
+type fakeDenomQuerier struct {
+	traces   map[string]*transferTypes.DenomTrace
+	metadata map[string]*bankTypes.Metadata
+	err      error
+	queried  []string
+}
+
+func (querier *fakeDenomQuerier) DenomTrace(_ context.Context, hash string) (*transferTypes.DenomTrace, error) {
+	querier.queried = append(querier.queried, "trace:"+hash)
+	return querier.traces[hash], querier.err
+}
+
+func (querier *fakeDenomQuerier) DenomMetadata(_ context.Context, denom string) (*bankTypes.Metadata, error) {
+	querier.queried = append(querier.queried, "metadata:"+denom)
+	return querier.metadata[denom], querier.err
+}
+
+func TestLoadDenomTraceFile(t *testing.T) {
+	atomTrace := transferTypes.DenomTrace{Path: "transfer/channel-0", BaseDenom: "uatom"}
+	osmoTrace := transferTypes.DenomTrace{Path: "transfer/channel-141/transfer/channel-0", BaseDenom: "uosmo"}
+
+	tests := []struct {
+		name     string
+		contents string
+		expected map[string]transferTypes.DenomTrace
+		err      string
+	}{
+		{
+			name:     "traces are keyed by their IBC denom",
+			contents: `{"denom_traces":[{"path":"transfer/channel-0","base_denom":"uatom"},{"path":"transfer/channel-141/transfer/channel-0","base_denom":"uosmo"}],"pagination":{"next_key":null,"total":"2"}}`,
+			expected: map[string]transferTypes.DenomTrace{
+				atomTrace.IBCDenom(): atomTrace,
+				osmoTrace.IBCDenom(): osmoTrace,
+			},
+		},
+		{
+			name:     "empty trace list",
+			contents: `{"denom_traces":[]}`,
+			expected: map[string]transferTypes.DenomTrace{},
+		},
+		{
+			name:     "invalid JSON",
+			contents: `{"denom_traces":`,
+			err:      "error parsing denom trace file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "traces.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.contents), 0o600))
+
+			traces, err := LoadDenomTraceFile(path)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, traces)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadDenomTraceFile(filepath.Join(t.TempDir(), "missing.json"))
+		require.ErrorContains(t, err, "error reading denom trace file")
+	})
+}
+
+func TestResolveDenom(t *testing.T) {
+	atomTrace := transferTypes.DenomTrace{Path: "transfer/channel-0", BaseDenom: "uatom"}
+	atomIBCDenom := atomTrace.IBCDenom()
+	atomHash := atomTrace.Hash().String()
+
+	osmoTrace := transferTypes.DenomTrace{Path: "transfer/channel-141", BaseDenom: "uosmo"}
+	osmoIBCDenom := osmoTrace.IBCDenom()
+
+	junoMetadata := &bankTypes.Metadata{
+		Base:    "ujuno",
+		Display: "juno",
+		Symbol:  "JUNO",
+		DenomUnits: []*bankTypes.DenomUnit{
+			{Denom: "ujuno", Exponent: 0},
+			{Denom: "juno", Exponent: 6},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		denom    string
+		traces   map[string]transferTypes.DenomTrace
+		querier  *fakeDenomQuerier
+		found    bool
+		err      bool
+		expected models.Denom
+		queried  []string
+	}{
+		{
+			name:     "native denom with metadata",
+			denom:    "ujuno",
+			querier:  &fakeDenomQuerier{metadata: map[string]*bankTypes.Metadata{"ujuno": junoMetadata}},
+			found:    true,
+			expected: models.Denom{Base: "ujuno", Display: "juno", Symbol: "JUNO", Exponent: 6, MetadataResolved: true},
+			queried:  []string{"metadata:ujuno"},
+		},
+		{
+			name:     "native denom without metadata is left unresolved",
+			denom:    "uother",
+			querier:  &fakeDenomQuerier{},
+			found:    false,
+			expected: models.Denom{Base: "uother"},
+			queried:  []string{"metadata:uother"},
+		},
+		{
+			name:     "IBC denom traced from the trace file",
+			denom:    osmoIBCDenom,
+			traces:   map[string]transferTypes.DenomTrace{osmoIBCDenom: osmoTrace},
+			querier:  &fakeDenomQuerier{},
+			found:    true,
+			expected: models.Denom{Base: osmoIBCDenom, IBCPath: "transfer/channel-141", IBCBaseDenom: "uosmo", MetadataResolved: true},
+			queried:  []string{"metadata:" + osmoIBCDenom},
+		},
+		{
+			name:     "IBC denom traced from the chain",
+			denom:    atomIBCDenom,
+			querier:  &fakeDenomQuerier{traces: map[string]*transferTypes.DenomTrace{atomHash: &atomTrace}},
+			found:    true,
+			expected: models.Denom{Base: atomIBCDenom, IBCPath: "transfer/channel-0", IBCBaseDenom: "uatom", MetadataResolved: true},
+			queried:  []string{"trace:" + atomHash, "metadata:" + atomIBCDenom},
+		},
+		{
+			name:     "IBC denom without a trace is left unresolved",
+			denom:    atomIBCDenom,
+			querier:  &fakeDenomQuerier{metadata: map[string]*bankTypes.Metadata{atomIBCDenom: junoMetadata}},
+			found:    false,
+			expected: models.Denom{Base: atomIBCDenom},
+			queried:  []string{"trace:" + atomHash},
+		},
+		{
+			name:     "query errors are returned",
+			denom:    "ujuno",
+			querier:  &fakeDenomQuerier{err: errors.New("connection refused")},
+			err:      true,
+			expected: models.Denom{Base: "ujuno"},
+			queried:  []string{"metadata:ujuno"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			denom := models.Denom{Base: tt.denom}
+			found, err := resolveDenom(context.Background(), tt.querier, tt.traces, &denom)
+			if tt.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.found, found)
+			require.Equal(t, tt.expected, denom)
+			require.Equal(t, tt.queried, tt.querier.queried)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	dbTypes "github.com/DefiantLabs/cosmos-indexer/db"
+	"gorm.io/gorm"
+)
+
+// Max failed blocks claimed from each failed blocks table per retry scan
+const failedBlockRetryScanSize = 1000
+
+// RetryFailedBlocks periodically re-enqueues blocks from the failed blocks tables while indexing is running, until the context is cancelled.
+// A block is retried once its backoff has passed since it last failed, the backoff doubles with every failed attempt up to the max backoff.
+// Blocks that reached base.failed-block-max-attempts are not retried. Retries are claimed in the DB, so when several replicas index the
+// same chain each block is retried by a single replica, and a retry still in the pipeline is not enqueued again before it fails or succeeds.
+func RetryFailedBlocks(ctx context.Context, db *gorm.DB, cfg config.IndexConfig, chainID uint, blockChan chan *EnqueueData) {
+	interval := time.Duration(cfg.Base.FailedBlockRetryInterval) * time.Second
+	backoff := time.Duration(cfg.Base.FailedBlockRetryBackoff) * time.Second
+	maxBackoff := time.Duration(cfg.Base.FailedBlockRetryMaxBackoff) * time.Second
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		blocks, err := claimFailedBlockRetries(db, cfg, chainID, backoff, maxBackoff)
+		if err != nil {
+			config.LogCtx(ctx).Errorf("Error claiming failed blocks to retry. Err: %v", err)
+			continue
+		}
+
+		if len(blocks) > 0 {
+			config.LogCtx(ctx).Infof("Retrying %d failed blocks", len(blocks))
+		}
+
+		for _, block := range blocks {
+			config.LogCtx(ctx).Debugf("Retrying failed block %d, block events: %t, transactions: %t", block.Height, block.IndexBlockEvents, block.IndexTransactions)
+			if err := enqueueBlock(ctx, blockChan, block); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// claimFailedBlockRetries claims the failed blocks whose backoff has passed, merging the datasets that failed at the same height
+func claimFailedBlockRetries(db *gorm.DB, cfg config.IndexConfig, chainID uint, backoff time.Duration, maxBackoff time.Duration) ([]*EnqueueData, error) {
+	uniqueBlockFailures := make(map[int64]*EnqueueData)
+
+	if cfg.Base.BlockEventIndexingEnabled {
+		heights, err := dbTypes.ClaimFailedBlockRetries(db, "failed_event_blocks", chainID, cfg.Base.FailedBlockMaxAttempts, backoff, maxBackoff, failedBlockRetryScanSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, height := range heights {
+			uniqueBlockFailures[height] = &EnqueueData{
+				Height:           height,
+				IndexBlockEvents: true,
+			}
+		}
+	}
+
+	if cfg.Base.TransactionIndexingEnabled {
+		heights, err := dbTypes.ClaimFailedBlockRetries(db, "failed_blocks", chainID, cfg.Base.FailedBlockMaxAttempts, backoff, maxBackoff, failedBlockRetryScanSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, height := range heights {
+			if block, ok := uniqueBlockFailures[height]; ok {
+				block.IndexTransactions = true
+			} else {
+				uniqueBlockFailures[height] = &EnqueueData{
+					Height:            height,
+					IndexTransactions: true,
+				}
+			}
+		}
+	}
+
+	blocks := make([]*EnqueueData, 0, len(uniqueBlockFailures))
+	for _, block := range uniqueBlockFailures {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Height < blocks[j].Height })
+
+	return blocks, nil
+}
@@ -0,0 +1,230 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	dbTypes "github.com/DefiantLabs/cosmos-indexer/db"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"github.com/DefiantLabs/cosmos-indexer/rpc"
+	"gorm.io/gorm"
+)
+
+// leaseTracker holds the leases claimed by this replica that have not been completed yet
+type leaseTracker struct {
+	mu     sync.Mutex
+	leases []*models.BlockRangeLease
+}
+
+func (t *leaseTracker) add(lease *models.BlockRangeLease) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leases = append(t.leases, lease)
+}
+
+func (t *leaseTracker) active() []*models.BlockRangeLease {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*models.BlockRangeLease(nil), t.leases...)
+}
+
+func (t *leaseTracker) remove(lease *models.BlockRangeLease) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, held := range t.leases {
+		if held == lease {
+			t.leases = append(t.leases[:i], t.leases[i+1:]...)
+			return
+		}
+	}
+}
+
+// heartbeat renews the active leases every interval, completing the leases whose heights have all been processed
+func (t *leaseTracker) heartbeat(ctx context.Context, db *gorm.DB, cfg config.IndexConfig, duration time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, lease := range t.active() {
+			processed, err := dbTypes.BlockRangeLeaseProcessed(db, lease, cfg.Base.TransactionIndexingEnabled, cfg.Base.BlockEventIndexingEnabled)
+			if err != nil {
+				config.LogCtx(ctx).Errorf("Error checking lease for blocks %d to %d. Err: %v", lease.StartHeight, lease.EndHeight, err)
+				continue
+			}
+
+			if processed {
+				err = dbTypes.CompleteBlockRangeLease(db, lease)
+			} else {
+				err = dbTypes.RenewBlockRangeLease(db, lease, duration)
+			}
+
+			switch {
+			case errors.Is(err, dbTypes.ErrBlockRangeLeaseLost):
+				config.LogCtx(ctx).Warnf("Lease for blocks %d to %d expired and was claimed by another replica", lease.StartHeight, lease.EndHeight)
+				t.remove(lease)
+			case err != nil:
+				config.LogCtx(ctx).Errorf("Error renewing lease for blocks %d to %d. Err: %v", lease.StartHeight, lease.EndHeight, err)
+			case processed:
+				config.LogCtx(ctx).Infof("Completed lease for blocks %d to %d", lease.StartHeight, lease.EndHeight)
+				t.remove(lease)
+			}
+		}
+	}
+}
+
+// GenerateLeaseEnqueueFunction enqueues blocks from height ranges claimed in the DB lease table, so several replicas can index the same chain.
+// Each replica claims the next unleased range, or an expired range another replica stopped renewing, and heartbeats its leases
+// until every height in them is indexed or recorded as failed. If reindexing is disabled, blocks that are already indexed are skipped.
+// The enqueue function exits once the end block is reached and every lease up to it has been completed by one of the replicas.
+func GenerateLeaseEnqueueFunction(db *gorm.DB, cfg config.IndexConfig, rpcPool *rpc.Pool, chainID uint) (func(context.Context, chan *EnqueueData) error, error) {
+	startBlock := cfg.Base.StartBlock
+	endBlock := cfg.Base.EndBlock
+	reindexing := cfg.Base.ReIndex
+	leaseDuration := time.Duration(cfg.Base.LeaseDuration) * time.Second
+
+	if startBlock <= 0 {
+		startBlock = 1
+	}
+
+	if !reindexing {
+		checkpoint, err := dbTypes.InitCheckpoint(db, chainID)
+		if err != nil {
+			return nil, err
+		}
+
+		resumeBlock := checkpoint.ResumeHeight(startBlock, cfg.Base.TransactionIndexingEnabled, cfg.Base.BlockEventIndexingEnabled)
+		if resumeBlock > startBlock {
+			config.LogCtx(db.Statement.Context).Infof("Blocks %d to %d have already been indexed, leasing from block %d", startBlock, resumeBlock-1, resumeBlock)
+			startBlock = resumeBlock
+		}
+	}
+
+	return func(ctx context.Context, blockChan chan *EnqueueData) error {
+		tracker := &leaseTracker{}
+
+		heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+		defer stopHeartbeat()
+		go tracker.heartbeat(heartbeatCtx, db, cfg, leaseDuration, leaseDuration/3)
+
+		config.LogCtx(ctx).Infof("Claiming block range leases as %s", cfg.Base.LeaseOwner)
+
+		for {
+			// Stop claiming new leases once shutdown has been requested, held leases expire and are claimed by the other replicas
+			if ctx.Err() != nil {
+				config.LogCtx(ctx).Info("Shutdown requested, exiting enqueue func.")
+				return ctx.Err()
+			}
+
+			// Leave the unclaimed ranges to the other replicas while this one still has plenty of blocks queued
+			if len(blockChan) > cap(blockChan)/4 {
+				if err := waitForNextPoll(ctx); err != nil {
+					return err
+				}
+				continue
+			}
+
+			latestBlock, err := rpcPool.LatestBlockHeight(ctx)
+			if err != nil {
+				config.LogCtx(ctx).Errorf("Error getting blockchain latest height. Err: %v", err)
+				return err
+			}
+
+			// Like the default enqueue function, stay one block behind the latest height
+			leaseLimit := latestBlock - 1
+			if endBlock != -1 && endBlock < leaseLimit {
+				leaseLimit = endBlock
+			} else if cfg.Base.ExitWhenCaughtUp && endBlock == -1 {
+				endBlock = leaseLimit
+			}
+
+			lease, err := dbTypes.ClaimBlockRangeLease(db, chainID, cfg.Base.LeaseOwner, startBlock, leaseLimit, cfg.Base.LeaseRangeSize, leaseDuration)
+			if err != nil {
+				config.LogCtx(ctx).Errorf("Error claiming block range lease. Err: %v", err)
+				return err
+			}
+
+			if lease == nil {
+				if endBlock != -1 && leaseLimit == endBlock {
+					incomplete, err := dbTypes.CountIncompleteBlockRangeLeases(db, chainID, endBlock)
+					if err != nil {
+						config.LogCtx(ctx).Errorf("Error checking for incomplete block range leases. Err: %v", err)
+						return err
+					}
+
+					if incomplete == 0 && len(tracker.active()) == 0 {
+						config.LogCtx(ctx).Info("All block range leases up to the last block have been completed, exiting enqueue func.")
+						return nil
+					}
+				}
+
+				if err := waitForNextPoll(ctx); err != nil {
+					return err
+				}
+				continue
+			}
+
+			config.LogCtx(ctx).Infof("Claimed lease for blocks %d to %d", lease.StartHeight, lease.EndHeight)
+			tracker.add(lease)
+
+			if err := enqueueLease(ctx, db, cfg, chainID, lease, blockChan); err != nil {
+				return err
+			}
+
+			// Nothing is written to the DB in dry runs, so the lease can not be checked for completion
+			if cfg.Base.Dry {
+				if err := dbTypes.CompleteBlockRangeLease(db, lease); err != nil && !errors.Is(err, dbTypes.ErrBlockRangeLeaseLost) {
+					return err
+				}
+				tracker.remove(lease)
+			}
+		}
+	}, nil
+}
+
+// enqueueLease enqueues the heights in the lease, skipping blocks that are already indexed unless reindexing is enabled
+func enqueueLease(ctx context.Context, db *gorm.DB, cfg config.IndexConfig, chainID uint, lease *models.BlockRangeLease, blockChan chan *EnqueueData) error {
+	blocksInDB := &indexedBlockWindow{db: db, chainID: chainID}
+
+	for height := lease.StartHeight; height <= lease.EndHeight; height++ {
+		indexBlockEvents := cfg.Base.BlockEventIndexingEnabled
+		indexTransactions := cfg.Base.TransactionIndexingEnabled
+
+		if !cfg.Base.ReIndex {
+			block, blockExists, err := blocksInDB.get(height)
+			if err != nil {
+				config.LogCtx(ctx).Error("Error retrieving indexed blocks", err)
+				return err
+			}
+
+			if blockExists {
+				indexBlockEvents = indexBlockEvents && !block.BlockEventsIndexed
+				indexTransactions = indexTransactions && !block.TxIndexed
+			}
+		}
+
+		if !indexBlockEvents && !indexTransactions {
+			config.LogCtx(ctx).Debugf("Block %d already indexed, skipping", height)
+			continue
+		}
+
+		err := enqueueBlock(ctx, blockChan, &EnqueueData{
+			Height:            height,
+			IndexBlockEvents:  indexBlockEvents,
+			IndexTransactions: indexTransactions,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -4,10 +4,14 @@ import (
 	"fmt"
 
 	"github.com/DefiantLabs/cosmos-indexer/config"
+	dbTypes "github.com/DefiantLabs/cosmos-indexer/db"
 	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"github.com/DefiantLabs/cosmos-indexer/metrics"
 	"github.com/DefiantLabs/cosmos-indexer/rpc"
 	ctypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmtTypes "github.com/cometbft/cometbft/types"
 	sdkTypes "github.com/cosmos/cosmos-sdk/types"
+	"gorm.io/gorm"
 )
 
 type BlockProcessingFailure int
@@ -20,10 +24,72 @@ const (
 	OsmosisNodeRewardIndexError
 	NodeMissingHistoryForBlock
 	FailedBlockEventHandling
+	FailedDBWrite
 )
 
+// String returns the failure code as a metrics label value
+func (code BlockProcessingFailure) String() string {
+	switch code {
+	case NodeMissingBlockTxs:
+		return "node_missing_block_txs"
+	case BlockQueryError:
+		return "block_query_error"
+	case UnprocessableTxError:
+		return "unprocessable_tx_error"
+	case OsmosisNodeRewardLookupError:
+		return "osmosis_node_reward_lookup_error"
+	case OsmosisNodeRewardIndexError:
+		return "osmosis_node_reward_index_error"
+	case NodeMissingHistoryForBlock:
+		return "node_missing_history_for_block"
+	case FailedBlockEventHandling:
+		return "failed_block_event_handling"
+	case FailedDBWrite:
+		return "failed_db_write"
+	}
+	return "unknown"
+}
+
 type FailedBlockHandler func(height int64, code BlockProcessingFailure, err error)
 
+// FailedDataset selects the failed blocks tables a block failure is recorded in
+type FailedDataset int
+
+const (
+	FailedTransactions FailedDataset = 1 << iota
+	FailedBlockEvents
+)
+
+// RecordFailedBlock counts the block failure in the failed blocks metric and records the block in the failed blocks table of every
+// failed dataset, so it is picked up by the failed block retrier and reprocessing
+func RecordFailedBlock(db *gorm.DB, cfg *config.IndexConfig, height int64, datasets FailedDataset, code BlockProcessingFailure, stage string, err error) error {
+	metrics.FailedBlocks.WithLabelValues(cfg.Probe.ChainID, code.String()).Inc()
+
+	failure := NewBlockFailure(code, stage, err)
+	if datasets&FailedBlockEvents != 0 {
+		if err := dbTypes.UpsertFailedEventBlock(db, height, cfg.Probe.ChainID, cfg.Probe.ChainName, failure); err != nil {
+			return fmt.Errorf("failed to insert failed block event: %w", err)
+		}
+	}
+
+	if datasets&FailedTransactions != 0 {
+		if err := dbTypes.UpsertFailedBlock(db, height, cfg.Probe.ChainID, cfg.Probe.ChainName, failure); err != nil {
+			return fmt.Errorf("failed to insert failed block: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// NewBlockFailure describes a failure for the failed blocks tables
+func NewBlockFailure(code BlockProcessingFailure, stage string, err error) dbTypes.BlockFailure {
+	return dbTypes.BlockFailure{
+		Code:  code.String(),
+		Stage: stage,
+		Err:   err,
+	}
+}
+
 // Process RPC Block data into the model object used by the application.
 func ProcessBlock(blockData *ctypes.ResultBlock, blockResultsData *rpc.CustomBlockResults, chainID uint) (models.Block, error) {
 	block := models.Block{
@@ -39,9 +105,56 @@ func ProcessBlock(blockData *ctypes.ResultBlock, blockResultsData *rpc.CustomBlo
 	block.ProposerConsAddress = models.Address{Address: propAddressFromHex.String()}
 	block.TimeStamp = blockData.Block.Time
 
+	header := blockData.Block.Header
+	block.BlockHeader = models.BlockHeader{
+		Hash:                 blockData.BlockID.Hash.String(),
+		AppHash:              header.AppHash.String(),
+		DataHash:             header.DataHash.String(),
+		LastBlockHash:        header.LastBlockID.Hash.String(),
+		LastBlockPartSetHash: header.LastBlockID.PartSetHeader.Hash.String(),
+		BlockVersion:         header.Version.Block,
+		AppVersion:           header.Version.App,
+		TxCount:              len(blockData.Block.Txs),
+	}
+
 	return block, nil
 }
 
+// Process the last commit signatures of a block, which commit the previous block, into the model objects used by the application.
+func ProcessBlockSignatures(blockData *ctypes.ResultBlock) []models.BlockSignature {
+	lastCommit := blockData.Block.LastCommit
+	// The first block has no last commit
+	if lastCommit == nil {
+		return nil
+	}
+
+	var signatures []models.BlockSignature
+	for validatorIndex, commitSig := range lastCommit.Signatures {
+		signature := models.BlockSignature{
+			ValidatorIndex: validatorIndex,
+			CommitHeight:   lastCommit.Height,
+			TimeStamp:      commitSig.Timestamp,
+		}
+
+		switch commitSig.BlockIDFlag {
+		case cmtTypes.BlockIDFlagCommit:
+			signature.Flag = models.BlockSignatureCommit
+		case cmtTypes.BlockIDFlagNil:
+			signature.Flag = models.BlockSignatureNil
+		default:
+			signature.Flag = models.BlockSignatureAbsent
+		}
+
+		if len(commitSig.ValidatorAddress) != 0 {
+			signature.ValidatorConsAddress = &models.Address{Address: sdkTypes.ConsAddress(commitSig.ValidatorAddress).String()}
+		}
+
+		signatures = append(signatures, signature)
+	}
+
+	return signatures
+}
+
 // Log error to stdout. Not much else we can do to handle right now.
 func HandleFailedBlock(height int64, code BlockProcessingFailure, err error) {
 	reason := "{unknown error}"
@@ -58,6 +171,8 @@ func HandleFailedBlock(height int64, code BlockProcessingFailure, err error) {
 		reason = "Node has no TX history for block"
 	case FailedBlockEventHandling:
 		reason = "Failed to process block event"
+	case FailedDBWrite:
+		reason = "Failed to write block to the DB"
 	}
 
 	config.Log.Error(fmt.Sprintf("Block %v failed. Reason: %v", height, reason), err)
@@ -1,14 +1,16 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"sync"
+	"time"
 
 	"github.com/DefiantLabs/cosmos-indexer/config"
 	dbTypes "github.com/DefiantLabs/cosmos-indexer/db"
+	"github.com/DefiantLabs/cosmos-indexer/metrics"
 	"github.com/DefiantLabs/cosmos-indexer/rpc"
-	"github.com/DefiantLabs/probe/client"
 	abci "github.com/cometbft/cometbft/abci/types"
 	ctypes "github.com/cometbft/cometbft/rpc/core/types"
 	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
@@ -28,21 +30,47 @@ type IndexerBlockEventData struct {
 
 // This function is responsible for making all RPC requests to the chain needed for later processing.
 // The indexer relies on a number of RPC endpoints for full block data, including block event and transaction searches.
-func BlockRPCWorker(wg *sync.WaitGroup, blockEnqueueChan chan *EnqueueData, chainID uint, chainStringID string, cfg *config.IndexConfig, chainClient *client.ChainClient, db *gorm.DB, outputChannel chan IndexerBlockEventData) {
+// Requests are distributed over the RPC endpoint pool, failing over to other endpoints when a request errors.
+// Once the context is cancelled, the worker stops pulling new blocks from the enqueue channel but finishes the requests of the block it is currently working on.
+// Requests stopped by a cancellation are not recorded as failed blocks, and a block that cannot be handed off after the cancellation is left for the next run.
+// If a failed block cannot be recorded, the error is passed to stopChain and the worker exits.
+func BlockRPCWorker(ctx context.Context, wg *sync.WaitGroup, blockEnqueueChan chan *EnqueueData, chainID uint, chainStringID string, cfg *config.IndexConfig, rpcPool *rpc.Pool, db *gorm.DB, outputChannel chan IndexerBlockEventData, stopChain context.CancelCauseFunc) {
 	defer wg.Done()
-	rpcClient := rpc.URIClient{
-		Address: chainClient.Config.RPCAddr,
-		Client:  &http.Client{},
-	}
 
 	for {
 		// Get the next block to process
-		block, open := <-blockEnqueueChan
+		var block *EnqueueData
+		var open bool
+		select {
+		case <-ctx.Done():
+			config.LogCtx(ctx).Debugf("Shutdown requested. Exiting RPC worker.")
+			return
+		case block, open = <-blockEnqueueChan:
+		}
+
 		if !open {
-			config.Log.Debugf("Block enqueue channel closed. Exiting RPC worker.")
+			config.LogCtx(ctx).Debugf("Block enqueue channel closed. Exiting RPC worker.")
 			break
 		}
 
+		metrics.BlocksEnqueued.WithLabelValues(chainStringID).Inc()
+
+		// The requests of a dequeued block are not cancelled by a shutdown, sending the signal again forces the exit
+		requestCtx := context.WithoutCancel(ctx)
+
+		// recordFailure records the failed datasets of the block, returning false if the failure could not be recorded and the worker has to exit.
+		// A cancelled request is not a failure of the block.
+		recordFailure := func(datasets FailedDataset, code BlockProcessingFailure, stage string, err error) bool {
+			if errors.Is(err, rpc.ErrRequestCancelled) {
+				return true
+			}
+			if err := RecordFailedBlock(db, cfg, block.Height, datasets, code, stage, err); err != nil {
+				stopChain(fmt.Errorf("failed to record failed block %d: %w", block.Height, err))
+				return false
+			}
+			return true
+		}
+
 		currentHeightIndexerData := IndexerBlockEventData{
 			BlockEventRequestsFailed: false,
 			TxRequestsFailed:         false,
@@ -51,17 +79,19 @@ func BlockRPCWorker(wg *sync.WaitGroup, blockEnqueueChan chan *EnqueueData, chai
 		}
 
 		// Get the block from the RPC
-		blockData, err := rpc.GetBlock(chainClient, block.Height)
+		requestStart := time.Now()
+		var blockData *ctypes.ResultBlock
+		err := rpcPool.Do(requestCtx, func(endpoint *rpc.Endpoint) error {
+			var err error
+			blockData, err = rpc.GetBlock(endpoint.ChainClient, block.Height)
+			return err
+		})
+		metrics.ObserveRPCRequest(chainStringID, "GetBlock", requestStart, err)
 		if err != nil {
 			// This is the only response we continue on. If we can't get the block, we can't index anything.
-			config.Log.Errorf("Error getting block %v from RPC. Err: %v", block, err)
-			err := dbTypes.UpsertFailedEventBlock(db, block.Height, chainStringID, cfg.Probe.ChainName)
-			if err != nil {
-				config.Log.Fatal("Failed to insert failed block event", err)
-			}
-			err = dbTypes.UpsertFailedBlock(db, block.Height, chainStringID, cfg.Probe.ChainName)
-			if err != nil {
-				config.Log.Fatal("Failed to insert failed block", err)
+			config.LogCtx(ctx).Errorf("Error getting block %v from RPC. Err: %v", block, err)
+			if !recordFailure(FailedTransactions|FailedBlockEvents, BlockQueryError, dbTypes.FailureStageRPC, err) {
+				return
 			}
 			continue
 		}
@@ -69,23 +99,23 @@ func BlockRPCWorker(wg *sync.WaitGroup, blockEnqueueChan chan *EnqueueData, chai
 		currentHeightIndexerData.BlockData = blockData
 
 		if block.IndexBlockEvents {
-			bresults, err := rpc.GetBlockResultWithRetry(rpcClient, block.Height, cfg.Base.RequestRetryAttempts, cfg.Base.RequestRetryMaxWait)
+			requestStart := time.Now()
+			bresults, err := getBlockResult(requestCtx, rpcPool, block.Height)
+			metrics.ObserveRPCRequest(chainStringID, "GetBlockResult", requestStart, err)
 
 			if err != nil {
-				config.Log.Errorf("Error getting block results for block %v from RPC. Err: %v", block, err)
-				err := dbTypes.UpsertFailedEventBlock(db, block.Height, chainStringID, cfg.Probe.ChainName)
-				if err != nil {
-					config.Log.Fatal("Failed to insert failed block event", err)
+				config.LogCtx(ctx).Errorf("Error getting block results for block %v from RPC. Err: %v", block, err)
+				if !recordFailure(FailedBlockEvents, BlockQueryError, dbTypes.FailureStageRPC, err) {
+					return
 				}
 				currentHeightIndexerData.BlockResultsData = nil
 				currentHeightIndexerData.BlockEventRequestsFailed = true
 			} else {
 				bresults, err = NormalizeCustomBlockResults(bresults)
 				if err != nil {
-					config.Log.Errorf("Error normalizing block results for block %v from RPC. Err: %v", block, err)
-					err := dbTypes.UpsertFailedEventBlock(db, block.Height, chainStringID, cfg.Probe.ChainName)
-					if err != nil {
-						config.Log.Fatal("Failed to insert failed block event", err)
+					config.LogCtx(ctx).Errorf("Error normalizing block results for block %v from RPC. Err: %v", block, err)
+					if !recordFailure(FailedBlockEvents, FailedBlockEventHandling, dbTypes.FailureStageDecode, err) {
+						return
 					}
 				} else {
 					currentHeightIndexerData.BlockResultsData = bresults
@@ -97,20 +127,28 @@ func BlockRPCWorker(wg *sync.WaitGroup, blockEnqueueChan chan *EnqueueData, chai
 			var txsEventResp *txTypes.GetTxsEventResponse
 			var err error
 			if !cfg.Base.SkipBlockByHeightRPCRequest {
-				txsEventResp, err = rpc.GetTxsByBlockHeight(chainClient, block.Height)
+				requestStart := time.Now()
+				// Only fail over once, falling back to the block results below is cheaper than retrying nodes without a tx index
+				err = rpcPool.Failover(requestCtx, func(endpoint *rpc.Endpoint) error {
+					var err error
+					txsEventResp, err = rpc.GetTxsByBlockHeight(endpoint.ChainClient, block.Height)
+					return err
+				})
+				metrics.ObserveRPCRequest(chainStringID, "GetTxsByBlockHeight", requestStart, err)
 			}
 
 			if err != nil || cfg.Base.SkipBlockByHeightRPCRequest {
 				// Attempt to get block results to attempt an in-app codec decode of transactions.
 				if currentHeightIndexerData.BlockResultsData == nil {
 
-					bresults, err := rpc.GetBlockResultWithRetry(rpcClient, block.Height, cfg.Base.RequestRetryAttempts, cfg.Base.RequestRetryMaxWait)
+					requestStart := time.Now()
+					bresults, err := getBlockResult(requestCtx, rpcPool, block.Height)
+					metrics.ObserveRPCRequest(chainStringID, "GetBlockResult", requestStart, err)
 
 					if err != nil {
-						config.Log.Errorf("Error getting txs for block %v from RPC. Err: %v", block, err)
-						err := dbTypes.UpsertFailedBlock(db, block.Height, chainStringID, cfg.Probe.ChainName)
-						if err != nil {
-							config.Log.Fatal("Failed to insert failed block", err)
+						config.LogCtx(ctx).Errorf("Error getting txs for block %v from RPC. Err: %v", block, err)
+						if !recordFailure(FailedTransactions, BlockQueryError, dbTypes.FailureStageRPC, err) {
+							return
 						}
 						currentHeightIndexerData.GetTxsResponse = nil
 						currentHeightIndexerData.BlockResultsData = nil
@@ -119,10 +157,9 @@ func BlockRPCWorker(wg *sync.WaitGroup, blockEnqueueChan chan *EnqueueData, chai
 					} else {
 						bresults, err = NormalizeCustomBlockResults(bresults)
 						if err != nil {
-							config.Log.Errorf("Error normalizing block results for block %v from RPC. Err: %v", block, err)
-							err := dbTypes.UpsertFailedBlock(db, block.Height, chainStringID, cfg.Probe.ChainName)
-							if err != nil {
-								config.Log.Fatal("Failed to insert failed block", err)
+							config.LogCtx(ctx).Errorf("Error normalizing block results for block %v from RPC. Err: %v", block, err)
+							if !recordFailure(FailedTransactions, UnprocessableTxError, dbTypes.FailureStageDecode, err) {
+								return
 							}
 						} else {
 							currentHeightIndexerData.BlockResultsData = bresults
@@ -135,10 +172,26 @@ func BlockRPCWorker(wg *sync.WaitGroup, blockEnqueueChan chan *EnqueueData, chai
 			}
 		}
 
-		outputChannel <- currentHeightIndexerData
+		select {
+		case outputChannel <- currentHeightIndexerData:
+		case <-ctx.Done():
+			config.LogCtx(ctx).Infof("Shutdown requested, block %d is not handed off and will be indexed on the next run", block.Height)
+			return
+		}
 	}
 }
 
+// getBlockResult gets the block results for the height from the RPC endpoint pool
+func getBlockResult(ctx context.Context, rpcPool *rpc.Pool, height int64) (*rpc.CustomBlockResults, error) {
+	var bresults *rpc.CustomBlockResults
+	err := rpcPool.Do(ctx, func(endpoint *rpc.Endpoint) error {
+		var err error
+		bresults, err = rpc.GetBlockResult(endpoint.URIClient, height)
+		return err
+	})
+	return bresults, err
+}
+
 func NormalizeCustomBlockResults(blockResults *rpc.CustomBlockResults) (*rpc.CustomBlockResults, error) {
 	if len(blockResults.FinalizeBlockEvents) != 0 {
 		beginBlockEvents := []abci.Event{}
@@ -1,7 +1,10 @@
 package core
 
 import (
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"reflect"
@@ -19,6 +22,8 @@ import (
 	"github.com/DefiantLabs/cosmos-indexer/util"
 	"github.com/DefiantLabs/probe/client"
 	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	"github.com/cosmos/cosmos-sdk/codec"
+	codecTypes "github.com/cosmos/cosmos-sdk/codec/types"
 	"github.com/cosmos/cosmos-sdk/crypto/keys/multisig"
 	cryptoTypes "github.com/cosmos/cosmos-sdk/crypto/types"
 	"github.com/cosmos/cosmos-sdk/types"
@@ -32,17 +37,20 @@ func getUnexportedField(field reflect.Value) interface{} {
 	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Interface()
 }
 
-func ProcessRPCBlockByHeightTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client.ChainClient, messageTypeFilters []filter.MessageTypeFilter, messageFilters []filter.MessageFilter, blockResults *coretypes.ResultBlock, resultBlockRes *rpc.CustomBlockResults, customParsers map[string][]parsers.MessageParser) ([]dbTypes.TxDBWrapper, *time.Time, error) {
+func ProcessRPCBlockByHeightTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client.ChainClient, messageTypeFilters []filter.MessageTypeFilter, messageFilters []filter.MessageFilter, blockResults *coretypes.ResultBlock, resultBlockRes *rpc.CustomBlockResults, customParsers map[string][]parsers.MessageParser) ([]dbTypes.TxDBWrapper, []models.FailedTx, *time.Time, error) {
 	if len(blockResults.Block.Txs) != len(resultBlockRes.TxsResults) {
-		config.Log.Fatalf("blockResults & resultBlockRes: different length")
+		return nil, nil, nil, fmt.Errorf("blockResults & resultBlockRes: different length")
 	}
 
 	blockTime := &blockResults.Block.Time
 	blockTimeStr := blockTime.Format(time.RFC3339)
 	var currTxDbWrappers []dbTypes.TxDBWrapper
+	var failedTxs []models.FailedTx
 
 	for txIdx, tendermintTx := range blockResults.Block.Txs {
 		txResult := resultBlockRes.TxsResults[txIdx]
+		txHash := tendermintTx.Hash()
+		hexTxHash := tendermintHashToHex(txHash)
 
 		// Indexer types only used by the indexer app (similar to the cosmos types)
 		var indexerMergedTx txtypes.MergedTx
@@ -50,6 +58,7 @@ func ProcessRPCBlockByHeightTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client
 		var txBody txtypes.Body
 		var currMessages []types.Msg
 		var currLogMsgs []txtypes.LogMessage
+		var failedMessages []models.FailedMessage
 
 		txDecoder := cl.Codec.TxConfig.TxDecoder()
 
@@ -58,7 +67,8 @@ func ProcessRPCBlockByHeightTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client
 		if err != nil {
 			txBasic, err = InAppTxDecoder(cl.Codec)(tendermintTx)
 			if err != nil {
-				return nil, blockTime, fmt.Errorf("ProcessRPCBlockByHeightTXs: TX cannot be parsed from block %v. This is usually a proto definition error. Err: %v", blockResults.Block.Height, err)
+				failedTxs = append(failedTxs, newFailedTx(blockResults.Block.Height, hexTxHash, tendermintTx, fmt.Errorf("TX cannot be parsed, this is usually a proto definition error: %w", err)))
+				continue
 			}
 			txFull = txBasic.(*cosmosTx.Tx)
 		} else {
@@ -82,12 +92,10 @@ func ProcessRPCBlockByHeightTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client
 		}
 
 		if err != nil {
-			config.Log.Errorf("Error parsing events to message index events to normalize: %v", err)
-			return nil, blockTime, fmt.Errorf("logs could not be parsed")
+			failedTxs = append(failedTxs, newFailedTx(blockResults.Block.Height, hexTxHash, tendermintTx, fmt.Errorf("logs could not be parsed: %w", err)))
+			continue
 		}
 
-		txHash := tendermintTx.Hash()
-
 		var messagesRaw [][]byte
 		var messageTypeURLs []string
 
@@ -96,13 +104,13 @@ func ProcessRPCBlockByHeightTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client
 
 			shouldIndex, err := messageTypeShouldIndex(txFull.Body.Messages[msgIdx].TypeUrl, messageTypeFilters, customParsers)
 			if err != nil {
-				return nil, blockTime, err
+				return nil, nil, blockTime, err
 			}
 
 			messageTypeURLs = append(messageTypeURLs, txFull.Body.Messages[msgIdx].TypeUrl)
 
 			if !shouldIndex {
-				config.Log.Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping msg of type '%v' due to message type filter.", blockResults.Block.Height, tendermintHashToHex(txHash), txFull.Body.Messages[msgIdx].TypeUrl))
+				config.LogCtx(db.Statement.Context).Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping msg of type '%v' due to message type filter.", blockResults.Block.Height, tendermintHashToHex(txHash), txFull.Body.Messages[msgIdx].TypeUrl))
 				currMessages = append(currMessages, nil)
 				currLogMsgs = append(currLogMsgs, txtypes.LogMessage{
 					MessageIndex: msgIdx,
@@ -136,7 +144,7 @@ func ProcessRPCBlockByHeightTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client
 				}
 
 				if !shouldIndex {
-					config.Log.Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping msg of type '%v' due to custom message filter.", blockResults.Block.Height, tendermintHashToHex(txHash), txFull.Body.Messages[msgIdx].TypeUrl))
+					config.LogCtx(db.Statement.Context).Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping msg of type '%v' due to custom message filter.", blockResults.Block.Height, tendermintHashToHex(txHash), txFull.Body.Messages[msgIdx].TypeUrl))
 					currMessages = append(currMessages, nil)
 					currLogMsgs = append(currLogMsgs, txtypes.LogMessage{
 						MessageIndex: msgIdx,
@@ -149,12 +157,15 @@ func ProcessRPCBlockByHeightTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client
 				currMessages = append(currMessages, msg)
 				currLogMsgs = append(currLogMsgs, currTxLog)
 			} else {
-				return nil, blockTime, fmt.Errorf("tx message could not be processed")
+				failedMessages = append(failedMessages, newFailedMessage(blockResults.Block.Height, hexTxHash, msgIdx, txFull.Body.Messages[msgIdx], errMessageNotDecoded))
+				currMessages = append(currMessages, nil)
+				currLogMsgs = append(currLogMsgs, txtypes.LogMessage{
+					MessageIndex: msgIdx,
+				})
+				messagesRaw = append(messagesRaw, nil)
 			}
 		}
 
-		hexTxHash := tendermintHashToHex(txHash)
-
 		txBody.Messages = currMessages
 		indexerTx.Body = txBody
 		indexerTxResp := txtypes.Response{
@@ -164,6 +175,10 @@ func ProcessRPCBlockByHeightTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client
 			RawLog:    txResult.Log,
 			Log:       currLogMsgs,
 			Code:      txResult.Code,
+			Codespace: txResult.Codespace,
+			GasWanted: txResult.GasWanted,
+			GasUsed:   txResult.GasUsed,
+			TxEvents:  indexerEvents.ParseTxLevelEvents(txResult.Code, txResult.Events),
 		}
 
 		indexerTx.AuthInfo = *txFull.AuthInfo
@@ -171,17 +186,20 @@ func ProcessRPCBlockByHeightTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client
 		indexerMergedTx.Tx = indexerTx
 		indexerMergedTx.Tx.AuthInfo = *txFull.AuthInfo
 
-		processedTx, _, err := ProcessTx(cfg, db, indexerMergedTx, messagesRaw, messageTypeURLs, customParsers)
+		processedTx, _, err := ProcessTx(cfg, db, cl.Codec.Marshaler, indexerMergedTx, messagesRaw, messageTypeURLs, customParsers)
 		if err != nil {
-			return currTxDbWrappers, blockTime, err
+			failedTxs = append(failedTxs, newFailedTx(blockResults.Block.Height, hexTxHash, tendermintTx, err))
+			continue
 		}
 
-		if len(processedTx.Messages) == 0 && !cfg.Flags.IndexEmptyTransactions {
-			config.Log.Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping empty transaction.", blockResults.Block.Height, hexTxHash))
+		processedTx.FailedMessages = failedMessages
+
+		if processedTx.IsEmpty() && !cfg.Flags.IndexEmptyTransactions {
+			config.LogCtx(db.Statement.Context).Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping empty transaction.", blockResults.Block.Height, hexTxHash))
 			continue
 		}
 
-		config.Log.Debug(fmt.Sprintf("[Block: %v] [TX: %v] Processing transaction with %d messages.", blockResults.Block.Height, hexTxHash, len(processedTx.Messages)))
+		config.LogCtx(db.Statement.Context).Debug(fmt.Sprintf("[Block: %v] [TX: %v] Processing transaction with %d messages.", blockResults.Block.Height, hexTxHash, len(processedTx.Messages)))
 
 		filteredSigners := []types.AccAddress{}
 		for _, filteredMessage := range txBody.Messages {
@@ -192,23 +210,39 @@ func ProcessRPCBlockByHeightTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client
 
 		signers, err := ProcessSigners(cl, txFull.AuthInfo, filteredSigners)
 		if err != nil {
-			return currTxDbWrappers, blockTime, err
+			failedTxs = append(failedTxs, newFailedTx(blockResults.Block.Height, hexTxHash, tendermintTx, err))
+			continue
 		}
 
 		processedTx.Tx.SignerAddresses = signers
 
 		fees, err := ProcessFees(db, indexerTx.AuthInfo, signers)
 		if err != nil {
-			return currTxDbWrappers, blockTime, err
+			failedTxs = append(failedTxs, newFailedTx(blockResults.Block.Height, hexTxHash, tendermintTx, err))
+			continue
 		}
 
 		processedTx.Tx.Fees = fees
 		processedTx.Tx.Memo = txFull.Body.Memo
 
+		signerInfos, err := ProcessSignerInfos(cl, txFull.AuthInfo)
+		if err != nil {
+			failedTxs = append(failedTxs, newFailedTx(blockResults.Block.Height, hexTxHash, tendermintTx, err))
+			continue
+		}
+
+		processedTx.Tx.SignerInfos = signerInfos
+		ProcessTxMetadata(&processedTx.Tx, txIdx, txFull, signers)
+
+		// Only SDK v0.50 and later mark the fee events of successful txes, derive the fee deduction from the fees otherwise
+		if cfg.Flags.IndexBalanceChanges && len(processedTx.TxEvents) == 0 {
+			processedTx.BalanceChanges = ProcessFeeBalanceChanges(processedTx.Tx)
+		}
+
 		currTxDbWrappers = append(currTxDbWrappers, processedTx)
 	}
 
-	return currTxDbWrappers, blockTime, nil
+	return currTxDbWrappers, failedTxs, blockTime, nil
 }
 
 func tendermintHashToHex(hash []byte) string {
@@ -216,8 +250,9 @@ func tendermintHashToHex(hash []byte) string {
 }
 
 // ProcessRPCTXs - Given an RPC response, build out the more specific data used by the parser.
-func ProcessRPCTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client.ChainClient, messageTypeFilters []filter.MessageTypeFilter, messageFilters []filter.MessageFilter, txEventResp *cosmosTx.GetTxsEventResponse, customParsers map[string][]parsers.MessageParser) ([]dbTypes.TxDBWrapper, *time.Time, error) {
+func ProcessRPCTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client.ChainClient, messageTypeFilters []filter.MessageTypeFilter, messageFilters []filter.MessageFilter, txEventResp *cosmosTx.GetTxsEventResponse, customParsers map[string][]parsers.MessageParser) ([]dbTypes.TxDBWrapper, []models.FailedTx, *time.Time, error) {
 	var currTxDbWrappers []dbTypes.TxDBWrapper
+	var failedTxs []models.FailedTx
 	var blockTime *time.Time
 
 	for txIdx := range txEventResp.Txs {
@@ -229,16 +264,23 @@ func ProcessRPCTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client.ChainClient,
 		var currLogMsgs []txtypes.LogMessage
 		var messagesRaw [][]byte
 		var messageTypeURLs []string
+		var failedMessages []models.FailedMessage
 
 		currTx := txEventResp.Txs[txIdx]
 		currTxResp := txEventResp.TxResponses[txIdx]
 
+		// The raw bytes are only needed to record the transaction as failed
+		failTx := func(err error) {
+			txBytes, _ := currTx.Marshal()
+			failedTxs = append(failedTxs, newFailedTx(currTxResp.Height, currTxResp.TxHash, txBytes, err))
+		}
+
 		if len(currTxResp.Logs) == 0 && len(currTxResp.Events) != 0 {
 			// We have a version of Cosmos SDK that removed the Logs field from the TxResponse, we need to parse the events into message index logs
 			parsedLogs, err := indexerEvents.ParseTxEventsToMessageIndexEvents(len(currTx.Body.Messages), currTxResp.Events)
 			if err != nil {
-				config.Log.Errorf("Error parsing events to message index events to normalize: %v", err)
-				return nil, blockTime, err
+				failTx(fmt.Errorf("logs could not be parsed: %w", err))
+				continue
 			}
 
 			currTxResp.Logs = parsedLogs
@@ -249,13 +291,13 @@ func ProcessRPCTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client.ChainClient,
 
 			shouldIndex, err := messageTypeShouldIndex(currTx.Body.Messages[msgIdx].TypeUrl, messageTypeFilters, customParsers)
 			if err != nil {
-				return nil, blockTime, err
+				return nil, nil, blockTime, err
 			}
 
 			messageTypeURLs = append(messageTypeURLs, currTx.Body.Messages[msgIdx].TypeUrl)
 
 			if !shouldIndex {
-				config.Log.Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping msg of type '%v' due to message type filter.", currTxResp.Height, currTxResp.TxHash, currTx.Body.Messages[msgIdx].TypeUrl))
+				config.LogCtx(db.Statement.Context).Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping msg of type '%v' due to message type filter.", currTxResp.Height, currTxResp.TxHash, currTx.Body.Messages[msgIdx].TypeUrl))
 				currMessages = append(currMessages, nil)
 				currLogMsgs = append(currLogMsgs, txtypes.LogMessage{
 					MessageIndex: msgIdx,
@@ -273,12 +315,15 @@ func ProcessRPCTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client.ChainClient,
 				var currMsgUnpack types.Msg
 				err := cl.Codec.InterfaceRegistry.UnpackAny(currTx.Body.Messages[msgIdx], &currMsgUnpack)
 				if err != nil || currMsgUnpack == nil {
-					return nil, blockTime, fmt.Errorf("tx message could not be processed. Unpacking protos failed and CachedValue is not present. TX Hash: %s, Msg type: %s, Msg index: %d, Code: %d",
-						currTxResp.TxHash,
-						currTx.Body.Messages[msgIdx].TypeUrl,
-						msgIdx,
-						currTxResp.Code,
-					)
+					if err == nil {
+						err = errMessageNotDecoded
+					}
+					failedMessages = append(failedMessages, newFailedMessage(currTxResp.Height, currTxResp.TxHash, msgIdx, currTx.Body.Messages[msgIdx], err))
+					currMessages = append(currMessages, nil)
+					currLogMsgs = append(currLogMsgs, txtypes.LogMessage{
+						MessageIndex: msgIdx,
+					})
+					continue
 				}
 				currMsg = currMsgUnpack
 			}
@@ -301,7 +346,7 @@ func ProcessRPCTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client.ChainClient,
 				}
 
 				if !shouldIndex {
-					config.Log.Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping msg of type '%v' due to custom message filter.", currTxResp.Height, currTxResp.TxHash, currTx.Body.Messages[msgIdx].TypeUrl))
+					config.LogCtx(db.Statement.Context).Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping msg of type '%v' due to custom message filter.", currTxResp.Height, currTxResp.TxHash, currTx.Body.Messages[msgIdx].TypeUrl))
 					currMessages = append(currMessages, nil)
 					currLogMsgs = append(currLogMsgs, txtypes.LogMessage{
 						MessageIndex: msgIdx,
@@ -325,6 +370,10 @@ func ProcessRPCTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client.ChainClient,
 			RawLog:    currTxResp.RawLog,
 			Log:       currLogMsgs,
 			Code:      currTxResp.Code,
+			Codespace: currTxResp.Codespace,
+			GasWanted: currTxResp.GasWanted,
+			GasUsed:   currTxResp.GasUsed,
+			TxEvents:  indexerEvents.ParseTxLevelEvents(currTxResp.Code, currTxResp.Events),
 		}
 
 		indexerTx.AuthInfo = *currTx.AuthInfo
@@ -332,17 +381,20 @@ func ProcessRPCTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client.ChainClient,
 		indexerMergedTx.Tx = indexerTx
 		indexerMergedTx.Tx.AuthInfo = *currTx.AuthInfo
 
-		processedTx, txTime, err := ProcessTx(cfg, db, indexerMergedTx, messagesRaw, messageTypeURLs, customParsers)
+		processedTx, txTime, err := ProcessTx(cfg, db, cl.Codec.Marshaler, indexerMergedTx, messagesRaw, messageTypeURLs, customParsers)
 		if err != nil {
-			return currTxDbWrappers, blockTime, err
+			failTx(err)
+			continue
 		}
 
-		if len(processedTx.Messages) == 0 && !cfg.Flags.IndexEmptyTransactions {
-			config.Log.Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping empty transaction.", currTxResp.Height, currTxResp.TxHash))
+		processedTx.FailedMessages = failedMessages
+
+		if processedTx.IsEmpty() && !cfg.Flags.IndexEmptyTransactions {
+			config.LogCtx(db.Statement.Context).Debug(fmt.Sprintf("[Block: %v] [TX: %v] Skipping empty transaction.", currTxResp.Height, currTxResp.TxHash))
 			continue
 		}
 
-		config.Log.Debug(fmt.Sprintf("[Block: %v] [TX: %v] Processing transaction with %d messages.", currTxResp.Height, currTxResp.TxHash, len(processedTx.Messages)))
+		config.LogCtx(db.Statement.Context).Debug(fmt.Sprintf("[Block: %v] [TX: %v] Processing transaction with %d messages.", currTxResp.Height, currTxResp.TxHash, len(processedTx.Messages)))
 
 		if blockTime == nil {
 			blockTime = &txTime
@@ -357,27 +409,69 @@ func ProcessRPCTXs(cfg *config.IndexConfig, db *gorm.DB, cl *client.ChainClient,
 
 		err = currTx.AuthInfo.UnpackInterfaces(cl.Codec.InterfaceRegistry)
 		if err != nil {
-			return currTxDbWrappers, blockTime, err
+			failTx(err)
+			continue
 		}
 
 		signers, err := ProcessSigners(cl, currTx.AuthInfo, filteredSigners)
 		if err != nil {
-			return currTxDbWrappers, blockTime, err
+			failTx(err)
+			continue
 		}
 		processedTx.Tx.SignerAddresses = signers
 
 		fees, err := ProcessFees(db, indexerTx.AuthInfo, signers)
 		if err != nil {
-			return currTxDbWrappers, blockTime, err
+			failTx(err)
+			continue
 		}
 
 		processedTx.Tx.Fees = fees
 		processedTx.Tx.Memo = currTx.Body.Memo
 
+		signerInfos, err := ProcessSignerInfos(cl, currTx.AuthInfo)
+		if err != nil {
+			failTx(err)
+			continue
+		}
+
+		processedTx.Tx.SignerInfos = signerInfos
+		// Txes at a single height are returned in the order they were included in the block
+		ProcessTxMetadata(&processedTx.Tx, txIdx, currTx, signers)
+
+		// Only SDK v0.50 and later mark the fee events of successful txes, derive the fee deduction from the fees otherwise
+		if cfg.Flags.IndexBalanceChanges && len(processedTx.TxEvents) == 0 {
+			processedTx.BalanceChanges = ProcessFeeBalanceChanges(processedTx.Tx)
+		}
+
 		currTxDbWrappers = append(currTxDbWrappers, processedTx)
 	}
 
-	return currTxDbWrappers, blockTime, nil
+	return currTxDbWrappers, failedTxs, blockTime, nil
+}
+
+// errMessageNotDecoded is recorded for messages whose proto definition is not registered with the codec
+var errMessageNotDecoded = errors.New("message could not be decoded, the proto definition of the message type is likely not registered")
+
+// newFailedTx records a transaction that could not be processed, so the rest of the block can still be indexed
+func newFailedTx(height int64, txHash string, txBytes []byte, err error) models.FailedTx {
+	config.Log.Errorf("[Block: %v] [TX: %v] Failed to process transaction, adding to failed txes. Err: %v", height, txHash, err)
+	return models.FailedTx{
+		Hash:         txHash,
+		TxBytes:      txBytes,
+		ErrorMessage: err.Error(),
+	}
+}
+
+// newFailedMessage records a message that could not be decoded, so the rest of the transaction can still be indexed
+func newFailedMessage(height int64, txHash string, messageIndex int, message *codecTypes.Any, err error) models.FailedMessage {
+	config.Log.Errorf("[Block: %v] [TX: %v] Failed to decode message %d of type '%v', adding to failed messages. Err: %v", height, txHash, messageIndex, message.TypeUrl, err)
+	return models.FailedMessage{
+		MessageIndex:   messageIndex,
+		MessageTypeURL: message.TypeUrl,
+		MessageBytes:   message.Value,
+		ErrorMessage:   err.Error(),
+	}
 }
 
 func messageTypeShouldIndex(messageType string, filters []filter.MessageTypeFilter, customParsers map[string][]parsers.MessageParser) (bool, error) {
@@ -414,10 +508,23 @@ func messageTypeShouldIndex(messageType string, filters []filter.MessageTypeFilt
 	return true, nil
 }
 
-func ProcessTx(cfg *config.IndexConfig, db *gorm.DB, tx txtypes.MergedTx, messagesRaw [][]byte, messageTypeURLs []string, customParsers map[string][]parsers.MessageParser) (txDBWapper dbTypes.TxDBWrapper, txTime time.Time, err error) {
+// MessageJSON renders a message as proto-JSON with the client codec.
+// Messages decoded from runtime loaded descriptors are not gogoproto types, so they render themselves.
+func MessageJSON(cdc codec.JSONCodec, msg types.Msg) (json.RawMessage, error) {
+	bz, err := cdc.MarshalJSON(msg)
+	if err != nil {
+		if jsonMsg, ok := msg.(json.Marshaler); ok {
+			return jsonMsg.MarshalJSON()
+		}
+		return nil, err
+	}
+	return bz, nil
+}
+
+func ProcessTx(cfg *config.IndexConfig, db *gorm.DB, cdc codec.JSONCodec, tx txtypes.MergedTx, messagesRaw [][]byte, messageTypeURLs []string, customParsers map[string][]parsers.MessageParser) (txDBWapper dbTypes.TxDBWrapper, txTime time.Time, err error) {
 	txTime, err = time.Parse(time.RFC3339, tx.TxResponse.TimeStamp)
 	if err != nil {
-		config.Log.Error("Error parsing tx timestamp.", err)
+		config.LogCtx(db.Statement.Context).Error("Error parsing tx timestamp.", err)
 		return txDBWapper, txTime, err
 	}
 
@@ -435,8 +542,22 @@ func ProcessTx(cfg *config.IndexConfig, db *gorm.DB, tx txtypes.MergedTx, messag
 				messageLog := txtypes.GetMessageLogForIndex(tx.TxResponse.Log, messageIndex)
 				messageType, currMessageDBWrapper := ProcessMessage(messageIndex, message, messageTypeURLs[messageIndex], messageLog, uniqueEventTypes, uniqueEventAttributeKeys)
 				currMessageDBWrapper.Message.MessageBytes = messagesRaw[messageIndex]
+				if cfg.Flags.IndexBalanceChanges && messageLog != nil {
+					for eventIndex, event := range messageLog.Events {
+						currMessageDBWrapper.MessageEvents[eventIndex].BalanceChanges = ProcessBalanceChanges(event)
+					}
+				}
+				if cfg.Flags.IndexTxMessageJSON {
+					messageJSON, err := MessageJSON(cdc, message)
+					if err != nil {
+						// The message is still indexed, only without its JSON rendering
+						config.LogCtx(db.Statement.Context).Warnf("[Block: %v] [TX: %v] Error rendering msg %v of type '%v' as JSON: %v", tx.TxResponse.Height, tx.TxResponse.TxHash, messageIndex, messageType, err)
+					} else {
+						currMessageDBWrapper.Message.MessageJSON = messageJSON
+					}
+				}
 				uniqueMessageTypes[messageType] = currMessageDBWrapper.Message.MessageType
-				config.Log.Debug(fmt.Sprintf("[Block: %v] [TX: %v] Found msg of type '%v'.", tx.TxResponse.Height, tx.TxResponse.TxHash, messageType))
+				config.LogCtx(db.Statement.Context).Debug(fmt.Sprintf("[Block: %v] [TX: %v] Found msg of type '%v'.", tx.TxResponse.Height, tx.TxResponse.TxHash, messageType))
 
 				if customParsers != nil {
 					if customMessageParsers, ok := customParsers[messageType]; ok {
@@ -458,8 +579,27 @@ func ProcessTx(cfg *config.IndexConfig, db *gorm.DB, tx txtypes.MergedTx, messag
 		}
 	}
 
-	txDBWapper.Tx = models.Tx{Hash: tx.TxResponse.TxHash, Code: code}
+	txDBWapper.Tx = models.Tx{
+		Hash:      tx.TxResponse.TxHash,
+		Code:      code,
+		Codespace: tx.TxResponse.Codespace,
+		GasWanted: tx.TxResponse.GasWanted,
+		GasUsed:   tx.TxResponse.GasUsed,
+	}
+	// The logs of successful txes are already indexed as message events
+	if code != 0 {
+		txDBWapper.Tx.RawLog = tx.TxResponse.RawLog
+	}
 	txDBWapper.Messages = messages
+	// The balance changes of tx events are kept even if the events themselves are not indexed
+	if cfg.Flags.IndexTxEvents || cfg.Flags.IndexBalanceChanges {
+		txDBWapper.TxEvents = ProcessTxEvents(tx.TxResponse.TxEvents, uniqueEventTypes, uniqueEventAttributeKeys)
+	}
+	if cfg.Flags.IndexBalanceChanges {
+		for eventIndex, event := range tx.TxResponse.TxEvents {
+			txDBWapper.TxEvents[eventIndex].BalanceChanges = ProcessBalanceChanges(event)
+		}
+	}
 	txDBWapper.UniqueMessageTypes = uniqueMessageTypes
 	txDBWapper.UniqueMessageAttributeKeys = uniqueEventAttributeKeys
 	txDBWapper.UniqueMessageEventTypes = uniqueEventTypes
@@ -537,7 +677,6 @@ func ProcessSigners(cl *client.ChainClient, authInfo *cosmosTx.AuthInfo, message
 // Processes fees into model form, applying denoms and addresses to them
 func ProcessFees(db *gorm.DB, authInfo cosmosTx.AuthInfo, signers []models.Address) ([]models.Fee, error) {
 	feeCoins := authInfo.Fee.Amount
-	payer := authInfo.Fee.GetPayer()
 	fees := []models.Fee{}
 
 	for _, coin := range feeCoins {
@@ -547,18 +686,109 @@ func ProcessFees(db *gorm.DB, authInfo cosmosTx.AuthInfo, signers []models.Addre
 			amount := util.ToNumeric(coin.Amount.BigInt())
 			denom := models.Denom{Base: coin.Denom}
 
-			payerAddr := models.Address{}
-			if payer != "" {
-				payerAddr.Address = payer
-			} else if len(signers) > 0 {
-				payerAddr = signers[0]
+			fees = append(fees, models.Fee{Amount: amount, Denomination: denom, PayerAddress: feePayer(authInfo.Fee, signers)})
+		}
+	}
+
+	return fees, nil
+}
+
+// feePayer is the explicit payer of the fee, or the first signer otherwise
+func feePayer(fee *cosmosTx.Fee, signers []models.Address) models.Address {
+	payerAddr := models.Address{}
+	if fee.GetPayer() != "" {
+		payerAddr.Address = fee.GetPayer()
+	} else if len(signers) > 0 {
+		payerAddr = signers[0]
+	}
+	return payerAddr
+}
+
+// Processes the position, timeout height and fee payer and granter of a tx into model form
+func ProcessTxMetadata(tx *models.Tx, txIndex int, txFull *cosmosTx.Tx, signers []models.Address) {
+	tx.TxIndex = txIndex
+	tx.TimeoutHeight = txFull.Body.TimeoutHeight
+
+	if payer := feePayer(txFull.AuthInfo.Fee, signers); payer.Address != "" {
+		tx.FeePayerAddress = &models.Address{Address: payer.Address}
+	}
+
+	if granter := txFull.AuthInfo.Fee.GetGranter(); granter != "" {
+		tx.FeeGranterAddress = &models.Address{Address: granter}
+	}
+}
+
+// Processes the sequence and sign mode of every signer info, in the order of the tx signatures
+func ProcessSignerInfos(cl *client.ChainClient, authInfo *cosmosTx.AuthInfo) ([]models.TxSignerInfo, error) {
+	var signerInfos []models.TxSignerInfo
+
+	for signerIndex, signerInfo := range authInfo.SignerInfos {
+		txSignerInfo := models.TxSignerInfo{
+			SignerIndex: signerIndex,
+			Sequence:    signerInfo.Sequence,
+			SignMode:    signModeName(signerInfo.ModeInfo),
+		}
+
+		// The public key is omitted once it is stored on the signer account
+		if signerInfo.PublicKey != nil {
+			var pubKey cryptoTypes.PubKey
+			err := cl.Codec.InterfaceRegistry.UnpackAny(signerInfo.PublicKey, &pubKey)
+			if err != nil {
+				return nil, err
 			}
+			txSignerInfo.Address = &models.Address{
+				Address:    types.AccAddress(pubKey.Address().Bytes()).String(),
+				PubKeyType: signerInfo.PublicKey.TypeUrl,
+				PubKey:     base64.StdEncoding.EncodeToString(pubKey.Bytes()),
+			}
+		}
+
+		signerInfos = append(signerInfos, txSignerInfo)
+	}
+
+	return signerInfos, nil
+}
 
-			fees = append(fees, models.Fee{Amount: amount, Denomination: denom, PayerAddress: payerAddr})
+// signModeName names the sign mode of a signer, multisig signers list the sign modes of their keys e.g. MULTI(SIGN_MODE_DIRECT,SIGN_MODE_LEGACY_AMINO_JSON)
+func signModeName(modeInfo *cosmosTx.ModeInfo) string {
+	switch mode := modeInfo.GetSum().(type) {
+	case *cosmosTx.ModeInfo_Single_:
+		return mode.Single.Mode.String()
+	case *cosmosTx.ModeInfo_Multi_:
+		var modes []string
+		for _, keyModeInfo := range mode.Multi.ModeInfos {
+			modes = append(modes, signModeName(keyModeInfo))
 		}
+		return fmt.Sprintf("MULTI(%s)", strings.Join(modes, ","))
 	}
+	return ""
+}
 
-	return fees, nil
+// ProcessTxEvents processes the events of a transaction that belong to no message, normalizing their types and attribute keys like message events
+func ProcessTxEvents(events []txtypes.LogMessageEvent, uniqueEventTypes map[string]models.MessageEventType, uniqueEventAttributeKeys map[string]models.MessageEventAttributeKey) []dbTypes.TxEventDBWrapper {
+	var txEvents []dbTypes.TxEventDBWrapper
+	for eventIndex, event := range events {
+		uniqueEventTypes[event.Type] = models.MessageEventType{Type: event.Type}
+
+		currTxEvent := dbTypes.TxEventDBWrapper{
+			TxEvent: models.TxEvent{
+				MessageEventType: uniqueEventTypes[event.Type],
+				Index:            uint64(eventIndex),
+			},
+		}
+		for attributeIndex, attribute := range event.Attributes {
+			uniqueEventAttributeKeys[attribute.Key] = models.MessageEventAttributeKey{Key: attribute.Key}
+
+			currTxEvent.Attributes = append(currTxEvent.Attributes, models.TxEventAttribute{
+				Value:                    attribute.Value,
+				MessageEventAttributeKey: uniqueEventAttributeKeys[attribute.Key],
+				Index:                    uint64(attributeIndex),
+			})
+		}
+
+		txEvents = append(txEvents, currTxEvent)
+	}
+	return txEvents
 }
 
 func ProcessMessage(messageIndex int, message types.Msg, messageTypeURL string, messageLog *txtypes.LogMessage, uniqueEventTypes map[string]models.MessageEventType, uniqueEventAttributeKeys map[string]models.MessageEventAttributeKey) (string, dbTypes.MessageDBWrapper) {
@@ -573,6 +803,11 @@ func ProcessMessage(messageIndex int, message types.Msg, messageTypeURL string,
 	currMessage.MessageType = currMessageType
 	currMessageDBWrapper.Message = currMessage
 
+	// Messages without a log, such as messages of older SDK logs or filtered messages, have no events
+	if messageLog == nil {
+		return currMessageType.MessageType, currMessageDBWrapper
+	}
+
 	for eventIndex, event := range messageLog.Events {
 		uniqueEventTypes[event.Type] = models.MessageEventType{Type: event.Type}
 
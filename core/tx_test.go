@@ -0,0 +1,208 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	txtypes "github.com/DefiantLabs/cosmos-indexer/cosmos/modules/tx"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/types"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestProcessMessage(t *testing.T) {
+	const msgSendType = "/cosmos.bank.v1beta1.MsgSend"
+
+	tests := []struct {
+		name       string
+		messageLog *txtypes.LogMessage
+		eventTypes []string
+	}{
+		{
+			name:       "message without a log has no events",
+			messageLog: nil,
+		},
+		{
+			name: "message events are taken from the log",
+			messageLog: &txtypes.LogMessage{Events: []txtypes.LogMessageEvent{
+				{Type: "coin_spent", Attributes: []txtypes.Attribute{{Key: "spender", Value: "cosmos1sender"}, {Key: "amount", Value: "100uatom"}}},
+				{Type: "coin_received", Attributes: []txtypes.Attribute{{Key: "receiver", Value: "cosmos1receiver"}}},
+			}},
+			eventTypes: []string{"coin_spent", "coin_received"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uniqueEventTypes := make(map[string]models.MessageEventType)
+			uniqueEventAttributeKeys := make(map[string]models.MessageEventAttributeKey)
+
+			messageType, wrapper := ProcessMessage(1, &bankTypes.MsgSend{}, msgSendType, tt.messageLog, uniqueEventTypes, uniqueEventAttributeKeys)
+			require.Equal(t, msgSendType, messageType)
+			require.Equal(t, 1, wrapper.Message.MessageIndex)
+			require.Len(t, wrapper.MessageEvents, len(tt.eventTypes))
+			require.Len(t, uniqueEventTypes, len(tt.eventTypes))
+
+			for index, eventType := range tt.eventTypes {
+				require.Equal(t, eventType, wrapper.MessageEvents[index].MessageEvent.MessageEventType.Type)
+				require.Equal(t, uint64(index), wrapper.MessageEvents[index].MessageEvent.Index)
+				require.Len(t, wrapper.MessageEvents[index].Attributes, len(tt.messageLog.Events[index].Attributes))
+			}
+		})
+	}
+}
+
+// unregisteredMsg is a message type the codec cannot render as proto-JSON
+type unregisteredMsg struct{}
+
+func (m *unregisteredMsg) Reset()                         {}
+func (m *unregisteredMsg) String() string                 { return "unregistered" }
+func (m *unregisteredMsg) ProtoMessage()                  {}
+func (m *unregisteredMsg) ValidateBasic() error           { return nil }
+func (m *unregisteredMsg) GetSigners() []types.AccAddress { return nil }
+
+// selfRenderingMsg is an unregistered message type that renders itself, like the messages decoded from runtime loaded descriptors
+type selfRenderingMsg struct {
+	unregisteredMsg
+}
+
+func (m *selfRenderingMsg) MarshalJSON() ([]byte, error) {
+	return []byte(`{"rendered":true}`), nil
+}
+
+func newBankCodec() codec.Codec {
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	bankTypes.RegisterInterfaces(interfaceRegistry)
+	return codec.NewProtoCodec(interfaceRegistry)
+}
+
+func newMsgSend() *bankTypes.MsgSend {
+	return &bankTypes.MsgSend{
+		FromAddress: "cosmos1sender",
+		ToAddress:   "cosmos1receiver",
+		Amount:      types.NewCoins(types.NewInt64Coin("uatom", 100)),
+	}
+}
+
+func TestMessageJSON(t *testing.T) {
+	cdc := newBankCodec()
+
+	tests := []struct {
+		name     string
+		msg      types.Msg
+		expected string
+		err      bool
+	}{
+		{
+			name:     "registered message renders as proto-JSON",
+			msg:      newMsgSend(),
+			expected: `{"from_address":"cosmos1sender","to_address":"cosmos1receiver","amount":[{"denom":"uatom","amount":"100"}]}`,
+		},
+		{
+			name:     "message unknown to the codec renders itself",
+			msg:      &selfRenderingMsg{},
+			expected: `{"rendered":true}`,
+		},
+		{
+			name: "message unknown to the codec that cannot render itself",
+			msg:  &unregisteredMsg{},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageJSON, err := MessageJSON(cdc, tt.msg)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tt.expected, string(messageJSON))
+		})
+	}
+}
+
+func TestMessageJSONRoundTrip(t *testing.T) {
+	cdc := newBankCodec()
+	msg := newMsgSend()
+
+	messageJSON, err := MessageJSON(cdc, msg)
+	require.NoError(t, err)
+
+	// The indexed JSON decodes back into the registered message type
+	var decoded bankTypes.MsgSend
+	require.NoError(t, cdc.UnmarshalJSON(messageJSON, &decoded))
+	require.Equal(t, msg, &decoded)
+
+	// And through the type URL, as a consumer of the message_json column would
+	msgAny, err := codectypes.NewAnyWithValue(msg)
+	require.NoError(t, err)
+	var resolved types.Msg
+	require.NoError(t, cdc.UnpackAny(msgAny, &resolved))
+	resolvedJSON, err := MessageJSON(cdc, resolved)
+	require.NoError(t, err)
+	require.JSONEq(t, string(messageJSON), string(resolvedJSON))
+}
+
+func TestProcessTxMessageJSON(t *testing.T) {
+	cdc := newBankCodec()
+	db := &gorm.DB{Statement: &gorm.Statement{Context: context.Background()}}
+
+	tx := txtypes.MergedTx{
+		Tx: txtypes.IndexerTx{Body: txtypes.Body{Messages: []types.Msg{newMsgSend(), &unregisteredMsg{}}}},
+		TxResponse: txtypes.Response{
+			TxHash:    "hash",
+			Height:    "1",
+			TimeStamp: time.Now().Format(time.RFC3339),
+		},
+	}
+	messagesRaw := [][]byte{[]byte("send"), []byte("unregistered")}
+	messageTypeURLs := []string{"/cosmos.bank.v1beta1.MsgSend", "/fake.v1.MsgUnregistered"}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		json    []json.RawMessage
+	}{
+		{
+			name: "messages are indexed without JSON when disabled",
+			json: []json.RawMessage{nil, nil},
+		},
+		{
+			// A message that fails to render is still indexed, only without its JSON rendering
+			name:    "messages that fail to render are indexed without JSON",
+			enabled: true,
+			json:    []json.RawMessage{json.RawMessage(`{"from_address":"cosmos1sender","to_address":"cosmos1receiver","amount":[{"denom":"uatom","amount":"100"}]}`), nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.IndexConfig{}
+			cfg.Flags.IndexTxMessageJSON = tt.enabled
+
+			txDBWrapper, _, err := ProcessTx(cfg, db, cdc, tx, messagesRaw, messageTypeURLs, nil)
+			require.NoError(t, err)
+			require.Len(t, txDBWrapper.Messages, len(tt.json))
+
+			for index, expected := range tt.json {
+				message := txDBWrapper.Messages[index].Message
+				require.Equal(t, messagesRaw[index], message.MessageBytes)
+				require.Equal(t, messageTypeURLs[index], message.MessageType.MessageType)
+				if expected == nil {
+					require.Nil(t, message.MessageJSON)
+				} else {
+					require.JSONEq(t, string(expected), string(message.MessageJSON))
+				}
+			}
+		})
+	}
+}
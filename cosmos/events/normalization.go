@@ -87,3 +87,31 @@ func ParseTxEventsToMessageIndexEvents(numMessages int, events []cometAbciTypes.
 
 	return parsedLogs, nil
 }
+
+// ParseTxLevelEvents returns the events of a transaction that belong to no message, such as the fee deduction and the tx (acc_seq, signature) events of the ante handler.
+// Failed transactions only emit these events. Successful transactions tell them apart from message events by the missing msg_index attribute,
+// which is only set by Cosmos SDK v0.50 and later. For successful transactions of earlier versions no events are returned.
+func ParseTxLevelEvents(code uint32, events []cometAbciTypes.Event) []txtypes.LogMessageEvent {
+	logMessageEvents := toNormalizedEvents(events)
+	if code != 0 {
+		return logMessageEvents
+	}
+
+	var txEvents []txtypes.LogMessageEvent
+	hasMessageIndex := false
+	for _, event := range logMessageEvents {
+		loopEvent := event
+		val, err := txtypes.GetValueForAttribute("msg_index", &loopEvent)
+		if err == nil && val != "" {
+			hasMessageIndex = true
+			continue
+		}
+		txEvents = append(txEvents, event)
+	}
+
+	if !hasMessageIndex {
+		return nil
+	}
+
+	return txEvents
+}
@@ -7,7 +7,11 @@ import (
 	"unicode"
 )
 
-const EventAttributeAmount = "amount"
+const (
+	EventAttributeAmount  = "amount"
+	EventTypeCoinSpent    = "coin_spent"
+	EventTypeCoinReceived = "coin_received"
+)
 
 func GetMessageLogForIndex(logs []LogMessage, index int) *LogMessage {
 	for _, log := range logs {
@@ -70,6 +74,13 @@ type TransferEvent struct {
 	Amount    string
 }
 
+// CoinBalanceEvent is an address and the comma separated coins it spent or received
+type CoinBalanceEvent struct {
+	Address string
+	Amount  string
+	Spent   bool
+}
+
 // Transfer events should have attributes in the order recipient, sender, amount.
 func ParseTransferEvent(evt LogMessageEvent) ([]TransferEvent, error) {
 	errInvalidTransfer := errors.New("not a valid transfer event")
@@ -106,6 +117,41 @@ func ParseTransferEvent(evt LogMessageEvent) ([]TransferEvent, error) {
 	return transfers, nil
 }
 
+// Coin balance events should have attributes in the order address, amount. The address key is spender for coin_spent and receiver for coin_received events.
+// Logs of older SDK versions merge all events of a type emitted by a message, repeating the pairs.
+func ParseCoinBalanceEvent(evt LogMessageEvent) ([]CoinBalanceEvent, error) {
+	var addressKey string
+	switch evt.Type {
+	case EventTypeCoinSpent:
+		addressKey = "spender"
+	case EventTypeCoinReceived:
+		addressKey = "receiver"
+	default:
+		return nil, errors.New("not a valid coin balance event")
+	}
+
+	balanceEvents := []CoinBalanceEvent{}
+	for i := 0; i < len(evt.Attributes); i++ {
+		if evt.Attributes[i].Key != addressKey {
+			continue
+		}
+
+		attrAmountIdx := i + 1
+		if attrAmountIdx >= len(evt.Attributes) || evt.Attributes[attrAmountIdx].Key != EventAttributeAmount {
+			return nil, fmt.Errorf("%s attribute without amount in %s event", addressKey, evt.Type)
+		}
+
+		balanceEvents = append(balanceEvents, CoinBalanceEvent{
+			Address: evt.Attributes[i].Value,
+			Amount:  evt.Attributes[attrAmountIdx].Value,
+			Spent:   evt.Type == EventTypeCoinSpent,
+		})
+		i = attrAmountIdx
+	}
+
+	return balanceEvents, nil
+}
+
 // If order is reversed, the last attribute containing the given key will be returned
 // otherwise the first attribute will be returned
 func GetValueForAttribute(key string, evt *LogMessageEvent) (string, error) {
@@ -16,8 +16,13 @@ type Response struct {
 	Height    string       `json:"height"`
 	TimeStamp string       `json:"timestamp"`
 	Code      uint32       `json:"code"`
+	Codespace string       `json:"codespace"`
+	GasWanted int64        `json:"gas_wanted,string"`
+	GasUsed   int64        `json:"gas_used,string"`
 	RawLog    string       `json:"raw_log"`
 	Log       []LogMessage `json:"logs"`
+	// Events of the tx that belong to no message
+	TxEvents []LogMessageEvent `json:"-"`
 }
 
 // TxLogMessage:
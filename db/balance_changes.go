@@ -0,0 +1,146 @@
+package db
+
+import (
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// txEventKey identifies a tx event by its tx and its position in the tx
+type txEventKey struct {
+	TxID  uint
+	Index uint64
+}
+
+// indexTxBalanceChanges writes the balance changes of already created txes, replacing the changes of reindexed txes.
+// Changes reference the message events and the passed in created tx events they were derived from, if those were indexed.
+func indexTxBalanceChanges(db *gorm.DB, txs []TxDBWrapper, txEvents []models.TxEvent) error {
+	txEventIDs := make(map[txEventKey]uint, len(txEvents))
+	for _, txEvent := range txEvents {
+		txEventIDs[txEventKey{TxID: txEvent.TxID, Index: txEvent.Index}] = txEvent.ID
+	}
+
+	var txIDs []uint
+	var balanceChanges []models.BalanceChange
+	addBalanceChanges := func(tx models.Tx, changes []models.BalanceChange, messageEventID *uint, txEventID *uint) {
+		for _, change := range changes {
+			change.BlockID = tx.BlockID
+			change.Height = tx.Block.Height
+			change.TxID = &tx.ID
+			change.MessageEventID = messageEventID
+			change.TxEventID = txEventID
+			balanceChanges = append(balanceChanges, change)
+		}
+	}
+
+	for _, tx := range txs {
+		if tx.Tx.ID == 0 {
+			continue
+		}
+		txIDs = append(txIDs, tx.Tx.ID)
+
+		addBalanceChanges(tx.Tx, tx.BalanceChanges, nil, nil)
+
+		for _, txEvent := range tx.TxEvents {
+			var txEventID *uint
+			if eventID, ok := txEventIDs[txEventKey{TxID: tx.Tx.ID, Index: txEvent.TxEvent.Index}]; ok {
+				txEventID = &eventID
+			}
+			addBalanceChanges(tx.Tx, txEvent.BalanceChanges, nil, txEventID)
+		}
+
+		for _, message := range tx.Messages {
+			for _, messageEvent := range message.MessageEvents {
+				var messageEventID *uint
+				if messageEvent.MessageEvent.ID != 0 {
+					eventID := messageEvent.MessageEvent.ID
+					messageEventID = &eventID
+				}
+				addBalanceChanges(tx.Tx, messageEvent.BalanceChanges, messageEventID, nil)
+			}
+		}
+	}
+
+	// Reindexed txes may no longer have any balance changes, their stored changes are cleared either way
+	if len(txIDs) != 0 {
+		if err := db.Exec("DELETE FROM balance_changes WHERE tx_id IN ?", txIDs).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error clearing tx balance changes.", err)
+			return err
+		}
+	}
+
+	if len(balanceChanges) == 0 {
+		return nil
+	}
+
+	return insertBalanceChanges(db, balanceChanges)
+}
+
+// indexBlockEventBalanceChanges writes the balance changes of the already created BeginBlock and EndBlock events of the blocks,
+// replacing the block event changes of reindexed blocks. Blocks whose balance changes were not derived are skipped.
+func indexBlockEventBalanceChanges(db *gorm.DB, blockDBWrappers ...*BlockDBWrapper) error {
+	var blockIDs []uint
+	var balanceChanges []models.BalanceChange
+	for _, blockDBWrapper := range blockDBWrappers {
+		if !blockDBWrapper.IndexBalanceChanges {
+			continue
+		}
+		blockIDs = append(blockIDs, blockDBWrapper.Block.ID)
+		for _, events := range [][]BlockEventDBWrapper{blockDBWrapper.BeginBlockEvents, blockDBWrapper.EndBlockEvents} {
+			for _, event := range events {
+				eventID := event.BlockEvent.ID
+				for _, change := range event.BalanceChanges {
+					change.BlockID = blockDBWrapper.Block.ID
+					change.Height = blockDBWrapper.Block.Height
+					change.BlockEventID = &eventID
+					balanceChanges = append(balanceChanges, change)
+				}
+			}
+		}
+	}
+
+	// Reindexed blocks may no longer have any block event balance changes, their stored changes are cleared either way
+	if len(blockIDs) != 0 {
+		if err := db.Exec("DELETE FROM balance_changes WHERE block_id IN ? AND block_event_id IS NOT NULL", blockIDs).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error clearing block event balance changes.", err)
+			return err
+		}
+	}
+
+	if len(balanceChanges) == 0 {
+		return nil
+	}
+
+	return insertBalanceChanges(db, balanceChanges)
+}
+
+// insertBalanceChanges creates the addresses and denoms of the balance changes and inserts the changes
+func insertBalanceChanges(db *gorm.DB, balanceChanges []models.BalanceChange) error {
+	uniqueAddress := make(map[string]models.Address)
+	denomMap := make(map[string]models.Denom)
+	for _, change := range balanceChanges {
+		addAddress(uniqueAddress, change.Address, change.Height)
+		denomMap[change.Denom.Base] = models.Denom{Base: change.Denom.Base}
+	}
+
+	if err := upsertAddresses(db, uniqueAddress); err != nil {
+		return err
+	}
+
+	if err := upsertDenoms(db, denomMap); err != nil {
+		return err
+	}
+
+	for index := range balanceChanges {
+		balanceChanges[index].AddressID = uniqueAddress[balanceChanges[index].Address.Address].ID
+		balanceChanges[index].DenomID = denomMap[balanceChanges[index].Denom.Base].ID
+	}
+
+	if err := db.Omit(clause.Associations).CreateInBatches(balanceChanges, bulkInsertBatchSize).Error; err != nil {
+		config.LogCtx(db.Statement.Context).Error("Error creating balance changes.", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,318 @@
+package db
+
+import (
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Max rows per INSERT statement in batched writes, keeps statements below the Postgres bind parameter limit
+const bulkInsertBatchSize = 1000
+
+// BlockTxsDBWrapper holds a block and its transactions for batched indexing
+type BlockTxsDBWrapper struct {
+	Block     models.Block
+	Txs       []TxDBWrapper
+	FailedTxs []models.FailedTx
+}
+
+// IndexNewBlockBatch indexes the transactions of multiple blocks in a single DB transaction.
+// Addresses, denoms and the message lookup tables are upserted once for the whole batch, and txes, messages, message events and attributes
+// are bulk inserted across all blocks. Any error rolls back the whole batch, callers are expected to fall back to IndexNewBlock per block.
+// A block passed more than once is only written once, from its last occurrence, and every occurrence gets the indexed copy back.
+// The passed in batch is not modified so it can be reused for the fallback, the indexed copies are returned in the order passed in.
+func IndexNewBlockBatch(db *gorm.DB, batch []BlockTxsDBWrapper, indexerConfig config.IndexConfig) ([]BlockTxsDBWrapper, error) {
+	keys := make([]blockKey, len(batch))
+	for index, item := range batch {
+		keys[index] = blockKey{ChainID: item.Block.ChainID, Height: item.Block.Height}
+	}
+
+	kept, positions := latestBlockOccurrences(keys)
+	unique := make([]BlockTxsDBWrapper, len(kept))
+	for index, batchIndex := range kept {
+		unique[index] = batch[batchIndex]
+	}
+
+	unique = cloneBlockTxsBatch(unique)
+	err := db.Transaction(func(dbTransaction *gorm.DB) error {
+		return indexBlockTxs(dbTransaction, unique, indexerConfig)
+	})
+
+	// Contract: ensure that blocks and txs have been loaded with the indexed data before returning
+	indexed := make([]BlockTxsDBWrapper, len(batch))
+	for index, position := range positions {
+		indexed[index] = unique[position]
+	}
+	return indexed, err
+}
+
+// IndexBlockEventsBatch indexes the block events of multiple blocks in a single DB transaction.
+// Proposer addresses, event types and attribute keys are upserted once for the whole batch, and events and attributes are bulk inserted across all blocks.
+// Any error rolls back the whole batch, callers are expected to fall back to IndexBlockEvents per block.
+// A block passed more than once is only written once, from its last occurrence, and every occurrence gets the indexed copy back.
+// The passed in batch is not modified so it can be reused for the fallback, the indexed copies are returned in the order passed in.
+func IndexBlockEventsBatch(db *gorm.DB, batch []*BlockDBWrapper) ([]*BlockDBWrapper, error) {
+	keys := make([]blockKey, len(batch))
+	for index, blockDBWrapper := range batch {
+		keys[index] = blockKey{ChainID: blockDBWrapper.Block.ChainID, Height: blockDBWrapper.Block.Height}
+	}
+
+	kept, positions := latestBlockOccurrences(keys)
+	unique := make([]*BlockDBWrapper, len(kept))
+	for index, batchIndex := range kept {
+		unique[index] = batch[batchIndex]
+	}
+
+	unique = cloneBlockEventsBatch(unique)
+	err := db.Transaction(func(dbTransaction *gorm.DB) error {
+		return indexBlockEvents(dbTransaction, unique)
+	})
+
+	// Contract: ensure that wrappers have been loaded with all data before returning
+	indexed := make([]*BlockDBWrapper, len(batch))
+	for index, position := range positions {
+		indexed[index] = unique[position]
+	}
+	return indexed, err
+}
+
+// blockKey identifies a block by its chain and height
+type blockKey struct {
+	ChainID uint
+	Height  int64
+}
+
+// latestBlockOccurrences deduplicates the blocks of a batch, since a height can only be upserted once per statement.
+// It returns the indexes of the last occurrence of each block in their original order, and for every passed in block the position of its kept occurrence.
+func latestBlockOccurrences(keys []blockKey) (kept []int, positions []int) {
+	last := make(map[blockKey]int, len(keys))
+	for index, key := range keys {
+		last[key] = index
+	}
+
+	keptPositions := make(map[blockKey]int, len(last))
+	for index, key := range keys {
+		if last[key] == index {
+			keptPositions[key] = len(kept)
+			kept = append(kept, index)
+		}
+	}
+
+	positions = make([]int, len(keys))
+	for index, key := range keys {
+		positions[index] = keptPositions[key]
+	}
+
+	return kept, positions
+}
+
+// deleteFailedBlocks removes the blocks from the given failed blocks table, they are being indexed again
+func deleteFailedBlocks(db *gorm.DB, table string, blocks []*models.Block) error {
+	heightsByChain := make(map[uint][]int64)
+	for _, block := range blocks {
+		heightsByChain[block.ChainID] = append(heightsByChain[block.ChainID], block.Height)
+	}
+
+	for chainID, heights := range heightsByChain {
+		if err := db.Exec("DELETE FROM "+table+" WHERE height IN ? AND blockchain_id = ?", heights, chainID).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error updating failed blocks.", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upsertBlocks creates the blocks if they don't exist, loading their IDs back into the blocks.
+// Existing blocks get the block header and the passed in columns updated.
+func upsertBlocks(db *gorm.DB, blocks []*models.Block, updateColumns ...string) error {
+	if err := db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "height"}, {Name: "chain_id"}},
+		DoUpdates: clause.AssignmentColumns(append(updateColumns, blockHeaderColumns...)),
+	}).CreateInBatches(blocks, bulkInsertBatchSize).Error; err != nil {
+		config.LogCtx(db.Statement.Context).Error("Error getting/creating block DB objects.", err)
+		return err
+	}
+
+	return nil
+}
+
+// addAddress adds an address seen at the given height to the map, keeping the lowest and highest heights and any known public key
+func addAddress(uniqueAddress map[string]models.Address, address models.Address, height int64) {
+	merged, ok := uniqueAddress[address.Address]
+	if !ok {
+		merged = models.Address{Address: address.Address, FirstSeenHeight: height, LastSeenHeight: height}
+	}
+
+	if height < merged.FirstSeenHeight {
+		merged.FirstSeenHeight = height
+	}
+	if height > merged.LastSeenHeight {
+		merged.LastSeenHeight = height
+	}
+	if address.PubKey != "" {
+		merged.PubKeyType = address.PubKeyType
+		merged.PubKey = address.PubKey
+	}
+
+	uniqueAddress[address.Address] = merged
+}
+
+// addressConflictUpdates merges the metadata of an existing address with a newly seen one.
+// Heights only ever widen, reindexing an old block lowers the first seen height, and a known public key is never cleared.
+var addressConflictUpdates = clause.Set{
+	{Column: clause.Column{Name: "prefix"}, Value: gorm.Expr("EXCLUDED.prefix")},
+	{Column: clause.Column{Name: "kind"}, Value: gorm.Expr("EXCLUDED.kind")},
+	{Column: clause.Column{Name: "pub_key_type"}, Value: gorm.Expr("COALESCE(NULLIF(EXCLUDED.pub_key_type, ''), addresses.pub_key_type)")},
+	{Column: clause.Column{Name: "pub_key"}, Value: gorm.Expr("COALESCE(NULLIF(EXCLUDED.pub_key, ''), addresses.pub_key)")},
+	{Column: clause.Column{Name: "first_seen_height"}, Value: gorm.Expr("COALESCE(LEAST(NULLIF(addresses.first_seen_height, 0), NULLIF(EXCLUDED.first_seen_height, 0)), 0)")},
+	{Column: clause.Column{Name: "last_seen_height"}, Value: gorm.Expr("GREATEST(addresses.last_seen_height, EXCLUDED.last_seen_height)")},
+}
+
+// upsertAddresses creates the addresses if they don't exist and merges their metadata into existing ones, loading the stored addresses back into the map
+func upsertAddresses(db *gorm.DB, uniqueAddress map[string]models.Address) error {
+	if len(uniqueAddress) == 0 {
+		return nil
+	}
+
+	var addressesSlice []models.Address
+	for _, address := range uniqueAddress {
+		addressesSlice = append(addressesSlice, address)
+	}
+
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "address"}},
+		DoUpdates: addressConflictUpdates,
+	}, clause.Returning{}).CreateInBatches(addressesSlice, bulkInsertBatchSize).Error; err != nil {
+		config.LogCtx(db.Statement.Context).Error("Error getting/creating addresses.", err)
+		return err
+	}
+
+	for _, address := range addressesSlice {
+		uniqueAddress[address.Address] = address
+	}
+
+	return nil
+}
+
+// upsertDenoms creates the denoms if they don't exist, loading the denom IDs back into the map
+func upsertDenoms(db *gorm.DB, denomMap map[string]models.Denom) error {
+	if len(denomMap) == 0 {
+		return nil
+	}
+
+	var denomsSlice []models.Denom
+	for _, denom := range denomMap {
+		denomsSlice = append(denomsSlice, denom)
+	}
+
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base"}},
+		DoUpdates: clause.AssignmentColumns([]string{"base"}),
+	}).CreateInBatches(denomsSlice, bulkInsertBatchSize).Error; err != nil {
+		config.LogCtx(db.Statement.Context).Error("Error getting/creating denoms.", err)
+		return err
+	}
+
+	for _, denom := range denomsSlice {
+		denomMap[denom.Base] = denom
+	}
+
+	return nil
+}
+
+// loadBlockChains attaches the chain to each block, matching the preloaded chain returned by IndexNewBlock
+func loadBlockChains(db *gorm.DB, blocks []*models.Block) error {
+	chainIDs := make(map[uint]bool)
+	for _, block := range blocks {
+		chainIDs[block.ChainID] = true
+	}
+
+	var ids []uint
+	for id := range chainIDs {
+		ids = append(ids, id)
+	}
+
+	var chains []models.Chain
+	if err := db.Where("id IN ?", ids).Find(&chains).Error; err != nil {
+		config.LogCtx(db.Statement.Context).Error("Error getting chain DB objects.", err)
+		return err
+	}
+
+	chainsByID := make(map[uint]models.Chain)
+	for _, chain := range chains {
+		chainsByID[chain.ID] = chain
+	}
+
+	for _, block := range blocks {
+		block.Chain = chainsByID[block.ChainID]
+	}
+
+	return nil
+}
+
+// cloneBlockTxsBatch copies every slice the batched write assigns IDs into, so IDs from a rolled back batch do not leak into the fallback writes
+func cloneBlockTxsBatch(batch []BlockTxsDBWrapper) []BlockTxsDBWrapper {
+	cloned := make([]BlockTxsDBWrapper, len(batch))
+	for blockIndex, item := range batch {
+		cloned[blockIndex].Block = item.Block
+		cloned[blockIndex].FailedTxs = item.FailedTxs
+		cloned[blockIndex].Txs = make([]TxDBWrapper, len(item.Txs))
+		for txIndex, tx := range item.Txs {
+			tx.Tx.SignerAddresses = append([]models.Address(nil), tx.Tx.SignerAddresses...)
+			tx.Tx.Fees = append([]models.Fee(nil), tx.Tx.Fees...)
+			tx.Tx.SignerInfos = append([]models.TxSignerInfo(nil), tx.Tx.SignerInfos...)
+
+			messages := make([]MessageDBWrapper, len(tx.Messages))
+			for messageIndex, message := range tx.Messages {
+				messageEvents := make([]MessageEventDBWrapper, len(message.MessageEvents))
+				for eventIndex, messageEvent := range message.MessageEvents {
+					messageEvent.Attributes = append([]models.MessageEventAttribute(nil), messageEvent.Attributes...)
+					messageEvents[eventIndex] = messageEvent
+				}
+				message.MessageEvents = messageEvents
+				messages[messageIndex] = message
+			}
+			tx.Messages = messages
+
+			cloned[blockIndex].Txs[txIndex] = tx
+		}
+	}
+	return cloned
+}
+
+// cloneBlockEventsBatch copies every block, slice and map the batched write assigns IDs into, so IDs from a rolled back batch do not leak into the fallback writes
+func cloneBlockEventsBatch(batch []*BlockDBWrapper) []*BlockDBWrapper {
+	cloned := make([]*BlockDBWrapper, len(batch))
+	for index, blockDBWrapper := range batch {
+		block := *blockDBWrapper.Block
+		clone := &BlockDBWrapper{
+			Block:                         &block,
+			UniqueBlockEventTypes:         make(map[string]models.BlockEventType, len(blockDBWrapper.UniqueBlockEventTypes)),
+			UniqueBlockEventAttributeKeys: make(map[string]models.BlockEventAttributeKey, len(blockDBWrapper.UniqueBlockEventAttributeKeys)),
+			BeginBlockEvents:              cloneBlockEvents(blockDBWrapper.BeginBlockEvents),
+			EndBlockEvents:                cloneBlockEvents(blockDBWrapper.EndBlockEvents),
+			ValidatorUpdates:              blockDBWrapper.ValidatorUpdates,
+			ConsensusParamUpdate:          blockDBWrapper.ConsensusParamUpdate,
+			IndexBalanceChanges:           blockDBWrapper.IndexBalanceChanges,
+		}
+		for key, value := range blockDBWrapper.UniqueBlockEventTypes {
+			clone.UniqueBlockEventTypes[key] = value
+		}
+		for key, value := range blockDBWrapper.UniqueBlockEventAttributeKeys {
+			clone.UniqueBlockEventAttributeKeys[key] = value
+		}
+		cloned[index] = clone
+	}
+	return cloned
+}
+
+func cloneBlockEvents(events []BlockEventDBWrapper) []BlockEventDBWrapper {
+	cloned := make([]BlockEventDBWrapper, len(events))
+	for index, event := range events {
+		event.Attributes = append([]models.BlockEventAttribute(nil), event.Attributes...)
+		cloned[index] = event
+	}
+	return cloned
+}
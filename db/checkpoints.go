@@ -0,0 +1,231 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CheckpointDataset identifies which indexed dataset a checkpoint range tracks
+type CheckpointDataset int
+
+const (
+	CheckpointTransactions CheckpointDataset = iota
+	CheckpointBlockEvents
+)
+
+// Number of heights read per query when extending a checkpoint over blocks that are already indexed
+const checkpointScanSize = 1000
+
+// checkpointColumns returns the blocks table indexed flag and the failed blocks table used for the dataset
+func (dataset CheckpointDataset) checkpointColumns() (indexedColumn string, failedTable string) {
+	if dataset == CheckpointBlockEvents {
+		return "block_events_indexed", "failed_event_blocks"
+	}
+	return "tx_indexed", "failed_blocks"
+}
+
+func (dataset CheckpointDataset) String() string {
+	if dataset == CheckpointBlockEvents {
+		return "block events"
+	}
+	return "transactions"
+}
+
+// rangeColumns returns the checkpoints table start and end height columns of the dataset
+func (dataset CheckpointDataset) rangeColumns() (startColumn string, endColumn string) {
+	if dataset == CheckpointBlockEvents {
+		return "block_events_indexed_start_height", "block_events_indexed_height"
+	}
+	return "tx_indexed_start_height", "tx_indexed_height"
+}
+
+// checkpointRange returns pointers to the start and end heights of the checkpoint range for the dataset
+func checkpointRange(checkpoint *models.Checkpoint, dataset CheckpointDataset) (*int64, *int64) {
+	if dataset == CheckpointBlockEvents {
+		return &checkpoint.BlockEventsIndexedStartHeight, &checkpoint.BlockEventsIndexedHeight
+	}
+	return &checkpoint.TxIndexedStartHeight, &checkpoint.TxIndexedHeight
+}
+
+// InitCheckpoint returns the checkpoint for the chain, creating it from the blocks already indexed if it does not exist yet.
+// Creating the checkpoint scans the indexed blocks once, after that the checkpoint is kept up to date by AdvanceCheckpoint.
+func InitCheckpoint(db *gorm.DB, chainID uint) (models.Checkpoint, error) {
+	var checkpoint models.Checkpoint
+	err := db.Where("chain_id = ?", chainID).Limit(1).Find(&checkpoint).Error
+	if err != nil || checkpoint.ID != 0 {
+		return checkpoint, err
+	}
+
+	checkpoint.ChainID = chainID
+	for _, dataset := range []CheckpointDataset{CheckpointTransactions, CheckpointBlockEvents} {
+		start, end, err := firstIndexedRange(db, chainID, dataset)
+		if err != nil {
+			return checkpoint, err
+		}
+		startHeight, endHeight := checkpointRange(&checkpoint, dataset)
+		*startHeight, *endHeight = start, end
+	}
+
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain_id"}},
+		DoNothing: true,
+	}).Create(&checkpoint).Error
+	if err != nil {
+		return checkpoint, err
+	}
+
+	// Another process may have created the checkpoint first, always return the stored one
+	checkpoint = models.Checkpoint{}
+	err = db.Where("chain_id = ?", chainID).First(&checkpoint).Error
+	return checkpoint, err
+}
+
+// firstIndexedRange finds the contiguous range of indexed heights starting at the lowest indexed height for the dataset
+func firstIndexedRange(db *gorm.DB, chainID uint, dataset CheckpointDataset) (int64, int64, error) {
+	indexedColumn, failedTable := dataset.checkpointColumns()
+
+	var result struct {
+		StartHeight int64
+		EndHeight   int64
+	}
+
+	// Heights in the same contiguous run share the same height - row number value, the first run has the lowest one
+	query := fmt.Sprintf(`SELECT COALESCE(MIN(height), 0) AS start_height, COALESCE(MAX(height), 0) AS end_height FROM (
+		SELECT height, height - ROW_NUMBER() OVER (ORDER BY height) AS run, MIN(height - 1) OVER () AS first_run
+		FROM blocks
+		WHERE chain_id = ? AND %s = true
+		AND NOT EXISTS (SELECT 1 FROM %s f WHERE f.blockchain_id = blocks.chain_id AND f.height = blocks.height)
+	) runs WHERE run = first_run`, indexedColumn, failedTable)
+
+	err := db.Raw(query, chainID).Scan(&result).Error
+	return result.StartHeight, result.EndHeight, err
+}
+
+// checkpointUpdate holds the stored range of a dataset after a checkpoint update, and whether the heights next to the committed height are indexed
+type checkpointUpdate struct {
+	StartHeight int64
+	EndHeight   int64
+	NextIndexed bool
+	PrevIndexed bool
+}
+
+// AdvanceCheckpoint extends the checkpoint range for the dataset after the block at height has been committed.
+// The range is extended by a single conditional update against the stored range, so concurrent indexers never overwrite each other's progress
+// with a stale range. Heights that are not adjacent to the stored range are skipped, they are picked up once the gap before them is filled:
+// indexed blocks adjacent to the committed height are only scanned for when the update reports the next or previous height is already indexed.
+// The passed in checkpoint is updated with the stored range.
+func AdvanceCheckpoint(db *gorm.DB, checkpoint *models.Checkpoint, dataset CheckpointDataset, height int64) error {
+	startColumn, endColumn := dataset.rangeColumns()
+	indexedColumn, failedTable := dataset.checkpointColumns()
+	isIndexed := fmt.Sprintf(`EXISTS (SELECT 1 FROM blocks b WHERE b.chain_id = @chain AND b.height = %%s AND b.%s = true
+		AND NOT EXISTS (SELECT 1 FROM %s f WHERE f.blockchain_id = b.chain_id AND f.height = b.height))`, indexedColumn, failedTable)
+
+	query := fmt.Sprintf(`UPDATE checkpoints SET
+		%[1]s = CASE WHEN %[2]s = 0 THEN @height ELSE LEAST(%[1]s, @height) END,
+		%[2]s = GREATEST(%[2]s, @height),
+		updated_at = @now
+		WHERE chain_id = @chain AND (%[2]s = 0 OR @height = %[1]s - 1 OR @height = %[2]s + 1)
+		RETURNING %[1]s AS start_height, %[2]s AS end_height, %[3]s AS next_indexed, %[4]s AS prev_indexed`,
+		startColumn, endColumn, fmt.Sprintf(isIndexed, "@next"), fmt.Sprintf(isIndexed, "@prev"))
+
+	var updates []checkpointUpdate
+	if err := db.Raw(query, map[string]interface{}{"chain": checkpoint.ChainID, "height": height, "next": height + 1, "prev": height - 1, "now": time.Now()}).Scan(&updates).Error; err != nil {
+		return err
+	}
+
+	// Inside the range or not adjacent to it
+	if len(updates) == 0 {
+		return nil
+	}
+
+	update := updates[0]
+	if update.EndHeight == height && update.NextIndexed {
+		end, err := scanIndexedHeights(db, checkpoint.ChainID, dataset, height, 1)
+		if err != nil {
+			return err
+		}
+		if update, err = extendCheckpoint(db, checkpoint.ChainID, dataset, height, "%[2]s = GREATEST(%[2]s, @to)", end); err != nil {
+			return err
+		}
+	}
+	if update.StartHeight == height && update.PrevIndexed {
+		start, err := scanIndexedHeights(db, checkpoint.ChainID, dataset, height, -1)
+		if err != nil {
+			return err
+		}
+		if update, err = extendCheckpoint(db, checkpoint.ChainID, dataset, height, "%[1]s = LEAST(%[1]s, @to)", start); err != nil {
+			return err
+		}
+	}
+
+	startHeight, endHeight := checkpointRange(checkpoint, dataset)
+	*startHeight, *endHeight = update.StartHeight, update.EndHeight
+	return nil
+}
+
+// extendCheckpoint applies the assignment to the stored range of the dataset with the scanned height as @to, as long as the range still covers height.
+// The range may have been shrunk by a failure recorded since the committed height was added to it.
+func extendCheckpoint(db *gorm.DB, chainID uint, dataset CheckpointDataset, height int64, assignment string, to int64) (checkpointUpdate, error) {
+	startColumn, endColumn := dataset.rangeColumns()
+	query := fmt.Sprintf(`UPDATE checkpoints SET `+assignment+`, updated_at = @now
+		WHERE chain_id = @chain AND %[2]s <> 0 AND @height BETWEEN %[1]s AND %[2]s
+		RETURNING %[1]s AS start_height, %[2]s AS end_height`, startColumn, endColumn)
+
+	var updates []checkpointUpdate
+	err := db.Raw(query, map[string]interface{}{"chain": chainID, "height": height, "to": to, "now": time.Now()}).Scan(&updates).Error
+	if err != nil || len(updates) == 0 {
+		return checkpointUpdate{}, err
+	}
+	return updates[0], nil
+}
+
+// shrinkCheckpoint removes a height that failed to index from the checkpoint range for the dataset of the chain.
+// The range is cut at the failed height, keeping the part below it unless the failed height is the start of the range.
+func shrinkCheckpoint(db *gorm.DB, chainID uint, dataset CheckpointDataset, height int64) error {
+	startColumn, endColumn := dataset.rangeColumns()
+	query := fmt.Sprintf(`UPDATE checkpoints SET
+		%[1]s = CASE WHEN @height > %[1]s THEN %[1]s WHEN @height < %[2]s THEN @next ELSE 0 END,
+		%[2]s = CASE WHEN @height > %[1]s THEN @prev WHEN @height < %[2]s THEN %[2]s ELSE 0 END,
+		updated_at = @now
+		WHERE chain_id = @chain AND %[2]s <> 0 AND @height BETWEEN %[1]s AND %[2]s`, startColumn, endColumn)
+
+	return db.Exec(query, map[string]interface{}{"chain": chainID, "height": height, "next": height + 1, "prev": height - 1, "now": time.Now()}).Error
+}
+
+// scanIndexedHeights walks from height in the given direction over contiguous indexed heights, returning the last one reached
+func scanIndexedHeights(db *gorm.DB, chainID uint, dataset CheckpointDataset, height int64, direction int64) (int64, error) {
+	indexedColumn, failedTable := dataset.checkpointColumns()
+
+	comparison, order := ">", "height asc"
+	if direction < 0 {
+		comparison, order = "<", "height desc"
+	}
+
+	for {
+		var heights []int64
+		err := db.Model(&models.Block{}).
+			Where(fmt.Sprintf("chain_id = ? AND %s = true AND height %s ?", indexedColumn, comparison), chainID, height).
+			Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s f WHERE f.blockchain_id = blocks.chain_id AND f.height = blocks.height)", failedTable)).
+			Order(order).
+			Limit(checkpointScanSize).
+			Pluck("height", &heights).Error
+		if err != nil {
+			return height, err
+		}
+
+		for _, next := range heights {
+			if next != height+direction {
+				return height, nil
+			}
+			height = next
+		}
+
+		if len(heights) < checkpointScanSize {
+			return height, nil
+		}
+	}
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/DefiantLabs/cosmos-indexer/config"
 	"github.com/DefiantLabs/cosmos-indexer/db/models"
@@ -52,12 +53,17 @@ func MigrateModels(db *gorm.DB) error {
 func migrateChainModels(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&models.Chain{},
+		&models.Checkpoint{},
+		&models.BlockRangeLease{},
 	)
 }
 
 func migrateBlockModels(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&models.Block{},
+		&models.BlockSignature{},
+		&models.ValidatorUpdate{},
+		&models.ConsensusParamUpdate{},
 		&models.BlockEvent{},
 		&models.BlockEventType{},
 		&models.BlockEventAttribute{},
@@ -77,6 +83,7 @@ func migrateTXModels(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&models.Tx{},
 		&models.Fee{},
+		&models.TxSignerInfo{},
 		&models.Address{},
 		&models.MessageType{},
 		&models.Message{},
@@ -86,6 +93,9 @@ func migrateTXModels(db *gorm.DB) error {
 		&models.MessageEventType{},
 		&models.MessageEventAttribute{},
 		&models.MessageEventAttributeKey{},
+		&models.TxEvent{},
+		&models.TxEventAttribute{},
+		&models.BalanceChange{},
 	)
 }
 
@@ -104,7 +114,7 @@ func MigrateInterfaces(db *gorm.DB, interfaces []any) error {
 
 func GetDBChainID(db *gorm.DB, chain models.Chain) (uint, error) {
 	if err := db.Where("chain_id = ?", chain.ChainID).FirstOrCreate(&chain).Error; err != nil {
-		config.Log.Error("Error getting/creating chain DB object.", err)
+		config.LogCtx(db.Statement.Context).Error("Error getting/creating chain DB object.", err)
 		return chain.ID, err
 	}
 	return chain.ID, nil
@@ -145,280 +155,444 @@ func GetHighestEventIndexedBlock(db *gorm.DB, chainID uint) (models.Block, error
 	return block, err
 }
 
-func UpsertFailedBlock(db *gorm.DB, blockHeight int64, chainID string, chainName string) error {
+// Stages of the indexing pipeline a block can fail in
+const (
+	FailureStageRPC     = "rpc"
+	FailureStageDecode  = "decode"
+	FailureStageFilter  = "filter"
+	FailureStageDBWrite = "db_write"
+)
+
+// BlockFailure describes why a block failed, Code is the BlockProcessingFailure reported by the pipeline
+type BlockFailure struct {
+	Code  string
+	Stage string
+	Err   error
+}
+
+// model returns the failure columns for a failure first seen at the given time
+func (failure BlockFailure) model(failedAt time.Time) models.BlockFailure {
+	errorMessage := ""
+	if failure.Err != nil {
+		errorMessage = failure.Err.Error()
+	}
+
+	return models.BlockFailure{
+		Code:          failure.Code,
+		Stage:         failure.Stage,
+		ErrorMessage:  errorMessage,
+		FirstFailedAt: failedAt,
+		LastFailedAt:  failedAt,
+		Attempts:      1,
+	}
+}
+
+// failureConflictClause updates the latest failure and counts the attempt when the height has failed before.
+// Rows recorded before failures were tracked have no first failure time or attempts yet.
+// A background retry claim on the height is released, the height is due again once its backoff has passed.
+func failureConflictClause(table string, blockFailure models.BlockFailure) clause.OnConflict {
+	return clause.OnConflict{
+		Columns: []clause.Column{{Name: "height"}, {Name: "blockchain_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"code":                blockFailure.Code,
+			"stage":               blockFailure.Stage,
+			"error_message":       blockFailure.ErrorMessage,
+			"last_failed_at":      blockFailure.LastFailedAt,
+			"first_failed_at":     gorm.Expr(fmt.Sprintf("COALESCE(%s.first_failed_at, ?)", table), blockFailure.FirstFailedAt),
+			"attempts":            gorm.Expr(fmt.Sprintf("COALESCE(%s.attempts, 0) + 1", table)),
+			"retry_claimed_until": nil,
+		}),
+	}
+}
+
+// UpsertFailedBlock records a block whose transactions failed to index, incrementing the attempt count if it has failed before.
+// The height is removed from the transactions checkpoint range.
+func UpsertFailedBlock(db *gorm.DB, blockHeight int64, chainID string, chainName string, failure BlockFailure) error {
 	return db.Transaction(func(dbTransaction *gorm.DB) error {
-		failedBlock := models.FailedBlock{Height: blockHeight, Chain: models.Chain{ChainID: chainID, Name: chainName}}
+		failedBlock := models.FailedBlock{Height: blockHeight, Chain: models.Chain{ChainID: chainID, Name: chainName}, BlockFailure: failure.model(time.Now())}
 
 		if err := dbTransaction.Where(&failedBlock.Chain).FirstOrCreate(&failedBlock.Chain).Error; err != nil {
-			config.Log.Error("Error creating chain DB object.", err)
+			config.LogCtx(dbTransaction.Statement.Context).Error("Error creating chain DB object.", err)
 			return err
 		}
 
-		if err := dbTransaction.Where(&failedBlock).FirstOrCreate(&failedBlock).Error; err != nil {
-			config.Log.Error("Error creating failed block DB object.", err)
+		failedBlock.BlockchainID = failedBlock.Chain.ID
+		if err := dbTransaction.Clauses(failureConflictClause("failed_blocks", failedBlock.BlockFailure)).Omit(clause.Associations).Create(&failedBlock).Error; err != nil {
+			config.LogCtx(dbTransaction.Statement.Context).Error("Error creating failed block DB object.", err)
 			return err
 		}
-		return nil
+
+		// A failed height is no longer part of the contiguous indexed range
+		return shrinkCheckpoint(dbTransaction, failedBlock.BlockchainID, CheckpointTransactions, blockHeight)
 	})
 }
 
-func UpsertFailedEventBlock(db *gorm.DB, blockHeight int64, chainID string, chainName string) error {
+// UpsertFailedEventBlock records a block whose block events failed to index, incrementing the attempt count if it has failed before.
+// The height is removed from the block events checkpoint range.
+func UpsertFailedEventBlock(db *gorm.DB, blockHeight int64, chainID string, chainName string, failure BlockFailure) error {
 	return db.Transaction(func(dbTransaction *gorm.DB) error {
-		failedEventBlock := models.FailedEventBlock{Height: blockHeight, Chain: models.Chain{ChainID: chainID, Name: chainName}}
+		failedEventBlock := models.FailedEventBlock{Height: blockHeight, Chain: models.Chain{ChainID: chainID, Name: chainName}, BlockFailure: failure.model(time.Now())}
 
 		if err := dbTransaction.Where(&failedEventBlock.Chain).FirstOrCreate(&failedEventBlock.Chain).Error; err != nil {
-			config.Log.Error("Error creating chain DB object.", err)
+			config.LogCtx(dbTransaction.Statement.Context).Error("Error creating chain DB object.", err)
 			return err
 		}
 
-		if err := dbTransaction.Where(&failedEventBlock).FirstOrCreate(&failedEventBlock).Error; err != nil {
-			config.Log.Error("Error creating failed event block DB object.", err)
+		failedEventBlock.BlockchainID = failedEventBlock.Chain.ID
+		if err := dbTransaction.Clauses(failureConflictClause("failed_event_blocks", failedEventBlock.BlockFailure)).Omit(clause.Associations).Create(&failedEventBlock).Error; err != nil {
+			config.LogCtx(dbTransaction.Statement.Context).Error("Error creating failed event block DB object.", err)
 			return err
 		}
-		return nil
+
+		// A failed height is no longer part of the contiguous indexed range
+		return shrinkCheckpoint(dbTransaction, failedEventBlock.BlockchainID, CheckpointBlockEvents, blockHeight)
 	})
 }
 
-func IndexNewBlock(db *gorm.DB, block models.Block, txs []TxDBWrapper, indexerConfig config.IndexConfig) (models.Block, []TxDBWrapper, error) {
-	// consider optimizing the transaction, but how? Ordering matters due to foreign key constraints
-	// Order required: Block -> (For each Tx: Signer Address -> Tx -> (For each Message: Message -> Taxable Events))
-	// Also, foreign key relations are struct value based so create needs to be called first to get right foreign key ID
-	err := db.Transaction(func(dbTransaction *gorm.DB) error {
-		// remove from failed blocks if exists
-		if err := dbTransaction.
-			Exec("DELETE FROM failed_blocks WHERE height = ? AND blockchain_id = ?", block.Height, block.ChainID).
-			Error; err != nil {
-			config.Log.Error("Error updating failed block.", err)
-			return err
-		}
+// txConflictUpdateColumns are the tx columns updated when a tx is reindexed
+var txConflictUpdateColumns = []string{"code", "codespace", "raw_log", "gas_wanted", "gas_used", "timeout_height", "tx_index", "block_id", "fee_payer_address_id", "fee_granter_address_id"}
 
-		consAddress, err := FindOrCreateAddressByAddress(dbTransaction, block.ProposerConsAddress.Address)
-		// create cons address if it doesn't exist
-		if err != nil {
-			config.Log.Error("Error getting/creating cons address DB object.", err)
-			return err
+// txMetadataAddresses returns the fee payer, fee granter and signer info addresses of a tx
+func txMetadataAddresses(tx models.Tx) []models.Address {
+	var addresses []models.Address
+	if tx.FeePayerAddress != nil {
+		addresses = append(addresses, *tx.FeePayerAddress)
+	}
+	if tx.FeeGranterAddress != nil {
+		addresses = append(addresses, *tx.FeeGranterAddress)
+	}
+	for _, signerInfo := range tx.SignerInfos {
+		if signerInfo.Address != nil {
+			addresses = append(addresses, *signerInfo.Address)
 		}
+	}
+	return addresses
+}
 
-		// create block if it doesn't exist
-		block.ProposerConsAddressID = consAddress.ID
-		block.ProposerConsAddress = consAddress
-		block.TxIndexed = true
-		if err := dbTransaction.
-			Preload("Chain").
-			Where(models.Block{Height: block.Height, ChainID: block.ChainID}).
-			Assign(models.Block{TxIndexed: true, TimeStamp: block.TimeStamp}).
-			FirstOrCreate(&block).Error; err != nil {
-			config.Log.Error("Error getting/creating block DB object.", err)
-			return err
+// assignTxMetadataAddresses points the fee payer, fee granter and signer info addresses of a tx at the created addresses
+func assignTxMetadataAddresses(tx *models.Tx, uniqueAddress map[string]models.Address) {
+	if tx.FeePayerAddress != nil {
+		address := uniqueAddress[tx.FeePayerAddress.Address]
+		tx.FeePayerAddress = &address
+		tx.FeePayerAddressID = &address.ID
+	}
+	if tx.FeeGranterAddress != nil {
+		address := uniqueAddress[tx.FeeGranterAddress.Address]
+		tx.FeeGranterAddress = &address
+		tx.FeeGranterAddressID = &address.ID
+	}
+	for index := range tx.SignerInfos {
+		if tx.SignerInfos[index].Address != nil {
+			address := uniqueAddress[tx.SignerInfos[index].Address.Address]
+			tx.SignerInfos[index].Address = &address
+			tx.SignerInfos[index].AddressID = &address.ID
 		}
+	}
+}
 
-		// pull txes and insert them
-		uniqueTxes := make(map[string]models.Tx)
-		uniqueAddress := make(map[string]models.Address)
+// IndexNewBlock indexes the transactions of a block. Transactions that could not be processed are recorded in the failed txes table.
+// It is a batch of one block, sharing every table writer with IndexNewBlockBatch.
+func IndexNewBlock(db *gorm.DB, block models.Block, txs []TxDBWrapper, failedTxs []models.FailedTx, indexerConfig config.IndexConfig) (models.Block, []TxDBWrapper, error) {
+	indexed, err := IndexNewBlockBatch(db, []BlockTxsDBWrapper{{Block: block, Txs: txs, FailedTxs: failedTxs}}, indexerConfig)
 
-		denomMap := make(map[string]models.Denom)
+	// Contract: ensure that block and txs have been loaded with the indexed data before returning
+	return indexed[0].Block, indexed[0].Txs, err
+}
 
-		for _, tx := range txs {
-			if !indexerConfig.Flags.IndexEmptyTransactions && len(tx.Messages) == 0 {
+// indexBlockTxs writes the blocks and their transactions. Ordering matters due to foreign key constraints:
+// Addresses and denoms -> Blocks -> Txes -> Messages -> Message events and tx events -> Balance changes.
+// Foreign key relations are struct value based so create needs to be called first to get the right foreign key IDs.
+func indexBlockTxs(db *gorm.DB, batch []BlockTxsDBWrapper, indexerConfig config.IndexConfig) error {
+	blocks := make([]*models.Block, len(batch))
+	for index := range batch {
+		blocks[index] = &batch[index].Block
+	}
+
+	// remove from failed blocks if exists
+	if err := deleteFailedBlocks(db, "failed_blocks", blocks); err != nil {
+		return err
+	}
+
+	// Gather every address and denom referenced by the blocks so they can be created in bulk
+	uniqueAddress := make(map[string]models.Address)
+	denomMap := make(map[string]models.Denom)
+	for _, item := range batch {
+		height := item.Block.Height
+		addAddress(uniqueAddress, item.Block.ProposerConsAddress, height)
+		for _, tx := range item.Txs {
+			if !indexerConfig.Flags.IndexEmptyTransactions && tx.IsEmpty() {
 				continue
 			}
-
-			tx.Tx.BlockID = block.ID
-			tx.Tx.Block = block
-			uniqueTxes[tx.Tx.Hash] = tx.Tx
-			if len(tx.Tx.SignerAddresses) != 0 {
-				for _, signerAddress := range tx.Tx.SignerAddresses {
-					uniqueAddress[signerAddress.Address] = signerAddress
-				}
+			for _, signerAddress := range tx.Tx.SignerAddresses {
+				addAddress(uniqueAddress, signerAddress, height)
 			}
-			for feeIndex, fee := range tx.Tx.Fees {
-				uniqueAddress[fee.PayerAddress.Address] = fee.PayerAddress
+			for _, fee := range tx.Tx.Fees {
+				addAddress(uniqueAddress, fee.PayerAddress, height)
+				denomMap[fee.Denomination.Base] = models.Denom{Base: fee.Denomination.Base}
+			}
+			for _, address := range txMetadataAddresses(tx.Tx) {
+				addAddress(uniqueAddress, address, height)
+			}
+		}
+	}
 
-				denom := fee.Denomination
+	if err := upsertAddresses(db, uniqueAddress); err != nil {
+		return err
+	}
 
-				if _, ok := denomMap[denom.Base]; !ok {
-					denom, err = FindOrCreateDenomByBase(dbTransaction, denom.Base)
-					if err != nil {
-						config.Log.Error("Error getting/creating denom DB object.", err)
-						return err
-					}
-					denomMap[denom.Base] = denom
-				} else {
-					denom = denomMap[denom.Base]
-				}
+	if err := upsertDenoms(db, denomMap); err != nil {
+		return err
+	}
 
-				tx.Tx.Fees[feeIndex].DenominationID = denom.ID
-				tx.Tx.Fees[feeIndex].Denomination = denom
-			}
-		}
+	for _, block := range blocks {
+		block.ProposerConsAddress = uniqueAddress[block.ProposerConsAddress.Address]
+		block.ProposerConsAddressID = block.ProposerConsAddress.ID
+		block.TxIndexed = true
+	}
 
-		var addressesSlice []models.Address
-		for _, address := range uniqueAddress {
-			addressesSlice = append(addressesSlice, address)
-		}
+	// The proposer of an existing block is kept, it is set by the block events
+	if err := upsertBlocks(db, blocks, "tx_indexed", "time_stamp"); err != nil {
+		return err
+	}
 
-		if len(addressesSlice) != 0 {
-			if err := dbTransaction.Clauses(clause.OnConflict{
-				Columns:   []clause.Column{{Name: "address"}},
-				DoUpdates: clause.AssignmentColumns([]string{"address"}),
-			}).Create(addressesSlice).Error; err != nil {
-				config.Log.Error("Error getting/creating addresses.", err)
-				return err
-			}
-		}
+	if err := indexBlockSignatures(db, blocks...); err != nil {
+		return err
+	}
 
-		for _, address := range addressesSlice {
-			uniqueAddress[address.Address] = address
+	if err := loadBlockChains(db, blocks); err != nil {
+		return err
+	}
+
+	var failedTxs []models.FailedTx
+	for index := range batch {
+		for _, failedTx := range batch[index].FailedTxs {
+			failedTx.BlockID = batch[index].Block.ID
+			failedTxs = append(failedTxs, failedTx)
 		}
+	}
 
-		var txesSlice []models.Tx
-		for _, tx := range uniqueTxes {
+	var txs []TxDBWrapper
+	for index := range batch {
+		txs = append(txs, batch[index].Txs...)
+	}
 
-			var signerAddressID uint
+	if err := indexFailedTxs(db, txs, failedTxs); err != nil {
+		return err
+	}
 
-			if len(tx.SignerAddresses) != 0 {
-				for addressIndex := range tx.SignerAddresses {
-					signerAddressID = uniqueAddress[tx.SignerAddresses[addressIndex].Address].ID
-					tx.SignerAddresses[addressIndex] = uniqueAddress[tx.SignerAddresses[addressIndex].Address]
-					tx.SignerAddresses[addressIndex].ID = signerAddressID
-				}
-			}
+	uniqueTxes, err := indexTxes(db, batch, uniqueAddress, denomMap, indexerConfig)
+	if err != nil {
+		return err
+	}
 
-			for feeIndex := range tx.Fees {
-				tx.Fees[feeIndex].PayerAddressID = uniqueAddress[tx.Fees[feeIndex].PayerAddress.Address].ID
-				tx.Fees[feeIndex].PayerAddress = uniqueAddress[tx.Fees[feeIndex].PayerAddress.Address]
-			}
-			txesSlice = append(txesSlice, tx)
+	// Load the created txes into the wrappers, skipped empty txes are left without an ID
+	txs = nil
+	for blockIndex := range batch {
+		for txIndex := range batch[blockIndex].Txs {
+			batch[blockIndex].Txs[txIndex].Tx = uniqueTxes[batch[blockIndex].Txs[txIndex].Tx.Hash]
 		}
+		txs = append(txs, batch[blockIndex].Txs...)
+	}
 
-		if len(txesSlice) != 0 {
-			if err := dbTransaction.Clauses(clause.OnConflict{
-				Columns:   []clause.Column{{Name: "hash"}},
-				DoUpdates: clause.AssignmentColumns([]string{"code", "block_id"}),
-			}).Create(txesSlice).Error; err != nil {
-				config.Log.Error("Error getting/creating txes.", err)
-				return err
-			}
-		}
+	// Create unique message types and post-process them into the messages
+	uniqueMessageTypes, err := indexMessageTypes(db, txs)
+	if err != nil {
+		return err
+	}
 
-		for _, tx := range txesSlice {
-			uniqueTxes[tx.Hash] = tx
+	// Tx events share the message event types and attribute keys
+	uniqueMessageEventTypes := make(map[string]models.MessageEventType)
+	uniqueMessageEventAttributeKeys := make(map[string]models.MessageEventAttributeKey)
+	if indexerConfig.Flags.IndexMessageEvents || indexerConfig.Flags.IndexTxEvents {
+		uniqueMessageEventTypes, err = indexMessageEventTypes(db, txs)
+		if err != nil {
+			return err
 		}
 
-		// Create unique message types and post-process them into the messages
-		fullUniqueBlockMessageTypes, err := indexMessageTypes(dbTransaction, txs)
+		uniqueMessageEventAttributeKeys, err = indexMessageEventAttributeKeys(db, txs)
 		if err != nil {
 			return err
 		}
+	}
 
-		fullUniqueBlockMessageEventTypes := make(map[string]models.MessageEventType)
-		if indexerConfig.Flags.IndexMessageEvents {
-			fullUniqueBlockMessageEventTypes, err = indexMessageEventTypes(dbTransaction, txs)
-			if err != nil {
-				return err
-			}
+	if err := indexMessages(db, txs, uniqueMessageTypes, uniqueMessageEventTypes, uniqueMessageEventAttributeKeys, indexerConfig); err != nil {
+		return err
+	}
+
+	if err := indexFailedMessages(db, txs); err != nil {
+		return err
+	}
+
+	var txEvents []models.TxEvent
+	if indexerConfig.Flags.IndexTxEvents {
+		txEvents, err = indexTxEvents(db, txs, uniqueMessageEventTypes, uniqueMessageEventAttributeKeys)
+		if err != nil {
+			return err
 		}
+	}
 
-		fullUniqueBlockMessageEventAttributeKeys := make(map[string]models.MessageEventAttributeKey)
-		if indexerConfig.Flags.IndexMessageEvents {
-			fullUniqueBlockMessageEventAttributeKeys, err = indexMessageEventAttributeKeys(dbTransaction, txs)
-			if err != nil {
-				return err
-			}
+	if indexerConfig.Flags.IndexMessageEvents {
+		if err := indexMessageEvents(db, txs); err != nil {
+			return err
 		}
+	}
 
-		// This complex set of loops is to ensure that foreign key relations are created and attached to downstream models before batch insertion is executed.
-		// We are trading off in-app performance for batch insertion here and should consider complexity increase vs performance increase.
-		for txIndex, tx := range txs {
-			tx.Tx = uniqueTxes[tx.Tx.Hash]
-			txs[txIndex].Tx = tx.Tx
-			var messagesSlice []*models.Message
-			for messageIndex := range tx.Messages {
-				tx.Messages[messageIndex].Message.TxID = tx.Tx.ID
-				tx.Messages[messageIndex].Message.Tx = tx.Tx
-				tx.Messages[messageIndex].Message.MessageTypeID = fullUniqueBlockMessageTypes[tx.Messages[messageIndex].Message.MessageType.MessageType].ID
-
-				tx.Messages[messageIndex].Message.MessageType = fullUniqueBlockMessageTypes[tx.Messages[messageIndex].Message.MessageType.MessageType]
-
-				if indexerConfig.Flags.IndexMessageEvents {
-					for eventIndex := range tx.Messages[messageIndex].MessageEvents {
-						tx.Messages[messageIndex].MessageEvents[eventIndex].MessageEvent.MessageEventTypeID = fullUniqueBlockMessageEventTypes[tx.Messages[messageIndex].MessageEvents[eventIndex].MessageEvent.MessageEventType.Type].ID
-						tx.Messages[messageIndex].MessageEvents[eventIndex].MessageEvent.MessageEventType = fullUniqueBlockMessageEventTypes[tx.Messages[messageIndex].MessageEvents[eventIndex].MessageEvent.MessageEventType.Type]
-
-						for attributeIndex := range tx.Messages[messageIndex].MessageEvents[eventIndex].Attributes {
-							tx.Messages[messageIndex].MessageEvents[eventIndex].Attributes[attributeIndex].MessageEventAttributeKeyID = fullUniqueBlockMessageEventAttributeKeys[tx.Messages[messageIndex].MessageEvents[eventIndex].Attributes[attributeIndex].MessageEventAttributeKey.Key].ID
-							tx.Messages[messageIndex].MessageEvents[eventIndex].Attributes[attributeIndex].MessageEventAttributeKey = fullUniqueBlockMessageEventAttributeKeys[tx.Messages[messageIndex].MessageEvents[eventIndex].Attributes[attributeIndex].MessageEventAttributeKey.Key]
-						}
-					}
-				}
+	if indexerConfig.Flags.IndexBalanceChanges {
+		return indexTxBalanceChanges(db, txs, txEvents)
+	}
 
-				if !indexerConfig.Flags.IndexTxMessageRaw {
-					tx.Messages[messageIndex].Message.MessageBytes = nil
-				}
+	return nil
+}
+
+// indexTxes creates the txes of the already created blocks, returning the created txes by hash.
+// Empty txes are skipped unless flags.index-empty-transactions is set.
+func indexTxes(db *gorm.DB, batch []BlockTxsDBWrapper, uniqueAddress map[string]models.Address, denomMap map[string]models.Denom, indexerConfig config.IndexConfig) (map[string]models.Tx, error) {
+	uniqueTxes := make(map[string]models.Tx)
+	for index := range batch {
+		block := batch[index].Block
+		for _, tx := range batch[index].Txs {
+			if !indexerConfig.Flags.IndexEmptyTransactions && tx.IsEmpty() {
+				continue
+			}
+
+			tx.Tx.BlockID = block.ID
+			tx.Tx.Block = block
 
-				messagesSlice = append(messagesSlice, &tx.Messages[messageIndex].Message)
+			for addressIndex := range tx.Tx.SignerAddresses {
+				tx.Tx.SignerAddresses[addressIndex] = uniqueAddress[tx.Tx.SignerAddresses[addressIndex].Address]
 			}
 
-			if len(messagesSlice) != 0 {
-				if err := dbTransaction.Clauses(clause.OnConflict{
-					Columns:   []clause.Column{{Name: "tx_id"}, {Name: "message_index"}},
-					DoUpdates: clause.AssignmentColumns([]string{"message_type_id", "message_bytes"}),
-				}).Create(messagesSlice).Error; err != nil {
-					config.Log.Error("Error getting/creating messages.", err)
-					return err
-				}
+			for feeIndex := range tx.Tx.Fees {
+				tx.Tx.Fees[feeIndex].PayerAddress = uniqueAddress[tx.Tx.Fees[feeIndex].PayerAddress.Address]
+				tx.Tx.Fees[feeIndex].PayerAddressID = tx.Tx.Fees[feeIndex].PayerAddress.ID
+				tx.Tx.Fees[feeIndex].Denomination = denomMap[tx.Tx.Fees[feeIndex].Denomination.Base]
+				tx.Tx.Fees[feeIndex].DenominationID = tx.Tx.Fees[feeIndex].Denomination.ID
 			}
 
-			if indexerConfig.Flags.IndexMessageEvents {
-				var messagesEventsSlice []*models.MessageEvent
-				for messageIndex := range tx.Messages {
-					for eventIndex := range tx.Messages[messageIndex].MessageEvents {
-						tx.Messages[messageIndex].MessageEvents[eventIndex].MessageEvent.MessageID = tx.Messages[messageIndex].Message.ID
-						tx.Messages[messageIndex].MessageEvents[eventIndex].MessageEvent.Message = tx.Messages[messageIndex].Message
+			assignTxMetadataAddresses(&tx.Tx, uniqueAddress)
 
-						messagesEventsSlice = append(messagesEventsSlice, &tx.Messages[messageIndex].MessageEvents[eventIndex].MessageEvent)
-					}
-				}
+			uniqueTxes[tx.Tx.Hash] = tx.Tx
+		}
+	}
 
-				if len(messagesEventsSlice) != 0 {
-					if err := dbTransaction.Clauses(clause.OnConflict{
-						Columns:   []clause.Column{{Name: "message_id"}, {Name: "index"}},
-						DoUpdates: clause.AssignmentColumns([]string{"message_event_type_id"}),
-					}).Create(messagesEventsSlice).Error; err != nil {
-						config.Log.Error("Error getting/creating message events.", err)
-						return err
-					}
-				}
+	var txesSlice []models.Tx
+	for _, tx := range uniqueTxes {
+		txesSlice = append(txesSlice, tx)
+	}
 
-				var messagesEventsAttributesSlice []*models.MessageEventAttribute
-				for messageIndex := range tx.Messages {
-					for eventIndex := range tx.Messages[messageIndex].MessageEvents {
-						for attributeIndex := range tx.Messages[messageIndex].MessageEvents[eventIndex].Attributes {
-							tx.Messages[messageIndex].MessageEvents[eventIndex].Attributes[attributeIndex].MessageEventID = tx.Messages[messageIndex].MessageEvents[eventIndex].MessageEvent.ID
-							tx.Messages[messageIndex].MessageEvents[eventIndex].Attributes[attributeIndex].MessageEvent = tx.Messages[messageIndex].MessageEvents[eventIndex].MessageEvent
+	if len(txesSlice) != 0 {
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hash"}},
+			DoUpdates: clause.AssignmentColumns(txConflictUpdateColumns),
+		}).CreateInBatches(txesSlice, bulkInsertBatchSize).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error getting/creating txes.", err)
+			return nil, err
+		}
+	}
 
-							messagesEventsAttributesSlice = append(messagesEventsAttributesSlice, &tx.Messages[messageIndex].MessageEvents[eventIndex].Attributes[attributeIndex])
-						}
+	for _, tx := range txesSlice {
+		uniqueTxes[tx.Hash] = tx
+	}
+
+	return uniqueTxes, nil
+}
+
+// indexMessages creates the messages of the already created txes, attaching the created message types.
+// The message event types and attribute keys are attached to the message events when flags.index-message-events is set.
+func indexMessages(db *gorm.DB, txs []TxDBWrapper, messageTypes map[string]models.MessageType, messageEventTypes map[string]models.MessageEventType, messageEventAttributeKeys map[string]models.MessageEventAttributeKey, indexerConfig config.IndexConfig) error {
+	var messagesSlice []*models.Message
+	for _, tx := range txs {
+		for messageIndex := range tx.Messages {
+			message := &tx.Messages[messageIndex]
+			message.Message.TxID = tx.Tx.ID
+			message.Message.Tx = tx.Tx
+			message.Message.MessageType = messageTypes[message.Message.MessageType.MessageType]
+			message.Message.MessageTypeID = message.Message.MessageType.ID
+
+			if indexerConfig.Flags.IndexMessageEvents {
+				for eventIndex := range message.MessageEvents {
+					messageEvent := &message.MessageEvents[eventIndex]
+					messageEvent.MessageEvent.MessageEventType = messageEventTypes[messageEvent.MessageEvent.MessageEventType.Type]
+					messageEvent.MessageEvent.MessageEventTypeID = messageEvent.MessageEvent.MessageEventType.ID
+
+					for attributeIndex := range messageEvent.Attributes {
+						attribute := &messageEvent.Attributes[attributeIndex]
+						attribute.MessageEventAttributeKey = messageEventAttributeKeys[attribute.MessageEventAttributeKey.Key]
+						attribute.MessageEventAttributeKeyID = attribute.MessageEventAttributeKey.ID
 					}
 				}
+			}
 
-				if len(messagesEventsAttributesSlice) != 0 {
-					if err := dbTransaction.Clauses(clause.OnConflict{
-						Columns:   []clause.Column{{Name: "message_event_id"}, {Name: "index"}},
-						DoUpdates: clause.AssignmentColumns([]string{"value", "message_event_attribute_key_id"}),
-					}).Create(messagesEventsAttributesSlice).Error; err != nil {
-						config.Log.Error("Error getting/creating message event attributes.", err)
-						return err
-					}
+			if !indexerConfig.Flags.IndexTxMessageRaw {
+				message.Message.MessageBytes = nil
+			}
+
+			messagesSlice = append(messagesSlice, &message.Message)
+		}
+	}
+
+	if len(messagesSlice) != 0 {
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tx_id"}, {Name: "message_index"}},
+			DoUpdates: clause.AssignmentColumns([]string{"message_type_id", "message_bytes", "message_json"}),
+		}).CreateInBatches(messagesSlice, bulkInsertBatchSize).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error getting/creating messages.", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexMessageEvents creates the message events and attributes of the already created messages
+func indexMessageEvents(db *gorm.DB, txs []TxDBWrapper) error {
+	var messageEventsSlice []*models.MessageEvent
+	for _, tx := range txs {
+		for messageIndex := range tx.Messages {
+			message := &tx.Messages[messageIndex]
+			for eventIndex := range message.MessageEvents {
+				message.MessageEvents[eventIndex].MessageEvent.MessageID = message.Message.ID
+				message.MessageEvents[eventIndex].MessageEvent.Message = message.Message
+				messageEventsSlice = append(messageEventsSlice, &message.MessageEvents[eventIndex].MessageEvent)
+			}
+		}
+	}
+
+	if len(messageEventsSlice) != 0 {
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "message_id"}, {Name: "index"}},
+			DoUpdates: clause.AssignmentColumns([]string{"message_event_type_id"}),
+		}).CreateInBatches(messageEventsSlice, bulkInsertBatchSize).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error getting/creating message events.", err)
+			return err
+		}
+	}
+
+	var messageEventAttributesSlice []*models.MessageEventAttribute
+	for _, tx := range txs {
+		for messageIndex := range tx.Messages {
+			for eventIndex := range tx.Messages[messageIndex].MessageEvents {
+				messageEvent := &tx.Messages[messageIndex].MessageEvents[eventIndex]
+				for attributeIndex := range messageEvent.Attributes {
+					messageEvent.Attributes[attributeIndex].MessageEventID = messageEvent.MessageEvent.ID
+					messageEvent.Attributes[attributeIndex].MessageEvent = messageEvent.MessageEvent
+					messageEventAttributesSlice = append(messageEventAttributesSlice, &messageEvent.Attributes[attributeIndex])
 				}
 			}
 		}
+	}
 
-		return nil
-	})
+	if len(messageEventAttributesSlice) != 0 {
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "message_event_id"}, {Name: "index"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "message_event_attribute_key_id"}),
+		}).CreateInBatches(messageEventAttributesSlice, bulkInsertBatchSize).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error getting/creating message event attributes.", err)
+			return err
+		}
+	}
 
-	// Contract: ensure that block and txs have been loaded with the indexed data before returning
-	return block, txs, err
+	return nil
 }
 
 func indexMessageTypes(db *gorm.DB, txs []TxDBWrapper) (map[string]models.MessageType, error) {
@@ -439,7 +613,7 @@ func indexMessageTypes(db *gorm.DB, txs []TxDBWrapper) (map[string]models.Messag
 			Columns:   []clause.Column{{Name: "message_type"}},
 			DoUpdates: clause.AssignmentColumns([]string{"message_type"}),
 		}).Create(messageTypesSlice).Error; err != nil {
-			config.Log.Error("Error getting/creating message types.", err)
+			config.LogCtx(db.Statement.Context).Error("Error getting/creating message types.", err)
 			return nil, err
 		}
 	}
@@ -470,7 +644,7 @@ func indexMessageEventTypes(db *gorm.DB, txs []TxDBWrapper) (map[string]models.M
 			Columns:   []clause.Column{{Name: "type"}},
 			DoUpdates: clause.AssignmentColumns([]string{"type"}),
 		}).Create(messageTypesSlice).Error; err != nil {
-			config.Log.Error("Error getting/creating message event types.", err)
+			config.LogCtx(db.Statement.Context).Error("Error getting/creating message event types.", err)
 			return nil, err
 		}
 	}
@@ -501,7 +675,7 @@ func indexMessageEventAttributeKeys(db *gorm.DB, txs []TxDBWrapper) (map[string]
 			Columns:   []clause.Column{{Name: "key"}},
 			DoUpdates: clause.AssignmentColumns([]string{"key"}),
 		}).Create(messageEventAttributeKeysSlice).Error; err != nil {
-			config.Log.Error("Error getting/creating message event attribute keys.", err)
+			config.LogCtx(db.Statement.Context).Error("Error getting/creating message event attribute keys.", err)
 			return nil, err
 		}
 	}
@@ -524,7 +698,7 @@ func IndexCustomMessages(conf config.IndexConfig, db *gorm.DB, dryRun bool, bloc
 						if parsedData.Parser != nil {
 							err := DeleteCustomMessageParserError(db, message.Message, messageParserTrackers[(*parsedData.Parser).Identifier()])
 							if err != nil {
-								config.Log.Error("Error clearing block event error.", err)
+								config.LogCtx(dbTransaction.Statement.Context).Error("Error clearing block event error.", err)
 								return err
 							}
 						}
@@ -539,13 +713,13 @@ func IndexCustomMessages(conf config.IndexConfig, db *gorm.DB, dryRun bool, bloc
 							}
 							err := (*parsedData.Parser).IndexMessage(parsedData.Data, dbTransaction, message.Message, combinedEventsWithAttribues, conf)
 							if err != nil {
-								config.Log.Error("Error indexing message.", err)
+								config.LogCtx(dbTransaction.Statement.Context).Error("Error indexing message.", err)
 								return err
 							}
 						} else if parsedData.Error != nil {
 							err := CreateMessageParserError(db, message.Message, messageParserTrackers[(*parsedData.Parser).Identifier()], parsedData.Error)
 							if err != nil {
-								config.Log.Error("Error inserting message parser error.", err)
+								config.LogCtx(dbTransaction.Statement.Context).Error("Error inserting message parser error.", err)
 								return err
 							}
 						}
@@ -1,12 +1,20 @@
 package db
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/DefiantLabs/cosmos-indexer/config"
 	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	sdkTypes "github.com/cosmos/cosmos-sdk/types"
+	authTypes "github.com/cosmos/cosmos-sdk/x/auth/types"
 	"github.com/ory/dockertest/v3"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/gorm"
 )
@@ -158,6 +166,1578 @@ func (suite *DBTestSuite) TestGetHighestBlockFunctions() {
 	suite.Assert().Equal(block3.Height, eventBlock.Height)
 }
 
+func (suite *DBTestSuite) TestCheckpointFunctions() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	initConsAddress := models.Address{
+		Address: "testchainaddress",
+	}
+
+	err = suite.db.Create(&initConsAddress).Error
+	suite.Require().NoError(err)
+
+	for _, height := range []int64{1, 2, 4} {
+		_, err = createMockBlock(suite.db, initChain, initConsAddress, height, true, true)
+		suite.Require().NoError(err)
+	}
+
+	block3, err := createMockBlock(suite.db, initChain, initConsAddress, 3, false, false)
+	suite.Require().NoError(err)
+
+	checkpoint, err := InitCheckpoint(suite.db, initChain.ID)
+	suite.Require().NoError(err)
+
+	suite.Assert().Equal(int64(1), checkpoint.TxIndexedStartHeight)
+	suite.Assert().Equal(int64(2), checkpoint.TxIndexedHeight)
+	suite.Assert().Equal(int64(2), checkpoint.BlockEventsIndexedHeight)
+	suite.Assert().Equal(int64(3), checkpoint.ResumeHeight(1, true, true))
+
+	err = suite.db.Model(&block3).Update("tx_indexed", true).Error
+	suite.Require().NoError(err)
+
+	err = AdvanceCheckpoint(suite.db, &checkpoint, CheckpointTransactions, 3)
+	suite.Require().NoError(err)
+
+	// The out of order block 4 is picked up once block 3 fills the gap
+	checkpoint, err = InitCheckpoint(suite.db, initChain.ID)
+	suite.Require().NoError(err)
+
+	suite.Assert().Equal(int64(4), checkpoint.TxIndexedHeight)
+	suite.Assert().Equal(int64(2), checkpoint.BlockEventsIndexedHeight)
+	suite.Assert().Equal(int64(5), checkpoint.ResumeHeight(1, true, false))
+	suite.Assert().Equal(int64(3), checkpoint.ResumeHeight(1, true, true))
+}
+
+func (suite *DBTestSuite) TestAdvanceCheckpointNonAdjacentAndConcurrent() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	initConsAddress := models.Address{
+		Address: "testchainaddress",
+	}
+
+	err = suite.db.Create(&initConsAddress).Error
+	suite.Require().NoError(err)
+
+	_, err = createMockBlock(suite.db, initChain, initConsAddress, 1, true, false)
+	suite.Require().NoError(err)
+
+	checkpoint, err := InitCheckpoint(suite.db, initChain.ID)
+	suite.Require().NoError(err)
+
+	// Two indexers share the checkpoint, the second one keeps a stale copy of the range
+	staleCheckpoint := checkpoint
+
+	// Block 3 is not adjacent to the range and is skipped
+	_, err = createMockBlock(suite.db, initChain, initConsAddress, 3, true, false)
+	suite.Require().NoError(err)
+	err = AdvanceCheckpoint(suite.db, &staleCheckpoint, CheckpointTransactions, 3)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(1), staleCheckpoint.TxIndexedHeight)
+
+	// Block 2 fills the gap and the range picks up block 3
+	_, err = createMockBlock(suite.db, initChain, initConsAddress, 2, true, false)
+	suite.Require().NoError(err)
+	err = AdvanceCheckpoint(suite.db, &checkpoint, CheckpointTransactions, 2)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(3), checkpoint.TxIndexedHeight)
+
+	// Block 4 is adjacent to the stored range, not to the stale copy of the second indexer
+	_, err = createMockBlock(suite.db, initChain, initConsAddress, 4, true, false)
+	suite.Require().NoError(err)
+	err = AdvanceCheckpoint(suite.db, &staleCheckpoint, CheckpointTransactions, 4)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(1), staleCheckpoint.TxIndexedStartHeight)
+	suite.Assert().Equal(int64(4), staleCheckpoint.TxIndexedHeight)
+
+	// Concurrent commits of adjacent heights all end up in the range
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for height := int64(5); height < 15; height++ {
+		_, err = createMockBlock(suite.db, initChain, initConsAddress, height, true, false)
+		suite.Require().NoError(err)
+
+		wg.Add(1)
+		go func(height int64) {
+			defer wg.Done()
+			checkpoint := checkpoint
+			errs <- AdvanceCheckpoint(suite.db, &checkpoint, CheckpointTransactions, height)
+		}(height)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		suite.Require().NoError(err)
+	}
+
+	checkpoint, err = InitCheckpoint(suite.db, initChain.ID)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(1), checkpoint.TxIndexedStartHeight)
+	suite.Assert().Equal(int64(14), checkpoint.TxIndexedHeight)
+	suite.Assert().Zero(checkpoint.BlockEventsIndexedHeight)
+
+	// A failed reindex of a height inside the range cuts the range below it
+	err = UpsertFailedBlock(suite.db, 10, initChain.ChainID, initChain.Name, BlockFailure{Code: "unprocessable_tx_error", Stage: FailureStageDecode, Err: errors.New("decode failed")})
+	suite.Require().NoError(err)
+
+	checkpoint, err = InitCheckpoint(suite.db, initChain.ID)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(1), checkpoint.TxIndexedStartHeight)
+	suite.Assert().Equal(int64(9), checkpoint.TxIndexedHeight)
+	suite.Assert().Equal(int64(10), checkpoint.ResumeHeight(1, true, false))
+
+	// Failing the start of the range moves the start past it
+	err = UpsertFailedBlock(suite.db, 1, initChain.ChainID, initChain.Name, BlockFailure{Code: "unprocessable_tx_error", Stage: FailureStageDecode, Err: errors.New("decode failed")})
+	suite.Require().NoError(err)
+
+	checkpoint, err = InitCheckpoint(suite.db, initChain.ID)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(2), checkpoint.TxIndexedStartHeight)
+	suite.Assert().Equal(int64(9), checkpoint.TxIndexedHeight)
+}
+
+func (suite *DBTestSuite) TestFindBlockGaps() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	initConsAddress := models.Address{
+		Address: "testchainaddress",
+	}
+
+	err = suite.db.Create(&initConsAddress).Error
+	suite.Require().NoError(err)
+
+	_, err = createMockBlock(suite.db, initChain, initConsAddress, 1, true, true)
+	suite.Require().NoError(err)
+	_, err = createMockBlock(suite.db, initChain, initConsAddress, 4, true, false)
+	suite.Require().NoError(err)
+	_, err = createMockBlock(suite.db, initChain, initConsAddress, 5, true, true)
+	suite.Require().NoError(err)
+
+	// Block 6 failed its txes and is missing its block events, block 7 failed its block events and is not in the DB
+	_, err = createMockBlock(suite.db, initChain, initConsAddress, 6, true, false)
+	suite.Require().NoError(err)
+	_, err = createMockBlock(suite.db, initChain, initConsAddress, 8, true, true)
+	suite.Require().NoError(err)
+
+	err = suite.db.Create(&models.FailedBlock{Height: 5, BlockchainID: initChain.ID}).Error
+	suite.Require().NoError(err)
+	err = suite.db.Create(&models.FailedBlock{Height: 6, BlockchainID: initChain.ID}).Error
+	suite.Require().NoError(err)
+	err = suite.db.Create(&models.FailedEventBlock{Height: 7, BlockchainID: initChain.ID}).Error
+	suite.Require().NoError(err)
+
+	gaps, err := FindBlockGaps(suite.db, initChain.ID, 1, -1, true, true)
+	suite.Require().NoError(err)
+
+	suite.Require().Len(gaps, 4)
+	suite.Assert().Equal(BlockGap{StartHeight: 2, EndHeight: 3, Reason: GapMissing, Transactions: true, BlockEvents: true}, gaps[0])
+	suite.Assert().Equal(BlockGap{StartHeight: 4, EndHeight: 4, Reason: GapPartial, Transactions: false, BlockEvents: true}, gaps[1])
+	suite.Assert().Equal(BlockGap{StartHeight: 5, EndHeight: 5, Reason: GapFailed, Transactions: true, BlockEvents: false}, gaps[2])
+	suite.Assert().Equal(BlockGap{StartHeight: 6, EndHeight: 7, Reason: GapFailed, Transactions: true, BlockEvents: true}, gaps[3])
+	suite.Assert().Equal(int64(2), gaps[3].Blocks())
+
+	// Only the datasets being searched are reported for failed blocks
+	gaps, err = FindBlockGaps(suite.db, initChain.ID, 5, 8, true, false)
+	suite.Require().NoError(err)
+
+	suite.Require().Len(gaps, 2)
+	suite.Assert().Equal(BlockGap{StartHeight: 5, EndHeight: 6, Reason: GapFailed, Transactions: true, BlockEvents: false}, gaps[0])
+	suite.Assert().Equal(BlockGap{StartHeight: 7, EndHeight: 7, Reason: GapMissing, Transactions: true, BlockEvents: false}, gaps[1])
+}
+
+func (suite *DBTestSuite) TestBlockRangeLeaseFunctions() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	initConsAddress := models.Address{
+		Address: "testchainaddress",
+	}
+
+	err = suite.db.Create(&initConsAddress).Error
+	suite.Require().NoError(err)
+
+	first, err := ClaimBlockRangeLease(suite.db, initChain.ID, "replica-1", 1, 25, 10, time.Minute)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(1), first.StartHeight)
+	suite.Assert().Equal(int64(10), first.EndHeight)
+
+	second, err := ClaimBlockRangeLease(suite.db, initChain.ID, "replica-2", 1, 25, 10, -time.Minute)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(11), second.StartHeight)
+	suite.Assert().Equal(int64(20), second.EndHeight)
+
+	// The expired lease is re-claimed before a new range is leased
+	reclaimed, err := ClaimBlockRangeLease(suite.db, initChain.ID, "replica-1", 1, 25, 10, time.Minute)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(second.ID, reclaimed.ID)
+	suite.Assert().Equal("replica-1", reclaimed.Owner)
+	suite.Assert().Equal(int64(11), reclaimed.StartHeight)
+	suite.Assert().True(reclaimed.ExpiresAt.After(second.ExpiresAt))
+
+	err = RenewBlockRangeLease(suite.db, second, time.Minute)
+	suite.Assert().ErrorIs(err, ErrBlockRangeLeaseLost)
+
+	// Expiries are set from the DB clock
+	var dbNow time.Time
+	err = suite.db.Raw("SELECT now()").Scan(&dbNow).Error
+	suite.Require().NoError(err)
+	suite.Assert().WithinDuration(dbNow.Add(time.Minute), reclaimed.ExpiresAt, 5*time.Second)
+
+	err = RenewBlockRangeLease(suite.db, reclaimed, time.Hour)
+	suite.Require().NoError(err)
+	suite.Assert().WithinDuration(dbNow.Add(time.Hour), reclaimed.ExpiresAt, 5*time.Second)
+
+	var stored models.BlockRangeLease
+	err = suite.db.First(&stored, reclaimed.ID).Error
+	suite.Require().NoError(err)
+	suite.Assert().True(stored.ExpiresAt.Equal(reclaimed.ExpiresAt))
+
+	for height := int64(1); height <= 9; height++ {
+		_, err = createMockBlock(suite.db, initChain, initConsAddress, height, true, true)
+		suite.Require().NoError(err)
+	}
+
+	processed, err := BlockRangeLeaseProcessed(suite.db, first, true, true)
+	suite.Require().NoError(err)
+	suite.Assert().False(processed)
+
+	err = suite.db.Create(&models.FailedBlock{Height: 10, BlockchainID: initChain.ID}).Error
+	suite.Require().NoError(err)
+
+	processed, err = BlockRangeLeaseProcessed(suite.db, first, true, true)
+	suite.Require().NoError(err)
+	suite.Assert().True(processed)
+
+	err = CompleteBlockRangeLease(suite.db, first)
+	suite.Require().NoError(err)
+
+	last, err := ClaimBlockRangeLease(suite.db, initChain.ID, "replica-1", 1, 25, 10, time.Minute)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(21), last.StartHeight)
+	suite.Assert().Equal(int64(25), last.EndHeight)
+
+	none, err := ClaimBlockRangeLease(suite.db, initChain.ID, "replica-1", 1, 25, 10, time.Minute)
+	suite.Require().NoError(err)
+	suite.Assert().Nil(none)
+
+	incomplete, err := CountIncompleteBlockRangeLeases(suite.db, initChain.ID, 25)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(2), incomplete)
+}
+
+func (suite *DBTestSuite) TestUpsertFailedBlock() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	err = UpsertFailedBlock(suite.db, 10, "testchain-1", "testchain", BlockFailure{Code: "block_query_error", Stage: FailureStageRPC, Err: errors.New("timeout")})
+	suite.Require().NoError(err)
+
+	err = UpsertFailedBlock(suite.db, 10, "testchain-1", "testchain", BlockFailure{Code: "unprocessable_tx_error", Stage: FailureStageDecode, Err: errors.New("unknown message type")})
+	suite.Require().NoError(err)
+
+	var failedBlocks []models.FailedBlock
+	err = suite.db.Find(&failedBlocks).Error
+	suite.Require().NoError(err)
+
+	suite.Require().Len(failedBlocks, 1)
+	suite.Assert().Equal(int64(2), failedBlocks[0].Attempts)
+	suite.Assert().Equal("unprocessable_tx_error", failedBlocks[0].Code)
+	suite.Assert().Equal(FailureStageDecode, failedBlocks[0].Stage)
+	suite.Assert().Equal("unknown message type", failedBlocks[0].ErrorMessage)
+	suite.Assert().False(failedBlocks[0].LastFailedAt.Before(failedBlocks[0].FirstFailedAt))
+}
+
+func (suite *DBTestSuite) TestClaimFailedBlockRetries() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	for height := int64(1); height <= 20; height++ {
+		err = UpsertFailedBlock(suite.db, height, "testchain-1", "testchain", BlockFailure{Code: "block_query_error", Stage: FailureStageRPC, Err: errors.New("timeout")})
+		suite.Require().NoError(err)
+	}
+
+	// Height 20 has reached the max attempts
+	for i := 0; i < 2; i++ {
+		err = UpsertFailedBlock(suite.db, 20, "testchain-1", "testchain", BlockFailure{Code: "block_query_error", Stage: FailureStageRPC, Err: errors.New("timeout")})
+		suite.Require().NoError(err)
+	}
+
+	var chain models.Chain
+	err = suite.db.Where("chain_id = ?", "testchain-1").First(&chain).Error
+	suite.Require().NoError(err)
+
+	// Concurrent replicas each claim a disjoint set of heights
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	claimed := make(map[int64]int)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			heights, err := ClaimFailedBlockRetries(suite.db, "failed_blocks", chain.ID, 3, 0, time.Hour, 4)
+			suite.Assert().NoError(err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, height := range heights {
+				claimed[height]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Heights left over by the limit are claimed by a later scan
+	heights, err := ClaimFailedBlockRetries(suite.db, "failed_blocks", chain.ID, 3, 0, time.Hour, 100)
+	suite.Require().NoError(err)
+	for _, height := range heights {
+		claimed[height]++
+	}
+
+	suite.Require().Len(claimed, 19)
+	suite.Assert().NotContains(claimed, int64(20))
+	for height, count := range claimed {
+		suite.Assert().Equal(1, count, "height %d claimed more than once", height)
+	}
+
+	// Claimed heights are skipped until they fail again
+	heights, err = ClaimFailedBlockRetries(suite.db, "failed_blocks", chain.ID, 3, 0, time.Hour, 100)
+	suite.Require().NoError(err)
+	suite.Assert().Empty(heights)
+
+	err = UpsertFailedBlock(suite.db, 5, "testchain-1", "testchain", BlockFailure{Code: "block_query_error", Stage: FailureStageRPC, Err: errors.New("timeout")})
+	suite.Require().NoError(err)
+
+	heights, err = ClaimFailedBlockRetries(suite.db, "failed_blocks", chain.ID, 3, 0, time.Hour, 100)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int64{5}, heights)
+
+	// A failed height waits for its backoff before it is claimed again
+	err = UpsertFailedBlock(suite.db, 5, "testchain-1", "testchain", BlockFailure{Code: "block_query_error", Stage: FailureStageRPC, Err: errors.New("timeout")})
+	suite.Require().NoError(err)
+
+	heights, err = ClaimFailedBlockRetries(suite.db, "failed_blocks", chain.ID, 0, time.Minute, time.Hour, 100)
+	suite.Require().NoError(err)
+	suite.Assert().Empty(heights)
+}
+
+// setFailedBlockRetryState sets the attempts, the seconds since the last failure and the retry claim of a failed blocks row, relative to the DB clock
+func (suite *DBTestSuite) setFailedBlockRetryState(height int64, attempts int64, secondsSinceFailure int64, claimedUntil any) {
+	err := suite.db.Table("failed_blocks").Where("height = ?", height).Updates(map[string]any{
+		"attempts":            attempts,
+		"last_failed_at":      gorm.Expr("now() - make_interval(secs => ?)", secondsSinceFailure),
+		"retry_claimed_until": claimedUntil,
+	}).Error
+	suite.Require().NoError(err)
+}
+
+func (suite *DBTestSuite) TestClaimFailedBlockRetriesBackoff() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	for height := int64(1); height <= 6; height++ {
+		err = UpsertFailedBlock(suite.db, height, "testchain-1", "testchain", BlockFailure{Code: "block_query_error", Stage: FailureStageRPC, Err: errors.New("timeout")})
+		suite.Require().NoError(err)
+	}
+
+	var chain models.Chain
+	err = suite.db.Where("chain_id = ?", "testchain-1").First(&chain).Error
+	suite.Require().NoError(err)
+
+	// With a 60 second backoff, a height is due 60, 120, 240, 480 seconds after its 1st, 2nd, 3rd and 4th failure, capped at 300 seconds
+	suite.setFailedBlockRetryState(1, 1, 70, nil)   // due after 60 seconds
+	suite.setFailedBlockRetryState(2, 2, 70, nil)   // waits 120 seconds
+	suite.setFailedBlockRetryState(3, 2, 130, nil)  // due after 120 seconds
+	suite.setFailedBlockRetryState(4, 3, 200, nil)  // waits 240 seconds
+	suite.setFailedBlockRetryState(5, 4, 310, nil)  // capped at 300 seconds instead of 480
+	suite.setFailedBlockRetryState(6, 10, 290, nil) // still waits for the cap
+
+	heights, err := ClaimFailedBlockRetries(suite.db, "failed_blocks", chain.ID, 0, time.Minute, 5*time.Minute, 100)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int64{1, 3, 5}, heights)
+}
+
+func (suite *DBTestSuite) TestClaimFailedBlockRetriesMaxAttempts() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	for height := int64(1); height <= 3; height++ {
+		err = UpsertFailedBlock(suite.db, height, "testchain-1", "testchain", BlockFailure{Code: "block_query_error", Stage: FailureStageRPC, Err: errors.New("timeout")})
+		suite.Require().NoError(err)
+	}
+
+	var chain models.Chain
+	err = suite.db.Where("chain_id = ?", "testchain-1").First(&chain).Error
+	suite.Require().NoError(err)
+
+	suite.setFailedBlockRetryState(1, 2, 3600, nil)
+	suite.setFailedBlockRetryState(2, 3, 3600, nil)
+	suite.setFailedBlockRetryState(3, 4, 3600, nil)
+
+	// Heights that failed the max attempts are no longer retried
+	heights, err := ClaimFailedBlockRetries(suite.db, "failed_blocks", chain.ID, 3, 0, time.Hour, 100)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int64{1}, heights)
+
+	// 0 retries every height
+	suite.setFailedBlockRetryState(1, 2, 3600, nil)
+	heights, err = ClaimFailedBlockRetries(suite.db, "failed_blocks", chain.ID, 0, 0, time.Hour, 100)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int64{1, 2, 3}, heights)
+}
+
+func (suite *DBTestSuite) TestClaimFailedBlockRetriesClaimExpiry() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	for height := int64(1); height <= 3; height++ {
+		err = UpsertFailedBlock(suite.db, height, "testchain-1", "testchain", BlockFailure{Code: "block_query_error", Stage: FailureStageRPC, Err: errors.New("timeout")})
+		suite.Require().NoError(err)
+	}
+
+	var chain models.Chain
+	err = suite.db.Where("chain_id = ?", "testchain-1").First(&chain).Error
+	suite.Require().NoError(err)
+
+	// Height 1 is claimed by another replica, the claim of height 2 expired and height 3 was never claimed
+	suite.setFailedBlockRetryState(1, 1, 3600, gorm.Expr("now() + INTERVAL '1 minute'"))
+	suite.setFailedBlockRetryState(2, 1, 3600, gorm.Expr("now() - INTERVAL '1 second'"))
+	suite.setFailedBlockRetryState(3, 1, 3600, nil)
+
+	heights, err := ClaimFailedBlockRetries(suite.db, "failed_blocks", chain.ID, 0, 0, time.Hour, 100)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int64{2, 3}, heights)
+
+	// The claims last for the max backoff from the DB clock
+	var expired int64
+	err = suite.db.Table("failed_blocks").Where("height IN ? AND retry_claimed_until BETWEEN now() + INTERVAL '59 minutes' AND now() + INTERVAL '61 minutes'", []int64{2, 3}).Count(&expired).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(2), expired)
+
+	// Nothing is claimed again before the claims expire
+	heights, err = ClaimFailedBlockRetries(suite.db, "failed_blocks", chain.ID, 0, 0, time.Hour, 100)
+	suite.Require().NoError(err)
+	suite.Assert().Empty(heights)
+
+	suite.setFailedBlockRetryState(1, 1, 3600, gorm.Expr("now() - INTERVAL '1 second'"))
+	heights, err = ClaimFailedBlockRetries(suite.db, "failed_blocks", chain.ID, 0, 0, time.Hour, 100)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int64{1}, heights)
+}
+
+func (suite *DBTestSuite) TestIndexNewBlockFailedTxs() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	indexerConfig := config.IndexConfig{}
+	indexerConfig.Flags.IndexEmptyTransactions = true
+
+	block := models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "testchainaddress"}}
+	txs := []TxDBWrapper{{
+		Tx: models.Tx{Hash: "TXHASH1"},
+		FailedMessages: []models.FailedMessage{{
+			MessageIndex:   0,
+			MessageTypeURL: "/unknown.v1.MsgUnknown",
+			MessageBytes:   []byte{1, 2, 3},
+			ErrorMessage:   "message could not be decoded",
+		}},
+	}}
+	failedTxs := []models.FailedTx{{Hash: "TXHASH2", TxBytes: []byte{4, 5, 6}, ErrorMessage: "TX cannot be parsed"}}
+
+	_, _, err = IndexNewBlock(suite.db, block, txs, failedTxs, indexerConfig)
+	suite.Require().NoError(err)
+
+	heights, err := GetFailedTxHeights(suite.db, initChain.ID, 1, -1)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int64{1}, heights)
+
+	var failedMessages []models.FailedMessage
+	err = suite.db.Find(&failedMessages).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(failedMessages, 1)
+	suite.Assert().Equal("/unknown.v1.MsgUnknown", failedMessages[0].MessageTypeURL)
+
+	// Reprocessing the block with both transactions decoded removes the failures
+	txs = []TxDBWrapper{{Tx: models.Tx{Hash: "TXHASH1"}}, {Tx: models.Tx{Hash: "TXHASH2"}}}
+	_, _, err = IndexNewBlock(suite.db, block, txs, nil, indexerConfig)
+	suite.Require().NoError(err)
+
+	heights, err = GetFailedTxHeights(suite.db, initChain.ID, 1, -1)
+	suite.Require().NoError(err)
+	suite.Assert().Empty(heights)
+}
+
+func (suite *DBTestSuite) TestIndexNewBlockBatchFailedTxIsolation() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	indexerConfig := config.IndexConfig{}
+	indexerConfig.Flags.IndexEmptyTransactions = true
+
+	failedMessage := models.FailedMessage{MessageIndex: 1, MessageTypeURL: "/unknown.v1.MsgUnknown", MessageBytes: []byte{1, 2, 3}, ErrorMessage: "message could not be decoded"}
+	blockOne := models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}}
+	blockTwo := models.Block{Height: 2, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}}
+
+	// Block 1 has a decoded tx, a tx with an undecodable message and an undecodable tx, block 2 only has a decoded tx
+	batch := []BlockTxsDBWrapper{
+		{
+			Block:     blockOne,
+			Txs:       []TxDBWrapper{{Tx: models.Tx{Hash: "TXHASH1"}}, {Tx: models.Tx{Hash: "TXHASH2"}, FailedMessages: []models.FailedMessage{failedMessage}}},
+			FailedTxs: []models.FailedTx{{Hash: "TXHASH3", TxBytes: []byte{4, 5, 6}, ErrorMessage: "TX cannot be parsed"}},
+		},
+		{
+			Block: blockTwo,
+			Txs:   []TxDBWrapper{{Tx: models.Tx{Hash: "TXHASH4"}}},
+		},
+	}
+
+	indexed, err := IndexNewBlockBatch(suite.db, batch, indexerConfig)
+	suite.Require().NoError(err)
+
+	// The failures do not keep the rest of the block or the batch from being indexed
+	var hashes []string
+	err = suite.db.Model(&models.Tx{}).Order("hash").Pluck("hash", &hashes).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]string{"TXHASH1", "TXHASH2", "TXHASH4"}, hashes)
+
+	var failedTxs []models.FailedTx
+	err = suite.db.Find(&failedTxs).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(failedTxs, 1)
+	suite.Assert().Equal("TXHASH3", failedTxs[0].Hash)
+	suite.Assert().Equal(indexed[0].Block.ID, failedTxs[0].BlockID)
+	suite.Assert().Equal([]byte{4, 5, 6}, failedTxs[0].TxBytes)
+
+	var failedMessages []models.FailedMessage
+	err = suite.db.Find(&failedMessages).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(failedMessages, 1)
+	suite.Assert().Equal(indexed[0].Txs[1].Tx.ID, failedMessages[0].TxID)
+	suite.Assert().Equal(1, failedMessages[0].MessageIndex)
+	suite.Assert().Equal([]byte{1, 2, 3}, failedMessages[0].MessageBytes)
+
+	heights, err := GetFailedTxHeights(suite.db, initChain.ID, 1, -1)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int64{1}, heights)
+
+	// Reprocessing block 1 with the message decoded only clears the failure of that tx
+	batch = []BlockTxsDBWrapper{{
+		Block:     blockOne,
+		Txs:       []TxDBWrapper{{Tx: models.Tx{Hash: "TXHASH1"}}, {Tx: models.Tx{Hash: "TXHASH2"}}},
+		FailedTxs: []models.FailedTx{{Hash: "TXHASH3", TxBytes: []byte{4, 5, 6}, ErrorMessage: "TX still cannot be parsed"}},
+	}}
+
+	_, err = IndexNewBlockBatch(suite.db, batch, indexerConfig)
+	suite.Require().NoError(err)
+
+	var failedMessageCount int64
+	err = suite.db.Model(&models.FailedMessage{}).Count(&failedMessageCount).Error
+	suite.Require().NoError(err)
+	suite.Assert().Zero(failedMessageCount)
+
+	failedTxs = nil
+	err = suite.db.Find(&failedTxs).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(failedTxs, 1)
+	suite.Assert().Equal("TX still cannot be parsed", failedTxs[0].ErrorMessage)
+
+	heights, err = GetFailedTxHeights(suite.db, initChain.ID, 1, -1)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int64{1}, heights)
+}
+
+func (suite *DBTestSuite) TestIndexNewBlockTxMetadata() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	indexerConfig := config.IndexConfig{}
+	indexerConfig.Flags.IndexEmptyTransactions = true
+
+	block := models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "testchainaddress"}}
+	txs := []TxDBWrapper{{
+		Tx: models.Tx{
+			Hash:              "TXHASH1",
+			Code:              5,
+			Codespace:         "sdk",
+			RawLog:            "insufficient funds",
+			GasWanted:         200000,
+			GasUsed:           150000,
+			TimeoutHeight:     10,
+			TxIndex:           2,
+			FeePayerAddress:   &models.Address{Address: "payeraddress"},
+			FeeGranterAddress: &models.Address{Address: "granteraddress"},
+			SignerInfos: []models.TxSignerInfo{
+				{SignerIndex: 0, Address: &models.Address{Address: "payeraddress"}, Sequence: 7, SignMode: "SIGN_MODE_DIRECT"},
+				{SignerIndex: 1, Sequence: 3, SignMode: "SIGN_MODE_LEGACY_AMINO_JSON"},
+			},
+		},
+	}}
+
+	_, _, err = IndexNewBlock(suite.db, block, txs, nil, indexerConfig)
+	suite.Require().NoError(err)
+
+	var tx models.Tx
+	err = suite.db.Preload("FeePayerAddress").Preload("FeeGranterAddress").Preload("SignerInfos", func(db *gorm.DB) *gorm.DB {
+		return db.Order("signer_index")
+	}).Preload("SignerInfos.Address").Where("hash = ?", "TXHASH1").First(&tx).Error
+	suite.Require().NoError(err)
+
+	suite.Assert().Equal("sdk", tx.Codespace)
+	suite.Assert().Equal("insufficient funds", tx.RawLog)
+	suite.Assert().Equal(int64(150000), tx.GasUsed)
+	suite.Assert().Equal(uint64(10), tx.TimeoutHeight)
+	suite.Assert().Equal(2, tx.TxIndex)
+	suite.Require().NotNil(tx.FeePayerAddress)
+	suite.Assert().Equal("payeraddress", tx.FeePayerAddress.Address)
+	suite.Require().NotNil(tx.FeeGranterAddress)
+	suite.Assert().Equal("granteraddress", tx.FeeGranterAddress.Address)
+
+	suite.Require().Len(tx.SignerInfos, 2)
+	suite.Require().NotNil(tx.SignerInfos[0].Address)
+	suite.Assert().Equal(tx.FeePayerAddress.ID, tx.SignerInfos[0].Address.ID)
+	suite.Assert().Equal(uint64(7), tx.SignerInfos[0].Sequence)
+	suite.Assert().Nil(tx.SignerInfos[1].AddressID)
+	suite.Assert().Equal("SIGN_MODE_LEGACY_AMINO_JSON", tx.SignerInfos[1].SignMode)
+}
+
+func (suite *DBTestSuite) TestIndexNewBlockBatchTxMetadata() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	indexerConfig := config.IndexConfig{}
+	indexerConfig.Flags.IndexEmptyTransactions = true
+
+	blockOne := models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}}
+	txOne := func(gasUsed int64, sequence uint64, feeGranter *models.Address) TxDBWrapper {
+		return TxDBWrapper{Tx: models.Tx{
+			Hash:              "TXHASH1",
+			GasWanted:         200000,
+			GasUsed:           gasUsed,
+			TxIndex:           1,
+			FeePayerAddress:   &models.Address{Address: "payeraddress"},
+			FeeGranterAddress: feeGranter,
+			SignerInfos:       []models.TxSignerInfo{{SignerIndex: 0, Address: &models.Address{Address: "payeraddress"}, Sequence: sequence, SignMode: "SIGN_MODE_DIRECT"}},
+		}}
+	}
+
+	// The tx of block 2 has no fee payer, fee granter or signer infos
+	batch := []BlockTxsDBWrapper{
+		{Block: blockOne, Txs: []TxDBWrapper{txOne(150000, 1, &models.Address{Address: "granteraddress"})}},
+		{Block: models.Block{Height: 2, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}}, Txs: []TxDBWrapper{{Tx: models.Tx{Hash: "TXHASH2", GasUsed: 1000}}}},
+	}
+
+	indexed, err := IndexNewBlockBatch(suite.db, batch, indexerConfig)
+	suite.Require().NoError(err)
+
+	loadTx := func(hash string) models.Tx {
+		var tx models.Tx
+		err := suite.db.Preload("Block").Preload("SignerInfos").Where("hash = ?", hash).First(&tx).Error
+		suite.Require().NoError(err)
+		return tx
+	}
+
+	tx := loadTx("TXHASH1")
+	suite.Assert().Equal(indexed[0].Block.ID, tx.BlockID)
+	suite.Assert().Equal(int64(150000), tx.GasUsed)
+	suite.Assert().Equal(1, tx.TxIndex)
+	suite.Require().NotNil(tx.FeePayerAddressID)
+	suite.Require().NotNil(tx.FeeGranterAddressID)
+	suite.Require().Len(tx.SignerInfos, 1)
+	suite.Assert().Equal(*tx.FeePayerAddressID, *tx.SignerInfos[0].AddressID)
+
+	tx = loadTx("TXHASH2")
+	suite.Assert().Equal(int64(2), tx.Block.Height)
+	suite.Assert().Nil(tx.FeePayerAddressID)
+	suite.Assert().Nil(tx.FeeGranterAddressID)
+	suite.Assert().Empty(tx.SignerInfos)
+
+	// Reindexing the tx updates its metadata in place, including clearing a fee granter that is no longer set
+	_, err = IndexNewBlockBatch(suite.db, []BlockTxsDBWrapper{{Block: blockOne, Txs: []TxDBWrapper{txOne(160000, 2, nil)}}}, indexerConfig)
+	suite.Require().NoError(err)
+
+	tx = loadTx("TXHASH1")
+	suite.Assert().Equal(int64(160000), tx.GasUsed)
+	suite.Assert().NotNil(tx.FeePayerAddressID)
+	suite.Assert().Nil(tx.FeeGranterAddressID)
+	suite.Require().Len(tx.SignerInfos, 1)
+	suite.Assert().Equal(uint64(2), tx.SignerInfos[0].Sequence)
+
+	var txCount int64
+	err = suite.db.Model(&models.Tx{}).Count(&txCount).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(2), txCount)
+}
+
+func (suite *DBTestSuite) TestIndexNewBlockHeaderAndSignatures() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	block := models.Block{
+		Height:              2,
+		ChainID:             initChain.ID,
+		ProposerConsAddress: models.Address{Address: "proposeraddress"},
+		BlockHeader:         models.BlockHeader{Hash: "BLOCKHASH", LastBlockHash: "LASTBLOCKHASH", TxCount: 3},
+		LastCommitSignatures: []models.BlockSignature{
+			{ValidatorIndex: 0, CommitHeight: 1, ValidatorConsAddress: &models.Address{Address: "proposeraddress"}, Flag: models.BlockSignatureCommit},
+			{ValidatorIndex: 1, CommitHeight: 1, Flag: models.BlockSignatureAbsent},
+		},
+	}
+
+	_, _, err = IndexNewBlock(suite.db, block, nil, nil, config.IndexConfig{})
+	suite.Require().NoError(err)
+
+	// Reindexing the block overwrites the signatures instead of duplicating them
+	block.LastCommitSignatures[1].Flag = models.BlockSignatureNil
+	_, _, err = IndexNewBlock(suite.db, block, nil, nil, config.IndexConfig{})
+	suite.Require().NoError(err)
+
+	var storedBlock models.Block
+	err = suite.db.Where("height = ?", 2).First(&storedBlock).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal("BLOCKHASH", storedBlock.Hash)
+	suite.Assert().Equal(3, storedBlock.TxCount)
+
+	var signatures []models.BlockSignature
+	err = suite.db.Where("block_id = ?", storedBlock.ID).Order("validator_index").Find(&signatures).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(signatures, 2)
+	suite.Require().NotNil(signatures[0].ValidatorConsAddressID)
+	suite.Assert().Equal(storedBlock.ProposerConsAddressID, *signatures[0].ValidatorConsAddressID)
+	suite.Assert().Equal(models.BlockSignatureNil, signatures[1].Flag)
+	suite.Assert().Nil(signatures[1].ValidatorConsAddressID)
+}
+
+func (suite *DBTestSuite) TestIndexNewBlockBatchSignatureAbsence() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	// The first block of a chain has no last commit, the validator at index 1 did not sign the commit of block 1
+	batch := []BlockTxsDBWrapper{
+		{Block: models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "validatoraddress1"}}},
+		{Block: models.Block{
+			Height:              2,
+			ChainID:             initChain.ID,
+			ProposerConsAddress: models.Address{Address: "validatoraddress1"},
+			LastCommitSignatures: []models.BlockSignature{
+				{ValidatorIndex: 0, CommitHeight: 1, ValidatorConsAddress: &models.Address{Address: "validatoraddress1"}, Flag: models.BlockSignatureCommit},
+				{ValidatorIndex: 1, CommitHeight: 1, Flag: models.BlockSignatureAbsent},
+				{ValidatorIndex: 2, CommitHeight: 1, ValidatorConsAddress: &models.Address{Address: "validatoraddress3"}, Flag: models.BlockSignatureNil},
+			},
+		}},
+	}
+
+	indexed, err := IndexNewBlockBatch(suite.db, batch, config.IndexConfig{})
+	suite.Require().NoError(err)
+
+	var count int64
+	err = suite.db.Model(&models.BlockSignature{}).Where("block_id = ?", indexed[0].Block.ID).Count(&count).Error
+	suite.Require().NoError(err)
+	suite.Assert().Zero(count)
+
+	var signatures []models.BlockSignature
+	err = suite.db.Preload("ValidatorConsAddress").Where("block_id = ?", indexed[1].Block.ID).Order("validator_index").Find(&signatures).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(signatures, 3)
+
+	suite.Assert().Equal(models.BlockSignatureCommit, signatures[0].Flag)
+	suite.Require().NotNil(signatures[0].ValidatorConsAddress)
+	suite.Assert().Equal("validatoraddress1", signatures[0].ValidatorConsAddress.Address)
+
+	// Absent signatures carry no validator address
+	suite.Assert().Equal(models.BlockSignatureAbsent, signatures[1].Flag)
+	suite.Assert().Equal(int64(1), signatures[1].CommitHeight)
+	suite.Assert().Nil(signatures[1].ValidatorConsAddressID)
+
+	suite.Assert().Equal(models.BlockSignatureNil, signatures[2].Flag)
+	suite.Require().NotNil(signatures[2].ValidatorConsAddress)
+	suite.Assert().Equal("validatoraddress3", signatures[2].ValidatorConsAddress.Address)
+	suite.Assert().Equal(int64(2), signatures[2].ValidatorConsAddress.FirstSeenHeight)
+}
+
+func (suite *DBTestSuite) TestIndexBlockEventsValidatorAndConsensusParamUpdates() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	params := json.RawMessage(`{"block":{"max_bytes":1,"max_gas":2}}`)
+	changedParams := json.RawMessage(`{"block":{"max_bytes":1,"max_gas":3}}`)
+
+	// The params of block 2 are unchanged and not recorded
+	for index, blockParams := range []json.RawMessage{params, params, changedParams} {
+		height := int64(index + 1)
+		blockDBWrapper := &BlockDBWrapper{
+			Block: &models.Block{Height: height, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}},
+			ValidatorUpdates: []models.ValidatorUpdate{
+				{Index: 0, ValidatorConsAddress: models.Address{Address: "validatoraddress"}, PubKeyType: "ed25519", PubKey: "cHVia2V5", Power: height * 10},
+			},
+			ConsensusParamUpdate: &models.ConsensusParamUpdate{Params: blockParams},
+		}
+		_, err = IndexBlockEvents(suite.db, false, blockDBWrapper, "")
+		suite.Require().NoError(err)
+	}
+
+	var validatorUpdates []models.ValidatorUpdate
+	err = suite.db.Preload("ValidatorConsAddress").Find(&validatorUpdates).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(validatorUpdates, 3)
+	suite.Assert().Equal("validatoraddress", validatorUpdates[0].ValidatorConsAddress.Address)
+
+	var heights []int64
+	err = suite.db.Table("consensus_param_updates").
+		Joins("JOIN blocks ON blocks.id = consensus_param_updates.block_id").
+		Order("blocks.height").
+		Pluck("blocks.height", &heights).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int64{1, 3}, heights)
+}
+
+func (suite *DBTestSuite) TestIndexBlockEventsBatchValidatorUpdateKeyChange() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	params := json.RawMessage(`{"block":{"max_bytes":1,"max_gas":2}}`)
+	blockDBWrapper := func(height int64, validatorUpdates ...models.ValidatorUpdate) *BlockDBWrapper {
+		return &BlockDBWrapper{
+			Block:                &models.Block{Height: height, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}},
+			ValidatorUpdates:     validatorUpdates,
+			ConsensusParamUpdate: &models.ConsensusParamUpdate{Params: params},
+		}
+	}
+
+	// Validator 2 is removed from the set at height 2
+	_, err = IndexBlockEventsBatch(suite.db, []*BlockDBWrapper{
+		blockDBWrapper(1,
+			models.ValidatorUpdate{Index: 0, ValidatorConsAddress: models.Address{Address: "validatoraddress1"}, PubKeyType: "ed25519", PubKey: "cHVia2V5MQ==", Power: 10},
+			models.ValidatorUpdate{Index: 1, ValidatorConsAddress: models.Address{Address: "validatoraddress2"}, PubKeyType: "ed25519", PubKey: "cHVia2V5Mg==", Power: 5},
+		),
+		blockDBWrapper(2,
+			models.ValidatorUpdate{Index: 0, ValidatorConsAddress: models.Address{Address: "validatoraddress2"}, PubKeyType: "ed25519", PubKey: "cHVia2V5Mg==", Power: 0},
+		),
+	})
+	suite.Require().NoError(err)
+
+	// Reindexing block 1 with a different key at index 0 updates the existing row
+	_, err = IndexBlockEventsBatch(suite.db, []*BlockDBWrapper{
+		blockDBWrapper(1,
+			models.ValidatorUpdate{Index: 0, ValidatorConsAddress: models.Address{Address: "validatoraddress3"}, PubKeyType: "secp256k1", PubKey: "cHVia2V5Mw==", Power: 20},
+			models.ValidatorUpdate{Index: 1, ValidatorConsAddress: models.Address{Address: "validatoraddress2"}, PubKeyType: "ed25519", PubKey: "cHVia2V5Mg==", Power: 5},
+		),
+	})
+	suite.Require().NoError(err)
+
+	var validatorUpdates []models.ValidatorUpdate
+	err = suite.db.Preload("Block").Preload("ValidatorConsAddress").
+		Joins("JOIN blocks ON blocks.id = validator_updates.block_id").
+		Order("blocks.height").Order("validator_updates.index").
+		Find(&validatorUpdates).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(validatorUpdates, 3)
+
+	suite.Assert().Equal(int64(1), validatorUpdates[0].Block.Height)
+	suite.Assert().Equal("validatoraddress3", validatorUpdates[0].ValidatorConsAddress.Address)
+	suite.Assert().Equal("secp256k1", validatorUpdates[0].PubKeyType)
+	suite.Assert().Equal("cHVia2V5Mw==", validatorUpdates[0].PubKey)
+	suite.Assert().Equal(int64(20), validatorUpdates[0].Power)
+
+	suite.Assert().Equal("validatoraddress2", validatorUpdates[1].ValidatorConsAddress.Address)
+	suite.Assert().Equal(int64(5), validatorUpdates[1].Power)
+
+	suite.Assert().Equal(int64(2), validatorUpdates[2].Block.Height)
+	suite.Assert().Equal("validatoraddress2", validatorUpdates[2].ValidatorConsAddress.Address)
+	suite.Assert().Zero(validatorUpdates[2].Power)
+
+	// The params of block 2 equal the params written for block 1 earlier in the same batch and are not recorded
+	var heights []int64
+	err = suite.db.Table("consensus_param_updates").
+		Joins("JOIN blocks ON blocks.id = consensus_param_updates.block_id").
+		Order("blocks.height").
+		Pluck("blocks.height", &heights).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int64{1}, heights)
+}
+
+func (suite *DBTestSuite) TestIndexNewBlockAddressMetadata() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	indexerConfig := config.IndexConfig{}
+	indexerConfig.Flags.IndexEmptyTransactions = true
+
+	signer := sdkTypes.AccAddress(make([]byte, 20)).String()
+	proposer := sdkTypes.ConsAddress(make([]byte, 20)).String()
+	feeCollector := authTypes.NewModuleAddress(authTypes.FeeCollectorName).String()
+
+	// Block 5 is indexed before block 3, the public key is only included in the first tx of the signer
+	for _, height := range []int64{5, 3} {
+		signerInfo := models.TxSignerInfo{SignerIndex: 0, Address: &models.Address{Address: signer}}
+		if height == 5 {
+			signerInfo.Address.PubKeyType = "/cosmos.crypto.secp256k1.PubKey"
+			signerInfo.Address.PubKey = "cHVia2V5"
+		}
+
+		block := models.Block{Height: height, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: proposer}}
+		txs := []TxDBWrapper{{
+			Tx: models.Tx{
+				Hash:              fmt.Sprintf("TXHASH%d", height),
+				FeePayerAddress:   &models.Address{Address: signer},
+				FeeGranterAddress: &models.Address{Address: feeCollector},
+				SignerInfos:       []models.TxSignerInfo{signerInfo},
+			},
+		}}
+
+		_, _, err = IndexNewBlock(suite.db, block, txs, nil, indexerConfig)
+		suite.Require().NoError(err)
+	}
+
+	var signerAddress models.Address
+	err = suite.db.Where("address = ?", signer).First(&signerAddress).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal("cosmos", signerAddress.Prefix)
+	suite.Assert().Equal(models.AddressKindAccount, signerAddress.Kind)
+	suite.Assert().Equal("/cosmos.crypto.secp256k1.PubKey", signerAddress.PubKeyType)
+	suite.Assert().Equal("cHVia2V5", signerAddress.PubKey)
+	suite.Assert().Equal(int64(3), signerAddress.FirstSeenHeight)
+	suite.Assert().Equal(int64(5), signerAddress.LastSeenHeight)
+
+	var proposerAddress models.Address
+	err = suite.db.Where("address = ?", proposer).First(&proposerAddress).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal("cosmosvalcons", proposerAddress.Prefix)
+	suite.Assert().Equal(models.AddressKindConsensus, proposerAddress.Kind)
+
+	var feeCollectorAddress models.Address
+	err = suite.db.Where("address = ?", feeCollector).First(&feeCollectorAddress).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal(models.AddressKindModule, feeCollectorAddress.Kind)
+}
+
+func (suite *DBTestSuite) TestIndexBatchAddressFirstAndLastSeen() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	indexerConfig := config.IndexConfig{}
+	indexerConfig.Flags.IndexEmptyTransactions = true
+
+	signer := sdkTypes.AccAddress(make([]byte, 20)).String()
+	proposer := sdkTypes.ConsAddress(make([]byte, 20)).String()
+
+	loadAddress := func(address string) models.Address {
+		var stored models.Address
+		err := suite.db.Where("address = ?", address).First(&stored).Error
+		suite.Require().NoError(err)
+		return stored
+	}
+
+	// Addresses created outside of block indexing have no seen heights yet
+	_, err = FindOrCreateAddressByAddress(suite.db, signer)
+	suite.Require().NoError(err)
+	suite.Assert().Zero(loadAddress(signer).FirstSeenHeight)
+
+	blockTxs := func(height int64, signerAddress models.Address) BlockTxsDBWrapper {
+		return BlockTxsDBWrapper{
+			Block: models.Block{Height: height, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: proposer}},
+			Txs: []TxDBWrapper{{Tx: models.Tx{
+				Hash:            fmt.Sprintf("TXHASH%d", height),
+				FeePayerAddress: &models.Address{Address: signer},
+				SignerInfos:     []models.TxSignerInfo{{SignerIndex: 0, Address: &signerAddress}},
+			}}},
+		}
+	}
+
+	// The heights of a batch are merged before the addresses are written, block 7 comes before block 4
+	_, err = IndexNewBlockBatch(suite.db, []BlockTxsDBWrapper{
+		blockTxs(7, models.Address{Address: signer, PubKeyType: "/cosmos.crypto.secp256k1.PubKey", PubKey: "cHVia2V5"}),
+		blockTxs(4, models.Address{Address: signer}),
+	}, indexerConfig)
+	suite.Require().NoError(err)
+
+	signerAddress := loadAddress(signer)
+	suite.Assert().Equal(int64(4), signerAddress.FirstSeenHeight)
+	suite.Assert().Equal(int64(7), signerAddress.LastSeenHeight)
+	suite.Assert().Equal("cHVia2V5", signerAddress.PubKey)
+
+	// A later batch without the public key widens the heights and keeps the key
+	_, err = IndexNewBlockBatch(suite.db, []BlockTxsDBWrapper{blockTxs(2, models.Address{Address: signer})}, indexerConfig)
+	suite.Require().NoError(err)
+
+	signerAddress = loadAddress(signer)
+	suite.Assert().Equal(int64(2), signerAddress.FirstSeenHeight)
+	suite.Assert().Equal(int64(7), signerAddress.LastSeenHeight)
+	suite.Assert().Equal("/cosmos.crypto.secp256k1.PubKey", signerAddress.PubKeyType)
+	suite.Assert().Equal("cHVia2V5", signerAddress.PubKey)
+
+	// Reindexing a block inside the seen range leaves the heights unchanged
+	_, err = IndexNewBlockBatch(suite.db, []BlockTxsDBWrapper{blockTxs(4, models.Address{Address: signer})}, indexerConfig)
+	suite.Require().NoError(err)
+
+	signerAddress = loadAddress(signer)
+	suite.Assert().Equal(int64(2), signerAddress.FirstSeenHeight)
+	suite.Assert().Equal(int64(7), signerAddress.LastSeenHeight)
+
+	// Proposers seen by the block events path are tracked the same way
+	_, err = IndexBlockEventsBatch(suite.db, []*BlockDBWrapper{
+		{Block: &models.Block{Height: 9, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: proposer}}},
+	})
+	suite.Require().NoError(err)
+
+	proposerAddress := loadAddress(proposer)
+	suite.Assert().Equal(int64(2), proposerAddress.FirstSeenHeight)
+	suite.Assert().Equal(int64(9), proposerAddress.LastSeenHeight)
+}
+
+func (suite *DBTestSuite) TestIndexNewBlockTxEvents() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	indexerConfig := config.IndexConfig{}
+	indexerConfig.Flags.IndexEmptyTransactions = true
+	indexerConfig.Flags.IndexTxEvents = true
+
+	// Reindexing the block overwrites the tx events instead of duplicating them
+	for _, fee := range []string{"100uatom", "200uatom"} {
+		eventType := models.MessageEventType{Type: "tx"}
+		attributeKey := models.MessageEventAttributeKey{Key: "fee"}
+
+		block := models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "cosmosvalcons1"}}
+		txs := []TxDBWrapper{{
+			Tx: models.Tx{Hash: "TXHASH", Code: 5},
+			TxEvents: []TxEventDBWrapper{{
+				TxEvent: models.TxEvent{Index: 0, MessageEventType: eventType},
+				Attributes: []models.TxEventAttribute{
+					{Index: 0, Value: fee, MessageEventAttributeKey: attributeKey},
+				},
+			}},
+			UniqueMessageEventTypes:    map[string]models.MessageEventType{eventType.Type: eventType},
+			UniqueMessageAttributeKeys: map[string]models.MessageEventAttributeKey{attributeKey.Key: attributeKey},
+		}}
+
+		_, _, err = IndexNewBlock(suite.db, block, txs, nil, indexerConfig)
+		suite.Require().NoError(err)
+	}
+
+	var txEvents []models.TxEvent
+	err = suite.db.Preload("MessageEventType").Find(&txEvents).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(txEvents, 1)
+	suite.Assert().Equal("tx", txEvents[0].MessageEventType.Type)
+
+	var attributes []models.TxEventAttribute
+	err = suite.db.Preload("MessageEventAttributeKey").Where("tx_event_id = ?", txEvents[0].ID).Find(&attributes).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(attributes, 1)
+	suite.Assert().Equal("fee", attributes[0].MessageEventAttributeKey.Key)
+	suite.Assert().Equal("200uatom", attributes[0].Value)
+}
+
+func (suite *DBTestSuite) TestIndexNewBlockBatchTxEventsOfFailedTxs() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	indexerConfig := config.IndexConfig{}
+	indexerConfig.Flags.IndexMessageEvents = true
+	indexerConfig.Flags.IndexTxEvents = true
+
+	txEventType := models.MessageEventType{Type: "tx"}
+	transferEventType := models.MessageEventType{Type: "transfer"}
+	feeKey := models.MessageEventAttributeKey{Key: "fee"}
+	accSeqKey := models.MessageEventAttributeKey{Key: "acc_seq"}
+	msgSendType := models.MessageType{MessageType: "/cosmos.bank.v1beta1.MsgSend"}
+
+	feeEvent := func(index uint64, fee string) TxEventDBWrapper {
+		return TxEventDBWrapper{
+			TxEvent:    models.TxEvent{Index: index, MessageEventType: txEventType},
+			Attributes: []models.TxEventAttribute{{Index: 0, Value: fee, MessageEventAttributeKey: feeKey}},
+		}
+	}
+
+	// Failed txes have no message logs, their fee and signature events are only emitted at the tx level
+	failedTx := TxDBWrapper{
+		Tx: models.Tx{Hash: "TXHASH1", Code: 11, Codespace: "sdk", RawLog: "out of gas"},
+		Messages: []MessageDBWrapper{{
+			Message: models.Message{MessageIndex: 0, MessageType: msgSendType},
+		}},
+		TxEvents: []TxEventDBWrapper{
+			feeEvent(0, "100uatom"),
+			{
+				TxEvent:    models.TxEvent{Index: 1, MessageEventType: txEventType},
+				Attributes: []models.TxEventAttribute{{Index: 0, Value: "cosmos1signer/4", MessageEventAttributeKey: accSeqKey}},
+			},
+		},
+		UniqueMessageTypes:         map[string]models.MessageType{msgSendType.MessageType: msgSendType},
+		UniqueMessageEventTypes:    map[string]models.MessageEventType{txEventType.Type: txEventType},
+		UniqueMessageAttributeKeys: map[string]models.MessageEventAttributeKey{feeKey.Key: feeKey, accSeqKey.Key: accSeqKey},
+	}
+
+	// A tx whose only message could not be decoded is kept for its failure and tx events
+	undecodedTx := TxDBWrapper{
+		Tx:                         models.Tx{Hash: "TXHASH2"},
+		FailedMessages:             []models.FailedMessage{{MessageIndex: 0, MessageTypeURL: "/unknown.v1.MsgUnknown", ErrorMessage: "message could not be decoded"}},
+		TxEvents:                   []TxEventDBWrapper{feeEvent(0, "200uatom")},
+		UniqueMessageEventTypes:    map[string]models.MessageEventType{txEventType.Type: txEventType},
+		UniqueMessageAttributeKeys: map[string]models.MessageEventAttributeKey{feeKey.Key: feeKey},
+	}
+
+	successfulTx := TxDBWrapper{
+		Tx: models.Tx{Hash: "TXHASH3"},
+		Messages: []MessageDBWrapper{{
+			Message: models.Message{MessageIndex: 0, MessageType: msgSendType},
+			MessageEvents: []MessageEventDBWrapper{{
+				MessageEvent: models.MessageEvent{Index: 0, MessageEventType: transferEventType},
+			}},
+		}},
+		TxEvents:                   []TxEventDBWrapper{feeEvent(0, "300uatom")},
+		UniqueMessageTypes:         map[string]models.MessageType{msgSendType.MessageType: msgSendType},
+		UniqueMessageEventTypes:    map[string]models.MessageEventType{txEventType.Type: txEventType, transferEventType.Type: transferEventType},
+		UniqueMessageAttributeKeys: map[string]models.MessageEventAttributeKey{feeKey.Key: feeKey},
+	}
+
+	_, err = IndexNewBlockBatch(suite.db, []BlockTxsDBWrapper{
+		{Block: models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}}, Txs: []TxDBWrapper{failedTx, undecodedTx}},
+		{Block: models.Block{Height: 2, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}}, Txs: []TxDBWrapper{successfulTx}},
+	}, indexerConfig)
+	suite.Require().NoError(err)
+
+	txEventCounts := func() map[string]int64 {
+		var rows []struct {
+			Hash  string
+			Count int64
+		}
+		err := suite.db.Table("tx_events").
+			Select("txes.hash, COUNT(*) AS count").
+			Joins("JOIN txes ON txes.id = tx_events.tx_id").
+			Group("txes.hash").
+			Scan(&rows).Error
+		suite.Require().NoError(err)
+
+		counts := make(map[string]int64)
+		for _, row := range rows {
+			counts[row.Hash] = row.Count
+		}
+		return counts
+	}
+
+	suite.Assert().Equal(map[string]int64{"TXHASH1": 2, "TXHASH2": 1, "TXHASH3": 1}, txEventCounts())
+
+	var attributes []models.TxEventAttribute
+	err = suite.db.Preload("MessageEventAttributeKey").
+		Joins("JOIN tx_events ON tx_events.id = tx_event_attributes.tx_event_id").
+		Joins("JOIN txes ON txes.id = tx_events.tx_id").
+		Where("txes.hash = ?", "TXHASH1").
+		Order("tx_events.index").
+		Find(&attributes).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(attributes, 2)
+	suite.Assert().Equal("100uatom", attributes[0].Value)
+	suite.Assert().Equal("acc_seq", attributes[1].MessageEventAttributeKey.Key)
+	suite.Assert().Equal("cosmos1signer/4", attributes[1].Value)
+
+	// Tx events share the normalized message event types with the message events
+	var eventTypes []string
+	err = suite.db.Model(&models.MessageEventType{}).Order("type").Pluck("type", &eventTypes).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]string{"transfer", "tx"}, eventTypes)
+
+	var messageEventCount int64
+	err = suite.db.Model(&models.MessageEvent{}).Count(&messageEventCount).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(1), messageEventCount)
+
+	// Tx events are not written when disabled
+	indexerConfig.Flags.IndexTxEvents = false
+	_, err = IndexNewBlockBatch(suite.db, []BlockTxsDBWrapper{
+		{Block: models.Block{Height: 3, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}}, Txs: []TxDBWrapper{{
+			Tx:                         models.Tx{Hash: "TXHASH4", Code: 11},
+			Messages:                   []MessageDBWrapper{{Message: models.Message{MessageIndex: 0, MessageType: msgSendType}}},
+			TxEvents:                   []TxEventDBWrapper{feeEvent(0, "400uatom")},
+			UniqueMessageTypes:         map[string]models.MessageType{msgSendType.MessageType: msgSendType},
+			UniqueMessageEventTypes:    map[string]models.MessageEventType{txEventType.Type: txEventType},
+			UniqueMessageAttributeKeys: map[string]models.MessageEventAttributeKey{feeKey.Key: feeKey},
+		}}},
+	}, indexerConfig)
+	suite.Require().NoError(err)
+
+	suite.Assert().NotContains(txEventCounts(), "TXHASH4")
+}
+
+func (suite *DBTestSuite) TestIndexNewBlockBalanceChanges() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	indexerConfig := config.IndexConfig{}
+	indexerConfig.Flags.IndexEmptyTransactions = true
+	indexerConfig.Flags.IndexMessageEvents = true
+	indexerConfig.Flags.IndexBalanceChanges = true
+
+	sender := sdkTypes.AccAddress(make([]byte, 20)).String()
+	receiver := sdkTypes.AccAddress(append(make([]byte, 19), 1)).String()
+
+	// Reindexing the block replaces the balance changes instead of duplicating them
+	for i := 0; i < 2; i++ {
+		eventType := models.MessageEventType{Type: "coin_spent"}
+		block := models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "cosmosvalcons1"}}
+		txs := []TxDBWrapper{{
+			Tx: models.Tx{Hash: "TXHASH"},
+			Messages: []MessageDBWrapper{{
+				Message: models.Message{MessageIndex: 0, MessageType: models.MessageType{MessageType: "/cosmos.bank.v1beta1.MsgSend"}},
+				MessageEvents: []MessageEventDBWrapper{{
+					MessageEvent: models.MessageEvent{Index: 0, MessageEventType: eventType},
+					BalanceChanges: []models.BalanceChange{
+						{Address: models.Address{Address: sender}, Denom: models.Denom{Base: "uatom"}, Amount: decimal.NewFromInt(-100)},
+						{Address: models.Address{Address: receiver}, Denom: models.Denom{Base: "uatom"}, Amount: decimal.NewFromInt(100)},
+					},
+				}},
+			}},
+			UniqueMessageTypes:      map[string]models.MessageType{"/cosmos.bank.v1beta1.MsgSend": {MessageType: "/cosmos.bank.v1beta1.MsgSend"}},
+			UniqueMessageEventTypes: map[string]models.MessageEventType{eventType.Type: eventType},
+		}}
+
+		_, _, err = IndexNewBlock(suite.db, block, txs, nil, indexerConfig)
+		suite.Require().NoError(err)
+	}
+
+	var balanceChanges []models.BalanceChange
+	err = suite.db.Preload("Address").Preload("Denom").Order("amount").Find(&balanceChanges).Error
+	suite.Require().NoError(err)
+	suite.Require().Len(balanceChanges, 2)
+
+	suite.Assert().Equal(sender, balanceChanges[0].Address.Address)
+	suite.Assert().Equal("uatom", balanceChanges[0].Denom.Base)
+	suite.Assert().Equal("-100", balanceChanges[0].Amount.String())
+	suite.Assert().Equal(int64(1), balanceChanges[0].Height)
+	suite.Assert().NotNil(balanceChanges[0].TxID)
+	suite.Assert().NotNil(balanceChanges[0].MessageEventID)
+	suite.Assert().Equal(receiver, balanceChanges[1].Address.Address)
+	suite.Assert().Equal("100", balanceChanges[1].Amount.String())
+
+	// Block event balance changes are replaced separately from the tx balance changes
+	eventType := models.BlockEventType{Type: "coin_received"}
+	blockEventsWrapper := func(changes []models.BalanceChange) *BlockDBWrapper {
+		return &BlockDBWrapper{
+			Block: &models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "cosmosvalcons1"}},
+			BeginBlockEvents: []BlockEventDBWrapper{{
+				BlockEvent:     models.BlockEvent{Index: 0, LifecyclePosition: models.BeginBlockEvent, BlockEventType: eventType},
+				BalanceChanges: changes,
+			}},
+			UniqueBlockEventTypes:         map[string]models.BlockEventType{eventType.Type: eventType},
+			UniqueBlockEventAttributeKeys: map[string]models.BlockEventAttributeKey{},
+			IndexBalanceChanges:           true,
+		}
+	}
+
+	_, err = IndexBlockEvents(suite.db, false, blockEventsWrapper([]models.BalanceChange{
+		{Address: models.Address{Address: receiver}, Denom: models.Denom{Base: "uatom"}, Amount: decimal.NewFromInt(5)},
+	}), "")
+	suite.Require().NoError(err)
+
+	var count int64
+	err = suite.db.Model(&models.BalanceChange{}).Where("block_event_id IS NOT NULL").Count(&count).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(1), count)
+
+	// Reindexing the block events without balance changes clears the stored block event changes, the tx changes are kept
+	_, err = IndexBlockEvents(suite.db, false, blockEventsWrapper(nil), "")
+	suite.Require().NoError(err)
+
+	err = suite.db.Model(&models.BalanceChange{}).Where("block_event_id IS NOT NULL").Count(&count).Error
+	suite.Require().NoError(err)
+	suite.Assert().Zero(count)
+
+	err = suite.db.Model(&models.BalanceChange{}).Where("tx_id IS NOT NULL").Count(&count).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(2), count)
+
+	// Reindexing the tx without balance changes clears its stored changes
+	msgType := models.MessageType{MessageType: "/cosmos.bank.v1beta1.MsgSend"}
+	txs := []TxDBWrapper{{
+		Tx: models.Tx{Hash: "TXHASH"},
+		Messages: []MessageDBWrapper{{
+			Message: models.Message{MessageIndex: 0, MessageType: msgType},
+		}},
+		UniqueMessageTypes: map[string]models.MessageType{msgType.MessageType: msgType},
+	}}
+	_, _, err = IndexNewBlock(suite.db, models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "cosmosvalcons1"}}, txs, nil, indexerConfig)
+	suite.Require().NoError(err)
+
+	err = suite.db.Model(&models.BalanceChange{}).Count(&count).Error
+	suite.Require().NoError(err)
+	suite.Assert().Zero(count)
+}
+
+func (suite *DBTestSuite) TestIndexBatchBalanceChangeReplacement() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	indexerConfig := config.IndexConfig{}
+	indexerConfig.Flags.IndexEmptyTransactions = true
+	indexerConfig.Flags.IndexTxEvents = true
+	indexerConfig.Flags.IndexBalanceChanges = true
+
+	payer := sdkTypes.AccAddress(make([]byte, 20)).String()
+	txEventType := models.MessageEventType{Type: "tx"}
+
+	block := func(height int64) models.Block {
+		return models.Block{Height: height, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "cosmosvalcons1"}}
+	}
+
+	// The fee of the tx is deducted in a tx event, the tip is a change of the tx itself
+	blockTxs := func(height int64, fee int64) BlockTxsDBWrapper {
+		return BlockTxsDBWrapper{
+			Block: block(height),
+			Txs: []TxDBWrapper{{
+				Tx: models.Tx{Hash: fmt.Sprintf("TXHASH%d", height)},
+				TxEvents: []TxEventDBWrapper{{
+					TxEvent:        models.TxEvent{Index: 0, MessageEventType: txEventType},
+					BalanceChanges: []models.BalanceChange{{Address: models.Address{Address: payer}, Denom: models.Denom{Base: "uatom"}, Amount: decimal.NewFromInt(-fee)}},
+				}},
+				BalanceChanges:          []models.BalanceChange{{Address: models.Address{Address: payer}, Denom: models.Denom{Base: "uatom"}, Amount: decimal.NewFromInt(-1)}},
+				UniqueMessageEventTypes: map[string]models.MessageEventType{txEventType.Type: txEventType},
+			}},
+		}
+	}
+
+	blockEvents := func(height int64, amount int64, indexBalanceChanges bool) *BlockDBWrapper {
+		eventType := models.BlockEventType{Type: "coin_received"}
+		var changes []models.BalanceChange
+		if indexBalanceChanges {
+			changes = []models.BalanceChange{{Address: models.Address{Address: payer}, Denom: models.Denom{Base: "uatom"}, Amount: decimal.NewFromInt(amount)}}
+		}
+
+		blockModel := block(height)
+		return &BlockDBWrapper{
+			Block: &blockModel,
+			EndBlockEvents: []BlockEventDBWrapper{{
+				BlockEvent:     models.BlockEvent{Index: 0, LifecyclePosition: models.EndBlockEvent, BlockEventType: eventType},
+				BalanceChanges: changes,
+			}},
+			UniqueBlockEventTypes:         map[string]models.BlockEventType{eventType.Type: eventType},
+			UniqueBlockEventAttributeKeys: map[string]models.BlockEventAttributeKey{},
+			IndexBalanceChanges:           indexBalanceChanges,
+		}
+	}
+
+	// amounts returns the stored amounts by height, tx changes are prefixed with tx and block event changes with block
+	amounts := func() map[int64][]string {
+		var balanceChanges []models.BalanceChange
+		err := suite.db.Order("height").Order("amount").Find(&balanceChanges).Error
+		suite.Require().NoError(err)
+
+		stored := make(map[int64][]string)
+		for _, change := range balanceChanges {
+			source := "tx"
+			if change.BlockEventID != nil {
+				source = "block"
+			}
+			stored[change.Height] = append(stored[change.Height], source+" "+change.Amount.String())
+		}
+		return stored
+	}
+
+	_, err = IndexNewBlockBatch(suite.db, []BlockTxsDBWrapper{blockTxs(1, 100), blockTxs(2, 200)}, indexerConfig)
+	suite.Require().NoError(err)
+
+	_, err = IndexBlockEventsBatch(suite.db, []*BlockDBWrapper{blockEvents(1, 10, true), blockEvents(2, 20, true)})
+	suite.Require().NoError(err)
+
+	suite.Assert().Equal(map[int64][]string{
+		1: {"tx -100", "tx -1", "block 10"},
+		2: {"tx -200", "tx -1", "block 20"},
+	}, amounts())
+
+	// Fee deductions reference the tx event they were derived from
+	var linkedCount int64
+	err = suite.db.Model(&models.BalanceChange{}).Where("tx_event_id IS NOT NULL").Count(&linkedCount).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(2), linkedCount)
+
+	// Reindexing block 1 only replaces the tx changes of block 1
+	_, err = IndexNewBlockBatch(suite.db, []BlockTxsDBWrapper{blockTxs(1, 150)}, indexerConfig)
+	suite.Require().NoError(err)
+
+	suite.Assert().Equal(map[int64][]string{
+		1: {"tx -150", "tx -1", "block 10"},
+		2: {"tx -200", "tx -1", "block 20"},
+	}, amounts())
+
+	// Block event changes are only replaced for the blocks of the batch they were derived for
+	_, err = IndexBlockEventsBatch(suite.db, []*BlockDBWrapper{blockEvents(1, 15, true), blockEvents(2, 0, false)})
+	suite.Require().NoError(err)
+
+	suite.Assert().Equal(map[int64][]string{
+		1: {"tx -150", "tx -1", "block 15"},
+		2: {"tx -200", "tx -1", "block 20"},
+	}, amounts())
+}
+
+func (suite *DBTestSuite) TestIndexNewBlockBatchDuplicateHeights() {
+	err := MigrateModels(suite.db)
+	suite.Require().NoError(err)
+
+	initChain := models.Chain{
+		ChainID: "testchain-1",
+	}
+
+	err = suite.db.Create(&initChain).Error
+	suite.Require().NoError(err)
+
+	indexerConfig := config.IndexConfig{}
+	indexerConfig.Flags.IndexEmptyTransactions = true
+
+	// Height 1 is passed twice, the last occurrence wins
+	batch := []BlockTxsDBWrapper{
+		{Block: models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}}, Txs: []TxDBWrapper{{Tx: models.Tx{Hash: "TXHASH1"}}}},
+		{Block: models.Block{Height: 2, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}}, Txs: []TxDBWrapper{{Tx: models.Tx{Hash: "TXHASH2"}}}},
+		{Block: models.Block{Height: 1, ChainID: initChain.ID, ProposerConsAddress: models.Address{Address: "proposeraddress"}}, Txs: []TxDBWrapper{{Tx: models.Tx{Hash: "TXHASH3"}}}},
+	}
+
+	indexed, err := IndexNewBlockBatch(suite.db, batch, indexerConfig)
+	suite.Require().NoError(err)
+	suite.Require().Len(indexed, 3)
+	suite.Assert().Equal(int64(2), indexed[1].Block.Height)
+	suite.Assert().NotZero(indexed[0].Block.ID)
+	suite.Assert().Equal(indexed[0].Block.ID, indexed[2].Block.ID)
+	suite.Assert().Equal("TXHASH3", indexed[0].Txs[0].Tx.Hash)
+
+	// The passed in batch is left untouched for the per block fallback
+	suite.Assert().Zero(batch[0].Block.ID)
+	suite.Assert().Zero(batch[0].Txs[0].Tx.ID)
+
+	var blockCount int64
+	err = suite.db.Model(&models.Block{}).Count(&blockCount).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(2), blockCount)
+
+	var hashes []string
+	err = suite.db.Model(&models.Tx{}).Order("hash").Pluck("hash", &hashes).Error
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]string{"TXHASH2", "TXHASH3"}, hashes)
+}
+
 func TestDBSuite(t *testing.T) {
 	suite.Run(t, new(DBTestSuite))
 }
@@ -7,172 +7,157 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// IndexBlockEvents indexes the BeginBlock and EndBlock events of a block.
+// It is a batch of one block, sharing every table writer with IndexBlockEventsBatch.
 func IndexBlockEvents(db *gorm.DB, dryRun bool, blockDBWrapper *BlockDBWrapper, identifierLoggingString string) (*BlockDBWrapper, error) {
-	err := db.Transaction(func(dbTransaction *gorm.DB) error {
-		if err := dbTransaction.
-			Exec("DELETE FROM failed_event_blocks WHERE height = ? AND blockchain_id = ?", blockDBWrapper.Block.Height, blockDBWrapper.Block.ChainID).
-			Error; err != nil {
-			config.Log.Error("Error updating failed block.", err)
-			return err
-		}
+	indexed, err := IndexBlockEventsBatch(db, []*BlockDBWrapper{blockDBWrapper})
 
-		consAddress, err := FindOrCreateAddressByAddress(dbTransaction, blockDBWrapper.Block.ProposerConsAddress.Address)
-		// create cons address if it doesn't exist
-		if err != nil {
-			config.Log.Error("Error getting/creating cons address DB object.", err)
-			return err
-		}
+	// Contract: ensure that wrapper has been loaded with all data before returning
+	return indexed[0], err
+}
 
-		// create block if it doesn't exist
-		blockDBWrapper.Block.ProposerConsAddressID = consAddress.ID
-		blockDBWrapper.Block.ProposerConsAddress = consAddress
+// indexBlockEvents writes the blocks and their BeginBlock and EndBlock events.
+// Proposer addresses, event types and attribute keys are created first so their IDs can be attached to the events.
+func indexBlockEvents(db *gorm.DB, batch []*BlockDBWrapper) error {
+	blocks := make([]*models.Block, len(batch))
+	for index, blockDBWrapper := range batch {
+		blocks[index] = blockDBWrapper.Block
+	}
 
-		// create block if it doesn't exist
-		blockDBWrapper.Block.BlockEventsIndexed = true
+	if err := deleteFailedBlocks(db, "failed_event_blocks", blocks); err != nil {
+		return err
+	}
 
-		if err := dbTransaction.
-			Where(models.Block{Height: blockDBWrapper.Block.Height, ChainID: blockDBWrapper.Block.ChainID}).
-			Assign(models.Block{BlockEventsIndexed: true, TimeStamp: blockDBWrapper.Block.TimeStamp, ProposerConsAddress: blockDBWrapper.Block.ProposerConsAddress}).
-			FirstOrCreate(&blockDBWrapper.Block).Error; err != nil {
-			config.Log.Error("Error getting/creating block DB object.", err)
-			return err
+	uniqueAddress := make(map[string]models.Address)
+	uniqueBlockEventTypes := make(map[string]models.BlockEventType)
+	uniqueBlockEventAttributeKeys := make(map[string]models.BlockEventAttributeKey)
+	for _, blockDBWrapper := range batch {
+		addAddress(uniqueAddress, blockDBWrapper.Block.ProposerConsAddress, blockDBWrapper.Block.Height)
+		for eventType := range blockDBWrapper.UniqueBlockEventTypes {
+			uniqueBlockEventTypes[eventType] = models.BlockEventType{Type: eventType}
 		}
+		for key := range blockDBWrapper.UniqueBlockEventAttributeKeys {
+			uniqueBlockEventAttributeKeys[key] = models.BlockEventAttributeKey{Key: key}
+		}
+	}
+
+	if err := upsertAddresses(db, uniqueAddress); err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		block.ProposerConsAddress = uniqueAddress[block.ProposerConsAddress.Address]
+		block.ProposerConsAddressID = block.ProposerConsAddress.ID
+		block.BlockEventsIndexed = true
+	}
 
-		var uniqueBlockEventTypes []models.BlockEventType
+	if err := upsertBlocks(db, blocks, "block_events_indexed", "time_stamp", "proposer_cons_address_id"); err != nil {
+		return err
+	}
 
-		for _, value := range blockDBWrapper.UniqueBlockEventTypes {
-			uniqueBlockEventTypes = append(uniqueBlockEventTypes, value)
+	if err := indexBlockSignatures(db, blocks...); err != nil {
+		return err
+	}
+
+	if err := indexValidatorAndConsensusParamUpdates(db, batch...); err != nil {
+		return err
+	}
+
+	if len(uniqueBlockEventTypes) != 0 {
+		var eventTypesSlice []models.BlockEventType
+		for _, eventType := range uniqueBlockEventTypes {
+			eventTypesSlice = append(eventTypesSlice, eventType)
 		}
 
-		if len(uniqueBlockEventTypes) != 0 {
-			// Bulk find or create on unique event types
-			if err := dbTransaction.Clauses(
-				clause.Returning{
-					Columns: []clause.Column{
-						{Name: "id"}, {Name: "type"},
-					},
-				},
-				clause.OnConflict{
-					Columns:   []clause.Column{{Name: "type"}},
-					DoUpdates: clause.AssignmentColumns([]string{"type"}),
-				},
-			).Create(&uniqueBlockEventTypes).Error; err != nil {
-				config.Log.Error("Error creating begin block event types.", err)
-				return err
-			}
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"type"}),
+		}).Create(&eventTypesSlice).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error creating block event types.", err)
+			return err
 		}
 
-		for _, value := range uniqueBlockEventTypes {
-			blockDBWrapper.UniqueBlockEventTypes[value.Type] = value
+		for _, eventType := range eventTypesSlice {
+			uniqueBlockEventTypes[eventType.Type] = eventType
 		}
+	}
 
-		var uniqueBlockEventAttributeKeys []models.BlockEventAttributeKey
+	if len(uniqueBlockEventAttributeKeys) != 0 {
+		var attributeKeysSlice []models.BlockEventAttributeKey
+		for _, attributeKey := range uniqueBlockEventAttributeKeys {
+			attributeKeysSlice = append(attributeKeysSlice, attributeKey)
+		}
 
-		for _, value := range blockDBWrapper.UniqueBlockEventAttributeKeys {
-			uniqueBlockEventAttributeKeys = append(uniqueBlockEventAttributeKeys, value)
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"key"}),
+		}).Create(&attributeKeysSlice).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error creating block event attribute keys.", err)
+			return err
 		}
 
-		if len(uniqueBlockEventAttributeKeys) != 0 {
-			if err := dbTransaction.Clauses(
-				clause.Returning{
-					Columns: []clause.Column{
-						{Name: "id"}, {Name: "key"},
-					},
-				},
-				clause.OnConflict{
-					Columns:   []clause.Column{{Name: "key"}},
-					DoUpdates: clause.AssignmentColumns([]string{"key"}),
-				},
-			).Create(&uniqueBlockEventAttributeKeys).Error; err != nil {
-				config.Log.Error("Error creating begin block event attribute keys.", err)
-				return err
-			}
+		for _, attributeKey := range attributeKeysSlice {
+			uniqueBlockEventAttributeKeys[attributeKey.Key] = attributeKey
 		}
+	}
 
-		for _, value := range uniqueBlockEventAttributeKeys {
-			blockDBWrapper.UniqueBlockEventAttributeKeys[value.Key] = value
-		}
-
-		// Loop through begin and end block arrays and apply the block ID and event type ID
-		beginBlockEvents := make([]*models.BlockEvent, len(blockDBWrapper.BeginBlockEvents))
-		for index := range blockDBWrapper.BeginBlockEvents {
-			blockDBWrapper.BeginBlockEvents[index].BlockEvent.Block = *blockDBWrapper.Block
-			blockDBWrapper.BeginBlockEvents[index].BlockEvent.BlockID = blockDBWrapper.Block.ID
-			blockDBWrapper.BeginBlockEvents[index].BlockEvent.BlockEventType = blockDBWrapper.UniqueBlockEventTypes[blockDBWrapper.BeginBlockEvents[index].BlockEvent.BlockEventType.Type]
-			beginBlockEvents[index] = &blockDBWrapper.BeginBlockEvents[index].BlockEvent
-		}
-
-		endBlockEvents := make([]*models.BlockEvent, len(blockDBWrapper.EndBlockEvents))
-		for index := range blockDBWrapper.EndBlockEvents {
-			blockDBWrapper.EndBlockEvents[index].BlockEvent.Block = *blockDBWrapper.Block
-			blockDBWrapper.EndBlockEvents[index].BlockEvent.BlockID = blockDBWrapper.Block.ID
-			blockDBWrapper.EndBlockEvents[index].BlockEvent.BlockEventType = blockDBWrapper.UniqueBlockEventTypes[blockDBWrapper.EndBlockEvents[index].BlockEvent.BlockEventType.Type]
-			endBlockEvents[index] = &blockDBWrapper.EndBlockEvents[index].BlockEvent
-		}
-
-		// Bulk insert the block events
-		allBlockEvents := make([]*models.BlockEvent, len(beginBlockEvents)+len(endBlockEvents))
-		copy(allBlockEvents, beginBlockEvents)
-		copy(allBlockEvents[len(beginBlockEvents):], endBlockEvents)
-
-		// TODO: Should consider the on conflict values here, do we want to provide the user with some control over the behavior here?
-		// Something similar to our reindex flag may be appropriate, unless we just want to have that pre-check the block has already been indexed.
-		if len(allBlockEvents) != 0 {
-			// This clause forces a return of ID for all items even on conflict
-			// We need this so that we can then create the proper associations with the attributes below
-			if err := dbTransaction.Clauses(
-				clause.OnConflict{
-					Columns: []clause.Column{{Name: "index"}, {Name: "lifecycle_position"}, {Name: "block_id"}},
-					// Force update of block event type ID
-					DoUpdates: clause.AssignmentColumns([]string{"block_event_type_id"}),
-				},
-			).Create(&allBlockEvents).Error; err != nil {
-				config.Log.Error("Error creating begin block events.", err)
-				return err
-			}
+	// Apply the block ID and event type ID to the begin and end block events
+	var allBlockEvents []*models.BlockEvent
+	for _, blockDBWrapper := range batch {
+		for eventType := range blockDBWrapper.UniqueBlockEventTypes {
+			blockDBWrapper.UniqueBlockEventTypes[eventType] = uniqueBlockEventTypes[eventType]
+		}
+		for key := range blockDBWrapper.UniqueBlockEventAttributeKeys {
+			blockDBWrapper.UniqueBlockEventAttributeKeys[key] = uniqueBlockEventAttributeKeys[key]
+		}
 
-			var allAttributes []*models.BlockEventAttribute
-			for index := range blockDBWrapper.BeginBlockEvents {
-				currAttributes := blockDBWrapper.BeginBlockEvents[index].Attributes
-				for attrIndex := range currAttributes {
-					currAttributes[attrIndex].BlockEventID = blockDBWrapper.BeginBlockEvents[index].BlockEvent.ID
-					currAttributes[attrIndex].BlockEvent = blockDBWrapper.BeginBlockEvents[index].BlockEvent
-					currAttributes[attrIndex].BlockEventAttributeKey = blockDBWrapper.UniqueBlockEventAttributeKeys[currAttributes[attrIndex].BlockEventAttributeKey.Key]
-				}
-				for ii := range currAttributes {
-					allAttributes = append(allAttributes, &currAttributes[ii])
-				}
+		for _, events := range [][]BlockEventDBWrapper{blockDBWrapper.BeginBlockEvents, blockDBWrapper.EndBlockEvents} {
+			for index := range events {
+				events[index].BlockEvent.Block = *blockDBWrapper.Block
+				events[index].BlockEvent.BlockID = blockDBWrapper.Block.ID
+				events[index].BlockEvent.BlockEventType = uniqueBlockEventTypes[events[index].BlockEvent.BlockEventType.Type]
+				allBlockEvents = append(allBlockEvents, &events[index].BlockEvent)
 			}
+		}
+	}
 
-			for index := range blockDBWrapper.EndBlockEvents {
-				currAttributes := blockDBWrapper.EndBlockEvents[index].Attributes
-				for attrIndex := range currAttributes {
-					currAttributes[attrIndex].BlockEventID = blockDBWrapper.EndBlockEvents[index].BlockEvent.ID
-					currAttributes[attrIndex].BlockEvent = blockDBWrapper.EndBlockEvents[index].BlockEvent
-					currAttributes[attrIndex].BlockEventAttributeKey = blockDBWrapper.UniqueBlockEventAttributeKeys[currAttributes[attrIndex].BlockEventAttributeKey.Key]
-				}
-				for ii := range currAttributes {
-					allAttributes = append(allAttributes, &currAttributes[ii])
-				}
-			}
+	if len(allBlockEvents) != 0 {
+		// The conflict update forces a return of the ID for all events, needed to associate the attributes below
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "index"}, {Name: "lifecycle_position"}, {Name: "block_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"block_event_type_id"}),
+		}).CreateInBatches(allBlockEvents, bulkInsertBatchSize).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error creating block events.", err)
+			return err
+		}
+	}
 
-			if len(allAttributes) != 0 {
-				if err := dbTransaction.Clauses(clause.OnConflict{
-					Columns: []clause.Column{{Name: "block_event_id"}, {Name: "index"}},
-					// Force update of value
-					DoUpdates: clause.AssignmentColumns([]string{"value"}),
-				}).Create(&allAttributes).Error; err != nil {
-					config.Log.Error("Error creating begin block event attributes.", err)
-					return err
+	var allAttributes []*models.BlockEventAttribute
+	for _, blockDBWrapper := range batch {
+		for _, events := range [][]BlockEventDBWrapper{blockDBWrapper.BeginBlockEvents, blockDBWrapper.EndBlockEvents} {
+			for index := range events {
+				currAttributes := events[index].Attributes
+				for attrIndex := range currAttributes {
+					currAttributes[attrIndex].BlockEventID = events[index].BlockEvent.ID
+					currAttributes[attrIndex].BlockEvent = events[index].BlockEvent
+					currAttributes[attrIndex].BlockEventAttributeKey = uniqueBlockEventAttributeKeys[currAttributes[attrIndex].BlockEventAttributeKey.Key]
+					allAttributes = append(allAttributes, &currAttributes[attrIndex])
 				}
 			}
 		}
+	}
 
-		return nil
-	})
+	if len(allAttributes) != 0 {
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "block_event_id"}, {Name: "index"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value"}),
+		}).CreateInBatches(allAttributes, bulkInsertBatchSize).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error creating block event attributes.", err)
+			return err
+		}
+	}
 
-	// Contract: ensure that wrapper has been loaded with all data before returning
-	return blockDBWrapper, err
+	return indexBlockEventBalanceChanges(db, batch...)
 }
 
 func IndexCustomBlockEvents(conf config.IndexConfig, db *gorm.DB, dryRun bool, blockDBWrapper *BlockDBWrapper, identifierLoggingString string, beginBlockParserTrackers map[string]models.BlockEventParser, endBlockParserTrackers map[string]models.BlockEventParser) error {
@@ -180,14 +165,14 @@ func IndexCustomBlockEvents(conf config.IndexConfig, db *gorm.DB, dryRun bool, b
 		// call generic function below
 		err := indexLifecycleCustomBlockEvents(dbTransaction, conf, blockDBWrapper, blockDBWrapper.BeginBlockEvents, beginBlockParserTrackers)
 		if err != nil {
-			config.Log.Error("Error indexing begin block events.", err)
+			config.LogCtx(dbTransaction.Statement.Context).Error("Error indexing begin block events.", err)
 			return err
 		}
 
 		// do the same here
 		err = indexLifecycleCustomBlockEvents(dbTransaction, conf, blockDBWrapper, blockDBWrapper.EndBlockEvents, endBlockParserTrackers)
 		if err != nil {
-			config.Log.Error("Error indexing end block events.", err)
+			config.LogCtx(dbTransaction.Statement.Context).Error("Error indexing end block events.", err)
 			return err
 		}
 
@@ -204,7 +189,7 @@ func indexLifecycleCustomBlockEvents(db *gorm.DB, conf config.IndexConfig, block
 				if parsedData.Parser != nil {
 					err := DeleteCustomBlockEventParserError(db, blockEvent.BlockEvent, parserTrackers[(*parsedData.Parser).Identifier()])
 					if err != nil {
-						config.Log.Error("Error clearing block event error.", err)
+						config.LogCtx(db.Statement.Context).Error("Error clearing block event error.", err)
 						return err
 					}
 				}
@@ -212,13 +197,13 @@ func indexLifecycleCustomBlockEvents(db *gorm.DB, conf config.IndexConfig, block
 				if parsedData.Error == nil && parsedData.Data != nil && parsedData.Parser != nil {
 					err := (*parsedData.Parser).IndexBlockEvent(parsedData.Data, db, *blockDBWrapper.Block, blockEvent.BlockEvent, blockEvent.Attributes, conf)
 					if err != nil {
-						config.Log.Error("Error indexing block event.", err)
+						config.LogCtx(db.Statement.Context).Error("Error indexing block event.", err)
 						return err
 					}
 				} else if parsedData.Error != nil {
 					err := CreateBlockEventParserError(db, blockEvent.BlockEvent, parserTrackers[(*parsedData.Parser).Identifier()], parsedData.Error)
 					if err != nil {
-						config.Log.Error("Error indexing block event error.", err)
+						config.LogCtx(db.Statement.Context).Error("Error indexing block event error.", err)
 						return err
 					}
 				}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ClaimFailedBlockRetries claims up to limit heights of the failed blocks table that are due for a background retry, so only one indexer
+// replica retries each height. A height is due once its backoff has passed since it last failed, the backoff doubles with every failed attempt
+// up to maxBackoff. Claimed heights are skipped by every replica until the claim expires after maxBackoff or the height fails again.
+// Rows locked by a concurrent claim are skipped rather than waited on. Heights that failed maxAttempts times are not claimed, 0 disables the limit.
+// Backoffs and claims are measured against the DB clock, so replicas with skewed clocks agree on which heights are due.
+func ClaimFailedBlockRetries(db *gorm.DB, table string, chainID uint, maxAttempts int64, backoff time.Duration, maxBackoff time.Duration, limit int) ([]int64, error) {
+	attemptsCondition := ""
+	if maxAttempts > 0 {
+		attemptsCondition = "AND attempts < @max_attempts"
+	}
+
+	// Rows recorded before failures were tracked have no failure time or attempts, they are always due
+	query := fmt.Sprintf(`UPDATE %[1]s SET retry_claimed_until = now() + make_interval(secs => @max_backoff)
+		WHERE id IN (
+			SELECT id FROM %[1]s
+			WHERE blockchain_id = @chain %[2]s
+			AND COALESCE(last_failed_at, 'epoch') + LEAST(@backoff * POWER(2, GREATEST(COALESCE(attempts, 1) - 1, 0)), @max_backoff) * INTERVAL '1 second' <= now()
+			AND (retry_claimed_until IS NULL OR retry_claimed_until <= now())
+			ORDER BY height ASC
+			LIMIT @limit
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING height`, table, attemptsCondition)
+
+	var heights []int64
+	err := db.Raw(query, map[string]any{
+		"chain":        chainID,
+		"max_attempts": maxAttempts,
+		"backoff":      backoff.Seconds(),
+		"max_backoff":  maxBackoff.Seconds(),
+		"limit":        limit,
+	}).Scan(&heights).Error
+
+	return heights, err
+}
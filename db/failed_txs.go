@@ -0,0 +1,79 @@
+package db
+
+import (
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// indexFailedTxs records the transactions of a block that could not be processed and removes the failures of transactions that are now indexed
+func indexFailedTxs(db *gorm.DB, txs []TxDBWrapper, failedTxs []models.FailedTx) error {
+	var indexedHashes []string
+	for _, tx := range txs {
+		indexedHashes = append(indexedHashes, tx.Tx.Hash)
+	}
+
+	if len(indexedHashes) != 0 {
+		if err := db.Where("hash IN ?", indexedHashes).Delete(&models.FailedTx{}).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error removing reprocessed failed txes.", err)
+			return err
+		}
+	}
+
+	if len(failedTxs) != 0 {
+		if err := db.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hash"}},
+			DoUpdates: clause.AssignmentColumns([]string{"block_id", "tx_bytes", "error_message"}),
+		}).CreateInBatches(failedTxs, bulkInsertBatchSize).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error creating failed txes.", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexFailedMessages replaces the failed messages of the indexed transactions, the transactions must already have their IDs set.
+// Messages that failed before and are now indexed are removed.
+func indexFailedMessages(db *gorm.DB, txs []TxDBWrapper) error {
+	var txIDs []uint
+	var failedMessages []models.FailedMessage
+	for _, tx := range txs {
+		if tx.Tx.ID == 0 {
+			continue
+		}
+
+		txIDs = append(txIDs, tx.Tx.ID)
+		for _, failedMessage := range tx.FailedMessages {
+			failedMessage.TxID = tx.Tx.ID
+			failedMessages = append(failedMessages, failedMessage)
+		}
+	}
+
+	if len(txIDs) != 0 {
+		if err := db.Where("tx_id IN ?", txIDs).Delete(&models.FailedMessage{}).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error removing reprocessed failed messages.", err)
+			return err
+		}
+	}
+
+	if len(failedMessages) != 0 {
+		if err := db.Omit(clause.Associations).CreateInBatches(failedMessages, bulkInsertBatchSize).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error creating failed messages.", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetFailedTxHeights returns the heights of the chain blocks that have failed transactions or failed messages, in ascending order
+func GetFailedTxHeights(db *gorm.DB, chainID uint, startHeight int64, endHeight int64) ([]int64, error) {
+	var heights []int64
+	err := db.Raw(`SELECT height FROM blocks WHERE chain_id = ? AND height >= ? AND (? = -1 OR height <= ?) AND (
+			EXISTS (SELECT 1 FROM failed_txes WHERE failed_txes.block_id = blocks.id)
+			OR EXISTS (SELECT 1 FROM failed_messages JOIN txes ON txes.id = failed_messages.tx_id WHERE txes.block_id = blocks.id)
+		) ORDER BY height ASC`, chainID, startHeight, endHeight, endHeight).Scan(&heights).Error
+	return heights, err
+}
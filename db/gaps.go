@@ -0,0 +1,137 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+// Reasons a range of heights is reported as a gap
+const (
+	GapMissing = "missing"
+	GapPartial = "partial"
+	GapFailed  = "failed"
+)
+
+// Number of heights read per query when scanning for gaps
+const gapScanSize = 10000
+
+// BlockGap is a range of heights that are missing from the DB or not fully indexed for the requested datasets
+type BlockGap struct {
+	StartHeight  int64  `json:"start_height"`
+	EndHeight    int64  `json:"end_height"`
+	Reason       string `json:"reason"`
+	Transactions bool   `json:"transactions"`
+	BlockEvents  bool   `json:"block_events"`
+}
+
+// Blocks returns the number of heights in the gap
+func (gap BlockGap) Blocks() int64 {
+	return gap.EndHeight - gap.StartHeight + 1
+}
+
+type gapBlock struct {
+	Height             int64
+	TxIndexed          bool
+	BlockEventsIndexed bool
+}
+
+// FindBlockGaps returns the ranges of heights between startHeight and endHeight that are missing from the blocks table,
+// are not indexed for one of the requested datasets, or are recorded in the failed blocks tables.
+// An endHeight of -1 scans up to the highest block in the DB for the chain.
+func FindBlockGaps(db *gorm.DB, chainID uint, startHeight int64, endHeight int64, txs bool, blockEvents bool) ([]BlockGap, error) {
+	if endHeight == -1 {
+		var highest *int64
+		err := db.Table("blocks").Where("chain_id = ?", chainID).Select("MAX(height)").Scan(&highest).Error
+		if err != nil {
+			return nil, err
+		}
+		if highest == nil {
+			return nil, nil
+		}
+		endHeight = *highest
+	}
+
+	var gaps []BlockGap
+	addGap := func(height int64, reason string, gapTxs bool, gapBlockEvents bool) {
+		if len(gaps) > 0 {
+			last := &gaps[len(gaps)-1]
+			if last.EndHeight == height-1 && last.Reason == reason && last.Transactions == gapTxs && last.BlockEvents == gapBlockEvents {
+				last.EndHeight = height
+				return
+			}
+		}
+		gaps = append(gaps, BlockGap{StartHeight: height, EndHeight: height, Reason: reason, Transactions: gapTxs, BlockEvents: gapBlockEvents})
+	}
+
+	for windowStart := startHeight; windowStart <= endHeight; windowStart += gapScanSize {
+		windowEnd := windowStart + gapScanSize - 1
+		if windowEnd > endHeight {
+			windowEnd = endHeight
+		}
+
+		var blocks []gapBlock
+		err := db.Table("blocks").Select("height, tx_indexed, block_events_indexed").
+			Where("chain_id = ? AND height >= ? AND height <= ?", chainID, windowStart, windowEnd).
+			Scan(&blocks).Error
+		if err != nil {
+			return nil, err
+		}
+
+		blocksByHeight := make(map[int64]gapBlock, len(blocks))
+		for _, block := range blocks {
+			blocksByHeight[block.Height] = block
+		}
+
+		failedTxHeights, err := failedHeightsInRange(db, "failed_blocks", chainID, windowStart, windowEnd, txs)
+		if err != nil {
+			return nil, err
+		}
+
+		failedEventHeights, err := failedHeightsInRange(db, "failed_event_blocks", chainID, windowStart, windowEnd, blockEvents)
+		if err != nil {
+			return nil, err
+		}
+
+		for height := windowStart; height <= windowEnd; height++ {
+			_, txFailed := failedTxHeights[height]
+			_, eventsFailed := failedEventHeights[height]
+			block, exists := blocksByHeight[height]
+
+			needsTxs, needsBlockEvents := txs, blockEvents
+			if exists {
+				needsTxs = txs && !block.TxIndexed
+				needsBlockEvents = blockEvents && !block.BlockEventsIndexed
+			}
+
+			switch {
+			case txFailed || eventsFailed:
+				// The failed datasets are indexed along with any dataset the block is still missing
+				addGap(height, GapFailed, txFailed || needsTxs, eventsFailed || needsBlockEvents)
+			case !exists:
+				addGap(height, GapMissing, needsTxs, needsBlockEvents)
+			case needsTxs || needsBlockEvents:
+				addGap(height, GapPartial, needsTxs, needsBlockEvents)
+			}
+		}
+	}
+
+	return gaps, nil
+}
+
+func failedHeightsInRange(db *gorm.DB, table string, chainID uint, startHeight int64, endHeight int64, enabled bool) (map[int64]struct{}, error) {
+	failedHeights := make(map[int64]struct{})
+	if !enabled {
+		return failedHeights, nil
+	}
+
+	var heights []int64
+	err := db.Table(table).Where("blockchain_id = ? AND height >= ? AND height <= ?", chainID, startHeight, endHeight).Pluck("height", &heights).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, height := range heights {
+		failedHeights[height] = struct{}{}
+	}
+
+	return failedHeights, nil
+}
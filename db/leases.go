@@ -0,0 +1,131 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"gorm.io/gorm"
+)
+
+// ErrBlockRangeLeaseLost is returned when a lease has expired and was claimed by another replica
+var ErrBlockRangeLeaseLost = errors.New("block range lease is no longer held")
+
+// Advisory lock class used to serialize lease claims, the second lock key is the chain ID
+const blockRangeLeaseLockKey = 0x6c656173
+
+// Lease expiries are computed and compared with the DB clock, so replicas with skewed clocks agree on when a lease expires
+const leaseExpiresAt = "now() + make_interval(secs => ?)"
+
+// ClaimBlockRangeLease claims a range of heights for the owner. An expired lease that was never completed is re-claimed first,
+// otherwise a new lease of up to rangeSize heights is created after the highest leased height, starting no lower than startHeight.
+// Returns nil if there is no range left to claim up to endHeight. The lease expires duration after the claim according to the DB clock.
+func ClaimBlockRangeLease(db *gorm.DB, chainID uint, owner string, startHeight int64, endHeight int64, rangeSize int64, duration time.Duration) (*models.BlockRangeLease, error) {
+	var lease *models.BlockRangeLease
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Replicas claiming at the same time would otherwise compute the same next range
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", blockRangeLeaseLockKey, chainID).Error; err != nil {
+			return err
+		}
+
+		var expired models.BlockRangeLease
+		err := tx.Where("chain_id = ? AND completed = false AND expires_at < now()", chainID).Order("start_height asc").Limit(1).Find(&expired).Error
+		if err != nil {
+			return err
+		}
+
+		if expired.ID != 0 {
+			lease = &models.BlockRangeLease{}
+			return tx.Raw(`UPDATE block_range_leases SET owner = ?, expires_at = `+leaseExpiresAt+`, updated_at = now() WHERE id = ? RETURNING *`,
+				owner, duration.Seconds(), expired.ID).Scan(lease).Error
+		}
+
+		var highest *int64
+		err = tx.Model(&models.BlockRangeLease{}).Where("chain_id = ?", chainID).Select("MAX(end_height)").Scan(&highest).Error
+		if err != nil {
+			return err
+		}
+
+		start := startHeight
+		if highest != nil && *highest+1 > start {
+			start = *highest + 1
+		}
+		if start > endHeight {
+			return nil
+		}
+
+		end := start + rangeSize - 1
+		if end > endHeight {
+			end = endHeight
+		}
+
+		lease = &models.BlockRangeLease{}
+		return tx.Raw(`INSERT INTO block_range_leases (chain_id, start_height, end_height, owner, expires_at, completed, created_at, updated_at)
+			VALUES (?, ?, ?, ?, `+leaseExpiresAt+`, false, now(), now()) RETURNING *`,
+			chainID, start, end, owner, duration.Seconds()).Scan(lease).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lease, nil
+}
+
+// RenewBlockRangeLease extends the expiry of a lease held by its owner to duration from now according to the DB clock,
+// returning ErrBlockRangeLeaseLost if the lease was claimed by another replica
+func RenewBlockRangeLease(db *gorm.DB, lease *models.BlockRangeLease, duration time.Duration) error {
+	var expiresAt []time.Time
+	err := db.Raw(`UPDATE block_range_leases SET expires_at = `+leaseExpiresAt+`, updated_at = now() WHERE id = ? AND owner = ? AND completed = false RETURNING expires_at`,
+		duration.Seconds(), lease.ID, lease.Owner).Scan(&expiresAt).Error
+	if err != nil {
+		return err
+	}
+	if len(expiresAt) == 0 {
+		return ErrBlockRangeLeaseLost
+	}
+
+	lease.ExpiresAt = expiresAt[0]
+	return nil
+}
+
+// CompleteBlockRangeLease releases a lease held by its owner by marking its range as done
+func CompleteBlockRangeLease(db *gorm.DB, lease *models.BlockRangeLease) error {
+	result := db.Model(&models.BlockRangeLease{}).
+		Where("id = ? AND owner = ? AND completed = false", lease.ID, lease.Owner).
+		Updates(map[string]any{"completed": true, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrBlockRangeLeaseLost
+	}
+
+	lease.Completed = true
+	return nil
+}
+
+// BlockRangeLeaseProcessed returns true if every height in the lease is indexed for the requested datasets or recorded as failed
+func BlockRangeLeaseProcessed(db *gorm.DB, lease *models.BlockRangeLease, txs bool, blockEvents bool) (bool, error) {
+	var processed int64
+	err := db.Raw(`SELECT COUNT(*) FROM (
+		SELECT height FROM blocks WHERE chain_id = ? AND height >= ? AND height <= ? AND (tx_indexed = true OR NOT ?) AND (block_events_indexed = true OR NOT ?)
+		UNION SELECT height FROM failed_blocks WHERE blockchain_id = ? AND height >= ? AND height <= ?
+		UNION SELECT height FROM failed_event_blocks WHERE blockchain_id = ? AND height >= ? AND height <= ?
+	) processed`,
+		lease.ChainID, lease.StartHeight, lease.EndHeight, txs, blockEvents,
+		lease.ChainID, lease.StartHeight, lease.EndHeight,
+		lease.ChainID, lease.StartHeight, lease.EndHeight,
+	).Scan(&processed).Error
+	if err != nil {
+		return false, err
+	}
+
+	return processed == lease.EndHeight-lease.StartHeight+1, nil
+}
+
+// CountIncompleteBlockRangeLeases returns the number of leases for the chain starting at or below endHeight that are not completed
+func CountIncompleteBlockRangeLeases(db *gorm.DB, chainID uint, endHeight int64) (int64, error) {
+	var count int64
+	err := db.Model(&models.BlockRangeLease{}).Where("chain_id = ? AND completed = false AND start_height <= ?", chainID, endHeight).Count(&count).Error
+	return count, err
+}
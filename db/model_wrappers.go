@@ -24,12 +24,17 @@ type BlockDBWrapper struct {
 	EndBlockEvents                []BlockEventDBWrapper
 	UniqueBlockEventTypes         map[string]models.BlockEventType
 	UniqueBlockEventAttributeKeys map[string]models.BlockEventAttributeKey
+	ValidatorUpdates              []models.ValidatorUpdate
+	ConsensusParamUpdate          *models.ConsensusParamUpdate
+	// Set when the balance changes of the block events were derived, the stored block event balance changes of the block are then replaced
+	IndexBalanceChanges bool
 }
 
 type BlockEventDBWrapper struct {
 	BlockEvent               models.BlockEvent
 	Attributes               []models.BlockEventAttribute
 	BlockEventParsedDatasets []parsers.BlockEventParsedData
+	BalanceChanges           []models.BalanceChange
 }
 
 // Store transactions with their messages for easy database creation
@@ -39,6 +44,15 @@ type TxDBWrapper struct {
 	UniqueMessageTypes         map[string]models.MessageType
 	UniqueMessageEventTypes    map[string]models.MessageEventType
 	UniqueMessageAttributeKeys map[string]models.MessageEventAttributeKey
+	FailedMessages             []models.FailedMessage
+	TxEvents                   []TxEventDBWrapper
+	// BalanceChanges derived from the tx itself rather than one of its events, the fee deduction of txes without tx events
+	BalanceChanges []models.BalanceChange
+}
+
+// IsEmpty returns true if the transaction has no messages to index, transactions with failed messages are kept so the failures can reference them
+func (tx TxDBWrapper) IsEmpty() bool {
+	return len(tx.Messages) == 0 && len(tx.FailedMessages) == 0
 }
 
 type MessageDBWrapper struct {
@@ -48,8 +62,15 @@ type MessageDBWrapper struct {
 }
 
 type MessageEventDBWrapper struct {
-	MessageEvent models.MessageEvent
-	Attributes   []models.MessageEventAttribute
+	MessageEvent   models.MessageEvent
+	Attributes     []models.MessageEventAttribute
+	BalanceChanges []models.BalanceChange
+}
+
+type TxEventDBWrapper struct {
+	TxEvent        models.TxEvent
+	Attributes     []models.TxEventAttribute
+	BalanceChanges []models.BalanceChange
 }
 
 type DenomDBWrapper struct {
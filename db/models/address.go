@@ -1,6 +1,91 @@
 package models
 
+import (
+	"strings"
+
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	authTypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"gorm.io/gorm"
+)
+
+// AddressKind is what an address belongs to, derived from its bech32 prefix and length
+type AddressKind string
+
+const (
+	AddressKindAccount           AddressKind = "account"
+	AddressKindValidatorOperator AddressKind = "validator_operator"
+	AddressKindConsensus         AddressKind = "consensus"
+	AddressKindModule            AddressKind = "module"
+	// AddressKindContract is any 32 byte account address, these are derived from a creator and are usually CosmWasm contracts
+	// but also interchain accounts and other module derived accounts
+	AddressKindContract AddressKind = "contract"
+)
+
+// Address is any bech32 address seen by the indexer. The first and last seen heights are the lowest and highest indexed block heights the address was seen in,
+// the public key is filled in once the address signs a transaction that includes it.
 type Address struct {
-	ID      uint
-	Address string `gorm:"uniqueIndex"`
+	ID         uint
+	Address    string      `gorm:"uniqueIndex"`
+	Prefix     string      `gorm:"index"`
+	Kind       AddressKind `gorm:"index"`
+	PubKeyType string
+	// PubKey is base64 encoded
+	PubKey          string
+	FirstSeenHeight int64 `gorm:"index"`
+	LastSeenHeight  int64 `gorm:"index"`
+}
+
+// BeforeCreate fills in the prefix and kind of the address if they are not set
+func (a *Address) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.Prefix == "" && a.Kind == "" {
+		a.Prefix, a.Kind = ClassifyAddress(a.Address)
+	}
+	return nil
+}
+
+// Module accounts hold no key, their address is the hash of the module name
+var moduleAccountAddresses = map[string]bool{}
+
+func init() {
+	RegisterModuleAccountNames(
+		authTypes.FeeCollectorName,
+		"distribution",
+		"mint",
+		"bonded_tokens_pool",
+		"not_bonded_tokens_pool",
+		"gov",
+		"transfer",
+		"interchainaccounts",
+		"feeibc",
+		"wasm",
+	)
+}
+
+// RegisterModuleAccountNames registers the names of chain specific module accounts so their addresses are classified as module addresses.
+// This must be called before indexing starts.
+func RegisterModuleAccountNames(names ...string) {
+	for _, name := range names {
+		moduleAccountAddresses[string(authTypes.NewModuleAddress(name))] = true
+	}
+}
+
+// ClassifyAddress returns the bech32 human-readable prefix and the kind of an address, both are empty if the address is not valid bech32
+func ClassifyAddress(address string) (string, AddressKind) {
+	prefix, bytes, err := bech32.DecodeAndConvert(address)
+	if err != nil {
+		return "", ""
+	}
+
+	switch {
+	case strings.HasSuffix(prefix, "valoper"):
+		return prefix, AddressKindValidatorOperator
+	case strings.HasSuffix(prefix, "valcons"):
+		return prefix, AddressKindConsensus
+	case moduleAccountAddresses[string(bytes)]:
+		return prefix, AddressKindModule
+	case len(bytes) == 32:
+		return prefix, AddressKindContract
+	}
+
+	return prefix, AddressKindAccount
 }
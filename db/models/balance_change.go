@@ -0,0 +1,26 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+// BalanceChange is a change of the balance of an address in a single denom, derived from a coin_spent (negative amount) or coin_received (positive amount) event.
+// Changes from BeginBlock and EndBlock events have no tx, changes from tx events and message events reference their tx.
+// The event a change was derived from is only referenced if that event is indexed, fee deductions derived from the tx fees reference no event.
+type BalanceChange struct {
+	ID             uint
+	BlockID        uint `gorm:"index"`
+	Block          Block
+	Height         int64 `gorm:"index:idx_balance_change_addr_denom_height,priority:3"`
+	TxID           *uint `gorm:"index"`
+	Tx             *Tx
+	MessageEventID *uint
+	MessageEvent   *MessageEvent
+	TxEventID      *uint
+	TxEvent        *TxEvent
+	BlockEventID   *uint
+	BlockEvent     *BlockEvent
+	AddressID      uint `gorm:"index:idx_balance_change_addr_denom_height,priority:1"`
+	Address        Address
+	DenomID        uint `gorm:"index:idx_balance_change_addr_denom_height,priority:2"`
+	Denom          Denom
+	Amount         decimal.Decimal `gorm:"type:decimal(78,0);"`
+}
@@ -15,6 +15,47 @@ type Block struct {
 	TxIndexed             bool
 	// TODO: Should block event indexing be split out or rolled up?
 	BlockEventsIndexed bool
+	BlockHeader
+	// LastCommitSignatures are written separately from the block by the indexing functions, see BlockSignature
+	LastCommitSignatures []BlockSignature `gorm:"-"`
+}
+
+// BlockHeader is the header data of a block, hashes are upper case hex encoded
+type BlockHeader struct {
+	Hash                 string `gorm:"index"`
+	AppHash              string
+	DataHash             string
+	LastBlockHash        string
+	LastBlockPartSetHash string
+	BlockVersion         uint64
+	AppVersion           uint64
+	TxCount              int
+}
+
+type BlockSignatureFlag string
+
+// The CometBFT block ID flags of a commit signature
+const (
+	// The validator did not sign
+	BlockSignatureAbsent BlockSignatureFlag = "absent"
+	// The validator voted for the block
+	BlockSignatureCommit BlockSignatureFlag = "commit"
+	// The validator voted nil
+	BlockSignatureNil BlockSignatureFlag = "nil"
+)
+
+// BlockSignature is a validator signature from the last commit included in a block, which commits the previous block at CommitHeight.
+// Absent signatures carry no validator address, the validator is only known by its index in the validator set of the committed height.
+type BlockSignature struct {
+	ID                     uint
+	BlockID                uint `gorm:"uniqueIndex:blockSignatureIndex,priority:1"`
+	Block                  Block
+	ValidatorIndex         int   `gorm:"uniqueIndex:blockSignatureIndex,priority:2"`
+	CommitHeight           int64 `gorm:"index"`
+	ValidatorConsAddressID *uint `gorm:"index:idx_block_signature_validator"`
+	ValidatorConsAddress   *Address
+	Flag                   BlockSignatureFlag
+	TimeStamp              time.Time
 }
 
 // Used to keep track of BeginBlock and EndBlock events
@@ -65,6 +106,7 @@ type FailedBlock struct {
 	Height       int64 `gorm:"uniqueIndex:failedchainheight"`
 	BlockchainID uint  `gorm:"uniqueIndex:failedchainheight"`
 	Chain        Chain `gorm:"foreignKey:BlockchainID"`
+	BlockFailure
 }
 
 type FailedEventBlock struct {
@@ -72,4 +114,17 @@ type FailedEventBlock struct {
 	Height       int64 `gorm:"uniqueIndex:failedchaineventheight"`
 	BlockchainID uint  `gorm:"uniqueIndex:failedchaineventheight"`
 	Chain        Chain `gorm:"foreignKey:BlockchainID"`
+	BlockFailure
+}
+
+// BlockFailure records why a block failed and how many times, the latest failure overwrites the code, stage and error message
+type BlockFailure struct {
+	Code          string
+	Stage         string
+	ErrorMessage  string
+	FirstFailedAt time.Time
+	LastFailedAt  time.Time
+	Attempts      int64
+	// Set while a background retry of the block is in the pipeline of one of the indexer replicas, the others skip the block until then
+	RetryClaimedUntil *time.Time
 }
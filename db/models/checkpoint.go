@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Checkpoint tracks the contiguous range of fully indexed block heights for a chain, separately for transactions and block events.
+// A height of 0 means nothing has been indexed for the dataset yet.
+type Checkpoint struct {
+	ID                            uint
+	ChainID                       uint `gorm:"uniqueIndex"`
+	Chain                         Chain
+	TxIndexedStartHeight          int64
+	TxIndexedHeight               int64
+	BlockEventsIndexedStartHeight int64
+	BlockEventsIndexedHeight      int64
+	UpdatedAt                     time.Time
+}
+
+// ResumeHeight returns the first height at or after startHeight that is not covered by the checkpoint for all of the enabled datasets
+func (checkpoint Checkpoint) ResumeHeight(startHeight int64, txIndexingEnabled bool, blockEventIndexingEnabled bool) int64 {
+	resumeHeight := int64(-1)
+
+	resumeFrom := func(rangeStart int64, rangeEnd int64) {
+		height := startHeight
+		if rangeEnd != 0 && rangeStart <= startHeight && startHeight <= rangeEnd {
+			height = rangeEnd + 1
+		}
+		if resumeHeight == -1 || height < resumeHeight {
+			resumeHeight = height
+		}
+	}
+
+	if txIndexingEnabled {
+		resumeFrom(checkpoint.TxIndexedStartHeight, checkpoint.TxIndexedHeight)
+	}
+
+	if blockEventIndexingEnabled {
+		resumeFrom(checkpoint.BlockEventsIndexedStartHeight, checkpoint.BlockEventsIndexedHeight)
+	}
+
+	if resumeHeight == -1 {
+		return startHeight
+	}
+
+	return resumeHeight
+}
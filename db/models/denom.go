@@ -3,4 +3,13 @@ package models
 type Denom struct {
 	ID   uint
 	Base string `gorm:"uniqueIndex"`
+	// IBC denoms (ibc/{hash}) are resolved to the transfer path and the denom on the source chain
+	IBCPath      string
+	IBCBaseDenom string `gorm:"index"`
+	// From the bank denom metadata of the chain, empty if the chain has no metadata for the denom
+	Display  string
+	Symbol   string
+	Exponent uint32
+	// MetadataResolved is set once an IBC trace or bank metadata has been found for the denom
+	MetadataResolved bool `gorm:"index"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// BlockRangeLease is a range of block heights claimed by an indexer replica when indexing is distributed over several replicas.
+// The owner renews the lease while the range is being indexed, expired leases that are not completed can be claimed by another replica.
+type BlockRangeLease struct {
+	ID          uint
+	ChainID     uint `gorm:"uniqueIndex:chainleasestart"`
+	Chain       Chain
+	StartHeight int64 `gorm:"uniqueIndex:chainleasestart"`
+	EndHeight   int64
+	Owner       string
+	ExpiresAt   time.Time `gorm:"index"`
+	Completed   bool      `gorm:"index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
@@ -1,27 +1,66 @@
 package models
 
 import (
+	"encoding/json"
+
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type Tx struct {
-	ID              uint
-	Hash            string `gorm:"uniqueIndex"`
-	Code            uint32
-	BlockID         uint
-	Block           Block
-	Memo            string
-	SignerAddresses []Address `gorm:"many2many:tx_signer_addresses;"`
-	Fees            []Fee
+	ID        uint
+	Hash      string `gorm:"uniqueIndex"`
+	Code      uint32
+	Codespace string
+	// RawLog is only kept for failed txes, the logs of successful txes are indexed as message events
+	RawLog              string
+	GasWanted           int64
+	GasUsed             int64
+	TimeoutHeight       uint64
+	TxIndex             int
+	BlockID             uint
+	Block               Block
+	Memo                string
+	SignerAddresses     []Address `gorm:"many2many:tx_signer_addresses;"`
+	SignerInfos         []TxSignerInfo
+	Fees                []Fee
+	FeePayerAddressID   *uint `gorm:"index:idx_fee_payer_addr"`
+	FeePayerAddress     *Address
+	FeeGranterAddressID *uint `gorm:"index:idx_fee_granter_addr"`
+	FeeGranterAddress   *Address
+}
+
+// TxSignerInfo is the auth info of a single tx signer, in the order of the tx signatures.
+// The address is only known when the signer info includes the signer public key.
+type TxSignerInfo struct {
+	ID          uint
+	TxID        uint  `gorm:"uniqueIndex:txSignerInfoIndex,priority:1"`
+	SignerIndex int   `gorm:"uniqueIndex:txSignerInfoIndex,priority:2"`
+	AddressID   *uint `gorm:"index:idx_signer_info_addr"`
+	Address     *Address
+	Sequence    uint64
+	SignMode    string
+}
+
+// This lifecycle function ensures the on conflict statement is added for TxSignerInfos which are associated to Txes by the Gorm slice association method for has_many
+func (b *TxSignerInfo) BeforeCreate(tx *gorm.DB) (err error) {
+	tx.Statement.AddClause(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tx_id"}, {Name: "signer_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"address_id", "sequence", "sign_mode"}),
+	})
+	return nil
 }
 
+// FailedTx is a transaction that could not be processed, the rest of its block is still indexed.
+// The raw transaction bytes are kept so the transaction can be reprocessed once it can be decoded.
 type FailedTx struct {
-	ID      uint
-	Hash    string `gorm:"uniqueIndex"`
-	BlockID uint
-	Block   Block
+	ID           uint
+	Hash         string `gorm:"uniqueIndex"`
+	BlockID      uint
+	Block        Block
+	TxBytes      []byte
+	ErrorMessage string
 }
 
 type Fee struct {
@@ -56,13 +95,19 @@ type Message struct {
 	MessageType   MessageType
 	MessageIndex  int `gorm:"uniqueIndex:messageIndex,priority:2"`
 	MessageBytes  []byte
+	MessageJSON   json.RawMessage `gorm:"type:jsonb;index:idx_messages_message_json,type:gin"`
 }
 
+// FailedMessage is a message that could not be decoded, usually because the proto definition of its type is not registered.
+// The rest of the transaction is still indexed.
 type FailedMessage struct {
-	ID           uint
-	MessageIndex int
-	TxID         uint
-	Tx           Tx
+	ID             uint
+	MessageIndex   int  `gorm:"uniqueIndex:failedMessageIndex,priority:2"`
+	TxID           uint `gorm:"uniqueIndex:failedMessageIndex,priority:1"`
+	Tx             Tx
+	MessageTypeURL string `gorm:"index"`
+	MessageBytes   []byte
+	ErrorMessage   string
 }
 
 type MessageEvent struct {
@@ -93,6 +138,28 @@ type MessageEventAttribute struct {
 	MessageEventAttributeKey   MessageEventAttributeKey
 }
 
+// TxEvent is an event of a transaction that belongs to no message, e.g. the fee deduction and the tx (acc_seq, signature) events of the ante handler.
+// Tx events share the event type and attribute key lookup tables of message events.
+type TxEvent struct {
+	ID uint
+	// Index refers to the position of the event among the tx events
+	Index              uint64 `gorm:"uniqueIndex:txEventIndex,priority:2"`
+	TxID               uint   `gorm:"uniqueIndex:txEventIndex,priority:1"`
+	Tx                 Tx
+	MessageEventTypeID uint
+	MessageEventType   MessageEventType
+}
+
+type TxEventAttribute struct {
+	ID                         uint
+	TxEvent                    TxEvent
+	TxEventID                  uint `gorm:"uniqueIndex:txEventAttributeIndex,priority:1"`
+	Value                      string
+	Index                      uint64 `gorm:"uniqueIndex:txEventAttributeIndex,priority:2"`
+	MessageEventAttributeKeyID uint
+	MessageEventAttributeKey   MessageEventAttributeKey
+}
+
 type MessageEventAttributeKey struct {
 	ID  uint
 	Key string `gorm:"uniqueIndex"`
@@ -0,0 +1,27 @@
+package models
+
+import "encoding/json"
+
+// ValidatorUpdate is a validator power change returned at the end of a block, the change applies to the validator set 2 blocks later.
+// A power of 0 removes the validator from the validator set.
+type ValidatorUpdate struct {
+	ID                     uint
+	BlockID                uint `gorm:"uniqueIndex:validatorUpdateIndex,priority:1"`
+	Block                  Block
+	Index                  int  `gorm:"uniqueIndex:validatorUpdateIndex,priority:2"`
+	ValidatorConsAddressID uint `gorm:"index:idx_validator_update_addr"`
+	ValidatorConsAddress   Address
+	PubKeyType             string
+	// PubKey is base64 encoded
+	PubKey string
+	Power  int64
+}
+
+// ConsensusParamUpdate is a consensus parameter change returned at the end of a block, applied from the next block on.
+// Only the changed parameter groups (block, evidence, validator, version) are set in the JSON params, although Cosmos SDK chains always return all of them.
+type ConsensusParamUpdate struct {
+	ID      uint
+	BlockID uint `gorm:"uniqueIndex"`
+	Block   Block
+	Params  json.RawMessage `gorm:"type:jsonb"`
+}
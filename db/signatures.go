@@ -0,0 +1,55 @@
+package db
+
+import (
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// blockHeaderColumns are the block columns filled from the block header, updated whenever a block is reindexed
+var blockHeaderColumns = []string{"hash", "app_hash", "data_hash", "last_block_hash", "last_block_part_set_hash", "block_version", "app_version", "tx_count"}
+
+// indexBlockSignatures writes the last commit signatures of already created blocks, overwriting the signatures of reindexed blocks
+func indexBlockSignatures(db *gorm.DB, blocks ...*models.Block) error {
+	uniqueAddress := make(map[string]models.Address)
+	for _, block := range blocks {
+		for _, signature := range block.LastCommitSignatures {
+			if signature.ValidatorConsAddress != nil {
+				addAddress(uniqueAddress, *signature.ValidatorConsAddress, block.Height)
+			}
+		}
+	}
+
+	if err := upsertAddresses(db, uniqueAddress); err != nil {
+		return err
+	}
+
+	// Signatures are copied so the IDs of a rolled back write do not leak into the blocks
+	var signatures []models.BlockSignature
+	for _, block := range blocks {
+		for _, signature := range block.LastCommitSignatures {
+			signature.BlockID = block.ID
+			if signature.ValidatorConsAddress != nil {
+				addressID := uniqueAddress[signature.ValidatorConsAddress.Address].ID
+				signature.ValidatorConsAddressID = &addressID
+				signature.ValidatorConsAddress = nil
+			}
+			signatures = append(signatures, signature)
+		}
+	}
+
+	if len(signatures) == 0 {
+		return nil
+	}
+
+	if err := db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "block_id"}, {Name: "validator_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"commit_height", "validator_cons_address_id", "flag", "time_stamp"}),
+	}).CreateInBatches(signatures, bulkInsertBatchSize).Error; err != nil {
+		config.LogCtx(db.Statement.Context).Error("Error creating block signatures.", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// indexTxEvents writes the tx events of already created txes, overwriting the events of reindexed txes.
+// The event types and attribute keys must already be created in the message event lookup tables. The created events are returned.
+func indexTxEvents(db *gorm.DB, txs []TxDBWrapper, eventTypes map[string]models.MessageEventType, attributeKeys map[string]models.MessageEventAttributeKey) ([]models.TxEvent, error) {
+	// Events are copied so the IDs of a rolled back write do not leak into the wrappers
+	var txEvents []models.TxEvent
+	var txEventAttributes [][]models.TxEventAttribute
+	for _, tx := range txs {
+		if tx.Tx.ID == 0 {
+			continue
+		}
+
+		for _, txEvent := range tx.TxEvents {
+			event := txEvent.TxEvent
+			event.TxID = tx.Tx.ID
+			event.MessageEventTypeID = eventTypes[event.MessageEventType.Type].ID
+			txEvents = append(txEvents, event)
+			txEventAttributes = append(txEventAttributes, txEvent.Attributes)
+		}
+	}
+
+	if len(txEvents) == 0 {
+		return nil, nil
+	}
+
+	if err := db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tx_id"}, {Name: "index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"message_event_type_id"}),
+	}).CreateInBatches(txEvents, bulkInsertBatchSize).Error; err != nil {
+		config.LogCtx(db.Statement.Context).Error("Error getting/creating tx events.", err)
+		return nil, err
+	}
+
+	var attributes []models.TxEventAttribute
+	for eventIndex, eventAttributes := range txEventAttributes {
+		for _, attribute := range eventAttributes {
+			attribute.TxEventID = txEvents[eventIndex].ID
+			attribute.MessageEventAttributeKeyID = attributeKeys[attribute.MessageEventAttributeKey.Key].ID
+			attributes = append(attributes, attribute)
+		}
+	}
+
+	if len(attributes) == 0 {
+		return txEvents, nil
+	}
+
+	if err := db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tx_event_id"}, {Name: "index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "message_event_attribute_key_id"}),
+	}).CreateInBatches(attributes, bulkInsertBatchSize).Error; err != nil {
+		config.LogCtx(db.Statement.Context).Error("Error getting/creating tx event attributes.", err)
+		return nil, err
+	}
+
+	return txEvents, nil
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// indexValidatorAndConsensusParamUpdates writes the validator and consensus param updates of already created blocks, overwriting the updates of reindexed blocks
+func indexValidatorAndConsensusParamUpdates(db *gorm.DB, blockDBWrappers ...*BlockDBWrapper) error {
+	uniqueAddress := make(map[string]models.Address)
+	for _, blockDBWrapper := range blockDBWrappers {
+		for _, validatorUpdate := range blockDBWrapper.ValidatorUpdates {
+			addAddress(uniqueAddress, validatorUpdate.ValidatorConsAddress, blockDBWrapper.Block.Height)
+		}
+	}
+
+	if err := upsertAddresses(db, uniqueAddress); err != nil {
+		return err
+	}
+
+	// Updates are copied so the IDs of a rolled back write do not leak into the wrappers
+	var validatorUpdates []models.ValidatorUpdate
+	for _, blockDBWrapper := range blockDBWrappers {
+		for _, validatorUpdate := range blockDBWrapper.ValidatorUpdates {
+			validatorUpdate.BlockID = blockDBWrapper.Block.ID
+			validatorUpdate.ValidatorConsAddressID = uniqueAddress[validatorUpdate.ValidatorConsAddress.Address].ID
+			validatorUpdates = append(validatorUpdates, validatorUpdate)
+		}
+	}
+
+	if len(validatorUpdates) != 0 {
+		if err := db.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "block_id"}, {Name: "index"}},
+			DoUpdates: clause.AssignmentColumns([]string{"validator_cons_address_id", "pub_key_type", "pub_key", "power"}),
+		}).CreateInBatches(validatorUpdates, bulkInsertBatchSize).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error creating validator updates.", err)
+			return err
+		}
+	}
+
+	// Consensus param updates are written one block at a time, each one is compared against the updates written before it
+	for _, blockDBWrapper := range blockDBWrappers {
+		if blockDBWrapper.ConsensusParamUpdate == nil {
+			continue
+		}
+
+		unchanged, err := consensusParamsUnchanged(db, blockDBWrapper.Block, blockDBWrapper.ConsensusParamUpdate.Params)
+		if err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error getting previous consensus param update.", err)
+			return err
+		}
+		if unchanged {
+			continue
+		}
+
+		consensusParamUpdate := *blockDBWrapper.ConsensusParamUpdate
+		consensusParamUpdate.BlockID = blockDBWrapper.Block.ID
+		if err := db.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "block_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"params"}),
+		}).Create(&consensusParamUpdate).Error; err != nil {
+			config.LogCtx(db.Statement.Context).Error("Error creating consensus param update.", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// consensusParamsUnchanged checks if the params equal the closest update recorded below the block height.
+// Cosmos SDK chains return the full consensus params at the end of every block, so only changes are recorded.
+// Blocks that are not indexed yet are not taken into account, reindexing the chain in order gives the exact timeline.
+func consensusParamsUnchanged(db *gorm.DB, block *models.Block, params []byte) (bool, error) {
+	var unchanged []bool
+	err := db.Raw(`
+		SELECT consensus_param_updates.params = ?::jsonb
+		FROM consensus_param_updates
+		JOIN blocks ON blocks.id = consensus_param_updates.block_id
+		WHERE blocks.chain_id = ? AND blocks.height < ?
+		ORDER BY blocks.height DESC
+		LIMIT 1
+	`, string(params), block.ChainID, block.Height).Scan(&unchanged).Error
+	if err != nil {
+		return false, err
+	}
+
+	return len(unchanged) != 0 && unchanged[0], nil
+}
@@ -0,0 +1,198 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/rpc"
+	"gorm.io/gorm"
+)
+
+// chainCommit tracks the last block committed to the DB for a chain
+type chainCommit struct {
+	height atomic.Int64
+	time   atomic.Int64 // unix nanoseconds
+}
+
+// Max time spent checking the RPC endpoints of a chain
+const rpcCheckTimeout = 5 * time.Second
+
+// Last commits by chain ID
+var commits sync.Map
+
+// RecordCommit records a block height of the chain that was successfully committed to the DB
+func RecordCommit(chainID string, height int64) {
+	commit, _ := commits.LoadOrStore(chainID, &chainCommit{})
+	commit.(*chainCommit).height.Store(height)
+	commit.(*chainCommit).time.Store(time.Now().UnixNano())
+}
+
+type DependencyStatus struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type RPCStatus struct {
+	DependencyStatus
+	CatchingUp bool `json:"catching_up"`
+}
+
+type ChainStatus struct {
+	RPC                    *RPCStatus `json:"rpc,omitempty"`
+	LastCommittedHeight    int64      `json:"last_committed_height"`
+	LastCommitTime         *time.Time `json:"last_commit_time,omitempty"`
+	SecondsSinceLastCommit float64    `json:"seconds_since_last_commit"`
+	Stale                  bool       `json:"stale"`
+}
+
+type Status struct {
+	OK       bool                    `json:"ok"`
+	Database DependencyStatus        `json:"database"`
+	Chains   map[string]*ChainStatus `json:"chains"`
+}
+
+// Checker reports the health of the indexer and its dependencies.
+// Liveness only depends on DB connectivity. Readiness also requires, for every chain, an RPC endpoint in the pool to be reachable and a block to have been committed
+// within MaxCommitStaleness, a zero MaxCommitStaleness disables the staleness check.
+type Checker struct {
+	DB                 *gorm.DB
+	MaxCommitStaleness time.Duration
+	startTime          time.Time
+	mu                 sync.RWMutex
+	rpcPools           map[string]*rpc.Pool
+}
+
+func NewChecker(db *gorm.DB, maxCommitStaleness time.Duration) *Checker {
+	return &Checker{
+		DB:                 db,
+		MaxCommitStaleness: maxCommitStaleness,
+		startTime:          time.Now(),
+		rpcPools:           map[string]*rpc.Pool{},
+	}
+}
+
+// AddChain adds a chain being indexed to the health checks
+func (c *Checker) AddChain(chainID string, rpcPool *rpc.Pool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rpcPools[chainID] = rpcPool
+}
+
+func (c *Checker) Liveness(ctx context.Context) Status {
+	status := Status{Database: c.databaseStatus(ctx), Chains: map[string]*ChainStatus{}}
+	now := time.Now()
+	for chainID := range c.chains() {
+		status.Chains[chainID] = c.commitStatus(chainID, now)
+	}
+	status.OK = status.Database.OK
+	return status
+}
+
+func (c *Checker) Readiness(ctx context.Context) Status {
+	status := Status{Database: c.databaseStatus(ctx), Chains: map[string]*ChainStatus{}}
+	status.OK = status.Database.OK
+
+	now := time.Now()
+	for chainID, rpcPool := range c.chains() {
+		chainStatus := c.commitStatus(chainID, now)
+		rpcStatus := c.rpcStatus(ctx, rpcPool)
+		chainStatus.RPC = &rpcStatus
+		status.Chains[chainID] = chainStatus
+		status.OK = status.OK && rpcStatus.OK && !chainStatus.Stale
+	}
+
+	return status
+}
+
+// chains returns a copy of the chains being checked so checks do not hold the lock during requests
+func (c *Checker) chains() map[string]*rpc.Pool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	chains := make(map[string]*rpc.Pool, len(c.rpcPools))
+	for chainID, rpcPool := range c.rpcPools {
+		chains[chainID] = rpcPool
+	}
+	return chains
+}
+
+func (c *Checker) databaseStatus(ctx context.Context) DependencyStatus {
+	sqlDB, err := c.DB.DB()
+	if err != nil {
+		return DependencyStatus{Error: err.Error()}
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := sqlDB.PingContext(pingCtx); err != nil {
+		return DependencyStatus{Error: err.Error()}
+	}
+
+	return DependencyStatus{OK: true}
+}
+
+// rpcStatus checks that an endpoint of the pool responds, making a single pass over the endpoints so probes are not held up by the indexer retry policy
+func (c *Checker) rpcStatus(ctx context.Context, rpcPool *rpc.Pool) RPCStatus {
+	checkCtx, cancel := context.WithTimeout(ctx, rpcCheckTimeout)
+	defer cancel()
+
+	var catchingUp bool
+	err := rpcPool.Failover(checkCtx, func(endpoint *rpc.Endpoint) error {
+		var err error
+		catchingUp, err = rpc.IsCatchingUp(endpoint.ChainClient)
+		return err
+	})
+	if err != nil {
+		return RPCStatus{DependencyStatus: DependencyStatus{Error: err.Error()}}
+	}
+
+	return RPCStatus{DependencyStatus: DependencyStatus{OK: true}, CatchingUp: catchingUp}
+}
+
+// commitStatus reports the last committed block of the chain, measuring staleness from the checker start time if nothing has been committed yet
+func (c *Checker) commitStatus(chainID string, now time.Time) *ChainStatus {
+	status := &ChainStatus{}
+
+	lastCommit := c.startTime
+	if commit, ok := commits.Load(chainID); ok {
+		if commitNanos := commit.(*chainCommit).time.Load(); commitNanos != 0 {
+			commitTime := time.Unix(0, commitNanos)
+			lastCommit = commitTime
+			status.LastCommitTime = &commitTime
+			status.LastCommittedHeight = commit.(*chainCommit).height.Load()
+		}
+	}
+
+	sinceLastCommit := now.Sub(lastCommit)
+	status.SecondsSinceLastCommit = sinceLastCommit.Seconds()
+	status.Stale = c.MaxCommitStaleness > 0 && sinceLastCommit > c.MaxCommitStaleness
+
+	return status
+}
+
+func (c *Checker) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, c.Liveness(r.Context()))
+}
+
+func (c *Checker) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, c.Readiness(r.Context()))
+}
+
+func writeStatus(w http.ResponseWriter, status Status) {
+	w.Header().Set("Content-Type", "application/json")
+	if status.OK {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		config.Log.Error("Error writing health status response", err)
+	}
+}
@@ -0,0 +1,195 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/rpc"
+	probeClient "github.com/DefiantLabs/probe/client"
+	rpchttp "github.com/cometbft/cometbft/rpc/client/http"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// pingConnector is a database connector whose connections only answer pings, connecting fails with err if set
+type pingConnector struct {
+	err error
+}
+
+func (c pingConnector) Connect(context.Context) (driver.Conn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return pingConn{}, nil
+}
+
+func (c pingConnector) Driver() driver.Driver {
+	return c
+}
+
+func (c pingConnector) Open(string) (driver.Conn, error) {
+	return c.Connect(context.Background())
+}
+
+type pingConn struct{}
+
+func (pingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (pingConn) Close() error                        { return nil }
+func (pingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+func (pingConn) Ping(context.Context) error          { return nil }
+
+func newTestDB(t *testing.T, connectErr error) *gorm.DB {
+	sqlDB := sql.OpenDB(pingConnector{err: connectErr})
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{DisableAutomaticPing: true})
+	require.NoError(t, err)
+	return db
+}
+
+// newTestPool returns a pool with a single endpoint whose node answers status requests, or fails every request if up is false
+func newTestPool(t *testing.T, up bool) *rpc.Pool {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "node down", http.StatusInternalServerError)
+			return
+		}
+
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"sync_info":{"latest_block_height":"10","latest_block_time":"2024-01-01T00:00:00Z","catching_up":true}}}`, req.ID)
+	}))
+	t.Cleanup(server.Close)
+
+	rpcClient, err := rpchttp.New(server.URL, "/websocket")
+	require.NoError(t, err)
+
+	chainClient := &probeClient.ChainClient{Config: &probeClient.ChainClientConfig{RPCAddr: server.URL, Timeout: "5s"}, RPCClient: rpcClient}
+	pool, err := rpc.NewPool([]*rpc.Endpoint{{Address: server.URL, ChainClient: chainClient}}, rpc.PoolOptions{RetryAttempts: -1})
+	require.NoError(t, err)
+	return pool
+}
+
+func TestReadinessHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		dbDown     bool
+		rpcDown    bool
+		staleness  time.Duration
+		commit     bool
+		status     int
+		databaseOK bool
+		rpcOK      bool
+		stale      bool
+	}{
+		{name: "ready", commit: true, staleness: time.Hour, status: http.StatusOK, databaseOK: true, rpcOK: true},
+		{name: "staleness check disabled", status: http.StatusOK, databaseOK: true, rpcOK: true},
+		{name: "stale", commit: true, staleness: time.Nanosecond, status: http.StatusServiceUnavailable, databaseOK: true, rpcOK: true, stale: true},
+		{name: "stale without commits", staleness: time.Nanosecond, status: http.StatusServiceUnavailable, databaseOK: true, rpcOK: true, stale: true},
+		{name: "db down", dbDown: true, commit: true, staleness: time.Hour, status: http.StatusServiceUnavailable, rpcOK: true},
+		{name: "rpc down", rpcDown: true, commit: true, staleness: time.Hour, status: http.StatusServiceUnavailable, databaseOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var connectErr error
+			if tt.dbDown {
+				connectErr = errors.New("connection refused")
+			}
+
+			// Commits are tracked globally, every case uses its own chain
+			chainID := "health-" + tt.name
+			checker := NewChecker(newTestDB(t, connectErr), tt.staleness)
+			checker.AddChain(chainID, newTestPool(t, !tt.rpcDown))
+			if tt.commit {
+				RecordCommit(chainID, 42)
+			}
+			time.Sleep(time.Millisecond)
+
+			start := time.Now()
+			recorder := httptest.NewRecorder()
+			checker.readinessHandler(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			// The RPC check makes a single pass over the endpoints instead of retrying them
+			require.Less(t, time.Since(start), rpcCheckTimeout)
+
+			require.Equal(t, tt.status, recorder.Code)
+			require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+			var status Status
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+			require.Equal(t, tt.status == http.StatusOK, status.OK)
+			require.Equal(t, tt.databaseOK, status.Database.OK)
+			require.Equal(t, tt.databaseOK, status.Database.Error == "")
+
+			chainStatus := status.Chains[chainID]
+			require.NotNil(t, chainStatus)
+			require.Equal(t, tt.stale, chainStatus.Stale)
+			require.Equal(t, tt.rpcOK, chainStatus.RPC.OK)
+			require.Equal(t, tt.rpcOK, chainStatus.RPC.CatchingUp)
+			require.Equal(t, tt.rpcOK, chainStatus.RPC.Error == "")
+
+			if tt.commit {
+				require.Equal(t, int64(42), chainStatus.LastCommittedHeight)
+				require.NotNil(t, chainStatus.LastCommitTime)
+			} else {
+				require.Zero(t, chainStatus.LastCommittedHeight)
+				require.Nil(t, chainStatus.LastCommitTime)
+			}
+		})
+	}
+}
+
+func TestLivenessHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		dbDown  bool
+		rpcDown bool
+		status  int
+	}{
+		{name: "live", status: http.StatusOK},
+		// Liveness only depends on the DB, a stale chain or a down RPC node is not fixed by a restart
+		{name: "rpc down", rpcDown: true, status: http.StatusOK},
+		{name: "db down", dbDown: true, status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var connectErr error
+			if tt.dbDown {
+				connectErr = errors.New("connection refused")
+			}
+
+			chainID := "liveness-" + tt.name
+			checker := NewChecker(newTestDB(t, connectErr), time.Nanosecond)
+			checker.AddChain(chainID, newTestPool(t, !tt.rpcDown))
+			time.Sleep(time.Millisecond)
+
+			recorder := httptest.NewRecorder()
+			checker.livenessHandler(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			require.Equal(t, tt.status, recorder.Code)
+
+			var status Status
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+			require.Equal(t, !tt.dbDown, status.Database.OK)
+			require.True(t, status.Chains[chainID].Stale)
+			// Liveness does not check the RPC endpoints
+			require.Nil(t, status.Chains[chainID].RPC)
+		})
+	}
+}
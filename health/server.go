@@ -0,0 +1,37 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+)
+
+// StartServer serves the /healthz and /readyz endpoints at the passed in address until the context is cancelled
+func StartServer(ctx context.Context, address string, checker *Checker) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", checker.livenessHandler)
+	mux.HandleFunc("/readyz", checker.readinessHandler)
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			config.Log.Error("Error shutting down health server", err)
+		}
+	}()
+
+	config.Log.Infof("Serving health checks on %s/healthz and %s/readyz", address, address)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		config.Log.Error("Health server failed", err)
+	}
+}
@@ -1,119 +1,388 @@
 package indexer
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/core"
 	dbTypes "github.com/DefiantLabs/cosmos-indexer/db"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"github.com/DefiantLabs/cosmos-indexer/health"
+	"github.com/DefiantLabs/cosmos-indexer/metrics"
 )
 
+// dbUpdateStats tracks DB write progress across DoDBUpdates for the block timer logs, the fail-fast failure rate check and the indexing checkpoint
+type dbUpdateStats struct {
+	blocksProcessed int
+	dbWrites        int
+	dbReattempts    int
+	timeStart       time.Time
+	checkpoint      *models.Checkpoint
+}
+
 // doDBUpdates will read the data out of the db data chan that had been processed by the workers
 // if this is a dry run, we will simply empty the channel and track progress
 // otherwise we will index the data in the DB.
 // it will also read rewars data and index that.
-func (indexer *Indexer) DoDBUpdates(wg *sync.WaitGroup, txDataChan chan *DBData, blockEventsDataChan chan *BlockEventsDBData, dbChainID uint) {
-	blocksProcessed := 0
-	dbWrites := 0
-	dbReattempts := 0
-	timeStart := time.Now()
+// Blocks are written in batches of base.db-batch-size, partial batches are written after base.db-batch-window seconds.
+// Cancelling the context does not interrupt DB writes, all processed blocks are written until the data channels are closed.
+// The chain checkpoint is advanced after every committed block so restarts can resume from the highest contiguous indexed height.
+// An error that stops the chain is passed to stopChain, after which the data channels are drained without writing until they are closed.
+func (indexer *Indexer) DoDBUpdates(ctx context.Context, wg *sync.WaitGroup, txDataChan chan *DBData, blockEventsDataChan chan *BlockEventsDBData, dbChainID uint, stopChain context.CancelCauseFunc) {
+	stats := &dbUpdateStats{timeStart: time.Now()}
+	shutdownChan := ctx.Done()
 	defer wg.Done()
 
+	failed := false
+	fail := func(err error) {
+		if err != nil && !failed {
+			config.LogCtx(ctx).Error("DB updates failed, stopping the chain", err)
+			stopChain(err)
+			failed = true
+		}
+	}
+
+	if !indexer.DryRun {
+		checkpoint, err := dbTypes.InitCheckpoint(indexer.DB, dbChainID)
+		if err != nil {
+			fail(fmt.Errorf("error loading the indexing checkpoint: %w", err))
+		}
+		stats.checkpoint = &checkpoint
+	}
+
+	batchSize := int(indexer.Config.Base.DBBatchSize)
+	var txBatch []*DBData
+	var blockEventsBatch []*BlockEventsDBData
+
+	// Partial batches are only held back when batching is enabled, a nil channel never fires
+	var batchWindow <-chan time.Time
+	if batchSize > 1 && indexer.Config.Base.DBBatchWindow > 0 {
+		ticker := time.NewTicker(time.Duration(indexer.Config.Base.DBBatchWindow * float64(time.Second)))
+		defer ticker.Stop()
+		batchWindow = ticker.C
+	}
+
 	for {
 		// break out of loop once all channels are fully consumed
 		if txDataChan == nil && blockEventsDataChan == nil {
-			config.Log.Info("DB updates complete")
+			config.LogCtx(ctx).Info("DB updates complete")
 			break
 		}
 
 		select {
+		case <-shutdownChan:
+			config.LogCtx(ctx).Info("Shutdown requested, writing remaining processed blocks to the DB")
+			// Only log once, keep draining the data channels until they are closed
+			shutdownChan = nil
+			continue
+		case <-batchWindow:
+			if !failed {
+				fail(indexer.writeTxBatch(ctx, txBatch, stats))
+			}
+			txBatch = nil
+			if !failed {
+				fail(indexer.writeBlockEventsBatch(ctx, blockEventsBatch, stats))
+			}
+			blockEventsBatch = nil
 		// read tx data from the data chan
 		case data, ok := <-txDataChan:
 			if !ok {
+				if !failed {
+					fail(indexer.writeTxBatch(ctx, txBatch, stats))
+				}
+				txBatch = nil
 				txDataChan = nil
 				continue
 			}
-			dbWrites++
-			// While debugging we'll sometimes want to turn off INSERTS to the DB
-			// Note that this does not turn off certain reads or DB connections.
-			indexedBlock := data.block
-			indexedDataset := data.txDBWrappers
-
-			if !indexer.DryRun {
-				var err error
-				config.Log.Info(fmt.Sprintf("Indexing %v TXs from block %d", len(data.txDBWrappers), data.block.Height))
-				indexedBlock, indexedDataset, err = dbTypes.IndexNewBlock(indexer.DB, data.block, data.txDBWrappers, *indexer.Config)
-				if err != nil {
-					// Do a single reattempt on failure
-					dbReattempts++
-					_, _, err = dbTypes.IndexNewBlock(indexer.DB, data.block, data.txDBWrappers, *indexer.Config)
-					if err != nil {
-						config.Log.Fatal(fmt.Sprintf("Error indexing block %v.", data.block.Height), err)
-					}
-				}
 
-				err = dbTypes.IndexCustomMessages(*indexer.Config, indexer.DB, indexer.DryRun, indexedDataset, indexer.CustomMessageParserTrackers)
-				if err != nil {
-					config.Log.Fatal(fmt.Sprintf("Error indexing custom messages for block %d", data.block.Height), err)
+			// Drain without writing once the chain has failed
+			if failed {
+				continue
+			}
+
+			txBatch = append(txBatch, data)
+			if len(txBatch) >= batchSize {
+				fail(indexer.writeTxBatch(ctx, txBatch, stats))
+				txBatch = nil
+			}
+		case eventData, ok := <-blockEventsDataChan:
+			if !ok {
+				if !failed {
+					fail(indexer.writeBlockEventsBatch(ctx, blockEventsBatch, stats))
 				}
+				blockEventsBatch = nil
+				blockEventsDataChan = nil
+				continue
+			}
 
-				config.Log.Info(fmt.Sprintf("Finished indexing %v TXs from block %d", len(data.txDBWrappers), data.block.Height))
-			} else {
-				config.Log.Info(fmt.Sprintf("Processing block %d (dry run, block data will not be stored in DB).", data.block.Height))
+			if failed {
+				continue
 			}
 
-			if indexer.PostIndexCustomMessageFunction != nil {
-				config.Log.Info(fmt.Sprintf("Running PostIndexCustomMessageFunction for block %d", data.block.Height))
+			blockEventsBatch = append(blockEventsBatch, eventData)
+			if len(blockEventsBatch) >= batchSize {
+				fail(indexer.writeBlockEventsBatch(ctx, blockEventsBatch, stats))
+				blockEventsBatch = nil
+			}
+		}
+	}
+}
 
-				dataset := &PostIndexCustomMessageDataset{
-					Config:         *indexer.Config,
-					DB:             indexer.DB,
-					DryRun:         indexer.DryRun,
-					IndexedDataset: &indexedDataset,
-					MessageParser:  indexer.CustomMessageParserTrackers,
-					IndexedBlock:   indexedBlock,
-				}
+// writeTxBatch writes the transactions of all blocks in the batch in a single DB transaction.
+// If the batch write fails, each block is written on its own so a single bad block is isolated according to the failure policy.
+func (indexer *Indexer) writeTxBatch(ctx context.Context, batch []*DBData, stats *dbUpdateStats) error {
+	if len(batch) == 0 {
+		return nil
+	}
 
-				err := indexer.PostIndexCustomMessageFunction(dataset)
-				if err != nil {
-					config.Log.Fatal(fmt.Sprintf("Error running PostIndexCustomMessageFunction for block %d", data.block.Height), err)
-				}
+	if len(batch) == 1 || indexer.DryRun {
+		for _, data := range batch {
+			if err := indexer.writeTxBlock(ctx, data, stats); err != nil {
+				return err
 			}
+		}
+		return nil
+	}
 
-			// Just measuring how many blocks/second we can process
-			if indexer.Config.Base.BlockTimer > 0 {
-				blocksProcessed++
-				if blocksProcessed%int(indexer.Config.Base.BlockTimer) == 0 {
-					totalTime := time.Since(timeStart)
-					config.Log.Info(fmt.Sprintf("Processing %d blocks took %f seconds. %d total blocks have been processed.\n", indexer.Config.Base.BlockTimer, totalTime.Seconds(), blocksProcessed))
-					timeStart = time.Now()
-				}
-				if float64(dbReattempts)/float64(dbWrites) > .1 {
-					config.Log.Fatalf("More than 10%% of the last %v DB writes have failed.", dbWrites)
-				}
-			}
-		case eventData, ok := <-blockEventsDataChan:
-			if !ok {
-				blockEventsDataChan = nil
-				continue
+	batchItems := make([]dbTypes.BlockTxsDBWrapper, len(batch))
+	for index, data := range batch {
+		batchItems[index] = dbTypes.BlockTxsDBWrapper{Block: data.block, Txs: data.txDBWrappers, FailedTxs: data.failedTxs}
+	}
+
+	config.LogCtx(ctx).Infof("Indexing TXs from %d blocks (%d to %d) in a single DB transaction", len(batch), batch[0].block.Height, batch[len(batch)-1].block.Height)
+	writeStart := time.Now()
+
+	indexedBatch, err := dbTypes.IndexNewBlockBatch(indexer.DB, batchItems, *indexer.Config)
+	if err != nil {
+		config.LogCtx(ctx).Error("Error writing TX batch, falling back to writing blocks one at a time", err)
+		for _, data := range batch {
+			if err := indexer.writeTxBlock(ctx, data, stats); err != nil {
+				return err
 			}
-			dbWrites++
-			numEvents := len(eventData.blockDBWrapper.BeginBlockEvents) + len(eventData.blockDBWrapper.EndBlockEvents)
-			config.Log.Info(fmt.Sprintf("Indexing %v Block Events from block %d", numEvents, eventData.blockDBWrapper.Block.Height))
-			identifierLoggingString := fmt.Sprintf("block %d", eventData.blockDBWrapper.Block.Height)
-
-			indexedDataset, err := dbTypes.IndexBlockEvents(indexer.DB, indexer.DryRun, eventData.blockDBWrapper, identifierLoggingString)
-			if err != nil {
-				config.Log.Fatal(fmt.Sprintf("Error indexing block events for %s.", identifierLoggingString), err)
+		}
+		return nil
+	}
+
+	stats.dbWrites += len(batch)
+
+	// Spread the batch write time over the blocks so the histogram stays comparable to single block writes
+	writeDuration := time.Since(writeStart) / time.Duration(len(batch))
+	for index, data := range batch {
+		if err := indexer.finishTxBlock(ctx, data, indexedBatch[index].Block, indexedBatch[index].Txs, writeDuration, stats); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTxBlock writes the transactions of a single block according to the failure policy
+func (indexer *Indexer) writeTxBlock(ctx context.Context, data *DBData, stats *dbUpdateStats) error {
+	stats.dbWrites++
+	// While debugging we'll sometimes want to turn off INSERTS to the DB
+	// Note that this does not turn off certain reads or DB connections.
+	indexedBlock := data.block
+	indexedDataset := data.txDBWrappers
+
+	if indexer.DryRun {
+		config.LogCtx(ctx).Info(fmt.Sprintf("Processing block %d (dry run, block data will not be stored in DB).", data.block.Height))
+		return indexer.postIndexTxBlock(ctx, data, indexedBlock, indexedDataset, stats)
+	}
+
+	config.LogCtx(ctx).Info(fmt.Sprintf("Indexing %v TXs from block %d", len(data.txDBWrappers), data.block.Height))
+	writeStart := time.Now()
+	identifierLoggingString := fmt.Sprintf("block %d", data.block.Height)
+
+	succeeded, reattempts, err := indexer.writeWithFailurePolicy(ctx, identifierLoggingString, true, func() error {
+		var err error
+		indexedBlock, indexedDataset, err = dbTypes.IndexNewBlock(indexer.DB, data.block, data.txDBWrappers, data.failedTxs, *indexer.Config)
+		return err
+	}, indexer.recordFailedBlockFunc(data.block.Height))
+	stats.dbReattempts += reattempts
+	if err != nil || !succeeded {
+		return err
+	}
+
+	return indexer.finishTxBlock(ctx, data, indexedBlock, indexedDataset, time.Since(writeStart), stats)
+}
+
+// finishTxBlock indexes the custom messages for a block whose transactions have been written, then records its progress
+func (indexer *Indexer) finishTxBlock(ctx context.Context, data *DBData, indexedBlock models.Block, indexedDataset []dbTypes.TxDBWrapper, writeDuration time.Duration, stats *dbUpdateStats) error {
+	writeStart := time.Now()
+	identifierLoggingString := fmt.Sprintf("block %d", data.block.Height)
+
+	succeeded, reattempts, err := indexer.writeWithFailurePolicy(ctx, fmt.Sprintf("custom messages for %s", identifierLoggingString), false, func() error {
+		return dbTypes.IndexCustomMessages(*indexer.Config, indexer.DB, indexer.DryRun, indexedDataset, indexer.CustomMessageParserTrackers)
+	}, indexer.recordFailedBlockFunc(data.block.Height))
+	stats.dbReattempts += reattempts
+	if err != nil || !succeeded {
+		return err
+	}
+
+	metrics.DBWriteDuration.WithLabelValues(indexer.Config.Probe.ChainID, metrics.DatasetTransactions).Observe((writeDuration + time.Since(writeStart)).Seconds())
+	metrics.BlocksIndexed.WithLabelValues(indexer.Config.Probe.ChainID, metrics.DatasetTransactions).Inc()
+	metrics.SetIndexedHeight(indexer.Config.Probe.ChainID, data.block.Height)
+	health.RecordCommit(indexer.Config.Probe.ChainID, data.block.Height)
+	indexer.advanceCheckpoint(ctx, stats, dbTypes.CheckpointTransactions, data.block.Height)
+
+	config.LogCtx(ctx).Info(fmt.Sprintf("Finished indexing %v TXs from block %d", len(data.txDBWrappers), data.block.Height))
+
+	return indexer.postIndexTxBlock(ctx, data, indexedBlock, indexedDataset, stats)
+}
+
+// postIndexTxBlock runs the custom post index function and tracks the block timer for a processed block
+func (indexer *Indexer) postIndexTxBlock(ctx context.Context, data *DBData, indexedBlock models.Block, indexedDataset []dbTypes.TxDBWrapper, stats *dbUpdateStats) error {
+	if indexer.PostIndexCustomMessageFunction != nil {
+		config.LogCtx(ctx).Info(fmt.Sprintf("Running PostIndexCustomMessageFunction for block %d", data.block.Height))
+
+		dataset := &PostIndexCustomMessageDataset{
+			Config:         *indexer.Config,
+			DB:             indexer.DB,
+			DryRun:         indexer.DryRun,
+			IndexedDataset: &indexedDataset,
+			MessageParser:  indexer.CustomMessageParserTrackers,
+			IndexedBlock:   indexedBlock,
+		}
+
+		err := indexer.PostIndexCustomMessageFunction(dataset)
+		if err != nil {
+			return fmt.Errorf("error running PostIndexCustomMessageFunction for block %d: %w", data.block.Height, err)
+		}
+	}
+
+	// Just measuring how many blocks/second we can process
+	if indexer.Config.Base.BlockTimer > 0 {
+		stats.blocksProcessed++
+		if stats.blocksProcessed%int(indexer.Config.Base.BlockTimer) == 0 {
+			totalTime := time.Since(stats.timeStart)
+			config.LogCtx(ctx).Info(fmt.Sprintf("Processing %d blocks took %f seconds. %d total blocks have been processed.\n", indexer.Config.Base.BlockTimer, totalTime.Seconds(), stats.blocksProcessed))
+			stats.timeStart = time.Now()
+		}
+		// Only enforced for fail-fast, the other policies are expected to keep going past failed writes
+		if indexer.Config.Base.DBFailurePolicy == config.FailurePolicyFailFast && float64(stats.dbReattempts)/float64(stats.dbWrites) > .1 {
+			return fmt.Errorf("more than 10%% of the last %v DB writes have failed", stats.dbWrites)
+		}
+	}
+
+	return nil
+}
+
+// writeBlockEventsBatch writes the block events of all blocks in the batch in a single DB transaction.
+// If the batch write fails, each block is written on its own so a single bad block is isolated according to the failure policy.
+func (indexer *Indexer) writeBlockEventsBatch(ctx context.Context, batch []*BlockEventsDBData, stats *dbUpdateStats) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if len(batch) == 1 || indexer.DryRun {
+		for _, eventData := range batch {
+			if err := indexer.writeBlockEventsBlock(ctx, eventData, stats); err != nil {
+				return err
 			}
+		}
+		return nil
+	}
+
+	blockDBWrappers := make([]*dbTypes.BlockDBWrapper, len(batch))
+	for index, eventData := range batch {
+		blockDBWrappers[index] = eventData.blockDBWrapper
+	}
 
-			err = dbTypes.IndexCustomBlockEvents(*indexer.Config, indexer.DB, indexer.DryRun, indexedDataset, identifierLoggingString, indexer.CustomBeginBlockParserTrackers, indexer.CustomEndBlockParserTrackers)
-			if err != nil {
-				config.Log.Fatal(fmt.Sprintf("Error indexing custom block events for %s.", identifierLoggingString), err)
+	config.LogCtx(ctx).Infof("Indexing Block Events from %d blocks (%d to %d) in a single DB transaction", len(batch), batch[0].blockDBWrapper.Block.Height, batch[len(batch)-1].blockDBWrapper.Block.Height)
+	writeStart := time.Now()
+
+	indexedBatch, err := dbTypes.IndexBlockEventsBatch(indexer.DB, blockDBWrappers)
+	if err != nil {
+		config.LogCtx(ctx).Error("Error writing Block Events batch, falling back to writing blocks one at a time", err)
+		for _, eventData := range batch {
+			if err := indexer.writeBlockEventsBlock(ctx, eventData, stats); err != nil {
+				return err
 			}
+		}
+		return nil
+	}
+
+	stats.dbWrites += len(batch)
 
-			config.Log.Info(fmt.Sprintf("Finished indexing %v Block Events from block %d", numEvents, eventData.blockDBWrapper.Block.Height))
+	writeDuration := time.Since(writeStart) / time.Duration(len(batch))
+	for index, eventData := range batch {
+		if err := indexer.finishBlockEventsBlock(ctx, eventData, indexedBatch[index], writeDuration, stats); err != nil {
+			return err
 		}
 	}
+
+	return nil
+}
+
+// writeBlockEventsBlock writes the block events of a single block according to the failure policy
+func (indexer *Indexer) writeBlockEventsBlock(ctx context.Context, eventData *BlockEventsDBData, stats *dbUpdateStats) error {
+	stats.dbWrites++
+	numEvents := len(eventData.blockDBWrapper.BeginBlockEvents) + len(eventData.blockDBWrapper.EndBlockEvents)
+	config.LogCtx(ctx).Info(fmt.Sprintf("Indexing %v Block Events from block %d", numEvents, eventData.blockDBWrapper.Block.Height))
+	identifierLoggingString := fmt.Sprintf("block %d", eventData.blockDBWrapper.Block.Height)
+
+	writeStart := time.Now()
+	var indexedDataset *dbTypes.BlockDBWrapper
+	succeeded, reattempts, err := indexer.writeWithFailurePolicy(ctx, fmt.Sprintf("block events for %s", identifierLoggingString), false, func() error {
+		var err error
+		indexedDataset, err = dbTypes.IndexBlockEvents(indexer.DB, indexer.DryRun, eventData.blockDBWrapper, identifierLoggingString)
+		return err
+	}, indexer.recordFailedEventBlockFunc(eventData.blockDBWrapper.Block.Height))
+	stats.dbReattempts += reattempts
+	if err != nil || !succeeded {
+		return err
+	}
+
+	return indexer.finishBlockEventsBlock(ctx, eventData, indexedDataset, time.Since(writeStart), stats)
+}
+
+// finishBlockEventsBlock indexes the custom block events for a block whose block events have been written, then records its progress
+func (indexer *Indexer) finishBlockEventsBlock(ctx context.Context, eventData *BlockEventsDBData, indexedDataset *dbTypes.BlockDBWrapper, writeDuration time.Duration, stats *dbUpdateStats) error {
+	writeStart := time.Now()
+	height := eventData.blockDBWrapper.Block.Height
+	identifierLoggingString := fmt.Sprintf("block %d", height)
+
+	// Custom block events are not covered by the failure policy
+	err := dbTypes.IndexCustomBlockEvents(*indexer.Config, indexer.DB, indexer.DryRun, indexedDataset, identifierLoggingString, indexer.CustomBeginBlockParserTrackers, indexer.CustomEndBlockParserTrackers)
+	if err != nil {
+		return fmt.Errorf("error indexing custom block events for %s: %w", identifierLoggingString, err)
+	}
+
+	metrics.DBWriteDuration.WithLabelValues(indexer.Config.Probe.ChainID, metrics.DatasetBlockEvents).Observe((writeDuration + time.Since(writeStart)).Seconds())
+	metrics.BlocksIndexed.WithLabelValues(indexer.Config.Probe.ChainID, metrics.DatasetBlockEvents).Inc()
+	metrics.SetIndexedHeight(indexer.Config.Probe.ChainID, height)
+	health.RecordCommit(indexer.Config.Probe.ChainID, height)
+	indexer.advanceCheckpoint(ctx, stats, dbTypes.CheckpointBlockEvents, height)
+
+	numEvents := len(indexedDataset.BeginBlockEvents) + len(indexedDataset.EndBlockEvents)
+	config.LogCtx(ctx).Info(fmt.Sprintf("Finished indexing %v Block Events from block %d", numEvents, height))
+
+	return nil
+}
+
+// advanceCheckpoint moves the checkpoint past a committed block. Failing to update the checkpoint only slows down the next resume,
+// so errors are logged and indexing continues.
+func (indexer *Indexer) advanceCheckpoint(ctx context.Context, stats *dbUpdateStats, dataset dbTypes.CheckpointDataset, height int64) {
+	if stats.checkpoint == nil {
+		return
+	}
+
+	if err := dbTypes.AdvanceCheckpoint(indexer.DB, stats.checkpoint, dataset, height); err != nil {
+		config.LogCtx(ctx).Errorf("Error advancing the %s checkpoint past block %d: %v", dataset, height, err)
+	}
+}
+
+func (indexer *Indexer) recordFailedBlockFunc(height int64) func(error) error {
+	return func(err error) error {
+		return core.RecordFailedBlock(indexer.DB, indexer.Config, height, core.FailedTransactions, core.FailedDBWrite, dbTypes.FailureStageDBWrite, err)
+	}
+}
+
+func (indexer *Indexer) recordFailedEventBlockFunc(height int64) func(error) error {
+	return func(err error) error {
+		return core.RecordFailedBlock(indexer.DB, indexer.Config, height, core.FailedBlockEvents, core.FailedDBWrite, dbTypes.FailureStageDBWrite, err)
+	}
 }
@@ -0,0 +1,76 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/DefiantLabs/cosmos-indexer/rpc"
+)
+
+// Backoff between retries of the retry policy, replaced in tests to avoid waiting on real backoffs
+var failurePolicyBackoff = rpc.GetBackoffDurationForAttempts
+
+// writeWithFailurePolicy runs the DB write according to the configured base.db-failure-policy.
+// It returns true if the write succeeded. If the write failed and the policy allows continuing, the failure is recorded
+// with the passed in recordFailure function and false is returned.
+// The fail-fast policy returns the write error, after a single reattempt if failFastReattempt is set.
+// An error is also returned if the failure could not be recorded.
+func (indexer *Indexer) writeWithFailurePolicy(ctx context.Context, identifierLoggingString string, failFastReattempt bool, write func() error, recordFailure func(error) error) (succeeded bool, reattempts int, err error) {
+	err = write()
+	if err == nil {
+		return true, 0, nil
+	}
+
+	switch indexer.Config.Base.DBFailurePolicy {
+	case config.FailurePolicyRecordAndContinue:
+		config.LogCtx(ctx).Error(fmt.Sprintf("Error writing %s, adding to failed blocks and continuing.", identifierLoggingString), err)
+	case config.FailurePolicyRetry:
+		maxRetryTime := time.Duration(indexer.Config.Base.DBFailureRetryMaxWait) * time.Second
+		if indexer.Config.Base.DBFailureRetryMaxWait < 2 {
+			maxRetryTime = 2 * time.Second
+		}
+
+	retryLoop:
+		for reattempts < int(indexer.Config.Base.DBFailureRetryAttempts) {
+			backoffDuration, _ := failurePolicyBackoff(int64(reattempts), maxRetryTime)
+			config.LogCtx(ctx).Error(fmt.Sprintf("Error writing %s, backing off and trying again.", identifierLoggingString), err)
+			config.LogCtx(ctx).Debugf("Attempt %d with wait time %+v", reattempts+1, backoffDuration)
+
+			// Do not hold up shutdown with long backoffs, record the block as failed so it can be reattempted later
+			select {
+			case <-ctx.Done():
+				config.LogCtx(ctx).Errorf("Shutdown requested while retrying %s, adding to failed blocks.", identifierLoggingString)
+				break retryLoop
+			case <-time.After(backoffDuration):
+			}
+
+			reattempts++
+			err = write()
+			if err == nil {
+				return true, reattempts, nil
+			}
+
+			if reattempts == int(indexer.Config.Base.DBFailureRetryAttempts) {
+				config.LogCtx(ctx).Error(fmt.Sprintf("Error writing %s, reached max retry attempts, adding to failed blocks and continuing.", identifierLoggingString), err)
+			}
+		}
+	default:
+		if failFastReattempt {
+			// Do a single reattempt on failure
+			reattempts++
+			err = write()
+			if err == nil {
+				return true, reattempts, nil
+			}
+		}
+		return false, reattempts, fmt.Errorf("error writing %s: %w", identifierLoggingString, err)
+	}
+
+	if recordErr := recordFailure(err); recordErr != nil {
+		return false, reattempts, fmt.Errorf("failed to record failure for %s: %w", identifierLoggingString, recordErr)
+	}
+
+	return false, reattempts, nil
+}
@@ -0,0 +1,91 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteWithFailurePolicy(t *testing.T) {
+	backoff := failurePolicyBackoff
+	defer func() { failurePolicyBackoff = backoff }()
+
+	errWrite := errors.New("write failed")
+	errRecord := errors.New("record failed")
+
+	tests := []struct {
+		name              string
+		policy            string
+		failFastReattempt bool
+		// The number of writes that fail before a write succeeds, -1 fails every write
+		failures       int
+		recordErr      error
+		cancelled      bool
+		backoff        time.Duration
+		wantSucceeded  bool
+		wantReattempts int
+		wantWrites     int
+		wantRecorded   bool
+		wantErr        error
+	}{
+		{name: "success", policy: config.FailurePolicyFailFast, failures: 0, wantSucceeded: true, wantWrites: 1},
+		{name: "record-and-continue records the failure", policy: config.FailurePolicyRecordAndContinue, failures: -1, wantWrites: 1, wantRecorded: true},
+		{name: "record-and-continue returns record errors", policy: config.FailurePolicyRecordAndContinue, failures: -1, recordErr: errRecord, wantWrites: 1, wantRecorded: true, wantErr: errRecord},
+		{name: "retry succeeds on reattempt", policy: config.FailurePolicyRetry, failures: 2, wantSucceeded: true, wantReattempts: 2, wantWrites: 3},
+		{name: "retry records after max attempts", policy: config.FailurePolicyRetry, failures: -1, wantReattempts: 3, wantWrites: 4, wantRecorded: true},
+		{name: "retry records on shutdown", policy: config.FailurePolicyRetry, failures: -1, cancelled: true, backoff: time.Hour, wantWrites: 1, wantRecorded: true},
+		{name: "fail-fast succeeds on reattempt", policy: config.FailurePolicyFailFast, failFastReattempt: true, failures: 1, wantSucceeded: true, wantReattempts: 1, wantWrites: 2},
+		{name: "fail-fast returns the error after reattempt", policy: config.FailurePolicyFailFast, failFastReattempt: true, failures: -1, wantReattempts: 1, wantWrites: 2, wantErr: errWrite},
+		{name: "fail-fast returns the error without reattempt", policy: config.FailurePolicyFailFast, failures: -1, wantWrites: 1, wantErr: errWrite},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failurePolicyBackoff = func(int64, time.Duration) (time.Duration, bool) {
+				return tt.backoff, false
+			}
+
+			indexer := &Indexer{Config: &config.IndexConfig{}}
+			indexer.Config.Base.DBFailurePolicy = tt.policy
+			indexer.Config.Base.DBFailureRetryAttempts = 3
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			writes := 0
+			write := func() error {
+				writes++
+				if tt.failures == -1 || writes <= tt.failures {
+					return errWrite
+				}
+				return nil
+			}
+
+			recorded := false
+			recordFailure := func(err error) error {
+				require.ErrorIs(t, err, errWrite)
+				recorded = true
+				return tt.recordErr
+			}
+
+			succeeded, reattempts, err := indexer.writeWithFailurePolicy(ctx, "block 1", tt.failFastReattempt, write, recordFailure)
+
+			require.Equal(t, tt.wantSucceeded, succeeded)
+			require.Equal(t, tt.wantReattempts, reattempts)
+			require.Equal(t, tt.wantWrites, writes)
+			require.Equal(t, tt.wantRecorded, recorded)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
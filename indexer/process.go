@@ -1,100 +1,241 @@
 package indexer
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/DefiantLabs/cosmos-indexer/config"
 	"github.com/DefiantLabs/cosmos-indexer/core"
 	dbTypes "github.com/DefiantLabs/cosmos-indexer/db"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	"github.com/DefiantLabs/cosmos-indexer/metrics"
 )
 
+// processedBlock holds the parsed datasets of a single block, a nil dataset was either not requested or failed processing
+type processedBlock struct {
+	blockEventsData *BlockEventsDBData
+	txData          *DBData
+}
+
+type processingJob struct {
+	sequence  uint64
+	blockData core.IndexerBlockEventData
+}
+
+type processingResult struct {
+	sequence uint64
+	processedBlock
+}
+
 // This function is responsible for processing raw RPC data into app-usable types. It handles both block events and transactions.
 // It parses each dataset according to the application configuration requirements and passes the data to the channels that handle the parsed data.
-func (indexer *Indexer) ProcessBlocks(wg *sync.WaitGroup, failedBlockHandler core.FailedBlockHandler, blockRPCWorkerChan chan core.IndexerBlockEventData, blockEventsDataChan chan *BlockEventsDBData, txDataChan chan *DBData, chainID uint, blockEventFilterRegistry BlockEventFilterRegistries) {
+// Blocks are parsed concurrently by base.processing-workers workers, then handed off to the DB channels in the order they were received from the RPC workers.
+// Cancelling the context does not stop processing, blocks already fetched by the RPC workers are drained until the input channel is closed.
+// If a failed block cannot be recorded, the error is passed to stopChain and the block is dropped.
+func (indexer *Indexer) ProcessBlocks(ctx context.Context, wg *sync.WaitGroup, failedBlockHandler core.FailedBlockHandler, blockRPCWorkerChan chan core.IndexerBlockEventData, blockEventsDataChan chan *BlockEventsDBData, txDataChan chan *DBData, chainID uint, blockEventFilterRegistry BlockEventFilterRegistries, stopChain context.CancelCauseFunc) {
 	defer close(blockEventsDataChan)
 	defer close(txDataChan)
 	defer wg.Done()
 
-	for blockData := range blockRPCWorkerChan {
-		currentHeight := blockData.BlockData.Block.Height
-		config.Log.Infof("Parsing data for block %d", currentHeight)
-
-		block, err := core.ProcessBlock(blockData.BlockData, blockData.BlockResultsData, chainID)
+	indexer.processInOrder(ctx, int(indexer.Config.Base.ProcessingWorkers), blockRPCWorkerChan, func(blockData core.IndexerBlockEventData) processedBlock {
+		processed, err := indexer.processBlock(ctx, blockData, failedBlockHandler, chainID, blockEventFilterRegistry)
 		if err != nil {
-			config.Log.Error("ProcessBlock: unhandled error", err)
-			failedBlockHandler(currentHeight, core.UnprocessableTxError, err)
-			err := dbTypes.UpsertFailedBlock(indexer.DB, currentHeight, indexer.Config.Probe.ChainID, indexer.Config.Probe.ChainName)
-			if err != nil {
-				config.Log.Fatal("Failed to insert failed block", err)
-			}
-			continue
+			stopChain(err)
+		}
+		return processed
+	}, func(processed processedBlock) {
+		if processed.blockEventsData != nil {
+			blockEventsDataChan <- processed.blockEventsData
 		}
+		if processed.txData != nil {
+			txDataChan <- processed.txData
+		}
+	})
 
-		if blockData.IndexBlockEvents && !blockData.BlockEventRequestsFailed {
-			config.Log.Info("Parsing block events")
-			blockDBWrapper, err := core.ProcessRPCBlockResults(*indexer.Config, block, blockData.BlockResultsData, indexer.CustomBeginBlockEventParserRegistry, indexer.CustomEndBlockEventParserRegistry)
-			if err != nil {
-				config.Log.Errorf("Failed to process block events during block %d event processing, adding to failed block events table", currentHeight)
-				failedBlockHandler(currentHeight, core.FailedBlockEventHandling, err)
-				err := dbTypes.UpsertFailedEventBlock(indexer.DB, currentHeight, indexer.Config.Probe.ChainID, indexer.Config.Probe.ChainName)
-				if err != nil {
-					config.Log.Fatal("Failed to insert failed block event", err)
-				}
-			} else {
-				config.Log.Infof("Finished parsing block event data for block %d", currentHeight)
+	config.LogCtx(ctx).Info("Block processing complete")
+}
 
-				var beginBlockFilterError error
-				var endBlockFilterError error
-				if blockEventFilterRegistry.BeginBlockEventFilterRegistry != nil && blockEventFilterRegistry.BeginBlockEventFilterRegistry.NumFilters() > 0 {
-					blockDBWrapper.BeginBlockEvents, beginBlockFilterError = core.FilterRPCBlockEvents(blockDBWrapper.BeginBlockEvents, *blockEventFilterRegistry.BeginBlockEventFilterRegistry)
-				}
+// processInOrder runs process on the blocks of the input channel with the given number of workers, and calls handOff with the results
+// in the order the blocks were received. It returns once the input channel is closed and every result has been handed off.
+func (indexer *Indexer) processInOrder(ctx context.Context, workers int, input chan core.IndexerBlockEventData, process func(core.IndexerBlockEventData) processedBlock, handOff func(processedBlock)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan processingJob, workers)
+	results := make(chan processingResult, workers)
+	// Bounds the number of blocks between dispatch and hand-off so a slow block cannot grow the reorder buffer without limit
+	inFlight := make(chan struct{}, 4*workers)
 
-				if blockEventFilterRegistry.EndBlockEventFilterRegistry != nil && blockEventFilterRegistry.EndBlockEventFilterRegistry.NumFilters() > 0 {
-					blockDBWrapper.EndBlockEvents, endBlockFilterError = core.FilterRPCBlockEvents(blockDBWrapper.EndBlockEvents, *blockEventFilterRegistry.EndBlockEventFilterRegistry)
+	var workersWg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		workersWg.Add(1)
+		go func() {
+			defer workersWg.Done()
+			for job := range jobs {
+				results <- processingResult{
+					sequence:       job.sequence,
+					processedBlock: process(job.blockData),
 				}
+			}
+		}()
+	}
 
-				if beginBlockFilterError == nil && endBlockFilterError == nil {
-					blockEventsDataChan <- &BlockEventsDBData{
-						blockDBWrapper: blockDBWrapper,
-					}
-				} else {
-					config.Log.Errorf("Failed to filter block events during block %d event processing, adding to failed block events table. Begin blocker filter error %s. End blocker filter error %s", currentHeight, beginBlockFilterError, endBlockFilterError)
-					failedBlockHandler(currentHeight, core.FailedBlockEventHandling, err)
-					err := dbTypes.UpsertFailedEventBlock(indexer.DB, currentHeight, indexer.Config.Probe.ChainID, indexer.Config.Probe.ChainName)
-					if err != nil {
-						config.Log.Fatal("Failed to insert failed block event", err)
-					}
+	go func() {
+		workersWg.Wait()
+		close(results)
+	}()
+
+	handOffDone := make(chan struct{})
+	go func() {
+		defer close(handOffDone)
+		pending := make(map[uint64]processedBlock)
+		var next uint64
+		for result := range results {
+			pending[result.sequence] = result.processedBlock
+			for {
+				processed, ok := pending[next]
+				if !ok {
+					break
 				}
+				delete(pending, next)
+				next++
+
+				handOff(processed)
+				<-inFlight
 			}
 		}
+	}()
+
+	shutdownChan := ctx.Done()
+	var sequence uint64
+
+	for {
+		var blockData core.IndexerBlockEventData
+		var ok bool
+		select {
+		case <-shutdownChan:
+			config.LogCtx(ctx).Info("Shutdown requested, processing remaining blocks fetched from RPC")
+			// Only log once, keep draining the input channel until it is closed
+			shutdownChan = nil
+			continue
+		case blockData, ok = <-input:
+		}
+
+		if !ok {
+			break
+		}
+
+		inFlight <- struct{}{}
+		jobs <- processingJob{sequence: sequence, blockData: blockData}
+		sequence++
+	}
+
+	close(jobs)
+	<-handOffDone
+}
+
+// processBlock parses the block events and transactions of a single block, recording failed datasets in the failed blocks tables.
+// It only returns an error when a failed dataset could not be recorded.
+func (indexer *Indexer) processBlock(ctx context.Context, blockData core.IndexerBlockEventData, failedBlockHandler core.FailedBlockHandler, chainID uint, blockEventFilterRegistry BlockEventFilterRegistries) (processedBlock, error) {
+	var processed processedBlock
+
+	// Report the failure to the handler, then record it so the block is retried and counted in the metrics
+	failBlock := func(height int64, datasets core.FailedDataset, code core.BlockProcessingFailure, stage string, err error) error {
+		failedBlockHandler(height, code, err)
+		if err := core.RecordFailedBlock(indexer.DB, indexer.Config, height, datasets, code, stage, err); err != nil {
+			return fmt.Errorf("failed to record failed block %d: %w", height, err)
+		}
+		return nil
+	}
 
-		if blockData.IndexTransactions && !blockData.TxRequestsFailed {
-			config.Log.Info("Parsing transactions")
-			var txDBWrappers []dbTypes.TxDBWrapper
-			var err error
-
-			if blockData.GetTxsResponse != nil {
-				config.Log.Debug("Processing TXs from RPC TX Search response")
-				txDBWrappers, _, err = core.ProcessRPCTXs(indexer.Config, indexer.DB, indexer.ChainClient, indexer.MessageTypeFilters, indexer.MessageFilters, blockData.GetTxsResponse, indexer.CustomMessageParserRegistry)
-			} else if blockData.BlockResultsData != nil {
-				config.Log.Debug("Processing TXs from BlockResults search response")
-				txDBWrappers, _, err = core.ProcessRPCBlockByHeightTXs(indexer.Config, indexer.DB, indexer.ChainClient, indexer.MessageTypeFilters, indexer.MessageFilters, blockData.BlockData, blockData.BlockResultsData, indexer.CustomMessageParserRegistry)
+	currentHeight := blockData.BlockData.Block.Height
+	config.LogCtx(ctx).Infof("Parsing data for block %d", currentHeight)
+	processingStart := time.Now()
+
+	block, err := core.ProcessBlock(blockData.BlockData, blockData.BlockResultsData, chainID)
+	if err != nil {
+		config.LogCtx(ctx).Error("ProcessBlock: unhandled error", err)
+		return processed, failBlock(currentHeight, core.FailedTransactions, core.UnprocessableTxError, dbTypes.FailureStageDecode, err)
+	}
+
+	if indexer.Config.Flags.IndexBlockSignatures {
+		block.LastCommitSignatures = core.ProcessBlockSignatures(blockData.BlockData)
+	}
+
+	if blockData.IndexBlockEvents && !blockData.BlockEventRequestsFailed {
+		config.LogCtx(ctx).Info("Parsing block events")
+		blockDBWrapper, err := core.ProcessRPCBlockResults(*indexer.Config, block, blockData.BlockResultsData, indexer.CustomBeginBlockEventParserRegistry, indexer.CustomEndBlockEventParserRegistry)
+		if err != nil {
+			config.LogCtx(ctx).Errorf("Failed to process block events during block %d event processing, adding to failed block events table", currentHeight)
+			if err := failBlock(currentHeight, core.FailedBlockEvents, core.FailedBlockEventHandling, dbTypes.FailureStageDecode, err); err != nil {
+				return processedBlock{}, err
 			}
+		} else {
+			config.LogCtx(ctx).Infof("Finished parsing block event data for block %d", currentHeight)
 
-			if err != nil {
-				config.Log.Error("ProcessRpcTxs: unhandled error", err)
-				failedBlockHandler(currentHeight, core.UnprocessableTxError, err)
-				err := dbTypes.UpsertFailedBlock(indexer.DB, currentHeight, indexer.Config.Probe.ChainID, indexer.Config.Probe.ChainName)
-				if err != nil {
-					config.Log.Fatal("Failed to insert failed block", err)
+			// The signatures are written with the transactions when those are indexed too
+			if blockData.IndexTransactions && !blockData.TxRequestsFailed {
+				blockDBWrapper.Block.LastCommitSignatures = nil
+			}
+
+			var beginBlockFilterError error
+			var endBlockFilterError error
+			if blockEventFilterRegistry.BeginBlockEventFilterRegistry != nil && blockEventFilterRegistry.BeginBlockEventFilterRegistry.NumFilters() > 0 {
+				blockDBWrapper.BeginBlockEvents, beginBlockFilterError = core.FilterRPCBlockEvents(blockDBWrapper.BeginBlockEvents, *blockEventFilterRegistry.BeginBlockEventFilterRegistry)
+			}
+
+			if blockEventFilterRegistry.EndBlockEventFilterRegistry != nil && blockEventFilterRegistry.EndBlockEventFilterRegistry.NumFilters() > 0 {
+				blockDBWrapper.EndBlockEvents, endBlockFilterError = core.FilterRPCBlockEvents(blockDBWrapper.EndBlockEvents, *blockEventFilterRegistry.EndBlockEventFilterRegistry)
+			}
+
+			if beginBlockFilterError == nil && endBlockFilterError == nil {
+				processed.blockEventsData = &BlockEventsDBData{
+					blockDBWrapper: blockDBWrapper,
 				}
 			} else {
-				txDataChan <- &DBData{
-					txDBWrappers: txDBWrappers,
-					block:        block,
+				config.LogCtx(ctx).Errorf("Failed to filter block events during block %d event processing, adding to failed block events table. Begin blocker filter error %s. End blocker filter error %s", currentHeight, beginBlockFilterError, endBlockFilterError)
+				filterErr := errors.Join(beginBlockFilterError, endBlockFilterError)
+				if err := failBlock(currentHeight, core.FailedBlockEvents, core.FailedBlockEventHandling, dbTypes.FailureStageFilter, filterErr); err != nil {
+					return processedBlock{}, err
 				}
 			}
+		}
+	}
+
+	if blockData.IndexTransactions && !blockData.TxRequestsFailed {
+		config.LogCtx(ctx).Info("Parsing transactions")
+		var txDBWrappers []dbTypes.TxDBWrapper
+		var failedTxs []models.FailedTx
+		var err error
+
+		if blockData.GetTxsResponse != nil {
+			config.LogCtx(ctx).Debug("Processing TXs from RPC TX Search response")
+			txDBWrappers, failedTxs, _, err = core.ProcessRPCTXs(indexer.Config, indexer.DB, indexer.ChainClient, indexer.MessageTypeFilters, indexer.MessageFilters, blockData.GetTxsResponse, indexer.CustomMessageParserRegistry)
+		} else if blockData.BlockResultsData != nil {
+			config.LogCtx(ctx).Debug("Processing TXs from BlockResults search response")
+			txDBWrappers, failedTxs, _, err = core.ProcessRPCBlockByHeightTXs(indexer.Config, indexer.DB, indexer.ChainClient, indexer.MessageTypeFilters, indexer.MessageFilters, blockData.BlockData, blockData.BlockResultsData, indexer.CustomMessageParserRegistry)
+		}
 
+		if err != nil {
+			config.LogCtx(ctx).Error("ProcessRpcTxs: unhandled error", err)
+			if err := failBlock(currentHeight, core.FailedTransactions, core.UnprocessableTxError, dbTypes.FailureStageDecode, err); err != nil {
+				return processedBlock{}, err
+			}
+		} else {
+			processed.txData = &DBData{
+				txDBWrappers: txDBWrappers,
+				failedTxs:    failedTxs,
+				block:        block,
+			}
 		}
+
 	}
+
+	metrics.BlockProcessingDuration.WithLabelValues(indexer.Config.Probe.ChainID).Observe(time.Since(processingStart).Seconds())
+
+	return processed, nil
 }
@@ -0,0 +1,90 @@
+package indexer
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/core"
+	"github.com/DefiantLabs/cosmos-indexer/db/models"
+	ctypes "github.com/cometbft/cometbft/rpc/core/types"
+	cometTypes "github.com/cometbft/cometbft/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessInOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		blocks  int
+		cancel  bool
+	}{
+		{name: "single worker", workers: 1, blocks: 50},
+		{name: "workers below one use a single worker", workers: 0, blocks: 10},
+		{name: "several workers", workers: 8, blocks: 500},
+		{name: "shutdown drains the input", workers: 4, blocks: 200, cancel: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			input := make(chan core.IndexerBlockEventData)
+			go func() {
+				defer close(input)
+				for height := int64(1); height <= int64(tt.blocks); height++ {
+					if tt.cancel && height == int64(tt.blocks/2) {
+						cancel()
+					}
+					input <- core.IndexerBlockEventData{BlockData: &ctypes.ResultBlock{Block: &cometTypes.Block{Header: cometTypes.Header{Height: height}}}}
+				}
+			}()
+
+			maxInFlight := 4 * tt.workers
+			if maxInFlight < 4 {
+				maxInFlight = 4
+			}
+
+			var started, handedOff, peakInFlight atomic.Int64
+			var heights []int64
+			process := func(blockData core.IndexerBlockEventData) processedBlock {
+				inFlight := started.Add(1) - handedOff.Load()
+				for {
+					peak := peakInFlight.Load()
+					if inFlight <= peak || peakInFlight.CompareAndSwap(peak, inFlight) {
+						break
+					}
+				}
+
+				// Random delays make later blocks regularly finish before earlier ones
+				time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+				return processedBlock{txData: &DBData{block: models.Block{Height: blockData.BlockData.Block.Height}}}
+			}
+			handOff := func(processed processedBlock) {
+				heights = append(heights, processed.txData.block.Height)
+				handedOff.Add(1)
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				(&Indexer{}).processInOrder(ctx, tt.workers, input, process, handOff)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("processing did not complete after the input channel was closed")
+			}
+
+			require.Len(t, heights, tt.blocks)
+			for index, height := range heights {
+				require.Equal(t, int64(index+1), height, "blocks must be handed off in the order they were received")
+			}
+			require.LessOrEqual(t, peakInFlight.Load(), int64(maxInFlight))
+		})
+	}
+}
@@ -1,12 +1,15 @@
 package indexer
 
 import (
+	"context"
+
 	"github.com/DefiantLabs/cosmos-indexer/config"
 	"github.com/DefiantLabs/cosmos-indexer/core"
 	dbTypes "github.com/DefiantLabs/cosmos-indexer/db"
 	"github.com/DefiantLabs/cosmos-indexer/db/models"
 	"github.com/DefiantLabs/cosmos-indexer/filter"
 	"github.com/DefiantLabs/cosmos-indexer/parsers"
+	"github.com/DefiantLabs/cosmos-indexer/rpc"
 	"github.com/DefiantLabs/probe/client"
 	sdkTypes "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/module"
@@ -46,8 +49,9 @@ type Indexer struct {
 	Config                              *config.IndexConfig
 	DryRun                              bool
 	DB                                  *gorm.DB
-	ChainClient                         *client.ChainClient
-	BlockEnqueueFunction                func(chan *core.EnqueueData) error
+	ChainClient                         *client.ChainClient // Client for the primary RPC endpoint, used for codec access and one-off requests
+	RPCPool                             *rpc.Pool           // Load balances and fails over RPC requests across all configured RPC endpoints
+	BlockEnqueueFunction                func(context.Context, chan *core.EnqueueData) error
 	CustomModuleBasics                  []module.AppModuleBasic // Used for extending the AppModuleBasics registered in the probe ChainClientient
 	BlockEventFilterRegistries          BlockEventFilterRegistries
 	MessageTypeFilters                  []filter.MessageTypeFilter
@@ -73,6 +77,7 @@ type BlockEventFilterRegistries struct {
 
 type DBData struct {
 	txDBWrappers []dbTypes.TxDBWrapper
+	failedTxs    []models.FailedTx
 	block        models.Block
 }
 
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "cosmos_indexer"
+
+// Label values for the indexed dataset types
+const (
+	DatasetTransactions = "transactions"
+	DatasetBlockEvents  = "block_events"
+)
+
+var (
+	BlocksEnqueued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocks_enqueued_total",
+		Help:      "Number of block heights received by the RPC workers from the block enqueue function.",
+	}, []string{"chain_id"})
+
+	BlocksIndexed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocks_indexed_total",
+		Help:      "Number of blocks successfully written to the DB, by dataset.",
+	}, []string{"chain_id", "dataset"})
+
+	RPCRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_request_duration_seconds",
+		Help:      "Latency of RPC requests made by the RPC workers, by request.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"chain_id", "request"})
+
+	RPCRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_request_errors_total",
+		Help:      "Number of failed RPC requests made by the RPC workers, by request.",
+	}, []string{"chain_id", "request"})
+
+	BlockProcessingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "block_processing_duration_seconds",
+		Help:      "Time spent parsing raw RPC data into DB types for a single block.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"chain_id"})
+
+	DBWriteDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_write_duration_seconds",
+		Help:      "Time spent writing a single block to the DB, by dataset.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"chain_id", "dataset"})
+
+	FailedBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "failed_blocks_total",
+		Help:      "Number of block failures, by failure reason.",
+	}, []string{"chain_id", "reason"})
+
+	chainHeadHeight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chain_head_height",
+		Help:      "Latest block height reported by the RPC node.",
+	}, []string{"chain_id"})
+
+	lastIndexedHeight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_indexed_height",
+		Help:      "Highest block height written to the DB.",
+	}, []string{"chain_id"})
+
+	chainHeadLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chain_head_lag_blocks",
+		Help:      "Number of blocks between the latest block height reported by the RPC node and the highest block height written to the DB.",
+	}, []string{"chain_id"})
+
+	// Latest heights by chain ID, used to keep the indexed height gauge at its highest value and to compute the lag
+	heightsMu sync.Mutex
+	heights   = map[string]*chainHeights{}
+)
+
+type chainHeights struct {
+	head    int64
+	indexed int64
+}
+
+// ObserveRPCRequest records the latency of an RPC request started at the passed in time, counting it as an error if err is not nil
+func ObserveRPCRequest(chainID string, request string, start time.Time, err error) {
+	RPCRequestDuration.WithLabelValues(chainID, request).Observe(time.Since(start).Seconds())
+	if err != nil {
+		RPCRequestErrors.WithLabelValues(chainID, request).Inc()
+	}
+}
+
+// SetChainHeadHeight records the latest block height reported by the RPC node for the chain
+func SetChainHeadHeight(chainID string, height int64) {
+	heightsMu.Lock()
+	defer heightsMu.Unlock()
+
+	chain := chainHeightsFor(chainID)
+	chain.head = height
+	setHeightGauges(chainID, chain)
+}
+
+// SetIndexedHeight records a block height written to the DB for the chain, keeping track of the highest height seen
+func SetIndexedHeight(chainID string, height int64) {
+	heightsMu.Lock()
+	defer heightsMu.Unlock()
+
+	chain := chainHeightsFor(chainID)
+	if height <= chain.indexed {
+		return
+	}
+	chain.indexed = height
+	setHeightGauges(chainID, chain)
+}
+
+func chainHeightsFor(chainID string) *chainHeights {
+	chain, ok := heights[chainID]
+	if !ok {
+		chain = &chainHeights{}
+		heights[chainID] = chain
+	}
+	return chain
+}
+
+func setHeightGauges(chainID string, chain *chainHeights) {
+	chainHeadHeight.WithLabelValues(chainID).Set(float64(chain.head))
+	lastIndexedHeight.WithLabelValues(chainID).Set(float64(chain.indexed))
+
+	lag := chain.head - chain.indexed
+	if chain.head == 0 || chain.indexed == 0 || lag < 0 {
+		lag = 0
+	}
+	chainHeadLag.WithLabelValues(chainID).Set(float64(lag))
+}
+
+// RegisterQueueDepth registers a gauge reporting the current number of items buffered in a pipeline channel of the chain.
+// A gauge already registered for the chain channel is replaced, so the depth of the latest channel is reported when a chain pipeline is started again.
+func RegisterQueueDepth(chainID string, channel string, depth func() int) error {
+	gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "queue_depth",
+		Help:        "Number of items buffered in an indexing pipeline channel.",
+		ConstLabels: prometheus.Labels{"chain_id": chainID, "channel": channel},
+	}, func() float64 {
+		return float64(depth())
+	})
+
+	err := prometheus.Register(gauge)
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		prometheus.Unregister(alreadyRegistered.ExistingCollector)
+		err = prometheus.Register(gauge)
+	}
+	return err
+}
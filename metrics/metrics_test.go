@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveRPCRequest(t *testing.T) {
+	ObserveRPCRequest("observe-1", "GetBlock", time.Now(), nil)
+	ObserveRPCRequest("observe-1", "GetBlock", time.Now(), errors.New("request failed"))
+	ObserveRPCRequest("observe-1", "GetBlockResult", time.Now(), nil)
+
+	require.Equal(t, 1, testutil.CollectAndCount(RPCRequestErrors.MustCurryWith(prometheus.Labels{"chain_id": "observe-1"})))
+	require.Equal(t, 1.0, testutil.ToFloat64(RPCRequestErrors.WithLabelValues("observe-1", "GetBlock")))
+	require.Equal(t, 2, testutil.CollectAndCount(RPCRequestDuration.MustCurryWith(prometheus.Labels{"chain_id": "observe-1"})))
+}
+
+func TestHeightGauges(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     func(chainID string)
+		head    float64
+		indexed float64
+		lag     float64
+	}{
+		{
+			name: "lag needs both heights",
+			set:  func(chainID string) { SetChainHeadHeight(chainID, 100) },
+			head: 100,
+		},
+		{
+			name: "lag between head and indexed height",
+			set: func(chainID string) {
+				SetChainHeadHeight(chainID, 100)
+				SetIndexedHeight(chainID, 40)
+			},
+			head: 100, indexed: 40, lag: 60,
+		},
+		{
+			name: "indexed height keeps its highest value",
+			set: func(chainID string) {
+				SetChainHeadHeight(chainID, 100)
+				SetIndexedHeight(chainID, 90)
+				SetIndexedHeight(chainID, 50)
+			},
+			head: 100, indexed: 90, lag: 10,
+		},
+		{
+			name: "lag is not negative",
+			set: func(chainID string) {
+				SetIndexedHeight(chainID, 120)
+				SetChainHeadHeight(chainID, 100)
+			},
+			head: 100, indexed: 120,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chainID := "heights-" + tt.name
+			tt.set(chainID)
+
+			require.Equal(t, tt.head, testutil.ToFloat64(chainHeadHeight.WithLabelValues(chainID)))
+			require.Equal(t, tt.indexed, testutil.ToFloat64(lastIndexedHeight.WithLabelValues(chainID)))
+			require.Equal(t, tt.lag, testutil.ToFloat64(chainHeadLag.WithLabelValues(chainID)))
+		})
+	}
+}
+
+func TestRegisterQueueDepth(t *testing.T) {
+	// queueDepths gathers the queue depths of the test chains from the default registry
+	queueDepths := func() map[string]float64 {
+		families, err := prometheus.DefaultGatherer.Gather()
+		require.NoError(t, err)
+
+		depths := map[string]float64{}
+		for _, family := range families {
+			if family.GetName() != namespace+"_queue_depth" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				labels := map[string]string{}
+				for _, label := range metric.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				if strings.HasPrefix(labels["chain_id"], "queue-") {
+					depths[labels["chain_id"]+"/"+labels["channel"]] = metric.GetGauge().GetValue()
+				}
+			}
+		}
+		return depths
+	}
+
+	require.NoError(t, RegisterQueueDepth("queue-1", "txDataChan", func() int { return 3 }))
+	require.NoError(t, RegisterQueueDepth("queue-2", "txDataChan", func() int { return 5 }))
+	require.Equal(t, map[string]float64{"queue-1/txDataChan": 3, "queue-2/txDataChan": 5}, queueDepths())
+
+	// Registering the channel of a chain again reports the new channel instead of failing
+	require.NoError(t, RegisterQueueDepth("queue-1", "txDataChan", func() int { return 7 }))
+	require.Equal(t, map[string]float64{"queue-1/txDataChan": 7, "queue-2/txDataChan": 5}, queueDepths())
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// StartServer serves the Prometheus metrics on /metrics at the passed in address until the context is cancelled
+func StartServer(ctx context.Context, address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			config.Log.Error("Error shutting down metrics server", err)
+		}
+	}()
+
+	config.Log.Infof("Serving metrics on %s/metrics", address)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		config.Log.Error("Metrics server failed", err)
+	}
+}
+
+// PollChainHead periodically records the latest block height reported by the RPC node for the chain until the context is cancelled
+func PollChainHead(ctx context.Context, chainID string, interval time.Duration, latestHeight func() (int64, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		height, err := latestHeight()
+		if err != nil {
+			config.LogCtx(ctx).Debug("Error getting latest block height for metrics", err)
+		} else {
+			SetChainHeadHeight(chainID, height)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
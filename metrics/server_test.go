@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartServer(t *testing.T) {
+	// Reserve a free port for the server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	SetChainHeadHeight("server-1", 42)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		StartServer(ctx, address)
+		close(stopped)
+	}()
+
+	var body []byte
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + address + "/metrics")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		body, err = io.ReadAll(resp.Body)
+		return err == nil && resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Contains(t, string(body), `cosmos_indexer_chain_head_height{chain_id="server-1"} 42`)
+
+	// The server shuts down with the context
+	cancel()
+	require.Eventually(t, func() bool {
+		select {
+		case <-stopped:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPollChainHead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	heights := []int64{10, 12, 0}
+	polls := 0
+	done := make(chan struct{})
+	go func() {
+		PollChainHead(ctx, "poll-1", time.Millisecond, func() (int64, error) {
+			polls++
+			switch {
+			case polls > len(heights):
+				cancel()
+				return 0, errors.New("done")
+			case heights[polls-1] == 0:
+				// Errors keep the last recorded height
+				return 0, errors.New("request failed")
+			default:
+				return heights[polls-1], nil
+			}
+		})
+		close(done)
+	}()
+
+	<-done
+	require.Equal(t, 12.0, testutil.ToFloat64(chainHeadHeight.WithLabelValues("poll-1")))
+}
@@ -0,0 +1,312 @@
+package probe
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	msgv1 "cosmossdk.io/api/cosmos/msg/v1"
+	probeClient "github.com/DefiantLabs/probe/client"
+	"github.com/cosmos/cosmos-sdk/codec"
+	codecTypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdkTypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/auth/tx"
+	gogoproto "github.com/cosmos/gogoproto/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// DescriptorRegistry holds the message types loaded at runtime from binary FileDescriptorSet files, keyed by type URL
+type DescriptorRegistry struct {
+	messages map[string]protoreflect.MessageDescriptor
+}
+
+// LoadDescriptorSets reads binary FileDescriptorSet files (as produced by `buf build -o` or `protoc --include_imports --descriptor_set_out`)
+// and collects every message type they define. Imports missing from the sets are resolved against the protos compiled into the indexer.
+func LoadDescriptorSets(paths []string) (*DescriptorRegistry, error) {
+	fileProtos := map[string]*descriptorpb.FileDescriptorProto{}
+	var fileNames []string
+
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading descriptor set %s: %w", path, err)
+		}
+
+		var set descriptorpb.FileDescriptorSet
+		err = proto.Unmarshal(raw, &set)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing descriptor set %s: %w", path, err)
+		}
+
+		for _, file := range set.File {
+			if _, ok := fileProtos[file.GetName()]; ok {
+				continue
+			}
+			fileProtos[file.GetName()] = file
+			fileNames = append(fileNames, file.GetName())
+		}
+	}
+
+	resolver := &descriptorResolver{local: &protoregistry.Files{}}
+	building := map[string]bool{}
+
+	// Files have to be built after their dependencies, so build them depth first
+	var build func(name string) error
+	build = func(name string) error {
+		if _, err := resolver.local.FindFileByPath(name); err == nil {
+			return nil
+		}
+
+		file, ok := fileProtos[name]
+		if !ok {
+			// Not part of the sets, the dependency must be compiled in
+			_, err := gogoproto.HybridResolver.FindFileByPath(name)
+			if err != nil {
+				return fmt.Errorf("error resolving proto import %s: not found in the descriptor sets or the indexer", name)
+			}
+			return nil
+		}
+
+		if building[name] {
+			return fmt.Errorf("error building proto file %s: import cycle", name)
+		}
+		building[name] = true
+
+		for _, dep := range file.GetDependency() {
+			if err := build(dep); err != nil {
+				return err
+			}
+		}
+
+		fd, err := protodesc.NewFile(file, resolver)
+		if err != nil {
+			return fmt.Errorf("error building proto file %s: %w", name, err)
+		}
+
+		return resolver.local.RegisterFile(fd)
+	}
+
+	registry := &DescriptorRegistry{messages: map[string]protoreflect.MessageDescriptor{}}
+
+	for _, name := range fileNames {
+		if err := build(name); err != nil {
+			return nil, err
+		}
+
+		fd, err := resolver.local.FindFileByPath(name)
+		if err != nil {
+			return nil, err
+		}
+		registry.addMessages(fd.Messages())
+	}
+
+	return registry, nil
+}
+
+func (r *DescriptorRegistry) addMessages(messages protoreflect.MessageDescriptors) {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		r.messages["/"+string(md.FullName())] = md
+		r.addMessages(md.Messages())
+	}
+}
+
+// TypeURLs returns the sorted type URLs of all loaded message types
+func (r *DescriptorRegistry) TypeURLs() []string {
+	typeURLs := make([]string, 0, len(r.messages))
+	for typeURL := range r.messages {
+		typeURLs = append(typeURLs, typeURL)
+	}
+	sort.Strings(typeURLs)
+	return typeURLs
+}
+
+// Unmarshal decodes the bytes of a loaded message type into a DynamicMsg
+func (r *DescriptorRegistry) Unmarshal(typeURL string, value []byte) (*DynamicMsg, error) {
+	md, ok := r.messages[typeURL]
+	if !ok {
+		return nil, fmt.Errorf("unable to resolve type URL %s", typeURL)
+	}
+
+	msg := &DynamicMsg{msg: dynamicpb.NewMessage(md)}
+	err := msg.Unmarshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// Will register the message types of the descriptor registry as a fallback in the client codec.
+// Types already known to the codec keep decoding into their compiled implementations.
+func IncludeDescriptorSets(client *probeClient.ChainClient, registry *DescriptorRegistry) {
+	interfaceRegistry := &dynamicInterfaceRegistry{
+		InterfaceRegistry: client.Codec.InterfaceRegistry,
+		descriptors:       registry,
+	}
+	marshaler := codec.NewProtoCodec(interfaceRegistry)
+
+	client.Codec.InterfaceRegistry = interfaceRegistry
+	client.Codec.Marshaler = marshaler
+	client.Codec.TxConfig = tx.NewTxConfig(marshaler, tx.DefaultSignModes)
+}
+
+// descriptorResolver resolves proto files from the descriptor sets first and falls back to the protos compiled into the indexer,
+// both the golang/protobuf and the gogoproto registered ones
+type descriptorResolver struct {
+	local *protoregistry.Files
+}
+
+func (r *descriptorResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	fd, err := r.local.FindFileByPath(path)
+	if err == nil {
+		return fd, nil
+	}
+	return gogoproto.HybridResolver.FindFileByPath(path)
+}
+
+func (r *descriptorResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	d, err := r.local.FindDescriptorByName(name)
+	if err == nil {
+		return d, nil
+	}
+	return gogoproto.HybridResolver.FindDescriptorByName(name)
+}
+
+// dynamicInterfaceRegistry falls back to the descriptor registry when the wrapped registry cannot unpack a message
+type dynamicInterfaceRegistry struct {
+	codecTypes.InterfaceRegistry
+	descriptors *DescriptorRegistry
+}
+
+var dynamicMsgType = reflect.TypeOf(&DynamicMsg{})
+
+func (registry *dynamicInterfaceRegistry) UnpackAny(any *codecTypes.Any, iface interface{}) error {
+	err := registry.InterfaceRegistry.UnpackAny(any, iface)
+	if err == nil || any == nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(iface)
+	if rv.Kind() != reflect.Ptr || !dynamicMsgType.AssignableTo(rv.Elem().Type()) {
+		return err
+	}
+
+	msg, dynErr := registry.descriptors.Unmarshal(any.TypeUrl, any.Value)
+	if dynErr != nil {
+		// Report the original error, the type is unknown to both registries
+		return err
+	}
+
+	newAny, err := codecTypes.NewAnyWithValue(msg)
+	if err != nil {
+		return err
+	}
+
+	rv.Elem().Set(reflect.ValueOf(msg))
+	*any = *newAny
+
+	return nil
+}
+
+func (registry *dynamicInterfaceRegistry) Resolve(typeURL string) (gogoproto.Message, error) {
+	msg, err := registry.InterfaceRegistry.Resolve(typeURL)
+	if err == nil {
+		return msg, nil
+	}
+
+	md, ok := registry.descriptors.messages[typeURL]
+	if !ok {
+		return nil, err
+	}
+
+	return &DynamicMsg{msg: dynamicpb.NewMessage(md)}, nil
+}
+
+// DynamicMsg is a Cosmos SDK message backed by a runtime loaded proto descriptor instead of generated Go code
+type DynamicMsg struct {
+	msg *dynamicpb.Message
+}
+
+var _ sdkTypes.Msg = &DynamicMsg{}
+
+func (m *DynamicMsg) Reset()         { m.msg.Reset() }
+func (m *DynamicMsg) String() string { return m.msg.String() }
+func (m *DynamicMsg) ProtoMessage()  {}
+
+// ProtoReflect gives access to the fields of the message
+func (m *DynamicMsg) ProtoReflect() protoreflect.Message { return m.msg.ProtoReflect() }
+
+// XXX_MessageName lets gogoproto (and so the SDK type URL helpers) name the message
+func (m *DynamicMsg) XXX_MessageName() string { //nolint:revive,stylecheck
+	return string(m.msg.Descriptor().FullName())
+}
+
+func (m *DynamicMsg) Marshal() ([]byte, error) {
+	return proto.Marshal(m.msg)
+}
+
+func (m *DynamicMsg) Unmarshal(value []byte) error {
+	return proto.Unmarshal(value, m.msg)
+}
+
+func (m *DynamicMsg) MarshalJSON() ([]byte, error) {
+	return protojson.Marshal(m.msg)
+}
+
+// ValidateBasic is a no-op, messages are indexed after the chain already accepted them
+func (m *DynamicMsg) ValidateBasic() error {
+	return nil
+}
+
+// GetSigners reads the addresses in the fields named by the cosmos.msg.v1.signer option of the message, if set
+func (m *DynamicMsg) GetSigners() []sdkTypes.AccAddress {
+	md := m.msg.Descriptor()
+
+	opts, ok := md.Options().(*descriptorpb.MessageOptions)
+	if !ok || opts == nil {
+		return nil
+	}
+
+	signerFields, ok := proto.GetExtension(opts, msgv1.E_Signer).([]string)
+	if !ok {
+		return nil
+	}
+
+	var signers []sdkTypes.AccAddress
+	addSigner := func(value protoreflect.Value) {
+		addr, err := sdkTypes.AccAddressFromBech32(value.String())
+		if err == nil {
+			signers = append(signers, addr)
+		}
+	}
+
+	for _, name := range signerFields {
+		fd := md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil || fd.Kind() != protoreflect.StringKind {
+			continue
+		}
+
+		value := m.msg.Get(fd)
+		if fd.IsList() {
+			list := value.List()
+			for i := 0; i < list.Len(); i++ {
+				addSigner(list.Get(i))
+			}
+		} else {
+			addSigner(value)
+		}
+	}
+
+	return signers
+}
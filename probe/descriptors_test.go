@@ -0,0 +1,204 @@
+package probe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	msgv1 "cosmossdk.io/api/cosmos/msg/v1"
+	"github.com/DefiantLabs/cosmos-indexer/core"
+	probeClient "github.com/DefiantLabs/probe/client"
+	sdkTypes "github.com/cosmos/cosmos-sdk/types"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	msgDoTypeURL  = "/fixture.v1.MsgDo"
+	detailTypeURL = "/fixture.v1.Detail"
+)
+
+// fixtureFiles returns a tx proto file for the fixture.v1.MsgDo message, it imports a types proto file of the fixture
+// and the compiled in cosmos coin proto. The tx file comes first to check that files are built after their imports.
+func fixtureFiles() []*descriptorpb.FileDescriptorProto {
+	msgOptions := &descriptorpb.MessageOptions{}
+	proto.SetExtension(msgOptions, msgv1.E_Signer, []string{"sender"})
+
+	field := func(name string, number int32, fieldType descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     fieldType.Enum(),
+		}
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+		return fd
+	}
+
+	tx := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("fixture/v1/tx.proto"),
+		Package:    proto.String("fixture.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"fixture/v1/types.proto", "cosmos/base/v1beta1/coin.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("MsgDo"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("sender", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("amount", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".cosmos.base.v1beta1.Coin"),
+				field("detail", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".fixture.v1.Detail"),
+			},
+			Options: msgOptions,
+		}},
+	}
+
+	types := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("fixture/v1/types.proto"),
+		Package: proto.String("fixture.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:  proto.String("Detail"),
+			Field: []*descriptorpb.FieldDescriptorProto{field("note", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")},
+		}},
+	}
+
+	return []*descriptorpb.FileDescriptorProto{tx, types}
+}
+
+// writeDescriptorSet writes the files as a binary FileDescriptorSet and returns its path
+func writeDescriptorSet(t *testing.T, files ...*descriptorpb.FileDescriptorProto) string {
+	raw, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: files})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "descriptors.binpb")
+	require.NoError(t, os.WriteFile(path, raw, 0o600))
+	return path
+}
+
+// newMsgDo builds a fixture.v1.MsgDo from the descriptors of the registry
+func newMsgDo(t *testing.T, registry *DescriptorRegistry, sender string) *DynamicMsg {
+	msgDo := dynamicpb.NewMessage(registry.messages[msgDoTypeURL])
+	fields := msgDo.Descriptor().Fields()
+
+	amount := msgDo.NewField(fields.ByName("amount")).Message()
+	amount.Set(amount.Descriptor().Fields().ByName("denom"), protoreflect.ValueOfString("uatom"))
+	amount.Set(amount.Descriptor().Fields().ByName("amount"), protoreflect.ValueOfString("100"))
+
+	detail := msgDo.NewField(fields.ByName("detail")).Message()
+	detail.Set(detail.Descriptor().Fields().ByName("note"), protoreflect.ValueOfString("hello"))
+
+	msgDo.Set(fields.ByName("sender"), protoreflect.ValueOfString(sender))
+	msgDo.Set(fields.ByName("amount"), protoreflect.ValueOfMessage(amount))
+	msgDo.Set(fields.ByName("detail"), protoreflect.ValueOfMessage(detail))
+
+	return &DynamicMsg{msg: msgDo}
+}
+
+func TestLoadDescriptorSets(t *testing.T) {
+	files := fixtureFiles()
+
+	t.Run("messages of every file are loaded", func(t *testing.T) {
+		registry, err := LoadDescriptorSets([]string{writeDescriptorSet(t, files...)})
+		require.NoError(t, err)
+		require.Equal(t, []string{detailTypeURL, msgDoTypeURL}, registry.TypeURLs())
+	})
+
+	t.Run("imports are resolved across descriptor sets", func(t *testing.T) {
+		registry, err := LoadDescriptorSets([]string{writeDescriptorSet(t, files[0]), writeDescriptorSet(t, files[1])})
+		require.NoError(t, err)
+		require.Equal(t, []string{detailTypeURL, msgDoTypeURL}, registry.TypeURLs())
+	})
+
+	t.Run("missing imports fail", func(t *testing.T) {
+		_, err := LoadDescriptorSets([]string{writeDescriptorSet(t, files[0])})
+		require.ErrorContains(t, err, "error resolving proto import fixture/v1/types.proto")
+	})
+
+	t.Run("invalid descriptor sets fail", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "descriptors.binpb")
+		require.NoError(t, os.WriteFile(path, []byte("not a descriptor set"), 0o600))
+
+		_, err := LoadDescriptorSets([]string{path})
+		require.ErrorContains(t, err, "error parsing descriptor set")
+	})
+
+	t.Run("missing descriptor sets fail", func(t *testing.T) {
+		_, err := LoadDescriptorSets([]string{filepath.Join(t.TempDir(), "missing.binpb")})
+		require.ErrorContains(t, err, "error reading descriptor set")
+	})
+}
+
+func TestDescriptorRegistryUnmarshal(t *testing.T) {
+	registry, err := LoadDescriptorSets([]string{writeDescriptorSet(t, fixtureFiles()...)})
+	require.NoError(t, err)
+
+	sender := sdkTypes.AccAddress("fixture_sender______").String()
+	value, err := newMsgDo(t, registry, sender).Marshal()
+	require.NoError(t, err)
+
+	msg, err := registry.Unmarshal(msgDoTypeURL, value)
+	require.NoError(t, err)
+	require.Equal(t, "fixture.v1.MsgDo", msg.XXX_MessageName())
+	require.Equal(t, []sdkTypes.AccAddress{sdkTypes.AccAddress("fixture_sender______")}, msg.GetSigners())
+
+	json, err := msg.MarshalJSON()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"sender":"`+sender+`","amount":{"denom":"uatom","amount":"100"},"detail":{"note":"hello"}}`, string(json))
+
+	_, err = registry.Unmarshal("/fixture.v1.Unknown", value)
+	require.ErrorContains(t, err, "unable to resolve type URL")
+}
+
+func TestIncludeDescriptorSets(t *testing.T) {
+	registry, err := LoadDescriptorSets([]string{writeDescriptorSet(t, fixtureFiles()...)})
+	require.NoError(t, err)
+
+	codec, err := probeClient.MakeCodec(probeClient.DefaultModuleBasics, nil)
+	require.NoError(t, err)
+	client := &probeClient.ChainClient{Codec: codec}
+	IncludeDescriptorSets(client, registry)
+
+	sender := sdkTypes.AccAddress("fixture_sender______")
+	msgDo := newMsgDo(t, registry, sender.String())
+	msgSend := bankTypes.NewMsgSend(sender, sender, sdkTypes.NewCoins(sdkTypes.NewInt64Coin("uatom", 1)))
+
+	// Round trip a tx through the codec, the way the indexer decodes the txes of a block
+	builder := client.Codec.TxConfig.NewTxBuilder()
+	require.NoError(t, builder.SetMsgs(msgDo, msgSend))
+	builder.SetMemo("descriptor sets")
+
+	raw, err := client.Codec.TxConfig.TxEncoder()(builder.GetTx())
+	require.NoError(t, err)
+
+	// The SDK decoder needs generated descriptors to check for unknown fields, so the indexer falls back to the in-app decoder
+	_, err = client.Codec.TxConfig.TxDecoder()(raw)
+	require.ErrorContains(t, err, "does not have a Descriptor() method")
+
+	decoded, err := core.InAppTxDecoder(client.Codec)(raw)
+	require.NoError(t, err)
+
+	msgs := decoded.GetMsgs()
+	require.Len(t, msgs, 2)
+
+	decodedMsgDo, ok := msgs[0].(*DynamicMsg)
+	require.True(t, ok, "loaded message types decode into a DynamicMsg")
+	require.True(t, proto.Equal(msgDo.msg, decodedMsgDo.msg))
+	require.Equal(t, msgDoTypeURL, sdkTypes.MsgTypeURL(decodedMsgDo))
+	require.Equal(t, []sdkTypes.AccAddress{sender}, decodedMsgDo.GetSigners())
+
+	// Compiled in message types keep decoding into their generated types
+	require.Equal(t, msgSend, msgs[1])
+
+	resolved, err := client.Codec.InterfaceRegistry.Resolve(msgDoTypeURL)
+	require.NoError(t, err)
+	require.IsType(t, &DynamicMsg{}, resolved)
+
+	_, err = client.Codec.InterfaceRegistry.Resolve("/fixture.v1.Unknown")
+	require.Error(t, err)
+}
@@ -0,0 +1,346 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+	probeClient "github.com/DefiantLabs/probe/client"
+	rpchttp "github.com/cometbft/cometbft/rpc/client/http"
+	libclient "github.com/cometbft/cometbft/rpc/jsonrpc/client"
+)
+
+// RPC endpoint selection strategies, selectable with probe.rpc-selection
+const (
+	SelectionRoundRobin   = "round-robin"
+	SelectionLeastLatency = "least-latency"
+)
+
+// Weight given to the latest request when updating the moving average latency of an endpoint
+const latencyDecay = 0.2
+
+// Wait before the first reattempt against the same endpoint, doubled for every further reattempt
+const endpointRetryWait = 250 * time.Millisecond
+
+var ErrNoEndpoints = errors.New("no RPC endpoints configured")
+
+// ErrRequestCancelled is returned by the pool when the context of a request is done before it succeeds.
+// It is not a failure of the endpoints or of the data requested, callers check for it before recording failures.
+var ErrRequestCancelled = errors.New("RPC request cancelled")
+
+// Endpoint wraps the clients for a single RPC node along with its tracked health
+type Endpoint struct {
+	Address     string
+	ChainClient *probeClient.ChainClient
+	URIClient   URIClient
+	Limiter     *RateLimiter
+
+	mu                  sync.Mutex
+	consecutiveFailures int64
+	unhealthyUntil      time.Time
+	averageLatency      time.Duration
+	latestHeight        int64
+	lagging             bool
+}
+
+// NewEndpoint creates an endpoint for the chain client, replacing its RPC client with one whose requests wait on the rate limiter.
+// The rate limiter is shared by every request made to the endpoint, a nil rate limiter does not limit requests.
+func NewEndpoint(chainClient *probeClient.ChainClient, limiter *RateLimiter) (*Endpoint, error) {
+	address := chainClient.Config.RPCAddr
+
+	httpClient, err := libclient.DefaultHTTPClient(address)
+	if err != nil {
+		return nil, err
+	}
+	httpClient.Timeout, _ = time.ParseDuration(chainClient.Config.Timeout)
+	httpClient = RateLimitedHTTPClient(httpClient, limiter)
+
+	rpcClient, err := rpchttp.NewWithClient(address, "/websocket", httpClient)
+	if err != nil {
+		return nil, err
+	}
+	chainClient.RPCClient = rpcClient
+
+	return &Endpoint{
+		Address:     address,
+		ChainClient: chainClient,
+		URIClient: URIClient{
+			Address: address,
+			Client:  RateLimitedHTTPClient(&http.Client{}, limiter),
+		},
+		Limiter: limiter,
+	}, nil
+}
+
+// available reports whether the endpoint is healthy and not lagging behind the other endpoints
+func (e *Endpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !e.lagging && !now.Before(e.unhealthyUntil)
+}
+
+func (e *Endpoint) latency() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.averageLatency
+}
+
+func (e *Endpoint) recordSuccess(latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.consecutiveFailures = 0
+	e.unhealthyUntil = time.Time{}
+	if e.averageLatency == 0 {
+		e.averageLatency = latency
+	} else {
+		e.averageLatency = time.Duration(latencyDecay*float64(latency) + (1-latencyDecay)*float64(e.averageLatency))
+	}
+}
+
+// recordFailure marks the endpoint as unhealthy for the cooldown period once it reaches the failure threshold, returning true if it was marked
+func (e *Endpoint) recordFailure(failureThreshold int64, cooldown time.Duration) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.consecutiveFailures++
+	if e.consecutiveFailures >= failureThreshold {
+		e.consecutiveFailures = 0
+		e.unhealthyUntil = time.Now().Add(cooldown)
+		return true
+	}
+	return false
+}
+
+type PoolOptions struct {
+	Selection           string
+	MaxLag              int64         // blocks an endpoint can be behind the highest endpoint before it is skipped, 0 disables lag checks
+	FailureThreshold    int64         // consecutive failures before an endpoint is marked unhealthy
+	UnhealthyCooldown   time.Duration // time an unhealthy endpoint is skipped for
+	EndpointRetryBudget int64         // attempts made against a single endpoint per request before failing over
+	RetryAttempts       int64         // full passes over the endpoints after the first, -1 for unlimited
+	RetryMaxWait        time.Duration // max backoff between passes over the endpoints
+}
+
+// Pool distributes RPC requests over a set of endpoints, failing over to the next endpoint when a request errors.
+// Endpoints that fail repeatedly or fall behind the other endpoints are skipped until they recover, unless no other endpoint is available.
+type Pool struct {
+	endpoints         []*Endpoint
+	options           PoolOptions
+	next              atomic.Uint64
+	endpointRetryWait time.Duration
+}
+
+func NewPool(endpoints []*Endpoint, options PoolOptions) (*Pool, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	if options.Selection == "" {
+		options.Selection = SelectionRoundRobin
+	}
+
+	if options.Selection != SelectionRoundRobin && options.Selection != SelectionLeastLatency {
+		return nil, fmt.Errorf("unknown RPC endpoint selection %s", options.Selection)
+	}
+
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = 1
+	}
+
+	if options.EndpointRetryBudget <= 0 {
+		options.EndpointRetryBudget = 1
+	}
+
+	if options.RetryMaxWait < 2*time.Second {
+		options.RetryMaxWait = 2 * time.Second
+	}
+
+	return &Pool{endpoints: endpoints, options: options, endpointRetryWait: endpointRetryWait}, nil
+}
+
+// Endpoints returns all endpoints in the pool in configuration order
+func (p *Pool) Endpoints() []*Endpoint {
+	return p.endpoints
+}
+
+// Primary returns the first configured endpoint, useful for codec access that does not depend on the node being used
+func (p *Pool) Primary() *Endpoint {
+	return p.endpoints[0]
+}
+
+// candidates returns the endpoints in the order they should be tried according to the selection strategy.
+// Unavailable endpoints are placed last so they are only used when every other endpoint has failed.
+func (p *Pool) candidates() []*Endpoint {
+	now := time.Now()
+	var available, unavailable []*Endpoint
+
+	start := int(p.next.Add(1)-1) % len(p.endpoints)
+	for i := range p.endpoints {
+		endpoint := p.endpoints[(start+i)%len(p.endpoints)]
+		if endpoint.available(now) {
+			available = append(available, endpoint)
+		} else {
+			unavailable = append(unavailable, endpoint)
+		}
+	}
+
+	if p.options.Selection == SelectionLeastLatency {
+		sort.SliceStable(available, func(i, j int) bool { return available[i].latency() < available[j].latency() })
+	}
+
+	return append(available, unavailable...)
+}
+
+// Do runs the request against the pool endpoints until it succeeds. Each endpoint is attempted up to the endpoint retry budget,
+// backing off between attempts, before failing over to the next endpoint. When every endpoint has failed, the pass is repeated
+// with incremental backoff according to the retry attempts option. No further attempts are made once the context is cancelled,
+// the returned error then wraps ErrRequestCancelled, the context error and the last request error.
+func (p *Pool) Do(ctx context.Context, request func(*Endpoint) error) error {
+	return p.do(ctx, p.options.RetryAttempts, request)
+}
+
+// Failover runs the request against the pool endpoints until one succeeds, making a single pass over the endpoints without backoff.
+// This is used for requests the caller has its own fallback for.
+func (p *Pool) Failover(ctx context.Context, request func(*Endpoint) error) error {
+	return p.do(ctx, 0, request)
+}
+
+func (p *Pool) do(ctx context.Context, retryAttempts int64, request func(*Endpoint) error) error {
+	var passes int64
+	var err error
+
+	for {
+		for _, endpoint := range p.candidates() {
+			for attempt := int64(0); attempt < p.options.EndpointRetryBudget; attempt++ {
+				if attempt > 0 {
+					if waitErr := wait(ctx, p.endpointRetryWait<<(attempt-1)); waitErr != nil {
+						return cancelled(waitErr, err)
+					}
+				}
+
+				if ctx.Err() != nil {
+					return cancelled(ctx.Err(), err)
+				}
+
+				start := time.Now()
+				err = request(endpoint)
+				if err == nil {
+					endpoint.recordSuccess(time.Since(start))
+					return nil
+				}
+
+				// A request interrupted by the shutdown is not held against the endpoint
+				if ctx.Err() != nil {
+					return cancelled(ctx.Err(), err)
+				}
+
+				if endpoint.recordFailure(p.options.FailureThreshold, p.options.UnhealthyCooldown) {
+					config.LogCtx(ctx).Warnf("RPC endpoint %s marked unhealthy for %s. Err: %v", endpoint.Address, p.options.UnhealthyCooldown, err)
+					break
+				}
+			}
+
+			config.LogCtx(ctx).Debugf("RPC request to endpoint %s failed, failing over to next endpoint. Err: %v", endpoint.Address, err)
+		}
+
+		passes++
+		if retryAttempts >= 0 && passes > retryAttempts {
+			return err
+		}
+
+		backoffDuration, _ := GetBackoffDurationForAttempts(passes-1, p.options.RetryMaxWait)
+		config.LogCtx(ctx).Error("Error getting RPC response from all endpoints, backing off and trying again", err)
+		config.LogCtx(ctx).Debugf("Attempt %d with wait time %+v", passes, backoffDuration)
+
+		if waitErr := wait(ctx, backoffDuration); waitErr != nil {
+			return cancelled(waitErr, err)
+		}
+	}
+}
+
+// cancelled wraps the context error and the last request error, if any, in ErrRequestCancelled
+func cancelled(ctxErr error, requestErr error) error {
+	return fmt.Errorf("%w: %w", ErrRequestCancelled, errors.Join(ctxErr, requestErr))
+}
+
+// wait waits for the duration, returning the context error if the context is cancelled first
+func wait(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// LatestBlockHeight returns the latest block height from the first endpoint that responds
+func (p *Pool) LatestBlockHeight(ctx context.Context) (int64, error) {
+	var height int64
+	err := p.Do(ctx, func(endpoint *Endpoint) error {
+		var err error
+		height, err = GetLatestBlockHeight(endpoint.ChainClient)
+		return err
+	})
+	return height, err
+}
+
+// MonitorHeights periodically queries the latest block height of every endpoint until the context is cancelled.
+// Endpoints more than the max lag behind the highest endpoint are skipped during selection until they catch up.
+func (p *Pool) MonitorHeights(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.checkHeights(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) checkHeights(ctx context.Context) {
+	var highest int64
+	for _, endpoint := range p.endpoints {
+		start := time.Now()
+		height, err := GetLatestBlockHeight(endpoint.ChainClient)
+		if err != nil {
+			config.LogCtx(ctx).Debugf("Error getting latest block height from RPC endpoint %s. Err: %v", endpoint.Address, err)
+			endpoint.recordFailure(p.options.FailureThreshold, p.options.UnhealthyCooldown)
+			continue
+		}
+
+		endpoint.recordSuccess(time.Since(start))
+		endpoint.mu.Lock()
+		endpoint.latestHeight = height
+		endpoint.mu.Unlock()
+
+		if height > highest {
+			highest = height
+		}
+	}
+
+	if p.options.MaxLag <= 0 {
+		return
+	}
+
+	for _, endpoint := range p.endpoints {
+		endpoint.mu.Lock()
+		lagging := endpoint.latestHeight != 0 && highest-endpoint.latestHeight > p.options.MaxLag
+		if lagging && !endpoint.lagging {
+			config.LogCtx(ctx).Warnf("RPC endpoint %s is %d blocks behind, skipping until it catches up", endpoint.Address, highest-endpoint.latestHeight)
+		}
+		endpoint.lagging = lagging
+		endpoint.mu.Unlock()
+	}
+}
@@ -0,0 +1,191 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PoolTestSuite struct {
+	suite.Suite
+}
+
+func (suite *PoolTestSuite) newPool(options PoolOptions, addresses ...string) *Pool {
+	var endpoints []*Endpoint
+	for _, address := range addresses {
+		endpoints = append(endpoints, &Endpoint{Address: address})
+	}
+
+	pool, err := NewPool(endpoints, options)
+	suite.Require().NoError(err)
+	pool.endpointRetryWait = time.Millisecond
+	return pool
+}
+
+func candidateAddresses(pool *Pool) []string {
+	var addresses []string
+	for _, endpoint := range pool.candidates() {
+		addresses = append(addresses, endpoint.Address)
+	}
+	return addresses
+}
+
+func (suite *PoolTestSuite) TestNewPoolValidation() {
+	_, err := NewPool(nil, PoolOptions{})
+	suite.Require().ErrorIs(err, ErrNoEndpoints)
+
+	_, err = NewPool([]*Endpoint{{Address: "a"}}, PoolOptions{Selection: "random"})
+	suite.Require().Error(err)
+}
+
+func (suite *PoolTestSuite) TestCandidatesRoundRobin() {
+	pool := suite.newPool(PoolOptions{Selection: SelectionRoundRobin}, "a", "b", "c")
+
+	suite.Require().Equal([]string{"a", "b", "c"}, candidateAddresses(pool))
+	suite.Require().Equal([]string{"b", "c", "a"}, candidateAddresses(pool))
+	suite.Require().Equal([]string{"c", "a", "b"}, candidateAddresses(pool))
+	suite.Require().Equal([]string{"a", "b", "c"}, candidateAddresses(pool))
+}
+
+func (suite *PoolTestSuite) TestCandidatesLeastLatency() {
+	pool := suite.newPool(PoolOptions{Selection: SelectionLeastLatency}, "a", "b", "c")
+	pool.endpoints[0].averageLatency = 30 * time.Millisecond
+	pool.endpoints[1].averageLatency = 10 * time.Millisecond
+	pool.endpoints[2].averageLatency = 20 * time.Millisecond
+
+	// The order does not depend on the round-robin start
+	suite.Require().Equal([]string{"b", "c", "a"}, candidateAddresses(pool))
+	suite.Require().Equal([]string{"b", "c", "a"}, candidateAddresses(pool))
+}
+
+func (suite *PoolTestSuite) TestCandidatesUnavailableLast() {
+	pool := suite.newPool(PoolOptions{Selection: SelectionLeastLatency}, "a", "b", "c", "d")
+	pool.endpoints[0].averageLatency = 10 * time.Millisecond
+	pool.endpoints[0].unhealthyUntil = time.Now().Add(time.Hour)
+	pool.endpoints[1].averageLatency = 40 * time.Millisecond
+	pool.endpoints[2].averageLatency = 20 * time.Millisecond
+	pool.endpoints[2].lagging = true
+	pool.endpoints[3].averageLatency = 30 * time.Millisecond
+
+	suite.Require().Equal([]string{"d", "b", "a", "c"}, candidateAddresses(pool))
+
+	// Endpoints become available again once the cooldown has passed
+	pool.endpoints[0].unhealthyUntil = time.Now().Add(-time.Second)
+	suite.Require().Equal("a", candidateAddresses(pool)[0])
+}
+
+func (suite *PoolTestSuite) TestDoFailover() {
+	pool := suite.newPool(PoolOptions{FailureThreshold: 10, EndpointRetryBudget: 2}, "a", "b")
+
+	var attempts []string
+	err := pool.Do(context.Background(), func(endpoint *Endpoint) error {
+		attempts = append(attempts, endpoint.Address)
+		if endpoint.Address == "a" {
+			return errors.New("request failed")
+		}
+		return nil
+	})
+
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"a", "a", "b"}, attempts)
+	suite.Require().Equal(int64(2), pool.endpoints[0].consecutiveFailures)
+	suite.Require().Equal(int64(0), pool.endpoints[1].consecutiveFailures)
+}
+
+func (suite *PoolTestSuite) TestDoMarksUnhealthy() {
+	pool := suite.newPool(PoolOptions{FailureThreshold: 1, EndpointRetryBudget: 3, UnhealthyCooldown: time.Hour}, "a", "b")
+
+	var attempts []string
+	err := pool.Do(context.Background(), func(endpoint *Endpoint) error {
+		attempts = append(attempts, endpoint.Address)
+		if endpoint.Address == "a" {
+			return errors.New("request failed")
+		}
+		return nil
+	})
+
+	// Reaching the failure threshold fails over without using the rest of the retry budget
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{"a", "b"}, attempts)
+	suite.Require().False(pool.endpoints[0].available(time.Now()))
+
+	// The unhealthy endpoint is tried last
+	suite.Require().Equal([]string{"b", "a"}, candidateAddresses(pool))
+}
+
+func (suite *PoolTestSuite) TestFailoverSinglePass() {
+	pool := suite.newPool(PoolOptions{FailureThreshold: 10, RetryAttempts: -1}, "a", "b")
+	requestErr := errors.New("request failed")
+
+	attempts := 0
+	err := pool.Failover(context.Background(), func(endpoint *Endpoint) error {
+		attempts++
+		return requestErr
+	})
+
+	suite.Require().ErrorIs(err, requestErr)
+	suite.Require().Equal(2, attempts)
+}
+
+func (suite *PoolTestSuite) TestDoStopsOnCancel() {
+	pool := suite.newPool(PoolOptions{FailureThreshold: 10, EndpointRetryBudget: 3, RetryAttempts: -1}, "a", "b")
+	requestErr := errors.New("request failed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	attempts := 0
+	err := pool.Do(ctx, func(endpoint *Endpoint) error {
+		attempts++
+		cancel()
+		return requestErr
+	})
+
+	// Neither the remaining retry budget nor the other endpoint are attempted
+	suite.Require().ErrorIs(err, ErrRequestCancelled)
+	suite.Require().ErrorIs(err, context.Canceled)
+	suite.Require().ErrorIs(err, requestErr)
+	suite.Require().Equal(1, attempts)
+
+	// The interrupted request is not counted against the endpoint
+	for _, endpoint := range pool.endpoints {
+		suite.Require().Zero(endpoint.consecutiveFailures)
+	}
+
+	attempts = 0
+	err = pool.Do(ctx, func(endpoint *Endpoint) error {
+		attempts++
+		return nil
+	})
+	suite.Require().ErrorIs(err, ErrRequestCancelled)
+	suite.Require().ErrorIs(err, context.Canceled)
+	suite.Require().Equal(0, attempts)
+}
+
+func (suite *PoolTestSuite) TestDoCancelledDuringBackoff() {
+	pool := suite.newPool(PoolOptions{FailureThreshold: 10, RetryAttempts: -1}, "a")
+	requestErr := errors.New("request failed")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	err := pool.Do(ctx, func(endpoint *Endpoint) error {
+		attempts++
+		return requestErr
+	})
+
+	// The failure before the backoff is still counted, the backoff is cut short by the context
+	suite.Require().ErrorIs(err, ErrRequestCancelled)
+	suite.Require().ErrorIs(err, context.DeadlineExceeded)
+	suite.Require().ErrorIs(err, requestErr)
+	suite.Require().Equal(1, attempts)
+	suite.Require().Equal(int64(1), pool.endpoints[0].consecutiveFailures)
+}
+
+func TestPool(t *testing.T) {
+	suite.Run(t, new(PoolTestSuite))
+}
@@ -0,0 +1,218 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DefiantLabs/cosmos-indexer/config"
+)
+
+const (
+	// Fraction of the configured rate the limiter can slow down to after repeated rate limit responses
+	minRateFraction = 0.1
+	// Fraction of the configured rate added back after each successful request while slowed down
+	rateRecoveryFraction = 0.05
+	// Pause applied when a rate limit response does not include a usable Retry-After header
+	defaultRetryAfter = time.Second
+	maxRetryAfter     = time.Minute
+)
+
+// RateLimiter is a token bucket limiting the rate of requests made to a single RPC endpoint.
+// It slows down when the node responds with HTTP 429 or 503, halving the request rate and pausing requests for the Retry-After duration,
+// then gradually recovers to the configured rate as requests succeed. A nil RateLimiter does not limit requests.
+type RateLimiter struct {
+	mu           sync.Mutex
+	baseRate     float64
+	rate         float64
+	burst        float64
+	tokens       float64
+	last         time.Time
+	pausedUntil  time.Time
+	nextPauseFor time.Duration
+}
+
+// NewRateLimiter returns a limiter allowing requestsPerSecond requests with bursts of up to burst requests.
+// A requestsPerSecond of 0 does not limit the request rate, but still pauses requests when the node responds with a rate limit.
+func NewRateLimiter(requestsPerSecond float64, burst int64) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		baseRate: requestsPerSecond,
+		rate:     requestsPerSecond,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until a request is allowed, returning the context error if the context is cancelled while waiting
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	for {
+		wait := l.reserve(time.Now())
+		if wait == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token if one is available, otherwise it returns how long to wait before trying again
+func (l *RateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// Slowdown pauses requests for the retry after duration, or an increasing default pause if it is 0, and halves the request rate
+func (l *RateLimiter) Slowdown(retryAfter time.Duration) {
+	if l == nil {
+		return
+	}
+
+	l.slowdown(time.Now(), retryAfter)
+}
+
+func (l *RateLimiter) slowdown(now time.Time, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if retryAfter <= 0 {
+		if l.nextPauseFor == 0 {
+			l.nextPauseFor = defaultRetryAfter
+		}
+		retryAfter = l.nextPauseFor
+		l.nextPauseFor *= 2
+		if l.nextPauseFor > maxRetryAfter {
+			l.nextPauseFor = maxRetryAfter
+		}
+	}
+
+	if until := now.Add(retryAfter); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+
+	if l.baseRate > 0 {
+		l.rate /= 2
+		if minRate := l.baseRate * minRateFraction; l.rate < minRate {
+			l.rate = minRate
+		}
+		// Start refilling once the pause ends, otherwise the pause itself refills the bucket and a full burst is sent to the node
+		l.tokens = 0
+		l.last = l.pausedUntil
+	}
+}
+
+// Recover moves the request rate back towards the configured rate after a successful request
+func (l *RateLimiter) Recover() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.nextPauseFor = 0
+	if l.rate < l.baseRate {
+		l.rate += l.baseRate * rateRecoveryFraction
+		if l.rate > l.baseRate {
+			l.rate = l.baseRate
+		}
+	}
+}
+
+// rateLimitedTransport applies the rate limiter to every HTTP request made to an RPC endpoint
+type rateLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *RateLimiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		config.Log.Warnf("RPC node %s responded with %s, slowing down requests", req.URL.Host, resp.Status)
+		t.limiter.Slowdown(retryAfter)
+	} else {
+		t.limiter.Recover()
+	}
+
+	return resp, nil
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date, returning 0 if it is missing or invalid
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		retryAfter = time.Until(date)
+	}
+
+	if retryAfter < 0 {
+		return 0
+	}
+	if retryAfter > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return retryAfter
+}
+
+// RateLimitedHTTPClient wraps the client transport so every request waits on the rate limiter
+func RateLimitedHTTPClient(client *http.Client, limiter *RateLimiter) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	limited := *client
+	limited.Transport = &rateLimitedTransport{base: base, limiter: limiter}
+	return &limited
+}
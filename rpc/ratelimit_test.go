@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimiterTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RateLimiterTestSuite) TestReserveBurstAndRefill() {
+	limiter := NewRateLimiter(10, 3)
+	start := limiter.last
+
+	for i := 0; i < 3; i++ {
+		suite.Require().Zero(limiter.reserve(start))
+	}
+	suite.Require().Equal(100*time.Millisecond, limiter.reserve(start))
+
+	suite.Require().Zero(limiter.reserve(start.Add(100 * time.Millisecond)))
+	suite.Require().Equal(100*time.Millisecond, limiter.reserve(start.Add(100*time.Millisecond)))
+
+	// The bucket does not fill past the burst
+	later := start.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		suite.Require().Zero(limiter.reserve(later))
+	}
+	suite.Require().NotZero(limiter.reserve(later))
+}
+
+func (suite *RateLimiterTestSuite) TestSlowdownPausesWithoutRefillingBurst() {
+	limiter := NewRateLimiter(10, 5)
+	start := limiter.last
+
+	limiter.slowdown(start, 2*time.Second)
+	suite.Require().Equal(5.0, limiter.rate)
+
+	// Requests wait for the pause to end
+	suite.Require().Equal(time.Second, limiter.reserve(start.Add(time.Second)))
+
+	// Tokens only refill at the halved rate from the end of the pause, the pause itself does not refill a full burst
+	pauseEnd := start.Add(2 * time.Second)
+	suite.Require().Equal(200*time.Millisecond, limiter.reserve(pauseEnd))
+	suite.Require().Zero(limiter.reserve(pauseEnd.Add(200 * time.Millisecond)))
+	suite.Require().Equal(200*time.Millisecond, limiter.reserve(pauseEnd.Add(200*time.Millisecond)))
+}
+
+func (suite *RateLimiterTestSuite) TestSlowdownDefaultPause() {
+	limiter := NewRateLimiter(10, 1)
+	start := limiter.last
+
+	limiter.slowdown(start, 0)
+	suite.Require().Equal(start.Add(defaultRetryAfter), limiter.pausedUntil)
+
+	limiter.slowdown(start, 0)
+	suite.Require().Equal(start.Add(2*defaultRetryAfter), limiter.pausedUntil)
+
+	// A shorter pause does not shorten the current pause
+	limiter.slowdown(start, time.Millisecond)
+	suite.Require().Equal(start.Add(2*defaultRetryAfter), limiter.pausedUntil)
+
+	for i := 0; i < 10; i++ {
+		limiter.slowdown(start, 0)
+	}
+	suite.Require().Equal(start.Add(maxRetryAfter), limiter.pausedUntil)
+	suite.Require().Equal(10*minRateFraction, limiter.rate)
+}
+
+func (suite *RateLimiterTestSuite) TestRecover() {
+	limiter := NewRateLimiter(10, 1)
+	start := limiter.last
+
+	limiter.slowdown(start, 0)
+	limiter.slowdown(start, 0)
+	suite.Require().Equal(2.5, limiter.rate)
+
+	limiter.Recover()
+	suite.Require().Equal(3.0, limiter.rate)
+	suite.Require().Zero(limiter.nextPauseFor)
+
+	for i := 0; i < 100; i++ {
+		limiter.Recover()
+	}
+	suite.Require().Equal(10.0, limiter.rate)
+}
+
+func (suite *RateLimiterTestSuite) TestUnlimited() {
+	limiter := NewRateLimiter(0, 1)
+	start := limiter.last
+
+	for i := 0; i < 100; i++ {
+		suite.Require().Zero(limiter.reserve(start))
+	}
+
+	// Rate limit responses still pause an unlimited limiter
+	limiter.slowdown(start, time.Second)
+	suite.Require().Equal(time.Second, limiter.reserve(start))
+	suite.Require().Zero(limiter.reserve(start.Add(time.Second)))
+
+	var nilLimiter *RateLimiter
+	suite.Require().NoError(nilLimiter.Wait(context.Background()))
+	nilLimiter.Slowdown(time.Second)
+	nilLimiter.Recover()
+}
+
+func (suite *RateLimiterTestSuite) TestWaitCancelled() {
+	limiter := NewRateLimiter(10, 1)
+	limiter.Slowdown(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	suite.Require().ErrorIs(limiter.Wait(ctx), context.Canceled)
+}
+
+func (suite *RateLimiterTestSuite) TestParseRetryAfter() {
+	suite.Require().Zero(parseRetryAfter(""))
+	suite.Require().Zero(parseRetryAfter("invalid"))
+	suite.Require().Zero(parseRetryAfter("-5"))
+	suite.Require().Equal(3*time.Second, parseRetryAfter("3"))
+	suite.Require().Equal(maxRetryAfter, parseRetryAfter("3600"))
+}
+
+func TestRateLimiter(t *testing.T) {
+	suite.Run(t, new(RateLimiterTestSuite))
+}
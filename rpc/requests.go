@@ -10,6 +10,8 @@ import (
 	probeQuery "github.com/DefiantLabs/probe/query"
 	"github.com/cosmos/cosmos-sdk/types/query"
 	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	transferTypes "github.com/cosmos/ibc-go/v7/modules/apps/transfer/types"
 )
 
 // GetBlockTimestamp
@@ -127,3 +129,29 @@ func GetEarliestAndLatestBlockHeights(cl *probeClient.ChainClient) (int64, int64
 	}
 	return resStatus.SyncInfo.EarliestBlockHeight, resStatus.SyncInfo.LatestBlockHeight, nil
 }
+
+// GetDenomTrace returns the IBC transfer path and base denom of an IBC denom hash
+func GetDenomTrace(cl *probeClient.ChainClient, hash string) (*transferTypes.DenomTrace, error) {
+	query := probeQuery.Query{Client: cl, Options: &probeQuery.QueryOptions{}}
+	ctx, cancel := query.GetQueryContext()
+	defer cancel()
+
+	resp, err := transferTypes.NewQueryClient(cl).DenomTrace(ctx, &transferTypes.QueryDenomTraceRequest{Hash: hash})
+	if err != nil {
+		return nil, err
+	}
+	return resp.DenomTrace, nil
+}
+
+// GetDenomMetadata returns the bank denom metadata of a denom
+func GetDenomMetadata(cl *probeClient.ChainClient, denom string) (*bankTypes.Metadata, error) {
+	query := probeQuery.Query{Client: cl, Options: &probeQuery.QueryOptions{}}
+	ctx, cancel := query.GetQueryContext()
+	defer cancel()
+
+	resp, err := bankTypes.NewQueryClient(cl).DenomMetadata(ctx, &bankTypes.QueryDenomMetadataRequest{Denom: denom})
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Metadata, nil
+}